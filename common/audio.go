@@ -345,3 +345,35 @@ func getAACDuration(r io.ReadSeeker) (float64, error) {
 	duration := float64(totalSamples) / float64(sampleRate)
 	return duration, nil
 }
+
+// GetPCMDuration 根据裸 PCM 数据大小计算时长（秒），PCM 没有文件头，需要调用方给出采样参数。
+func GetPCMDuration(size int, sampleRate int, channels int, bitsPerSample int) float64 {
+	bytesPerSecond := sampleRate * channels * bitsPerSample / 8
+	if size <= 0 || bytesPerSecond <= 0 {
+		return 0
+	}
+	return float64(size) / float64(bytesPerSecond)
+}
+
+// PCMToWAV 为小端序裸 PCM 数据加上 44 字节的 WAV 文件头。
+func PCMToWAV(pcm []byte, sampleRate int, channels int, bitsPerSample int) []byte {
+	blockAlign := channels * bitsPerSample / 8
+	byteRate := sampleRate * blockAlign
+
+	out := make([]byte, 44+len(pcm))
+	copy(out[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:8], uint32(36+len(pcm)))
+	copy(out[8:12], "WAVE")
+	copy(out[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(out[16:20], 16)
+	binary.LittleEndian.PutUint16(out[20:22], 1) // PCM
+	binary.LittleEndian.PutUint16(out[22:24], uint16(channels))
+	binary.LittleEndian.PutUint32(out[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(out[28:32], uint32(byteRate))
+	binary.LittleEndian.PutUint16(out[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(out[34:36], uint16(bitsPerSample))
+	copy(out[36:40], "data")
+	binary.LittleEndian.PutUint32(out[40:44], uint32(len(pcm)))
+	copy(out[44:], pcm)
+	return out
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 统一的上传文件目录名（与请求体缓存目录分开，避免被缓存清理任务删除）
+const fileStoreDir = "new-api-files"
+
+// GetFileStoreDir 获取 Files API 本地存储目录
+// 上传的文件需要长期保存，basePath 为空时使用工作目录（与 SQLite 数据库一致），不能放在会被清理的临时目录
+func GetFileStoreDir(basePath string) string {
+	return filepath.Join(basePath, fileStoreDir)
+}
+
+// EnsureFileStoreDir 确保文件存储目录存在
+func EnsureFileStoreDir(basePath string) error {
+	return os.MkdirAll(GetFileStoreDir(basePath), 0755)
+}
+
+// GetFileStorePath 根据存储 key 计算文件路径
+// key 只允许由字母、数字、下划线和短横线组成，防止路径穿越
+func GetFileStorePath(basePath string, key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\.`) {
+		return "", fmt.Errorf("invalid file store key: %q", key)
+	}
+	return filepath.Join(GetFileStoreDir(basePath), key), nil
+}
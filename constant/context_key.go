@@ -19,6 +19,12 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache"
+	ContextKeyTokenQuotaBudget       ContextKey = "token_quota_budget"
+	ContextKeyTokenRateLimit         ContextKey = "token_rate_limit"
+	ContextKeyTokenTPMLimit          ContextKey = "token_tpm_limit"
+	ContextKeyTokenSensitiveRuleSets ContextKey = "token_sensitive_rule_sets"
+	ContextKeyTokenOrganizationId    ContextKey = "token_organization_id"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -72,4 +78,39 @@ const (
 	// fallback in authHelper (finishAdminAudit) skips its record to avoid
 	// duplicate entries.
 	ContextKeyAuditLogged ContextKey = "audit_logged"
+
+	// ContextKeyBatchId marks a request executed by the /v1/batches runner;
+	// pricing applies the configured batch discount when it is set.
+	ContextKeyBatchId ContextKey = "batch_id"
+
+	// ContextKeyResponseCache stores the response cache state of the current
+	// request (*service.ResponseCacheState); ContextKeyResponseCacheHit marks a
+	// request answered from the response cache so the consume log records it.
+	ContextKeyResponseCache    ContextKey = "response_cache"
+	ContextKeyResponseCacheHit ContextKey = "response_cache_hit"
+
+	// ContextKeyTPMReservation stores the TPM reserved for the current request
+	// (*service.TPMReservation), reconciled with real usage at settlement.
+	ContextKeyTPMReservation ContextKey = "tpm_reservation"
+
+	// ContextKeyCompletionSensitive stores the completion sensitive word scanner
+	// of the current request (*service.CompletionSensitiveScanner).
+	ContextKeyCompletionSensitive ContextKey = "completion_sensitive"
+
+	// ContextKeyModerationDecision stores the external moderation decision of
+	// the current request (*service.ModerationDecision).
+	ContextKeyModerationDecision ContextKey = "moderation_decision"
+
+	// ContextKeySensitiveRuleHits stores the sensitive rule set hits of the
+	// current request ([]service.SensitiveRuleHit).
+	ContextKeySensitiveRuleHits ContextKey = "sensitive_rule_hits"
+
+	// ContextKeyPIIRedactor stores the placeholder mapping of the current
+	// upstream attempt (*service.PIIRedactor).
+	ContextKeyPIIRedactor ContextKey = "pii_redactor"
+
+	// ContextKeyAuthzScope stores the permission scope resolved by
+	// RequireScopedPermission (authz.PermissionScope) so the handler can narrow
+	// its query to the allowed user groups or channel tags.
+	ContextKeyAuthzScope ContextKey = "authz_scope"
 )
@@ -30,6 +30,11 @@ var auditContentTemplates = map[string]string{
 	"user.reset_passkey":    "Reset the user passkey",
 	"option.update":         "Updated system setting ${key}",
 
+	"authz.role_create":       "Created authorization role ${key}",
+	"authz.role_update":       "Updated authorization role ${key}",
+	"authz.role_delete":       "Deleted authorization role ${key}",
+	"authz.user_roles_update": "Updated authorization roles of user ${username} (ID: ${id})",
+
 	"channel.create":             "Created channel ${name} (type ${type}, count ${count})",
 	"channel.update":             "Updated channel ${name} (ID: ${id})",
 	"channel.delete":             "Deleted channel ${name} (ID: ${id})",
@@ -48,6 +53,9 @@ var auditContentTemplates = map[string]string{
 	"redemption.create":    "Created ${count} redemption codes named ${name} (${quota} each)",
 	"topup.epay_reconcile": "Ran EPay reconciliation (${mode}; scanned ${scanned}, completed ${completed}, failed ${failed})",
 
+	"organization.quota_adjust": "Adjusted quota of organization ${name} (ID: ${id}) by ${quota}",
+	"organization.delete":       "Deleted organization ${name} (ID: ${id})",
+
 	"subscription.plan_reset":      "Reset active subscriptions for plan ${plan_id}",
 	"subscription.user_plan_reset": "Reset active plan ${plan_id} subscriptions for user ${target_user_id}",
 }
@@ -2,7 +2,13 @@ package controller
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service/authz"
 
 	"github.com/gin-gonic/gin"
@@ -22,3 +28,223 @@ func GetPermissionCatalog(c *gin.Context) {
 		},
 	})
 }
+
+// GetAuthzRoles lists the built-in and custom roles with their grants.
+func GetAuthzRoles(c *gin.Context) {
+	common.ApiSuccess(c, authz.Roles())
+}
+
+// CreateAuthzRole creates a custom role. Operators can only grant permissions
+// they hold themselves.
+func CreateAuthzRole(c *gin.Context) {
+	var input authz.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if !authz.CanGrant(c.GetInt("id"), c.GetInt("role"), input.Grants) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
+	if err := authz.CreateRole(input); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_create", roleAuditParams(strings.TrimSpace(input.Key), input))
+	common.ApiSuccess(c, nil)
+}
+
+// UpdateAuthzRole replaces the metadata and grants of a custom role. Operators
+// can neither edit a role that holds permissions they lack nor grant such
+// permissions.
+func UpdateAuthzRole(c *gin.Context) {
+	roleKey := c.Param("key")
+	var input authz.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if !canManageAuthzRole(c, roleKey) {
+		return
+	}
+	if !authz.CanGrant(c.GetInt("id"), c.GetInt("role"), input.Grants) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
+	if err := authz.UpdateRole(roleKey, input); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_update", roleAuditParams(roleKey, input))
+	common.ApiSuccess(c, nil)
+}
+
+// DeleteAuthzRole deletes a custom role together with all its assignments.
+func DeleteAuthzRole(c *gin.Context) {
+	roleKey := c.Param("key")
+	if !canManageAuthzRole(c, roleKey) {
+		return
+	}
+	if err := authz.DeleteRole(roleKey); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_delete", map[string]interface{}{
+		"key": roleKey,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+type userAuthzRolesRequest struct {
+	Assignments []authz.RoleAssignment `json:"assignments"`
+}
+
+// GetUserAuthzRoles returns the roles explicitly assigned to a user. An empty
+// list means the user falls back to the role derived from the system role.
+func GetUserAuthzRoles(c *gin.Context) {
+	user, ok := authzTargetUser(c)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"assignments": authz.UserRoleAssignments(user.Id),
+	})
+}
+
+// UpdateUserAuthzRoles replaces the roles assigned to an administrator. Roles
+// only take effect for administrators; root always keeps every permission.
+func UpdateUserAuthzRoles(c *gin.Context) {
+	user, ok := authzTargetUser(c)
+	if !ok {
+		return
+	}
+	var req userAuthzRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if user.Role != common.RoleAdminUser {
+		common.ApiErrorMsg(c, "roles can only be assigned to administrators")
+		return
+	}
+	operatorID := c.GetInt("id")
+	operatorRole := c.GetInt("role")
+	if user.Id == operatorID {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
+	if operatorRole != common.RoleRootUser {
+		// Neither touch an administrator who holds permissions the operator
+		// lacks, nor hand out such permissions through a role.
+		if !authz.CanGrant(operatorID, operatorRole, authz.Capabilities(user.Id, user.Role)) {
+			common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+			return
+		}
+		for _, assignment := range req.Assignments {
+			if !authz.RoleAssignable(operatorID, operatorRole, assignment.Role) {
+				common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+				return
+			}
+		}
+	}
+	if err := authz.SetUserRoleAssignments(user.Id, req.Assignments); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	roles := make([]string, 0, len(req.Assignments))
+	for _, assignment := range req.Assignments {
+		roles = append(roles, assignment.Role)
+	}
+	recordManageAuditFor(c, user.Id, "authz.user_roles_update", map[string]interface{}{
+		"username":    user.Username,
+		"id":          user.Id,
+		"roles":       strings.Join(roles, ","),
+		"assignments": req.Assignments,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+func authzTargetUser(c *gin.Context) (*model.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return user, true
+}
+
+func canManageAuthzRole(c *gin.Context, roleKey string) bool {
+	if _, ok := authz.Role(roleKey); !ok {
+		common.ApiError(c, authz.ErrRoleNotFound)
+		return false
+	}
+	if !authz.RoleAssignable(c.GetInt("id"), c.GetInt("role"), roleKey) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return false
+	}
+	return true
+}
+
+func roleAuditParams(roleKey string, input authz.RoleInput) map[string]interface{} {
+	granted := make([]string, 0)
+	for _, permission := range authz.AllPermissions() {
+		if input.Grants[permission.Resource][permission.Action] {
+			granted = append(granted, permission.Resource+"."+permission.Action)
+		}
+	}
+	params := map[string]interface{}{
+		"key":    roleKey,
+		"name":   input.Name,
+		"grants": strings.Join(granted, ","),
+	}
+	if input.Enabled != nil {
+		params["enabled"] = *input.Enabled
+	}
+	return params
+}
+
+// requestPermissionScope returns the scope resolved by
+// middleware.RequireScopedPermission. Routes without it are unscoped.
+func requestPermissionScope(c *gin.Context) authz.PermissionScope {
+	if scope, ok := common.GetContextKeyType[authz.PermissionScope](c, constant.ContextKeyAuthzScope); ok {
+		return scope
+	}
+	return authz.PermissionScope{All: true}
+}
+
+// requireScopeAllows rejects the request when value (a user group or channel
+// tag) lies outside the operator's scope.
+func requireScopeAllows(c *gin.Context, value string) bool {
+	if requestPermissionScope(c).Allows(value) {
+		return true
+	}
+	common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+	return false
+}
+
+func requireChannelInScope(c *gin.Context, channelID int) bool {
+	if requestPermissionScope(c).All {
+		return true
+	}
+	channel, err := model.GetChannelById(channelID, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	return requireScopeAllows(c, channel.GetTag())
+}
+
+// scopedUserGroups returns the user groups the operator is limited to, or nil
+// when unscoped.
+func scopedUserGroups(c *gin.Context) []string {
+	scope := requestPermissionScope(c)
+	if scope.All {
+		return nil
+	}
+	return append([]string{}, scope.Values...)
+}
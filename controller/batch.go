@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBatch POST /v1/batches
+func CreateBatch(c *gin.Context) {
+	var request dto.OpenAIBatchCreateRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		respondOpenAIManageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest))
+		return
+	}
+	batch, apiErr := service.CreateBatch(c.Request.Context(), c.GetInt("id"), c.GetInt("token_id"), c.ClientIP(), request)
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, service.BatchToOpenAI(batch))
+}
+
+// ListBatches GET /v1/batches
+func ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	// 多取一条用于判断 has_more
+	batches, err := model.ListUserBatches(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		respondOpenAIManageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError))
+		return
+	}
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+	list := dto.OpenAIBatchList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIBatch, 0, len(batches)),
+		HasMore: hasMore,
+	}
+	for _, batch := range batches {
+		list.Data = append(list.Data, service.BatchToOpenAI(batch))
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// RetrieveBatch GET /v1/batches/:id
+func RetrieveBatch(c *gin.Context) {
+	batch, apiErr := service.GetOwnedBatch(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, service.BatchToOpenAI(batch))
+}
+
+// CancelBatch POST /v1/batches/:id/cancel
+func CancelBatch(c *gin.Context) {
+	batch, apiErr := service.CancelBatch(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, service.BatchToOpenAI(batch))
+}
+
+// batchRequestContextKey 通过 request context 把批处理执行信息传给内部 relay 引擎，
+// 不使用请求头，避免外部请求伪造批处理折扣。
+type batchRequestContextKey struct{}
+
+// batchExecution 批处理请求的执行信息，令牌与用户在执行前已解析并校验
+type batchExecution struct {
+	batchId string
+	token   *model.Token
+	user    *model.UserBase
+}
+
+var (
+	batchRelayEngineOnce sync.Once
+	batchRelayEngine     *gin.Engine
+)
+
+// getBatchRelayEngine 返回执行批处理请求的内部引擎：直接写入已解析的令牌与用户上下文，
+// 再经过与 /v1 路由相同的渠道选择和 relay 管线，计费、重试、日志行为与在线请求一致。
+func getBatchRelayEngine() *gin.Engine {
+	batchRelayEngineOnce.Do(func() {
+		engine := gin.New()
+		engine.Use(middleware.RequestId())
+		engine.Use(middleware.BodyStorageCleanup())
+		engine.Use(func(c *gin.Context) {
+			execution, ok := c.Request.Context().Value(batchRequestContextKey{}).(*batchExecution)
+			if !ok {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+			common.SetContextKey(c, constant.ContextKeyBatchId, execution.batchId)
+			if err := middleware.SetupContextForResolvedToken(c, execution.token, execution.user); err != nil {
+				respondOpenAIManageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeAccessDenied, http.StatusForbidden, types.ErrOptionWithSkipRetry()))
+				c.Abort()
+				return
+			}
+			c.Next()
+		})
+		engine.Use(middleware.I18n())
+		engine.Use(middleware.Distribute())
+		engine.POST("/v1/chat/completions", func(c *gin.Context) {
+			Relay(c, types.RelayFormatOpenAI)
+		})
+		engine.POST("/v1/completions", func(c *gin.Context) {
+			Relay(c, types.RelayFormatOpenAI)
+		})
+		engine.POST("/v1/moderations", func(c *gin.Context) {
+			Relay(c, types.RelayFormatOpenAI)
+		})
+		engine.POST("/v1/embeddings", func(c *gin.Context) {
+			Relay(c, types.RelayFormatEmbedding)
+		})
+		engine.POST("/v1/responses", func(c *gin.Context) {
+			Relay(c, types.RelayFormatOpenAIResponses)
+		})
+		batchRelayEngine = engine
+	})
+	return batchRelayEngine
+}
+
+// batchResponseWriter 收集内部执行的响应。批处理请求不支持流式输出，响应体完整保存到请求结果中
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBatchResponseWriter() *batchResponseWriter {
+	return &batchResponseWriter{header: make(http.Header)}
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *batchResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+func (w *batchResponseWriter) Flush() {}
+
+// resolveBatchToken 读取批处理创建者的令牌与用户，并按 TokenAuth 的规则校验仍然可用
+func resolveBatchToken(batch *model.Batch) (*batchExecution, error) {
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		return nil, err
+	}
+	if token, err = model.ValidateUserToken(token.Key); err != nil {
+		return nil, err
+	}
+	user, err := model.GetUserCache(token.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != common.UserStatusEnabled {
+		return nil, errors.New("the user has been disabled")
+	}
+	return &batchExecution{batchId: batch.BatchId, token: token, user: user}, nil
+}
+
+// executeBatchRequest 以批处理创建者已解析的令牌在内部引擎上执行一个请求
+func executeBatchRequest(ctx context.Context, batch *model.Batch, request *model.BatchRequest) {
+	execution, err := resolveBatchToken(batch)
+	if err != nil {
+		request.Status = model.BatchRequestStatusFailed
+		request.Error = service.NewBatchRequestError("invalid_token", fmt.Sprintf("the token used to create this batch is unavailable: %v", err))
+		return
+	}
+
+	reqCtx := context.WithValue(ctx, batchRequestContextKey{}, execution)
+	httpReq, err := http.NewRequestWithContext(reqCtx, http.MethodPost, request.Url, strings.NewReader(request.Body))
+	if err != nil {
+		request.Status = model.BatchRequestStatusFailed
+		request.Error = service.NewBatchRequestError("invalid_request", err.Error())
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if batch.ClientIp != "" {
+		httpReq.RemoteAddr = net.JoinHostPort(batch.ClientIp, "0")
+	}
+
+	writer := newBatchResponseWriter()
+	getBatchRelayEngine().ServeHTTP(writer, httpReq)
+	if ctx.Err() != nil {
+		return
+	}
+
+	// 未写出任何内容时与 HTTP 服务一致视为 200
+	writer.WriteHeader(http.StatusOK)
+	request.StatusCode = writer.status
+	request.RequestId = writer.header.Get(common.RequestIdKey)
+	request.Response = writer.body.String()
+	if writer.status >= http.StatusOK && writer.status < http.StatusMultipleChoices {
+		request.Status = model.BatchRequestStatusCompleted
+	} else {
+		request.Status = model.BatchRequestStatusFailed
+	}
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestExecuteBatchRequestUsesResolvedToken(t *testing.T) {
+	db := setupModelListControllerTestDB(t)
+	require.NoError(t, i18n.Init())
+	require.NoError(t, db.AutoMigrate(&model.Token{}))
+
+	user := &model.User{Id: 1, Username: "batch-user", Password: "password", Status: common.UserStatusEnabled, Group: "default", Quota: 1000}
+	require.NoError(t, db.Create(user).Error)
+	token := &model.Token{Id: 1, UserId: user.Id, Key: "batchtokenkey", Name: "batch", Status: common.TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}
+	require.NoError(t, db.Create(token).Error)
+
+	batch := &model.Batch{BatchId: "batch_exec", UserId: user.Id, TokenId: token.Id}
+	request := &model.BatchRequest{Url: "/v1/chat/completions", Body: `{"model":"missing-model","messages":[{"role":"user","content":"hi"}]}`}
+	executeBatchRequest(context.Background(), batch, request)
+
+	// 令牌已在执行前解析，请求直接进入渠道选择，而不是按密钥重新鉴权
+	assert.Equal(t, model.BatchRequestStatusFailed, request.Status)
+	assert.NotEqual(t, http.StatusUnauthorized, request.StatusCode)
+	assert.NotEmpty(t, request.RequestId)
+	assert.True(t, gjson.Get(request.Response, "error.message").Exists())
+
+	require.NoError(t, db.Model(token).Update("status", common.TokenStatusDisabled).Error)
+	request = &model.BatchRequest{Url: "/v1/chat/completions", Body: `{"model":"missing-model"}`}
+	executeBatchRequest(context.Background(), batch, request)
+	assert.Equal(t, model.BatchRequestStatusFailed, request.Status)
+	assert.Zero(t, request.StatusCode)
+	assert.Contains(t, request.Error, "invalid_token")
+}
@@ -839,6 +839,9 @@ func TestChannel(c *gin.Context) {
 			return
 		}
 	}
+	if !requireScopeAllows(c, channel.GetTag()) {
+		return
+	}
 	//defer func() {
 	//	if channel.ChannelInfo.IsMultiKey {
 	//		go func() { _ = channel.SaveChannelInfo() }()
@@ -449,6 +449,24 @@ func buildUserOAuthBindingsResponse(userId int) ([]UserOAuthBindingResponse, err
 
 	response := make([]UserOAuthBindingResponse, 0, len(bindings))
 	for _, binding := range bindings {
+		if binding.ProviderId == model.OAuthBindingProviderLDAP {
+			response = append(response, UserOAuthBindingResponse{
+				ProviderId:     binding.ProviderId,
+				ProviderName:   ldapProviderName,
+				ProviderSlug:   "ldap",
+				ProviderUserId: binding.ProviderUserId,
+			})
+			continue
+		}
+		if binding.ProviderId == model.OAuthBindingProviderSAML {
+			response = append(response, UserOAuthBindingResponse{
+				ProviderId:     binding.ProviderId,
+				ProviderName:   samlProviderName,
+				ProviderSlug:   "saml",
+				ProviderUserId: binding.ProviderUserId,
+			})
+			continue
+		}
 		provider, err := model.GetCustomOAuthProviderById(binding.ProviderId)
 		if err != nil {
 			continue
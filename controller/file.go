@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondOpenAIManageError 以 OpenAI 错误格式返回 files/batches 等管理类接口的错误
+func respondOpenAIManageError(c *gin.Context, apiErr *types.NewAPIError) {
+	if apiErr.StatusCode >= http.StatusInternalServerError {
+		logger.LogError(c, fmt.Sprintf("openai manage api error: %s", apiErr.Error()))
+	}
+	openAIError := apiErr.ToOpenAIError()
+	openAIError.Message = common.MessageWithRequestId(openAIError.Message, c.GetString(common.RequestIdKey))
+	c.JSON(apiErr.StatusCode, gin.H{
+		"error": openAIError,
+	})
+}
+
+// ListFiles GET /v1/files
+func ListFiles(c *gin.Context) {
+	if apiErr := service.CheckFilesAPIEnabled(); apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	// 多取一条用于判断 has_more
+	files, err := model.ListUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1)
+	if err != nil {
+		respondOpenAIManageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError))
+		return
+	}
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	list := dto.OpenAIFileList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIFile, 0, len(files)),
+		HasMore: hasMore,
+	}
+	for _, file := range files {
+		list.Data = append(list.Data, service.UserFileToOpenAI(file))
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// UploadFile POST /v1/files
+func UploadFile(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		respondOpenAIManageError(c, types.NewErrorWithStatusCode(fmt.Errorf("file is required: %w", err), types.ErrorCodeInvalidRequest, http.StatusBadRequest))
+		return
+	}
+	file, apiErr := service.CreateUserFile(c.Request.Context(), c.GetInt("id"), header, c.PostForm("purpose"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, service.UserFileToOpenAI(file))
+}
+
+// RetrieveFile GET /v1/files/:id
+func RetrieveFile(c *gin.Context) {
+	if apiErr := service.CheckFilesAPIEnabled(); apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	file, apiErr := service.GetOwnedUserFile(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, service.UserFileToOpenAI(file))
+}
+
+// DeleteFile DELETE /v1/files/:id
+func DeleteFile(c *gin.Context) {
+	file, apiErr := service.GetOwnedUserFile(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	if apiErr = service.DeleteUserFile(c.Request.Context(), file); apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
+
+// RetrieveFileContent GET /v1/files/:id/content
+func RetrieveFileContent(c *gin.Context) {
+	if apiErr := service.CheckFilesAPIEnabled(); apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	file, apiErr := service.GetOwnedUserFile(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	reader, apiErr := service.OpenUserFileContent(c.Request.Context(), file)
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	defer reader.Close()
+
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	c.Header("Content-Type", mimeType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Header("X-Content-Type-Options", "nosniff")
+	if !file.IsUpstream() && file.Bytes > 0 {
+		c.Header("Content-Length", strconv.FormatInt(file.Bytes, 10))
+	}
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write file content %s: %s", file.FileId, err.Error()))
+	}
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateFineTuningJob POST /v1/fine_tuning/jobs
+func CreateFineTuningJob(c *gin.Context) {
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		respondOpenAIManageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest))
+		return
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		respondOpenAIManageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest))
+		return
+	}
+	group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	// 该路由不经过 Distribute，令牌的模型限制在 service 中按请求的模型校验
+	var tokenModelLimit map[string]bool
+	if common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		tokenModelLimit = map[string]bool{}
+		if s, ok := common.GetContextKey(c, constant.ContextKeyTokenModelLimit); ok {
+			if limit, ok := s.(map[string]bool); ok {
+				tokenModelLimit = limit
+			}
+		}
+	}
+	job, apiErr := service.CreateFineTuningJob(c.Request.Context(), c.GetInt("id"), c.GetInt("token_id"), common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId), group, tokenModelLimit, body)
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, service.FineTuningJobToOpenAI(job))
+}
+
+// ListFineTuningJobs GET /v1/fine_tuning/jobs
+func ListFineTuningJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	// 多取一条用于判断 has_more
+	jobs, err := model.ListUserFineTuningJobs(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		respondOpenAIManageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError))
+		return
+	}
+	hasMore := len(jobs) > limit
+	if hasMore {
+		jobs = jobs[:limit]
+	}
+	data := make([]map[string]any, 0, len(jobs))
+	for _, job := range jobs {
+		data = append(data, service.FineTuningJobToOpenAI(job))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     data,
+		"has_more": hasMore,
+	})
+}
+
+// RetrieveFineTuningJob GET /v1/fine_tuning/jobs/:id
+func RetrieveFineTuningJob(c *gin.Context) {
+	job, apiErr := service.GetOwnedFineTuningJob(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	if !model.IsFineTuningJobFinished(job.Status) {
+		// 上游不可用时返回最近一次同步的状态
+		if apiErr := service.RefreshFineTuningJob(c.Request.Context(), job); apiErr != nil {
+			logger.LogWarn(c, fmt.Sprintf("refresh fine-tuning job %s failed: %s", job.JobId, apiErr.Error()))
+		}
+	}
+	c.JSON(http.StatusOK, service.FineTuningJobToOpenAI(job))
+}
+
+// CancelFineTuningJob POST /v1/fine_tuning/jobs/:id/cancel
+func CancelFineTuningJob(c *gin.Context) {
+	job, apiErr := service.GetOwnedFineTuningJob(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	if apiErr := service.CancelFineTuningJob(c.Request.Context(), job); apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, service.FineTuningJobToOpenAI(job))
+}
+
+// ListFineTuningJobEvents GET /v1/fine_tuning/jobs/:id/events
+func ListFineTuningJobEvents(c *gin.Context) {
+	proxyFineTuningJobSubresource(c, "events")
+}
+
+// ListFineTuningJobCheckpoints GET /v1/fine_tuning/jobs/:id/checkpoints
+func ListFineTuningJobCheckpoints(c *gin.Context) {
+	proxyFineTuningJobSubresource(c, "checkpoints")
+}
+
+func proxyFineTuningJobSubresource(c *gin.Context, subresource string) {
+	job, apiErr := service.GetOwnedFineTuningJob(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	resp, apiErr := service.ProxyFineTuningJobSubresource(c.Request.Context(), job, subresource, c.Request.URL.Query())
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	c.Header("Content-Type", "application/json")
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write fine-tuning job %s %s: %s", job.JobId, subresource, err.Error()))
+	}
+}
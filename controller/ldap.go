@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const ldapProviderName = "LDAP"
+
+var errLDAPUserNotProvisioned = errors.New("ldap user is not provisioned")
+
+type LDAPLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LDAPLogin 使用目录账号登录，首次登录时按设置自动创建用户
+func LDAPLogin(c *gin.Context) {
+	identity, ok := authenticateLDAPRequest(c)
+	if !ok {
+		return
+	}
+
+	user, err := findOrCreateLDAPUser(c, identity)
+	if err != nil {
+		if errors.Is(err, errLDAPUserNotProvisioned) {
+			common.ApiErrorI18n(c, i18n.MsgLDAPUserNotProvisioned)
+			return
+		}
+		if errors.Is(err, model.ErrEmailAlreadyTaken) {
+			common.ApiErrorI18n(c, i18n.MsgUserEmailAlreadyTaken)
+			return
+		}
+		switch err.(type) {
+		case *OAuthUserDeletedError:
+			common.ApiErrorI18n(c, i18n.MsgOAuthUserDeleted)
+		case *OAuthRegistrationDisabledError:
+			common.ApiErrorI18n(c, i18n.MsgUserRegisterDisabled)
+		case *OAuthEmailAlreadyTakenError:
+			common.ApiErrorI18n(c, i18n.MsgUserEmailAlreadyTaken)
+		default:
+			common.ApiError(c, err)
+		}
+		return
+	}
+	if user.Status != common.UserStatusEnabled {
+		common.ApiErrorI18n(c, i18n.MsgOAuthUserBanned)
+		return
+	}
+
+	syncLDAPUserGroup(user, identity)
+	loginWith2FACheck(c, user)
+}
+
+// LDAPBind 将目录账号绑定到当前登录用户，已有本地账号的用户之后即可通过 LDAP 登录
+func LDAPBind(c *gin.Context) {
+	identity, ok := authenticateLDAPRequest(c)
+	if !ok {
+		return
+	}
+
+	userId := c.GetInt("id")
+	providerUserId := identity.ProviderUserId()
+	if model.IsProviderUserIdTaken(model.OAuthBindingProviderLDAP, providerUserId) {
+		// 绑定记录可能指向其他用户或已注销的用户
+		bound, err := model.GetUserByOAuthBinding(model.OAuthBindingProviderLDAP, providerUserId)
+		if err != nil || bound.Id != userId {
+			common.ApiErrorI18n(c, i18n.MsgOAuthAlreadyBound, providerParams(ldapProviderName))
+			return
+		}
+	}
+	if err := model.UpdateUserOAuthBinding(userId, model.OAuthBindingProviderLDAP, providerUserId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	common.ApiSuccessI18n(c, i18n.MsgOAuthBindSuccess, gin.H{
+		"action": "bind",
+	})
+}
+
+// authenticateLDAPRequest 解析请求并完成目录认证，失败时已写入响应
+func authenticateLDAPRequest(c *gin.Context) (*service.LDAPIdentity, bool) {
+	if !system_setting.GetLDAPSettings().Enabled {
+		common.ApiErrorI18n(c, i18n.MsgOAuthNotEnabled, providerParams(ldapProviderName))
+		return nil, false
+	}
+	var req LDAPLoginRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil || req.Username == "" || req.Password == "" {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+
+	identity, err := service.AuthenticateLDAP(req.Username, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrLDAPInvalidCredentials):
+			common.ApiErrorI18n(c, i18n.MsgLDAPInvalidCredentials)
+		case errors.Is(err, service.ErrLDAPDisabled):
+			common.ApiErrorI18n(c, i18n.MsgOAuthNotEnabled, providerParams(ldapProviderName))
+		default:
+			common.SysError(fmt.Sprintf("[LDAP] authenticate %s failed: %v", req.Username, err))
+			common.ApiErrorI18n(c, i18n.MsgOAuthConnectFailed, providerParams(ldapProviderName))
+		}
+		return nil, false
+	}
+	return identity, true
+}
+
+// findOrCreateLDAPUser 先按绑定记录查找用户；同名本地账号不会被自动关联，需登录后主动绑定
+func findOrCreateLDAPUser(c *gin.Context, identity *service.LDAPIdentity) (*model.User, error) {
+	providerUserId := identity.ProviderUserId()
+	if model.IsProviderUserIdTaken(model.OAuthBindingProviderLDAP, providerUserId) {
+		user, err := model.GetUserByOAuthBinding(model.OAuthBindingProviderLDAP, providerUserId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, &OAuthUserDeletedError{}
+			}
+			return nil, err
+		}
+		return user, nil
+	}
+
+	if !system_setting.GetLDAPSettings().AutoProvision {
+		return nil, errLDAPUserNotProvisioned
+	}
+	if !common.RegisterEnabled {
+		return nil, &OAuthRegistrationDisabledError{}
+	}
+
+	user := &model.User{
+		Username:    "ldap_" + strconv.Itoa(model.GetMaxUserId()+1),
+		DisplayName: identity.DisplayName,
+		Role:        common.RoleCommonUser,
+		Status:      common.UserStatusEnabled,
+		Group:       ldapMappedGroup(identity),
+	}
+	if exists, err := model.CheckUserExistOrDeleted(identity.Username, ""); err == nil && !exists {
+		// 防止索引退化
+		if len(identity.Username) <= model.UserNameMaxLength {
+			user.Username = identity.Username
+		}
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = identity.Username
+	}
+	if identity.Email != "" {
+		user.Email = model.NormalizeEmail(identity.Email)
+		if err := model.EnsureEmailAvailable(user.Email, 0); err != nil {
+			if errors.Is(err, model.ErrEmailAlreadyTaken) {
+				return nil, &OAuthEmailAlreadyTakenError{}
+			}
+			return nil, err
+		}
+	}
+
+	inviterId := 0
+	if affCode, ok := sessions.Default(c).Get("aff").(string); ok && affCode != "" {
+		inviterId, _ = model.GetUserIdByAffCode(affCode)
+	}
+
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := user.InsertWithTx(tx, inviterId); err != nil {
+			return err
+		}
+		return model.CreateUserOAuthBindingWithTx(tx, &model.UserOAuthBinding{
+			UserId:         user.Id,
+			ProviderId:     model.OAuthBindingProviderLDAP,
+			ProviderUserId: providerUserId,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.FinalizeOAuthUserCreation(inviterId)
+	return user, nil
+}
+
+// syncLDAPUserGroup 每次登录按目录组映射同步分组，未命中映射时保持原分组
+func syncLDAPUserGroup(user *model.User, identity *service.LDAPIdentity) {
+	group := ldapMappedGroup(identity)
+	if group == "" || group == user.Group {
+		return
+	}
+	if err := model.UpdateUserGroup(user.Id, group); err != nil {
+		common.SysError(fmt.Sprintf("[LDAP] failed to sync group for user %d: %v", user.Id, err))
+		return
+	}
+	user.Group = group
+}
+
+func ldapMappedGroup(identity *service.LDAPIdentity) string {
+	if identity.Group == "" {
+		return ""
+	}
+	if !ratio_setting.ContainsGroupRatio(identity.Group) {
+		common.SysLog(fmt.Sprintf("[LDAP] mapped group %s does not exist, ignored", identity.Group))
+		return ""
+	}
+	return identity.Group
+}
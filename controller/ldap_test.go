@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/ldap/ldaptest"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupLDAPLoginTest(t *testing.T) (*gorm.DB, *gin.Engine) {
+	t.Helper()
+	db := setupModelListControllerTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.Log{}, &model.UserOAuthBinding{}, &model.TwoFA{}))
+
+	srv, err := ldaptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+	srv.AddEntry("cn=svc,dc=corp,dc=example", "svc-secret", nil)
+	srv.AddEntry("uid=carol,ou=people,dc=corp,dc=example", "carol-pass", map[string][]string{
+		"uid":      {"carol"},
+		"mail":     {"carol@corp.example"},
+		"cn":       {"Carol"},
+		"memberOf": {"cn=vip,ou=groups,dc=corp,dc=example"},
+	})
+
+	settings := system_setting.GetLDAPSettings()
+	original := *settings
+	originalRegister := common.RegisterEnabled
+	t.Cleanup(func() {
+		*settings = original
+		common.RegisterEnabled = originalRegister
+	})
+	*settings = system_setting.LDAPSettings{
+		Enabled:              true,
+		Url:                  srv.URL,
+		BindDN:               "cn=svc,dc=corp,dc=example",
+		BindPassword:         "svc-secret",
+		BaseDN:               "dc=corp,dc=example",
+		UserFilter:           "(uid={username})",
+		UsernameAttribute:    "uid",
+		EmailAttribute:       "mail",
+		DisplayNameAttribute: "cn",
+		GroupAttribute:       "memberOf",
+		GroupMapping:         map[string]string{"cn=vip,ou=groups,dc=corp,dc=example": "vip"},
+		AutoProvision:        true,
+		Timeout:              5,
+	}
+	common.RegisterEnabled = true
+
+	groupRatios := ratio_setting.GetGroupRatioCopy()
+	require.NoError(t, ratio_setting.UpdateGroupRatioByJSONString(`{"default":1,"vip":1}`))
+	t.Cleanup(func() {
+		data, _ := json.Marshal(groupRatios)
+		_ = ratio_setting.UpdateGroupRatioByJSONString(string(data))
+	})
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("ldap-test-session"))))
+	router.POST("/api/user/login/ldap", LDAPLogin)
+	return db, router
+}
+
+func postLDAP(t *testing.T, router *gin.Engine, path string, username string, password string) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(LDAPLoginRequest{Username: username, Password: password})
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestLDAPLoginProvisionsUser(t *testing.T) {
+	db, router := setupLDAPLoginTest(t)
+
+	resp := postLDAP(t, router, "/api/user/login/ldap", "carol", "carol-pass")
+	require.Equal(t, true, resp["success"], resp["message"])
+
+	var user model.User
+	require.NoError(t, db.Where("username = ?", "carol").First(&user).Error)
+	assert.Equal(t, "carol@corp.example", user.Email)
+	assert.Equal(t, "Carol", user.DisplayName)
+	assert.Equal(t, "vip", user.Group)
+
+	var binding model.UserOAuthBinding
+	require.NoError(t, db.Where("user_id = ?", user.Id).First(&binding).Error)
+	assert.Equal(t, model.OAuthBindingProviderLDAP, binding.ProviderId)
+	assert.Equal(t, "carol", binding.ProviderUserId)
+
+	// 再次登录复用同一用户，并按组映射恢复分组
+	require.NoError(t, db.Model(&model.User{}).Where("id = ?", user.Id).Update("group", "default").Error)
+	resp = postLDAP(t, router, "/api/user/login/ldap", "CAROL", "carol-pass")
+	require.Equal(t, true, resp["success"], resp["message"])
+	var count int64
+	db.Model(&model.User{}).Count(&count)
+	assert.EqualValues(t, 1, count)
+	require.NoError(t, db.First(&user, user.Id).Error)
+	assert.Equal(t, "vip", user.Group)
+}
+
+func TestLDAPLoginRejectsWrongPasswordAndRespectsAutoProvision(t *testing.T) {
+	db, router := setupLDAPLoginTest(t)
+
+	resp := postLDAP(t, router, "/api/user/login/ldap", "carol", "wrong")
+	assert.Equal(t, false, resp["success"])
+
+	system_setting.GetLDAPSettings().AutoProvision = false
+	resp = postLDAP(t, router, "/api/user/login/ldap", "carol", "carol-pass")
+	assert.Equal(t, false, resp["success"])
+
+	var count int64
+	db.Model(&model.User{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestLDAPLoginDoesNotTakeOverLocalUserWithSameName(t *testing.T) {
+	db, router := setupLDAPLoginTest(t)
+	local := &model.User{Username: "carol", Status: common.UserStatusEnabled, Group: "default", AffCode: "loc1"}
+	require.NoError(t, db.Create(local).Error)
+
+	resp := postLDAP(t, router, "/api/user/login/ldap", "carol", "carol-pass")
+	require.Equal(t, true, resp["success"], resp["message"])
+
+	data := resp["data"].(map[string]any)
+	assert.NotEqual(t, float64(local.Id), data["id"])
+	assert.NotEqual(t, "carol", data["username"])
+}
+
+func TestLDAPBindLinksExistingUser(t *testing.T) {
+	db, router := setupLDAPLoginTest(t)
+	local := &model.User{Username: "carol.local", Status: common.UserStatusEnabled, Group: "default", AffCode: "loc2"}
+	require.NoError(t, db.Create(local).Error)
+
+	bindRouter := gin.New()
+	bindRouter.Use(sessions.Sessions("session", cookie.NewStore([]byte("ldap-test-session"))))
+	bindRouter.POST("/api/user/ldap/bind", func(c *gin.Context) {
+		c.Set("id", local.Id)
+		LDAPBind(c)
+	})
+	resp := postLDAP(t, bindRouter, "/api/user/ldap/bind", "carol", "carol-pass")
+	require.Equal(t, true, resp["success"], resp["message"])
+
+	resp = postLDAP(t, router, "/api/user/login/ldap", "carol", "carol-pass")
+	require.Equal(t, true, resp["success"], resp["message"])
+	data := resp["data"].(map[string]any)
+	assert.Equal(t, float64(local.Id), data["id"])
+
+	var count int64
+	db.Model(&model.User{}).Count(&count)
+	assert.EqualValues(t, 1, count)
+}
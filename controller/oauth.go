@@ -10,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -156,6 +157,9 @@ func HandleOAuth(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgOAuthUserBanned)
 		return
 	}
+	if group, ok := oauthUser.Extra["group"].(string); ok {
+		syncOAuthUserGroup(user, group, provider.GetName())
+	}
 
 	// 9. Setup login
 	setupLogin(user, c)
@@ -207,9 +211,9 @@ func handleOAuthBind(c *gin.Context, provider oauth.Provider) {
 	}
 
 	// Handle binding based on provider type
-	if genericProvider, ok := provider.(*oauth.GenericOAuthProvider); ok {
-		// Custom provider: use user_oauth_bindings table
-		err = model.UpdateUserOAuthBinding(user.Id, genericProvider.GetProviderId(), oauthUser.ProviderUserID)
+	if bindingProvider, ok := provider.(oauth.BindingProvider); ok {
+		// Custom provider or SAML: use user_oauth_bindings table
+		err = model.UpdateUserOAuthBinding(user.Id, bindingProvider.GetProviderId(), oauthUser.ProviderUserID)
 		if err != nil {
 			common.ApiError(c, err)
 			return
@@ -310,8 +314,8 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 	}
 
 	// Use transaction to ensure user creation and OAuth binding are atomic
-	if genericProvider, ok := provider.(*oauth.GenericOAuthProvider); ok {
-		// Custom provider: create user and binding in a transaction
+	if bindingProvider, ok := provider.(oauth.BindingProvider); ok {
+		// Custom provider or SAML: create user and binding in a transaction
 		err := model.DB.Transaction(func(tx *gorm.DB) error {
 			// Create user
 			if err := user.InsertWithTx(tx, inviterId); err != nil {
@@ -321,7 +325,7 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 			// Create OAuth binding
 			binding := &model.UserOAuthBinding{
 				UserId:         user.Id,
-				ProviderId:     genericProvider.GetProviderId(),
+				ProviderId:     bindingProvider.GetProviderId(),
 				ProviderUserId: oauthUser.ProviderUserID,
 			}
 			if err := model.CreateUserOAuthBindingWithTx(tx, binding); err != nil {
@@ -370,6 +374,31 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 	return user, nil
 }
 
+// syncOAuthUserGroup 每次登录按提供方返回的分组（目前只有 SAML 的组映射）同步分组，未命中映射时保持原分组
+func syncOAuthUserGroup(user *model.User, group string, source string) {
+	group = validOAuthMappedGroup(group, source)
+	if group == "" || group == user.Group {
+		return
+	}
+	if err := model.UpdateUserGroup(user.Id, group); err != nil {
+		common.SysError(fmt.Sprintf("[%s] failed to sync group for user %d: %v", source, user.Id, err))
+		return
+	}
+	user.Group = group
+}
+
+// validOAuthMappedGroup 忽略映射到不存在分组的配置
+func validOAuthMappedGroup(group string, source string) string {
+	if group == "" {
+		return ""
+	}
+	if !ratio_setting.ContainsGroupRatio(group) {
+		common.SysLog(fmt.Sprintf("[%s] mapped group %s does not exist, ignored", source, group))
+		return ""
+	}
+	return group
+}
+
 // Error types for OAuth
 type OAuthUserDeletedError struct{}
 
@@ -3,12 +3,15 @@ package controller
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/authz"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/console_setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -33,6 +36,80 @@ func isPaymentComplianceOptionKey(key string) bool {
 	return strings.HasPrefix(key, "payment_setting.compliance_")
 }
 
+// pricingOptionKeys 模型计费与充值价格相关的配置项，修改需要定价权限而非设置权限
+var pricingOptionKeys = []string{
+	"ModelPrice",
+	"ModelRatio",
+	"CompletionRatio",
+	"CacheRatio",
+	"CreateCacheRatio",
+	"ImageRatio",
+	"AudioRatio",
+	"AudioCompletionRatio",
+	"GroupRatio",
+	"GroupGroupRatio",
+	"TopupGroupRatio",
+	"Price",
+	"USDExchangeRate",
+	"QuotaPerUnit",
+	"StripeUnitPrice",
+	"WaffoUnitPrice",
+	"WaffoPancakeUnitPrice",
+	"MinTopUp",
+	"payment_setting.amount_discount",
+}
+
+// rootOnlyOptionKeys 决定支付回调与收款去向的配置项，仅限 root 修改
+var rootOnlyOptionKeys = []string{
+	"PayAddress",
+	"EpayId",
+	"CustomCallbackAddress",
+}
+
+var pricingOptionPrefixes = []string{
+	"group_ratio_setting.",
+	"billing_setting.",
+	"tool_price_setting.",
+}
+
+func isPricingOptionKey(key string) bool {
+	if slices.Contains(pricingOptionKeys, key) {
+		return true
+	}
+	for _, prefix := range pricingOptionPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecretOptionKey 密钥类配置项，不在 GetOptions 中返回
+func isSecretOptionKey(key string) bool {
+	return strings.HasSuffix(key, "Token") ||
+		strings.HasSuffix(key, ".token") ||
+		strings.HasSuffix(key, "Secret") ||
+		strings.HasSuffix(key, "Key") ||
+		strings.HasSuffix(key, "secret") ||
+		strings.HasSuffix(key, "password") ||
+		strings.HasSuffix(key, "private_key") ||
+		strings.HasSuffix(key, "api_key")
+}
+
+// canUpdateOption 定价项需要定价权限，其余需要设置权限；密钥、支付合规与支付回调项仅限 root
+func canUpdateOption(c *gin.Context, key string) bool {
+	if c.GetInt("role") >= common.RoleRootUser {
+		return true
+	}
+	if isSecretOptionKey(key) || isPaymentComplianceOptionKey(key) || slices.Contains(rootOnlyOptionKeys, key) {
+		return false
+	}
+	if isPricingOptionKey(key) {
+		return authz.Can(c.GetInt("id"), c.GetInt("role"), authz.PricingWrite)
+	}
+	return authz.Can(c.GetInt("id"), c.GetInt("role"), authz.OptionWrite)
+}
+
 func isPositiveOptionValue(value string) bool {
 	intValue, err := strconv.Atoi(strings.TrimSpace(value))
 	if err == nil {
@@ -81,12 +158,7 @@ func GetOptions(c *gin.Context) {
 	common.OptionMapRWMutex.Lock()
 	for k, v := range common.OptionMap {
 		value := common.Interface2String(v)
-		isSensitiveKey := strings.HasSuffix(k, "Token") ||
-			strings.HasSuffix(k, "Secret") ||
-			strings.HasSuffix(k, "Key") ||
-			strings.HasSuffix(k, "secret") ||
-			strings.HasSuffix(k, "api_key")
-		if isSensitiveKey {
+		if isSecretOptionKey(k) {
 			continue
 		}
 		options = append(options, &model.Option{
@@ -137,6 +209,10 @@ func UpdateOption(c *gin.Context) {
 	default:
 		option.Value = fmt.Sprintf("%v", option.Value)
 	}
+	if !canUpdateOption(c, option.Key) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
 	switch option.Key {
 	case "QuotaForInviter", "QuotaForInvitee":
 		if isPositiveOptionValue(option.Value.(string)) && !operation_setting.IsPaymentComplianceConfirmed() {
@@ -174,6 +250,33 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "ldap.enabled":
+		ldapSettings := system_setting.GetLDAPSettings()
+		if option.Value == "true" && (ldapSettings.Url == "" || ldapSettings.BaseDN == "") {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 LDAP 登录，请先填入 LDAP 服务器地址以及 Base DN！",
+			})
+			return
+		}
+	case "saml.enabled":
+		samlSettings := system_setting.GetSAMLSettings()
+		if option.Value == "true" && (samlSettings.IdPEntityId == "" || samlSettings.IdPSSOUrl == "" || samlSettings.IdPCertificate == "") {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 SAML 登录，请先填入 IdP Entity ID、IdP 登录地址以及 IdP 签名证书！",
+			})
+			return
+		}
+		if option.Value == "true" {
+			if _, err := service.GetSAMLServiceProvider(); err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "无法启用 SAML 登录，证书或私钥格式错误：" + err.Error(),
+				})
+				return
+			}
+		}
 	case "LinuxDOOAuthEnabled":
 		if option.Value == "true" && common.LinuxDOClientId == "" {
 			c.JSON(http.StatusOK, gin.H{
@@ -339,6 +442,15 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "sensitive_rule_setting.rule_sets":
+		err = operation_setting.ValidateSensitiveRuleSets(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSecretOptionKey(t *testing.T) {
+	for _, key := range []string{
+		"GitHubClientSecret",
+		"TelegramBotToken",
+		"metrics_setting.token",
+		"ldap.bind_password",
+		"saml.sp_private_key",
+	} {
+		assert.True(t, isSecretOptionKey(key), key)
+	}
+	for _, key := range []string{
+		"metrics_setting.enabled",
+		"metrics_setting.allowed_ips",
+		"ServerAddress",
+	} {
+		assert.False(t, isSecretOptionKey(key), key)
+	}
+}
+
+func TestIsPricingOptionKey(t *testing.T) {
+	for _, key := range []string{
+		"ModelRatio",
+		"Price",
+		"QuotaPerUnit",
+		"WaffoPancakeUnitPrice",
+		"payment_setting.amount_discount",
+		"group_ratio_setting.group_special_usable_group",
+	} {
+		assert.True(t, isPricingOptionKey(key), key)
+	}
+	for _, key := range []string{
+		"PayAddress",
+		"ServerAddress",
+	} {
+		assert.False(t, isPricingOptionKey(key), key)
+	}
+}
@@ -0,0 +1,425 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service/authz"
+
+	"github.com/gin-gonic/gin"
+)
+
+type organizationRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Status      int    `json:"status"`
+}
+
+type organizationMemberRequest struct {
+	UserId            int    `json:"user_id"`
+	Username          string `json:"username"`
+	Role              string `json:"role"`
+	MonthlyQuotaLimit int    `json:"monthly_quota_limit"`
+}
+
+type organizationQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+// organizationAccess 解析路径中的组织，并返回调用者在该组织中的成员身份。
+// 持有平台组织权限的管理员即使不是成员也可以访问，此时 member 为 nil。
+func organizationAccess(c *gin.Context, platformPermission authz.Permission) (*model.Organization, *model.OrganizationMember, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, nil, false
+	}
+	org, err := model.GetOrganizationById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	member, err := model.GetOrganizationMember(org.Id, c.GetInt("id"))
+	if err != nil && !errors.Is(err, model.ErrOrganizationMemberNotFound) {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	if member == nil && !authz.Can(c.GetInt("id"), c.GetInt("role"), platformPermission) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+// organizationManageAccess 要求调用者是组织所有者/管理员，或持有平台组织权限
+func organizationManageAccess(c *gin.Context, platformPermission authz.Permission) (*model.Organization, *model.OrganizationMember, bool) {
+	org, member, ok := organizationAccess(c, platformPermission)
+	if !ok {
+		return nil, nil, false
+	}
+	if member != nil && !member.CanManage() && !authz.Can(c.GetInt("id"), c.GetInt("role"), platformPermission) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+// isOrganizationOwner 所有者或持有平台组织编辑权限的管理员
+func isOrganizationOwner(c *gin.Context, member *model.OrganizationMember) bool {
+	if member != nil && member.Role == model.OrganizationRoleOwner {
+		return true
+	}
+	return authz.Can(c.GetInt("id"), c.GetInt("role"), authz.OrganizationWrite)
+}
+
+// GetSelfOrganizations 查询当前用户加入的组织
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(c *gin.Context) {
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "organization name is too long")
+		return
+	}
+	org := &model.Organization{
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerId:     c.GetInt("id"),
+	}
+	if err := model.CreateOrganization(org); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// GetOrganization 查询组织详情及调用者的成员信息
+func GetOrganization(c *gin.Context) {
+	org, member, ok := organizationAccess(c, authz.OrganizationRead)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"organization": org,
+		"member":       member,
+	})
+}
+
+// UpdateOrganization 修改组织名称、描述和状态
+func UpdateOrganization(c *gin.Context) {
+	org, _, ok := organizationManageAccess(c, authz.OrganizationWrite)
+	if !ok {
+		return
+	}
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "organization name is too long")
+		return
+	}
+	// 启用/禁用组织只允许持有平台组织编辑权限的管理员操作，组织管理员只能修改资料
+	if req.Status != 0 && req.Status != org.Status {
+		if !authz.Can(c.GetInt("id"), c.GetInt("role"), authz.OrganizationWrite) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": i18n.T(c, i18n.MsgAuthInsufficientPrivilege),
+			})
+			return
+		}
+		org.Status = req.Status
+	}
+	org.Name = req.Name
+	org.Description = req.Description
+	if err := model.UpdateOrganization(org); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// DeleteOrganization 删除组织，仅所有者或平台管理员可操作
+func DeleteOrganization(c *gin.Context) {
+	org, member, ok := organizationAccess(c, authz.OrganizationWrite)
+	if !ok {
+		return
+	}
+	if !isOrganizationOwner(c, member) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
+	if err := model.DeleteOrganization(org.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, org.OwnerId, "organization.delete", map[string]interface{}{
+		"name": org.Name,
+		"id":   org.Id,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationMembers 查询组织成员及其月度用量
+func GetOrganizationMembers(c *gin.Context) {
+	org, _, ok := organizationAccess(c, authz.OrganizationRead)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+// AddOrganizationMember 添加成员，只有所有者可以添加组织管理员
+func AddOrganizationMember(c *gin.Context) {
+	org, member, ok := organizationManageAccess(c, authz.OrganizationWrite)
+	if !ok {
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Role == model.OrganizationRoleAdmin && !isOrganizationOwner(c, member) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
+	userId := req.UserId
+	if userId == 0 && req.Username != "" {
+		id, err := model.GetUserIdByUsername(req.Username)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		userId = id
+	}
+	if userId == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.AddOrganizationMember(org.Id, userId, req.Role, req.MonthlyQuotaLimit); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// UpdateOrganizationMember 修改成员角色和月度额度上限，组织管理员只能由所有者调整
+func UpdateOrganizationMember(c *gin.Context) {
+	org, member, ok := organizationManageAccess(c, authz.OrganizationWrite)
+	if !ok {
+		return
+	}
+	target, ok := organizationTargetMember(c, org.Id)
+	if !ok {
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if (target.Role == model.OrganizationRoleAdmin || req.Role == model.OrganizationRoleAdmin) && !isOrganizationOwner(c, member) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
+	if err := model.UpdateOrganizationMember(org.Id, target.UserId, req.Role, req.MonthlyQuotaLimit); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// RemoveOrganizationMember 移除成员；普通成员可以退出组织
+func RemoveOrganizationMember(c *gin.Context) {
+	org, member, ok := organizationAccess(c, authz.OrganizationWrite)
+	if !ok {
+		return
+	}
+	target, ok := organizationTargetMember(c, org.Id)
+	if !ok {
+		return
+	}
+	leaving := member != nil && member.UserId == target.UserId
+	if !leaving {
+		canManage := (member != nil && member.CanManage()) || authz.Can(c.GetInt("id"), c.GetInt("role"), authz.OrganizationWrite)
+		if !canManage || (target.Role == model.OrganizationRoleAdmin && !isOrganizationOwner(c, member)) {
+			common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+			return
+		}
+	}
+	if err := model.RemoveOrganizationMember(org.Id, target.UserId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func organizationTargetMember(c *gin.Context, orgId int) (*model.OrganizationMember, bool) {
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+	member, err := model.GetOrganizationMember(orgId, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return member, true
+}
+
+// TransferOrganizationQuota 成员将个人钱包额度转入组织钱包
+func TransferOrganizationQuota(c *gin.Context) {
+	org, member, ok := organizationAccess(c, authz.OrganizationRead)
+	if !ok {
+		return
+	}
+	if member == nil {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
+	var req organizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota <= 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.TransferUserQuotaToOrganization(member.UserId, org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(member.UserId, model.LogTypeManage, "转入组织 "+org.Name+" 额度 "+logger.LogQuota(req.Quota))
+	common.ApiSuccess(c, nil)
+}
+
+// AdjustOrganizationQuota 管理员调整组织钱包额度，quota 为负表示扣减
+func AdjustOrganizationQuota(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req organizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	org, err := model.GetOrganizationById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.AdjustOrganizationQuota(org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, org.OwnerId, "organization.quota_adjust", map[string]interface{}{
+		"name":  org.Name,
+		"id":    org.Id,
+		"quota": logger.LogQuota(req.Quota),
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// GetAllOrganizations 管理员分页查询组织
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(pageInfo, c.Query("keyword"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetOrganizationTokens 查询组织名下的令牌（密钥脱敏）
+func GetOrganizationTokens(c *gin.Context) {
+	org, _, ok := organizationManageAccess(c, authz.OrganizationRead)
+	if !ok {
+		return
+	}
+	tokens, err := model.GetOrganizationTokens(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, buildMaskedTokenResponses(tokens))
+}
+
+// GetOrganizationLogs 查询组织令牌产生的日志
+func GetOrganizationLogs(c *gin.Context) {
+	org, _, ok := organizationManageAccess(c, authz.OrganizationRead)
+	if !ok {
+		return
+	}
+	tokenIds, err := model.GetOrganizationTokenIds(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, total, err := model.GetOrganizationLogs(tokenIds, logType, startTimestamp, endTimestamp, c.Query("model_name"), c.Query("username"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetOrganizationQuotaDates 按成员和模型统计组织令牌的用量
+func GetOrganizationQuotaDates(c *gin.Context) {
+	org, _, ok := organizationManageAccess(c, authz.OrganizationRead)
+	if !ok {
+		return
+	}
+	startTimestamp, endTimestamp, ok := parseFlowQuotaTimeRange(c)
+	if !ok {
+		return
+	}
+	// 与个人数据看板一致，时间跨度不能超过 1 个月
+	if endTimestamp-startTimestamp > 2592000 {
+		common.ApiErrorMsg(c, "时间跨度不能超过 1 个月")
+		return
+	}
+	tokenIds, err := model.GetOrganizationTokenIds(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	dates, err := model.GetQuotaDataByTokenIds(tokenIds, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, dates)
+}
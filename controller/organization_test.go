@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateOrganizationStatusRequiresPlatformPermission(t *testing.T) {
+	require.NoError(t, i18n.Init())
+	db := setupModelListControllerTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.Organization{}, &model.OrganizationMember{}))
+
+	org := &model.Organization{Name: "acme", OwnerId: 1}
+	require.NoError(t, model.CreateOrganization(org))
+
+	update := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPut, "/api/organization/"+strconv.Itoa(org.Id), strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(org.Id)}}
+		c.Set("id", 1)
+		c.Set("role", common.RoleCommonUser)
+		UpdateOrganization(c)
+		return recorder
+	}
+
+	// 所有者可以修改资料
+	recorder := update(`{"name":"acme-2","status":1}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"success":true`)
+
+	// 但不能禁用组织
+	recorder = update(`{"name":"acme-3","status":2}`)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	saved, err := model.GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "acme-2", saved.Name)
+	assert.Equal(t, model.OrganizationStatusEnabled, saved.Status)
+}
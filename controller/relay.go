@@ -16,6 +16,8 @@ import (
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	perfmetrics "github.com/QuantumNous/new-api/pkg/perf_metrics"
+	prommetrics "github.com/QuantumNous/new-api/pkg/prom_metrics"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
@@ -30,12 +32,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
 	var err *types.NewAPIError
 	switch info.RelayMode {
-	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		err = relay.ImageHelper(c, info)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
@@ -133,6 +136,9 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 	helper.InitializeDownstreamStreamState(c)
+	if newAPIError = service.ResolveUserFileReferences(c, request); newAPIError != nil {
+		return
+	}
 	if service.IsLeakProtectionBalancedEnabled(relayInfo.UserSetting) {
 		if blocked, reason := service.CheckRequestLeakProtection(request); blocked {
 			logger.LogWarn(c, "leak protection blocked request: "+reason)
@@ -153,9 +159,12 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
 	needCountToken := constant.CountToken
-	// Avoid building huge CombineText (strings.Join) when token counting and sensitive check are both disabled.
+	needTPMRateLimit := service.NeedTPMRateLimit(c)
+	needModeration := service.NeedPromptModeration(relayInfo)
+	needSensitiveRules := service.NeedSensitiveRules(c, relayInfo)
+	// Avoid building huge CombineText (strings.Join) when token counting, sensitive check, moderation and TPM limiting are all disabled.
 	var meta *types.TokenCountMeta
-	if needSensitiveCheck || needCountToken {
+	if needSensitiveCheck || needCountToken || needTPMRateLimit || needModeration || needSensitiveRules {
 		meta = request.GetTokenCountMeta()
 	} else {
 		meta = fastTokenCountMetaForPricing(request)
@@ -170,6 +179,18 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	if needSensitiveRules && meta != nil {
+		if newAPIError = service.ApplySensitiveRules(c, relayInfo, request, meta.CombineText); newAPIError != nil {
+			return
+		}
+	}
+
+	if needModeration && meta != nil {
+		if newAPIError = service.ModeratePrompt(c, relayInfo, meta.CombineText); newAPIError != nil {
+			return
+		}
+	}
+
 	tokens, err := service.EstimateRequestToken(c, meta, relayInfo)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
@@ -178,6 +199,15 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	relayInfo.SetEstimatePromptTokens(tokens)
 
+	if newAPIError = service.ReserveTPM(c, relayInfo, meta); newAPIError != nil {
+		return
+	}
+	defer func() {
+		if newAPIError != nil {
+			service.ReleaseTPMReservation(c)
+		}
+	}()
+
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeModelPriceError, types.ErrOptionWithStatusCode(http.StatusBadRequest))
@@ -206,6 +236,14 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	if cacheKey, ok := service.GetResponseCacheKey(c, relayInfo, request); ok {
+		if entry, hit := service.GetResponseCacheEntry(c, cacheKey); hit {
+			service.ServeResponseCacheHit(c, relayInfo, entry)
+			return
+		}
+		service.StartResponseCacheCapture(c, cacheKey)
+	}
+
 	retryParam := &service.RetryParam{
 		Ctx:         c,
 		TokenGroup:  relayInfo.TokenGroup,
@@ -217,6 +255,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	relayInfo.LastError = nil
 	upstreamAttempted := false
 	channelRateLimited := false
+	channelBreakerOpen := false
 
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
 		if shouldStopRetryForClientDisconnect(c) {
@@ -243,8 +282,18 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 			break
 		}
 		c.Request.Body = io.NopCloser(bodyStorage)
+		if !tryAcquireChannelBreaker(c, channel.Id) {
+			if isChannelPinned(c) {
+				newAPIError = types.NewErrorWithStatusCode(fmt.Errorf("channel #%d is circuit broken", channel.Id), types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable, types.ErrOptionWithSkipRetry())
+				break
+			}
+			// 熔断中的渠道同样属于本地选路跳过
+			channelBreakerOpen = true
+			continue
+		}
 		acquired, acquireErr := service.TryAcquireChannelRequestForContext(c, channel.Id)
 		if acquireErr != nil {
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerNeutral)
 			newAPIError = types.NewErrorWithStatusCode(acquireErr, types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable, types.ErrOptionWithSkipRetry())
 			break
 		}
@@ -252,12 +301,26 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 			// This is a local routing skip, not an upstream failure. Continue the
 			// normal selection loop and deliberately avoid channel error handling
 			// and perf_metrics failure recording.
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerNeutral)
+			if isChannelPinned(c) {
+				newAPIError = types.NewErrorWithStatusCode(fmt.Errorf("channel #%d is locally rate limited", channel.Id), types.ErrorCodeGetChannelFailed, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+				break
+			}
 			channelRateLimited = true
 			continue
 		}
 		upstreamAttempted = true
 		addUsedChannel(c, channel.Id)
 
+		attemptSpan := tracing.StartSpan(c, "relay.attempt",
+			attribute.Int("newapi.retry_index", relayInfo.RetryIndex),
+			attribute.Int("newapi.channel_id", channel.Id),
+			attribute.Int("newapi.channel_type", channel.Type),
+			attribute.String("newapi.relay_format", string(relayFormat)),
+		)
+		relayInfo.SetTraceContext(c.Request.Context())
+		channelAttempt := perfmetrics.StartChannelAttempt(channel.Id)
+
 		switch relayFormat {
 		case types.RelayFormatOpenAIRealtime:
 			newAPIError = relay.WssHelper(c, relayInfo)
@@ -270,12 +333,24 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 
 		if newAPIError == nil {
+			channelAttempt.Finish(relayInfo, true)
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerSuccess)
+			attemptSpan.End(nil)
 			relayInfo.LastError = nil
+			service.FinishResponseCacheCapture(c, relayInfo)
 			return
 		}
 
 		newAPIError = service.NormalizeViolationFeeError(newAPIError)
 		relayInfo.LastError = newAPIError
+		if isUpstreamChannelFailure(newAPIError) {
+			channelAttempt.Finish(relayInfo, false)
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerFailure)
+		} else {
+			channelAttempt.Release()
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerNeutral)
+		}
+		attemptSpan.End(newAPIError)
 
 		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
 
@@ -293,6 +368,9 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	if newAPIError == nil && channelRateLimited && !upstreamAttempted {
 		newAPIError = types.NewErrorWithStatusCode(errors.New("all candidate channels are locally rate limited"), types.ErrorCodeGetChannelFailed, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
 	}
+	if newAPIError == nil && channelBreakerOpen && !upstreamAttempted {
+		newAPIError = types.NewErrorWithStatusCode(errors.New("all candidate channels are circuit broken"), types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable, types.ErrOptionWithSkipRetry())
+	}
 
 	useChannel := c.GetStringSlice("use_channel")
 	if len(useChannel) > 1 {
@@ -302,6 +380,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	if newAPIError != nil && upstreamAttempted {
 		gopool.Go(func() {
 			perfmetrics.RecordRelaySample(relayInfo, false, 0)
+			prommetrics.RecordRelay(relayInfo, false)
 		})
 	}
 }
@@ -362,7 +441,15 @@ func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service
 			AutoBan: &autoBanInt,
 		}, nil
 	}
+	selectSpan := tracing.StartSpan(c, "relay.select_channel",
+		attribute.Int("newapi.retry_index", retryParam.GetRetry()),
+		attribute.String("newapi.model", info.OriginModelName),
+	)
 	channel, selectGroup, err := service.CacheGetRandomSatisfiedChannel(retryParam)
+	if channel != nil {
+		selectSpan.SetAttributes(attribute.Int("newapi.channel_id", channel.Id))
+	}
+	selectSpan.End(err)
 
 	info.PriceData.GroupRatioInfo = helper.HandleGroupRatio(c, info)
 
@@ -423,6 +510,33 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return operation_setting.ShouldRetryByStatusCode(code)
 }
 
+// isChannelPinned 请求只能由当前渠道处理时返回 true，熔断或本地限流时直接报错而不是换渠道
+func isChannelPinned(c *gin.Context) bool {
+	return service.IsResponseAffinityUsed(c) || service.IsUpstreamFileChannelPinned(c)
+}
+
+// tryAcquireChannelBreaker 检查渠道熔断器，熔断状态不可读时放行，避免 Redis 故障阻断请求
+func tryAcquireChannelBreaker(c *gin.Context, channelId int) bool {
+	acquired, err := service.TryAcquireChannelBreakerForContext(c, channelId)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("channel #%d breaker unavailable: %s", channelId, err.Error()))
+		return true
+	}
+	return acquired
+}
+
+// isUpstreamChannelFailure 错误是否应计入渠道失败（负载感知选路与熔断器使用），客户端参数类错误不计入
+func isUpstreamChannelFailure(err *types.NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	if types.IsChannelError(err) {
+		return true
+	}
+	code := err.StatusCode
+	return code < 100 || code >= 500 || code == http.StatusTooManyRequests
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, common.LocalLogPreview(err.Error())))
 	service.RecentCallsCache().UpsertErrorByContext(c, err.MaskSensitiveError(), string(err.GetErrorType()), string(err.GetErrorCode()), err.StatusCode)
@@ -586,6 +700,7 @@ func RelayTask(c *gin.Context) {
 	}
 	upstreamAttempted := false
 	channelRateLimited := false
+	channelBreakerOpen := false
 
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
 		if shouldStopRetryForClientDisconnect(c) {
@@ -621,26 +736,50 @@ func RelayTask(c *gin.Context) {
 			break
 		}
 		c.Request.Body = io.NopCloser(bodyStorage)
+		if !tryAcquireChannelBreaker(c, channel.Id) {
+			if isChannelPinned(c) {
+				taskErr = service.TaskErrorWrapperLocal(fmt.Errorf("channel #%d is circuit broken", channel.Id), "channel_circuit_broken", http.StatusServiceUnavailable)
+				break
+			}
+			channelBreakerOpen = true
+			continue
+		}
 		acquired, acquireErr := service.TryAcquireChannelRequestForContext(c, channel.Id)
 		if acquireErr != nil {
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerNeutral)
 			taskErr = service.TaskErrorWrapperLocal(acquireErr, "channel_rate_limit_unavailable", http.StatusServiceUnavailable)
 			break
 		}
 		if !acquired {
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerNeutral)
+			if isChannelPinned(c) {
+				taskErr = service.TaskErrorWrapperLocal(fmt.Errorf("channel #%d is locally rate limited", channel.Id), "channel_rate_limited", http.StatusTooManyRequests)
+				break
+			}
 			channelRateLimited = true
 			continue
 		}
 		upstreamAttempted = true
 		addUsedChannel(c, channel.Id)
 
+		channelAttempt := perfmetrics.StartChannelAttempt(channel.Id)
 		result, taskErr = relay.RelayTaskSubmit(c, relayInfo)
 		if taskErr == nil {
+			channelAttempt.Finish(relayInfo, true)
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerSuccess)
 			if err := service.RecordChannelRequestSuccessForContext(c, channel.Id); err != nil {
 				logger.LogError(c, "record channel request success: "+err.Error())
 			}
 			break
 		}
 
+		if !taskErr.LocalError && (taskErr.StatusCode >= 500 || taskErr.StatusCode == http.StatusTooManyRequests) {
+			channelAttempt.Finish(relayInfo, false)
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerFailure)
+		} else {
+			channelAttempt.Release()
+			service.RecordChannelBreakerResultForContext(c, channel.Id, service.ChannelBreakerNeutral)
+		}
 		if !taskErr.LocalError {
 			processChannelError(c,
 				*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey,
@@ -659,6 +798,9 @@ func RelayTask(c *gin.Context) {
 	if taskErr == nil && channelRateLimited && !upstreamAttempted {
 		taskErr = service.TaskErrorWrapperLocal(errors.New("all candidate channels are locally rate limited"), "channel_rate_limited", http.StatusTooManyRequests)
 	}
+	if taskErr == nil && channelBreakerOpen && !upstreamAttempted {
+		taskErr = service.TaskErrorWrapperLocal(errors.New("all candidate channels are circuit broken"), "channel_circuit_broken", http.StatusServiceUnavailable)
+	}
 
 	useChannel := c.GetStringSlice("use_channel")
 	if len(useChannel) > 1 {
@@ -677,6 +819,7 @@ func RelayTask(c *gin.Context) {
 		task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
 		task.PrivateData.BillingSource = relayInfo.BillingSource
 		task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
+		task.PrivateData.OrganizationId = relayInfo.OrganizationId
 		task.PrivateData.TokenId = relayInfo.TokenId
 		task.PrivateData.NodeName = common.NodeName
 		task.PrivateData.BillingContext = &model.TaskBillingContext{
@@ -5,6 +5,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/QuantumNous/new-api/service"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,3 +24,15 @@ func TestShouldStopRetryForClientDisconnect(t *testing.T) {
 		t.Fatal("cancelled request must stop retry")
 	}
 }
+
+func TestIsChannelPinnedByResponseAffinity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if isChannelPinned(c) {
+		t.Fatal("request without affinity must be allowed to switch channel")
+	}
+	service.MarkResponseAffinityUsed(c, service.ResponseAffinity{ChannelId: 3, KeyIndex: -1})
+	if !isChannelPinned(c) {
+		t.Fatal("request pinned by previous_response_id must not switch channel")
+	}
+}
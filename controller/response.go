@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetrieveResponse GET /v1/responses/:id
+func RetrieveResponse(c *gin.Context) {
+	proxyResponseRequest(c, http.MethodGet, "")
+}
+
+// DeleteResponse DELETE /v1/responses/:id
+func DeleteResponse(c *gin.Context) {
+	if proxyResponseRequest(c, http.MethodDelete, "") {
+		service.DeleteResponseAffinity(c.GetInt("id"), c.Param("id"))
+	}
+}
+
+// CancelResponse POST /v1/responses/:id/cancel
+func CancelResponse(c *gin.Context) {
+	proxyResponseRequest(c, http.MethodPost, "cancel")
+}
+
+// ListResponseInputItems GET /v1/responses/:id/input_items
+func ListResponseInputItems(c *gin.Context) {
+	proxyResponseRequest(c, http.MethodGet, "input_items")
+}
+
+// proxyResponseRequest 转发到生成该 response 的渠道与 key，返回上游是否处理成功
+func proxyResponseRequest(c *gin.Context, method string, subresource string) bool {
+	upstream, apiErr := service.GetOwnedResponseUpstream(c.GetInt("id"), c.Param("id"))
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return false
+	}
+	resp, apiErr := service.ProxyResponseRequest(c.Request.Context(), upstream, method, subresource, c.Request.URL.Query())
+	if apiErr != nil {
+		respondOpenAIManageError(c, apiErr)
+		return false
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	c.Header("Content-Type", contentType)
+	c.Status(resp.StatusCode)
+	if !strings.HasPrefix(contentType, "text/event-stream") {
+		if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+			logger.LogError(c, fmt.Sprintf("failed to write response %s: %s", upstream.ResponseId, err.Error()))
+		}
+		return true
+	}
+	// GET /v1/responses/:id?stream=true 重放事件流，逐块刷新
+	buf := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
+				return true
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.LogError(c, fmt.Sprintf("failed to stream response %s: %s", upstream.ResponseId, err.Error()))
+			}
+			return true
+		}
+	}
+}
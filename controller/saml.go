@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const samlProviderName = "SAML"
+
+// SAMLMetadata 输出 SP 元数据，未启用时也可访问，便于先在 IdP 侧完成配置
+func SAMLMetadata(c *gin.Context) {
+	sp, err := service.GetSAMLServiceProvider()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml; charset=utf-8", sp.Metadata())
+}
+
+// SAMLLogin 跳转到 IdP 登录。state 来自 /api/oauth/state，会原样回传给前端回调页，
+// 与其他 OAuth 登录一样由 /api/oauth/saml 校验
+func SAMLLogin(c *gin.Context) {
+	if !system_setting.GetSAMLSettings().Enabled {
+		common.ApiErrorI18n(c, i18n.MsgOAuthNotEnabled, providerParams(samlProviderName))
+		return
+	}
+	state := c.Query("state")
+	sessionState, _ := sessions.Default(c).Get("oauth_state").(string)
+	if state == "" || state != sessionState {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": i18n.T(c, i18n.MsgOAuthStateInvalid),
+		})
+		return
+	}
+
+	sp, err := service.GetSAMLServiceProvider()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	relayState := common.GetRandomString(32)
+	loginURL, requestID, err := sp.AuthnRequestURL(relayState, time.Now())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := service.SaveSAMLRequestState(relayState, service.SAMLRequestState{RequestID: requestID, State: state}); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, loginURL)
+}
+
+// SAMLACS 接收 IdP 以 HTTP-POST 绑定提交的断言。该请求来自 IdP 站点，拿不到会话，
+// 校验结果以一次性票据交给前端回调页，再走 /api/oauth/saml 完成登录或绑定
+func SAMLACS(c *gin.Context) {
+	request, ok := service.ConsumeSAMLRequestState(c.PostForm("RelayState"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": i18n.T(c, i18n.MsgOAuthStateInvalid),
+		})
+		return
+	}
+
+	result := service.SAMLLoginResult{}
+	identity, err := service.ConsumeSAMLResponse(c.PostForm("SAMLResponse"), request.RequestID)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("[SAML] assertion rejected: %v", err))
+		switch {
+		case errors.Is(err, service.ErrSAMLDisabled):
+			result.ErrorKey = i18n.MsgOAuthNotEnabled
+		case errors.Is(err, service.ErrSAMLTransientNameID):
+			result.ErrorKey = i18n.MsgSAMLTransientNameID
+		default:
+			result.ErrorKey = i18n.MsgSAMLResponseInvalid
+		}
+	} else {
+		result.Identity = identity
+	}
+
+	ticket, err := service.IssueSAMLTicket(result)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	query := url.Values{}
+	query.Set("code", ticket)
+	query.Set("state", request.State)
+	c.Redirect(http.StatusSeeOther, strings.TrimRight(system_setting.ServerAddress, "/")+"/oauth/saml?"+query.Encode())
+}
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/saml/samltest"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const samlTestServerAddress = "https://sp.example.com"
+
+type samlTestEnv struct {
+	db     *gorm.DB
+	router *gin.Engine
+	idp    *samltest.IdP
+	cookie string
+}
+
+func setupSAMLTest(t *testing.T) *samlTestEnv {
+	t.Helper()
+	require.NoError(t, i18n.Init())
+	db := setupModelListControllerTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.Log{}, &model.UserOAuthBinding{}, &model.TwoFA{}))
+
+	idp, err := samltest.NewIdP("https://idp.corp.example")
+	require.NoError(t, err)
+
+	settings := system_setting.GetSAMLSettings()
+	original := *settings
+	originalAddress := system_setting.ServerAddress
+	originalRegister := common.RegisterEnabled
+	t.Cleanup(func() {
+		*settings = original
+		system_setting.ServerAddress = originalAddress
+		common.RegisterEnabled = originalRegister
+	})
+	*settings = system_setting.SAMLSettings{
+		Enabled:              true,
+		IdPEntityId:          idp.EntityID,
+		IdPSSOUrl:            "https://idp.corp.example/sso",
+		IdPCertificate:       idp.CertificatePEM(),
+		UsernameAttribute:    "uid",
+		EmailAttribute:       "email",
+		DisplayNameAttribute: "displayName",
+		GroupAttribute:       "groups",
+		GroupMapping:         map[string]string{"VIP-Staff": "vip"},
+		AllowedClockSkew:     120,
+	}
+	system_setting.ServerAddress = samlTestServerAddress
+	common.RegisterEnabled = true
+
+	groupRatios := ratio_setting.GetGroupRatioCopy()
+	require.NoError(t, ratio_setting.UpdateGroupRatioByJSONString(`{"default":1,"vip":1}`))
+	t.Cleanup(func() {
+		data, _ := json.Marshal(groupRatios)
+		_ = ratio_setting.UpdateGroupRatioByJSONString(string(data))
+	})
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("saml-test-session"))))
+	router.GET("/api/oauth/state", GenerateOAuthCode)
+	router.GET("/api/oauth/:provider", HandleOAuth)
+	router.GET("/api/saml/metadata", SAMLMetadata)
+	router.GET("/api/saml/login", SAMLLogin)
+	router.POST("/api/saml/acs", SAMLACS)
+	router.GET("/test/session/:id", func(c *gin.Context) {
+		session := sessions.Default(c)
+		session.Set("id", common.String2Int(c.Param("id")))
+		session.Set("username", "local")
+		require.NoError(t, session.Save())
+	})
+	return &samlTestEnv{db: db, router: router, idp: idp}
+}
+
+func (e *samlTestEnv) do(t *testing.T, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	if e.cookie != "" {
+		req.Header.Set("Cookie", e.cookie)
+	}
+	recorder := httptest.NewRecorder()
+	e.router.ServeHTTP(recorder, req)
+	if setCookie := recorder.Header().Get("Set-Cookie"); setCookie != "" {
+		e.cookie = strings.Split(setCookie, ";")[0]
+	}
+	return recorder
+}
+
+// startLogin 走完 state -> /api/saml/login，返回 IdP 需要回应的请求 ID 与 RelayState
+func (e *samlTestEnv) startLogin(t *testing.T) (state string, requestID string, relayState string) {
+	t.Helper()
+	recorder := e.do(t, httptest.NewRequest(http.MethodGet, "/api/oauth/state", nil))
+	var stateResp struct {
+		Data string `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &stateResp))
+	state = stateResp.Data
+
+	recorder = e.do(t, httptest.NewRequest(http.MethodGet, "/api/saml/login?state="+url.QueryEscape(state), nil))
+	require.Equal(t, http.StatusFound, recorder.Code, recorder.Body.String())
+	request, relayState, err := samltest.ParseAuthnRequestURL(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, samlTestServerAddress+"/api/saml/acs", request.Attr("AssertionConsumerServiceURL"))
+	return state, request.Attr("ID"), relayState
+}
+
+func (e *samlTestEnv) postACS(t *testing.T, samlResponse string, relayState string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"SAMLResponse": {samlResponse}, "RelayState": {relayState}}
+	req := httptest.NewRequest(http.MethodPost, "/api/saml/acs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// IdP 站点提交的表单不会带上 SameSite=Strict 的会话
+	recorder := httptest.NewRecorder()
+	e.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// login 完成一次完整的 SAML 登录，返回前端回调 /api/oauth/saml 的响应
+func (e *samlTestEnv) login(t *testing.T, response samltest.Response) map[string]any {
+	t.Helper()
+	state, requestID, relayState := e.startLogin(t)
+	response.InResponseTo = requestID
+	response.ACSURL = samlTestServerAddress + "/api/saml/acs"
+	response.Audience = samlTestServerAddress + "/api/saml/metadata"
+	encoded, err := e.idp.EncodedResponse(response)
+	require.NoError(t, err)
+
+	recorder := e.postACS(t, encoded, relayState)
+	require.Equal(t, http.StatusSeeOther, recorder.Code, recorder.Body.String())
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth/saml", location.Path)
+	assert.Equal(t, state, location.Query().Get("state"))
+
+	callback := "/api/oauth/saml?" + location.RawQuery
+	recorder = e.do(t, httptest.NewRequest(http.MethodGet, callback, nil))
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp
+}
+
+func samlTestUser() samltest.Response {
+	return samltest.Response{
+		NameID:       "dave@corp.example",
+		NameIDFormat: "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
+		Attributes: map[string][]string{
+			"uid":         {"dave"},
+			"displayName": {"Dave"},
+			"groups":      {"everyone", "vip-staff"},
+		},
+		SignAssertion: true,
+	}
+}
+
+func TestSAMLLoginProvisionsUser(t *testing.T) {
+	env := setupSAMLTest(t)
+
+	resp := env.login(t, samlTestUser())
+	require.Equal(t, true, resp["success"], resp["message"])
+
+	var user model.User
+	require.NoError(t, env.db.Where("username = ?", "dave").First(&user).Error)
+	// 未提供 email 属性时使用 emailAddress 格式的 NameID
+	assert.Equal(t, "dave@corp.example", user.Email)
+	assert.Equal(t, "Dave", user.DisplayName)
+	assert.Equal(t, "vip", user.Group)
+
+	var binding model.UserOAuthBinding
+	require.NoError(t, env.db.Where("user_id = ?", user.Id).First(&binding).Error)
+	assert.Equal(t, model.OAuthBindingProviderSAML, binding.ProviderId)
+	assert.Equal(t, "dave@corp.example", binding.ProviderUserId)
+
+	// 再次登录复用同一用户
+	resp = env.login(t, samlTestUser())
+	require.Equal(t, true, resp["success"], resp["message"])
+	var count int64
+	env.db.Model(&model.User{}).Count(&count)
+	assert.EqualValues(t, 1, count)
+}
+
+func TestSAMLBindExistingUser(t *testing.T) {
+	env := setupSAMLTest(t)
+	local := &model.User{Username: "local", Status: common.UserStatusEnabled, Group: "default", AffCode: "loc1"}
+	require.NoError(t, env.db.Create(local).Error)
+	env.do(t, httptest.NewRequest(http.MethodGet, "/test/session/"+common.Interface2String(local.Id), nil))
+
+	resp := env.login(t, samlTestUser())
+	require.Equal(t, true, resp["success"], resp["message"])
+	assert.Equal(t, "bind", resp["data"].(map[string]any)["action"])
+
+	bound, err := model.GetUserByOAuthBinding(model.OAuthBindingProviderSAML, "dave@corp.example")
+	require.NoError(t, err)
+	assert.Equal(t, local.Id, bound.Id)
+}
+
+func TestSAMLRejectsInvalidAssertionAndReplay(t *testing.T) {
+	env := setupSAMLTest(t)
+
+	unsigned := samlTestUser()
+	unsigned.SignAssertion = false
+	resp := env.login(t, unsigned)
+	assert.Equal(t, false, resp["success"])
+
+	var count int64
+	env.db.Model(&model.User{}).Count(&count)
+	assert.Zero(t, count)
+
+	// RelayState 只能使用一次
+	_, requestID, relayState := env.startLogin(t)
+	response := samlTestUser()
+	response.InResponseTo = requestID
+	response.ACSURL = samlTestServerAddress + "/api/saml/acs"
+	response.Audience = samlTestServerAddress + "/api/saml/metadata"
+	encoded, err := env.idp.EncodedResponse(response)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSeeOther, env.postACS(t, encoded, relayState).Code)
+	assert.Equal(t, http.StatusForbidden, env.postACS(t, encoded, relayState).Code)
+
+	// 同一断言搭配新的登录请求也会因 InResponseTo 不匹配被拒绝
+	_, _, relayState = env.startLogin(t)
+	recorder := env.postACS(t, encoded, relayState)
+	require.Equal(t, http.StatusSeeOther, recorder.Code)
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	recorder = env.do(t, httptest.NewRequest(http.MethodGet, "/api/oauth/saml?"+location.RawQuery, nil))
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	assert.Equal(t, false, resp["success"])
+}
+
+func TestSAMLLoginRequiresOAuthState(t *testing.T) {
+	env := setupSAMLTest(t)
+	recorder := env.do(t, httptest.NewRequest(http.MethodGet, "/api/saml/login?state=forged", nil))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestSAMLMetadata(t *testing.T) {
+	env := setupSAMLTest(t)
+	recorder := env.do(t, httptest.NewRequest(http.MethodGet, "/api/saml/metadata", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `entityID="https://sp.example.com/api/saml/metadata"`)
+	assert.Contains(t, recorder.Body.String(), `Location="https://sp.example.com/api/saml/acs"`)
+}
@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSensitiveHits 查询敏感规则命中记录，记录中只保存脱敏后的片段
+func GetSensitiveHits(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	query := model.SensitiveHitQuery{
+		RuleSet:  c.Query("rule_set"),
+		Category: c.Query("category"),
+		Action:   c.Query("action"),
+	}
+	query.UserId, _ = strconv.Atoi(c.Query("user_id"))
+	query.TokenId, _ = strconv.Atoi(c.Query("token_id"))
+	query.StartTimestamp, _ = strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	query.EndTimestamp, _ = strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	hits, total, err := model.GetSensitiveHits(query, pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(hits)
+	common.ApiSuccess(c, pageInfo)
+}
@@ -24,6 +24,8 @@ func RegisterScheduledSystemTasks() {
 	service.RegisterSystemTaskHandler(asyncTaskPollHandler{})
 	service.RegisterSystemTaskHandler(activeTaskHistoryHandler{})
 	service.RegisterSystemTaskHandler(epayReconcileHandler{})
+	service.RegisterSystemTaskHandler(batchProcessHandler{})
+	service.RegisterSystemTaskHandler(fineTuningPollHandler{})
 }
 
 type epayReconcileHandler struct{}
@@ -191,6 +193,46 @@ func (asyncTaskPollHandler) Run(ctx context.Context, task *model.SystemTask, run
 	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
 }
 
+// batchProcessHandler executes pending /v1/batches requests through the relay
+// pipeline until every active batch reaches a terminal state. Creating or
+// cancelling a batch enqueues a run immediately; the schedule only picks up
+// batches left over from a restart or another node.
+type batchProcessHandler struct{}
+
+func (batchProcessHandler) Type() string { return model.SystemTaskTypeBatchProcess }
+
+func (batchProcessHandler) Enabled() bool {
+	return operation_setting.GetBatchSetting().Enabled && model.HasActiveBatches()
+}
+
+func (batchProcessHandler) Interval() time.Duration { return 15 * time.Second }
+
+func (batchProcessHandler) NewPayload() any { return nil }
+
+func (batchProcessHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	summary := service.RunBatchProcessing(ctx, executeBatchRequest)
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
+}
+
+// fineTuningPollHandler refreshes proxied fine-tuning jobs and bills the
+// trained tokens once a job reaches a terminal state.
+type fineTuningPollHandler struct{}
+
+func (fineTuningPollHandler) Type() string { return model.SystemTaskTypeFineTuningPoll }
+
+func (fineTuningPollHandler) Enabled() bool {
+	return model.HasUnfinishedFineTuningJobs()
+}
+
+func (fineTuningPollHandler) Interval() time.Duration { return 30 * time.Second }
+
+func (fineTuningPollHandler) NewPayload() any { return nil }
+
+func (fineTuningPollHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	polled := service.PollFineTuningJobs(ctx)
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, map[string]int{"jobs_polled": polled}, nil)
+}
+
 func finishSystemTaskHandler(task *model.SystemTask, runnerID string, status model.SystemTaskStatus, result any, runErr error) {
 	errorMessage := ""
 	if runErr != nil {
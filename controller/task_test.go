@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestGetAllTaskHidesInputWithoutSensitiveView(t *testing.T) {
+	db := setupModelListControllerTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.Task{}))
+	require.NoError(t, db.Create(&model.Task{
+		TaskID:     "task_1",
+		UserId:     1,
+		Properties: model.Properties{Input: "secret prompt", OriginModelName: "video-model"},
+		Data:       json.RawMessage(`{"prompt":"secret prompt"}`),
+	}).Error)
+
+	list := func(role int) string {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/task/", nil)
+		c.Set("id", 1)
+		c.Set("role", role)
+		GetAllTask(c)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		return recorder.Body.String()
+	}
+
+	body := list(common.RoleRootUser)
+	assert.Equal(t, "secret prompt", gjson.Get(body, "data.items.0.properties.input").String())
+	assert.True(t, gjson.Get(body, "data.items.0.data.prompt").Exists())
+
+	body = list(common.RoleCommonUser)
+	assert.Empty(t, gjson.Get(body, "data.items.0.properties.input").String())
+	assert.Equal(t, "video-model", gjson.Get(body, "data.items.0.properties.origin_model_name").String())
+	assert.False(t, gjson.Get(body, "data.items.0.data.prompt").Exists())
+}
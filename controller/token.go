@@ -187,6 +187,17 @@ func AddToken(c *gin.Context) {
 			return
 		}
 	}
+	if hasNegativeTokenLimit(&token) {
+		common.ApiErrorI18n(c, i18n.MsgTokenLimitNegative)
+		return
+	}
+	// 组织令牌只能由组织成员创建，创建后不可转移
+	if token.OrganizationId > 0 {
+		if _, err := model.GetOrganizationMember(token.OrganizationId, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	// 检查用户令牌数量是否已达上限
 	maxTokens := operation_setting.GetMaxUserTokens()
 	count, err := model.CountUserTokens(c.GetInt("id"))
@@ -209,6 +220,7 @@ func AddToken(c *gin.Context) {
 	}
 	cleanToken := model.Token{
 		UserId:             c.GetInt("id"),
+		OrganizationId:     token.OrganizationId,
 		Name:               token.Name,
 		Key:                key,
 		CreatedTime:        common.GetTimestamp(),
@@ -221,6 +233,13 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		ResponseCache:      token.ResponseCache,
+		DailyQuotaLimit:    token.DailyQuotaLimit,
+		WeeklyQuotaLimit:   token.WeeklyQuotaLimit,
+		MonthlyQuotaLimit:  token.MonthlyQuotaLimit,
+		RpmLimit:           token.RpmLimit,
+		TpmLimit:           token.TpmLimit,
+		SensitiveRuleSets:  token.SensitiveRuleSets,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -233,6 +252,11 @@ func AddToken(c *gin.Context) {
 	})
 }
 
+func hasNegativeTokenLimit(token *model.Token) bool {
+	return token.DailyQuotaLimit < 0 || token.WeeklyQuotaLimit < 0 || token.MonthlyQuotaLimit < 0 ||
+		token.RpmLimit < 0 || token.TpmLimit < 0
+}
+
 func DeleteToken(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	userId := c.GetInt("id")
@@ -271,6 +295,10 @@ func UpdateToken(c *gin.Context) {
 			return
 		}
 	}
+	if hasNegativeTokenLimit(&token) {
+		common.ApiErrorI18n(c, i18n.MsgTokenLimitNegative)
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -299,6 +327,13 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.ResponseCache = token.ResponseCache
+		cleanToken.DailyQuotaLimit = token.DailyQuotaLimit
+		cleanToken.WeeklyQuotaLimit = token.WeeklyQuotaLimit
+		cleanToken.MonthlyQuotaLimit = token.MonthlyQuotaLimit
+		cleanToken.RpmLimit = token.RpmLimit
+		cleanToken.TpmLimit = token.TpmLimit
+		cleanToken.SensitiveRuleSets = token.SensitiveRuleSets
 	}
 	err = cleanToken.Update()
 	if err != nil {
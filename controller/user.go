@@ -72,6 +72,11 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	loginWith2FACheck(c, &user)
+}
+
+// loginWith2FACheck 开启了 2FA 的用户先写入待验证会话，否则直接完成登录
+func loginWith2FACheck(c *gin.Context, user *model.User) {
 	// 检查是否启用2FA
 	twoFAEnabled, err := model.IsTwoFAEnabled(user.Id)
 	if err != nil {
@@ -100,7 +105,7 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	setupLogin(&user, c)
+	setupLogin(user, c)
 }
 
 // loginMethodFromContext 根据请求路径推导登录方式，用于登录审计日志。
@@ -110,6 +115,8 @@ func loginMethodFromContext(c *gin.Context) string {
 		return "password"
 	case "/api/user/login/2fa":
 		return "2fa"
+	case "/api/user/login/ldap":
+		return "ldap"
 	case "/api/user/passkey/login/finish":
 		return "passkey"
 	case "/api/oauth/wechat":
@@ -308,7 +315,7 @@ func Register(c *gin.Context) {
 
 func GetAllUsers(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
-	users, total, err := model.GetAllUsers(pageInfo)
+	users, total, err := model.GetAllUsers(pageInfo, scopedUserGroups(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -337,7 +344,7 @@ func SearchUsers(c *gin.Context) {
 		}
 	}
 	pageInfo := common.GetPageQuery(c)
-	users, total, err := model.SearchUsers(keyword, group, role, status, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	users, total, err := model.SearchUsers(keyword, group, scopedUserGroups(c), role, status, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -369,6 +376,9 @@ func GetUser(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionSameLevel)
 		return
 	}
+	if !requireScopeAllows(c, user.Group) {
+		return
+	}
 	user.AdminPermissions = authz.Capabilities(user.Id, user.Role)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -692,6 +702,10 @@ func UpdateUser(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
+	// 受分组范围限制的操作者既不能编辑范围外的用户，也不能把用户移出范围
+	if !requireScopeAllows(c, originUser.Group) || !requireScopeAllows(c, updatedUser.Group) {
+		return
+	}
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
@@ -1063,6 +1077,9 @@ func ManageUser(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
+	if !requireScopeAllows(c, user.Group) {
+		return
+	}
 	switch req.Action {
 	case "disable":
 		user.Status = common.UserStatusDisabled
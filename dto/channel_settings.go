@@ -59,6 +59,22 @@ type ChannelOtherSettings struct {
 	UpstreamModelUpdateLastRemovedModels  []string              `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
 	UpstreamModelUpdateIgnoredModels      []string              `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
 	AdvancedCustom                        *AdvancedCustomConfig `json:"advanced_custom,omitempty"`
+	PIIRedaction                          *PIIRedactionSettings `json:"pii_redaction,omitempty"`
+}
+
+// PIIRedactionSettings 转发到上游前将请求中的个人信息替换为占位符，并在返回给客户端前还原
+type PIIRedactionSettings struct {
+	Enabled bool `json:"enabled"`
+	// Types 需要脱敏的类型：email、phone、id_number、credit_card、secret，为空表示全部
+	Types          []string `json:"types,omitempty"`
+	DisableRestore bool     `json:"disable_restore,omitempty"` // 不还原上游输出中的占位符
+}
+
+func (s *ChannelOtherSettings) GetPIIRedaction() *PIIRedactionSettings {
+	if s == nil || s.PIIRedaction == nil || !s.PIIRedaction.Enabled {
+		return nil
+	}
+	return s.PIIRedaction
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
@@ -21,6 +21,29 @@ const (
 	RealtimeEventConversationItemCreated            = "conversation.item.created"
 )
 
+// 其他服务端事件，以及 GA 版本协议中重命名的输出事件
+const (
+	RealtimeEventTypeResponseCreated                = "response.created"
+	RealtimeEventTypeResponseCancel                 = "response.cancel"
+	RealtimeEventResponseOutputItemAdded            = "response.output_item.added"
+	RealtimeEventResponseOutputItemDone             = "response.output_item.done"
+	RealtimeEventResponseContentPartAdded           = "response.content_part.added"
+	RealtimeEventResponseContentPartDone            = "response.content_part.done"
+	RealtimeEventResponseTextDelta                  = "response.text.delta"
+	RealtimeEventResponseTextDone                   = "response.text.done"
+	RealtimeEventResponseAudioDone                  = "response.audio.done"
+	RealtimeEventResponseAudioTranscriptionDone     = "response.audio_transcript.done"
+	RealtimeEventResponseOutputAudioDelta           = "response.output_audio.delta"
+	RealtimeEventResponseOutputAudioTranscriptDelta = "response.output_audio_transcript.delta"
+	RealtimeEventResponseOutputTextDelta            = "response.output_text.delta"
+	RealtimeEventInputAudioBufferCommit             = "input_audio_buffer.commit"
+	RealtimeEventInputAudioBufferCommitted          = "input_audio_buffer.committed"
+	RealtimeEventInputAudioBufferClear              = "input_audio_buffer.clear"
+	RealtimeEventInputAudioBufferCleared            = "input_audio_buffer.cleared"
+	RealtimeEventInputAudioBufferSpeechStarted      = "input_audio_buffer.speech_started"
+	RealtimeEventInputAudioTranscriptionCompleted   = "conversation.item.input_audio_transcription.completed"
+)
+
 type RealtimeEvent struct {
 	EventId string `json:"event_id"`
 	Type    string `json:"type"`
@@ -31,10 +54,26 @@ type RealtimeEvent struct {
 	Response *RealtimeResponse  `json:"response,omitempty"`
 	Delta    string             `json:"delta,omitempty"`
 	Audio    string             `json:"audio,omitempty"`
+
+	// 以下字段用于转换其他协议时构造服务端事件
+	ResponseId   string           `json:"response_id,omitempty"`
+	ItemId       string           `json:"item_id,omitempty"`
+	OutputIndex  *int             `json:"output_index,omitempty"`
+	ContentIndex *int             `json:"content_index,omitempty"`
+	CallId       string           `json:"call_id,omitempty"`
+	Name         string           `json:"name,omitempty"`
+	Arguments    string           `json:"arguments,omitempty"`
+	Text         string           `json:"text,omitempty"`
+	Transcript   string           `json:"transcript,omitempty"`
+	Part         *RealtimeContent `json:"part,omitempty"`
 }
 
 type RealtimeResponse struct {
-	Usage *RealtimeUsage `json:"usage"`
+	Id     string         `json:"id,omitempty"`
+	Object string         `json:"object,omitempty"`
+	Status string         `json:"status,omitempty"`
+	Output []RealtimeItem `json:"output,omitempty"`
+	Usage  *RealtimeUsage `json:"usage"`
 }
 
 type RealtimeUsage struct {
@@ -79,6 +118,8 @@ type RealtimeItem struct {
 	Name      *string           `json:"name,omitempty"`
 	ToolCalls any               `json:"tool_calls,omitempty"`
 	CallId    string            `json:"call_id,omitempty"`
+	Arguments string            `json:"arguments,omitempty"`
+	Output    string            `json:"output,omitempty"`
 }
 type RealtimeContent struct {
 	Type       string `json:"type"`
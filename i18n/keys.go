@@ -45,6 +45,7 @@ const (
 	MsgTokenNameTooLong          = "token.name_too_long"
 	MsgTokenQuotaNegative        = "token.quota_negative"
 	MsgTokenQuotaExceedMax       = "token.quota_exceed_max"
+	MsgTokenLimitNegative        = "token.limit_negative"
 	MsgTokenGenerateFailed       = "token.generate_failed"
 	MsgTokenGetInfoFailed        = "token.get_info_failed"
 	MsgTokenExpiredCannotEnable  = "token.expired_cannot_enable"
@@ -321,6 +322,9 @@ const (
 	MsgDistributorNoAvailableChannel      = "distributor.no_available_channel"
 	MsgDistributorInvalidMidjourney       = "distributor.invalid_midjourney_request"
 	MsgDistributorInvalidParseModel       = "distributor.invalid_request_parse_model"
+
+	MsgDistributorPreviousResponseUnavailable = "distributor.previous_response_unavailable"
+	MsgDistributorUpstreamFileUnavailable     = "distributor.upstream_file_unavailable"
 )
 
 // Custom OAuth provider related messages
@@ -333,3 +337,15 @@ const (
 	MsgCustomOAuthBindingNotFound   = "custom_oauth.binding_not_found"
 	MsgCustomOAuthProviderIdInvalid = "custom_oauth.provider_id_field_invalid"
 )
+
+// LDAP login related messages
+const (
+	MsgLDAPInvalidCredentials = "ldap.invalid_credentials"
+	MsgLDAPUserNotProvisioned = "ldap.user_not_provisioned"
+)
+
+// SAML login related messages
+const (
+	MsgSAMLResponseInvalid = "saml.response_invalid"
+	MsgSAMLTransientNameID = "saml.transient_name_id"
+)
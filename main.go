@@ -24,6 +24,8 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
 	perfmetrics "github.com/QuantumNous/new-api/pkg/perf_metrics"
+	prommetrics "github.com/QuantumNous/new-api/pkg/prom_metrics"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	"github.com/QuantumNous/new-api/router"
 	"github.com/QuantumNous/new-api/service"
@@ -144,6 +146,11 @@ func main() {
 		return a
 	}
 
+	// Wire channel circuit breaker filters into channel selection (model cannot
+	// import service).
+	model.ChannelBreakerFilterFunc = service.FilterChannelsByBreaker
+	model.ChannelKeyBreakerFilterFunc = service.FilterChannelKeysByBreaker
+
 	// Register the periodic channel test, upstream model update, and async task
 	// polling (Midjourney / Suno / video) jobs as scheduled system tasks
 	// (DB-lease dedup across masters + run history), then start the runner that
@@ -171,6 +178,11 @@ func main() {
 		common.SysError(fmt.Sprintf("start pyroscope error : %v", err))
 	}
 
+	shutdownTracing, err := tracing.Init()
+	if err != nil {
+		common.SysError(fmt.Sprintf("init tracing error : %v", err))
+	}
+
 	// Initialize HTTP server
 	server := gin.New()
 	server.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
@@ -185,6 +197,7 @@ func main() {
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
 	server.Use(middleware.RequestId())
+	server.Use(tracing.Middleware())
 	server.Use(middleware.Version())
 	server.Use(middleware.I18n())
 	middleware.SetUpLogger(server)
@@ -241,6 +254,9 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		common.SysError(fmt.Sprintf("server forced to shutdown: %v", err))
 	}
+	if err := shutdownTracing(ctx); err != nil {
+		common.SysError(fmt.Sprintf("flush traces error: %v", err))
+	}
 	// 内存中的看板数据保存入库，避免重启丢失未落库数据 (issue #5679)
 	if common.DataExportEnabled {
 		model.SaveQuotaDataCache()
@@ -347,6 +363,7 @@ func InitResources() error {
 	}
 
 	perfmetrics.Init()
+	prommetrics.Init()
 
 	// 启动系统监控
 	common.StartSystemMonitor()
@@ -212,6 +212,26 @@ func RequirePermission(permission authz.Permission) func(c *gin.Context) {
 	}
 }
 
+// RequireScopedPermission behaves like RequirePermission but also admits
+// operators who hold the permission only within some user groups or channel
+// tags. The resolved scope is stored in the context and the handler must
+// narrow its work to it; only use it on routes whose handlers do so.
+func RequireScopedPermission(permission authz.Permission) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		scope := authz.ScopeOf(c.GetInt("id"), c.GetInt("role"), permission)
+		if !scope.Empty() {
+			common.SetContextKey(c, constant.ContextKeyAuthzScope, scope)
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": common.TranslateMessage(c, i18n.MsgAuthInsufficientPrivilege),
+		})
+		c.Abort()
+	}
+}
+
 func WssAuth(c *gin.Context) {
 
 }
@@ -417,33 +437,56 @@ func TokenAuth() func(c *gin.Context) {
 
 		userCache.WriteContext(c)
 
-		userGroup := userCache.Group
-		tokenGroup := token.Group
-		if tokenGroup != "" {
-			// check common.UserUsableGroups[userGroup]
-			if _, ok := service.GetUserUsableGroups(userGroup)[tokenGroup]; !ok {
-				abortWithOpenAiMessage(c, http.StatusForbidden, fmt.Sprintf("无权访问 %s 分组", tokenGroup))
-				return
-			}
-			// check group in common.GroupRatio
-			if !ratio_setting.ContainsGroupRatio(tokenGroup) {
-				if tokenGroup != "auto" {
-					abortWithOpenAiMessage(c, http.StatusForbidden, fmt.Sprintf("分组 %s 已被弃用", tokenGroup))
-					return
-				}
-			}
-			userGroup = tokenGroup
+		usingGroup, err := resolveTokenUsingGroup(userCache.Group, token.Group)
+		if err != nil {
+			abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
+			return
 		}
-		common.SetContextKey(c, constant.ContextKeyUsingGroup, userGroup)
+		common.SetContextKey(c, constant.ContextKeyUsingGroup, usingGroup)
 
 		err = SetupContextForToken(c, token, parts...)
 		if err != nil {
 			return
 		}
+		// 令牌 RPM 在入口计入，免费模型、零预扣费等不经过预扣费的请求同样受限
+		if token.RpmLimit > 0 {
+			if err := service.CheckTokenRateLimit(c.Request.Context(), token.Id, token.RpmLimit, 0, 0); err != nil {
+				abortWithOpenAiMessage(c, http.StatusTooManyRequests, err.Error(), types.ErrorCodeRateLimitExceeded)
+				return
+			}
+		}
 		c.Next()
 	}
 }
 
+// resolveTokenUsingGroup 校验令牌分组对用户可用，返回请求实际使用的分组
+func resolveTokenUsingGroup(userGroup string, tokenGroup string) (string, error) {
+	if tokenGroup == "" {
+		return userGroup, nil
+	}
+	// check common.UserUsableGroups[userGroup]
+	if _, ok := service.GetUserUsableGroups(userGroup)[tokenGroup]; !ok {
+		return "", fmt.Errorf("无权访问 %s 分组", tokenGroup)
+	}
+	// check group in common.GroupRatio
+	if !ratio_setting.ContainsGroupRatio(tokenGroup) && tokenGroup != "auto" {
+		return "", fmt.Errorf("分组 %s 已被弃用", tokenGroup)
+	}
+	return tokenGroup, nil
+}
+
+// SetupContextForResolvedToken 为已通过校验的令牌写入用户与令牌上下文，分组校验与 TokenAuth 一致，
+// 供内部执行的请求（如批处理）直接进入 relay 管线而无需重新鉴权
+func SetupContextForResolvedToken(c *gin.Context, token *model.Token, userCache *model.UserBase) error {
+	userCache.WriteContext(c)
+	usingGroup, err := resolveTokenUsingGroup(userCache.Group, token.Group)
+	if err != nil {
+		return err
+	}
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, usingGroup)
+	return SetupContextForToken(c, token)
+}
+
 func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) error {
 	if token == nil {
 		return fmt.Errorf("token is nil")
@@ -464,6 +507,12 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, token.ResponseCache)
+	common.SetContextKey(c, constant.ContextKeyTokenQuotaBudget, token.HasQuotaBudget())
+	common.SetContextKey(c, constant.ContextKeyTokenRateLimit, token.TpmLimit > 0)
+	common.SetContextKey(c, constant.ContextKeyTokenTPMLimit, token.TpmLimit)
+	common.SetContextKey(c, constant.ContextKeyTokenSensitiveRuleSets, token.SensitiveRuleSets)
+	common.SetContextKey(c, constant.ContextKeyTokenOrganizationId, token.OrganizationId)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAuthEnforcesTokenRPM(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	originalDB, originalIsMasterNode, originalSQLitePath := model.DB, common.IsMasterNode, common.SQLitePath
+	t.Cleanup(func() {
+		model.DB, common.IsMasterNode, common.SQLitePath = originalDB, originalIsMasterNode, originalSQLitePath
+	})
+	common.RedisEnabled = false
+	common.IsMasterNode = false
+	common.SQLitePath = "file:token_auth_rpm?mode=memory&cache=shared"
+	common.SetDatabaseTypes(common.DatabaseTypeSQLite, common.DatabaseTypeSQLite)
+	t.Setenv("SQL_DSN", "local")
+	// InitDB 初始化各数据库方言的列名引用
+	require.NoError(t, model.InitDB())
+	db := model.DB
+	require.NoError(t, db.AutoMigrate(&model.User{}, &model.Token{}))
+
+	require.NoError(t, db.Create(&model.User{Id: 7, Username: "rpm-user", Password: "password", Status: common.UserStatusEnabled, Group: "default"}).Error)
+	require.NoError(t, db.Create(&model.Token{Id: 9107, UserId: 7, Key: "rpmtokenkey", Name: "rpm", Status: common.TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true, RpmLimit: 1}).Error)
+
+	router := gin.New()
+	router.Use(TokenAuth())
+	router.GET("/v1/models", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func() int {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer sk-rpmtokenkey")
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// RPM 在入口计入，不依赖后续的预扣费
+	assert.Equal(t, http.StatusOK, request())
+	assert.Equal(t, http.StatusTooManyRequests, request())
+}
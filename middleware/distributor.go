@@ -15,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
@@ -22,15 +23,27 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/tidwall/gjson"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ModelRequest struct {
 	Model string `json:"model"`
 	Group string `json:"group,omitempty"`
+
+	PreviousResponseId string `json:"previous_response_id,omitempty"`
+	// FileIds 请求中引用的 /v1/files 文件 ID，用于固定到上游文件所在渠道
+	FileIds []string `json:"-"`
 }
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		selectSpan := tracing.StartSpan(c, "distributor.select_channel")
+		defer func() {
+			// 选择渠道失败时 abort 后直接返回，正常路径已在 c.Next 前结束 span
+			if c.IsAborted() {
+				selectSpan.End(fmt.Errorf("channel selection failed with status %d", c.Writer.Status()))
+			}
+		}()
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -101,33 +114,71 @@ func Distribute() func(c *gin.Context) {
 					}
 				}
 
-				if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
-					affinityUsable := false
-					preferred, err := model.CacheGetChannel(preferredChannelID)
-					if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled &&
-						channelSupportsRequestPath(preferred, c.Request.URL.Path, modelRequest.Model) {
-						if usingGroup == "auto" {
-							userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
-							autoGroups := service.GetUserAutoGroup(userGroup)
-							for _, g := range autoGroups {
-								if model.IsChannelEnabledForGroupModel(g, modelRequest.Model, preferred.Id) {
-									selectGroup = g
-									common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
+				if modelRequest.PreviousResponseId != "" {
+					affinity, found := service.GetResponseAffinity(c.GetInt("id"), modelRequest.PreviousResponseId)
+					if found {
+						// 上一轮所在的渠道不可用时，其他渠道也无法读取该 response
+						preferred, err := model.CacheGetChannel(affinity.ChannelId)
+						g, usable := "", false
+						if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled {
+							if _, keyUsable := preferred.GetEnabledKeyByIndex(affinity.KeyIndex); keyUsable || affinity.KeyIndex < 0 {
+								g, usable = preferredChannelGroup(c, usingGroup, modelRequest.Model, preferred)
+							}
+						}
+						if !usable {
+							abortWithOpenAiMessage(c, http.StatusBadRequest, i18n.T(c, i18n.MsgDistributorPreviousResponseUnavailable, map[string]any{"ResponseId": modelRequest.PreviousResponseId}), types.ErrorCodePreviousResponseNotFound)
+							return
+						}
+						channel = preferred
+						selectGroup = g
+						service.MarkResponseAffinityUsed(c, affinity)
+					}
+				}
+
+				if len(modelRequest.FileIds) > 0 {
+					// 上游文件只能在上传时的渠道读取，直接固定到该渠道
+					fileChannelId, err := service.GetUpstreamFileChannel(c.GetInt("id"), modelRequest.FileIds)
+					if err != nil {
+						abortWithOpenAiMessage(c, http.StatusBadRequest, err.Error())
+						return
+					}
+					if fileChannelId > 0 {
+						// 已按 previous_response_id 固定渠道时，文件必须在同一渠道上
+						usable := channel != nil && channel.Id == fileChannelId
+						if channel == nil {
+							preferred, err := model.CacheGetChannel(fileChannelId)
+							if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled {
+								if g, ok := preferredChannelGroup(c, usingGroup, modelRequest.Model, preferred); ok {
 									channel = preferred
-									affinityUsable = true
-									service.MarkChannelAffinityUsed(c, g, preferred.Id)
-									break
+									selectGroup = g
+									usable = true
 								}
 							}
-						} else if model.IsChannelEnabledForGroupModel(usingGroup, modelRequest.Model, preferred.Id) {
-							channel = preferred
-							selectGroup = usingGroup
-							affinityUsable = true
-							service.MarkChannelAffinityUsed(c, usingGroup, preferred.Id)
 						}
+						if !usable {
+							abortWithOpenAiMessage(c, http.StatusBadRequest, i18n.T(c, i18n.MsgDistributorUpstreamFileUnavailable, map[string]any{"ChannelId": fileChannelId}))
+							return
+						}
+						service.MarkUpstreamFileChannelPinned(c)
 					}
-					if !affinityUsable && !service.ShouldKeepChannelAffinityOnChannelDisabled() {
-						service.ClearCurrentChannelAffinityCache(c)
+				}
+
+				if channel == nil {
+					if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
+						affinityUsable := false
+						preferred, err := model.CacheGetChannel(preferredChannelID)
+						if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled &&
+							channelSupportsRequestPath(preferred, c.Request.URL.Path, modelRequest.Model) {
+							if g, ok := preferredChannelGroup(c, usingGroup, modelRequest.Model, preferred); ok {
+								channel = preferred
+								selectGroup = g
+								affinityUsable = true
+								service.MarkChannelAffinityUsed(c, g, preferred.Id)
+							}
+						}
+						if !affinityUsable && !service.ShouldKeepChannelAffinityOnChannelDisabled() {
+							service.ClearCurrentChannelAffinityCache(c)
+						}
 					}
 				}
 
@@ -162,6 +213,14 @@ func Distribute() func(c *gin.Context) {
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
 		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		selectSpan.SetAttributes(
+			attribute.String("newapi.model", modelRequest.Model),
+			attribute.String("newapi.group", common.GetContextKeyString(c, constant.ContextKeyUsingGroup)),
+		)
+		if channel != nil {
+			selectSpan.SetAttributes(attribute.Int("newapi.channel_id", channel.Id))
+		}
+		selectSpan.End(nil)
 		c.Next()
 		if channel != nil && c.Writer != nil && c.Writer.Status() < http.StatusBadRequest {
 			service.RecordChannelAffinity(c, channel.Id)
@@ -169,6 +228,21 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// preferredChannelGroup 返回可以使用指定渠道的分组，auto 分组时依次检查自动分组
+func preferredChannelGroup(c *gin.Context, usingGroup string, modelName string, channel *model.Channel) (string, bool) {
+	if usingGroup != "auto" {
+		return usingGroup, model.IsChannelEnabledForGroupModel(usingGroup, modelName, channel.Id)
+	}
+	userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	for _, g := range service.GetUserAutoGroup(userGroup) {
+		if model.IsChannelEnabledForGroupModel(g, modelName, channel.Id) {
+			common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
+			return g, true
+		}
+	}
+	return "", false
+}
+
 // channelSupportsRequestPath reports whether a channel can serve the request path.
 // Only Advanced Custom (type 58) channels are path-checked; all other channel types
 // always pass. A type-58 channel is usable only when one of its routes matches.
@@ -218,7 +292,7 @@ func getModelFromJSONBody(c *gin.Context) (*ModelRequest, error) {
 		return nil, errors.New("invalid JSON request body")
 	}
 
-	values := gjson.GetManyBytes(requestBody, "model", "group")
+	values := gjson.GetManyBytes(requestBody, "model", "group", "previous_response_id")
 	model, err := getJSONStringValue(values[0], "model")
 	if err != nil {
 		return nil, err
@@ -227,6 +301,10 @@ func getModelFromJSONBody(c *gin.Context) (*ModelRequest, error) {
 	if err != nil {
 		return nil, err
 	}
+	previousResponseId := ""
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/responses") {
+		previousResponseId = values[2].String()
+	}
 
 	if _, seekErr := storage.Seek(0, io.SeekStart); seekErr != nil {
 		return nil, seekErr
@@ -234,11 +312,40 @@ func getModelFromJSONBody(c *gin.Context) (*ModelRequest, error) {
 	c.Request.Body = io.NopCloser(storage)
 
 	return &ModelRequest{
-		Model: model,
-		Group: group,
+		Model:              model,
+		Group:              group,
+		PreviousResponseId: previousResponseId,
+		FileIds:            getReferencedFileIds(requestBody),
 	}, nil
 }
 
+// getReferencedFileIds 收集 chat messages 与 Responses input 中以 file_id 引用的文件
+func getReferencedFileIds(requestBody []byte) []string {
+	var fileIds []string
+	collect := func(items gjson.Result, path string) {
+		if !items.IsArray() {
+			return
+		}
+		items.ForEach(func(_, item gjson.Result) bool {
+			content := item.Get("content")
+			if !content.IsArray() {
+				return true
+			}
+			content.ForEach(func(_, part gjson.Result) bool {
+				if fileId := part.Get(path).String(); strings.HasPrefix(fileId, "file-") {
+					fileIds = append(fileIds, fileId)
+				}
+				return true
+			})
+			return true
+		})
+	}
+	values := gjson.GetManyBytes(requestBody, "messages", "input")
+	collect(values[0], "file.file_id")
+	collect(values[1], "file_id")
+	return fileIds
+}
+
 func getJSONStringValue(result gjson.Result, field string) (string, error) {
 	if !result.Exists() || result.Type == gjson.Null {
 		return "", nil
@@ -349,6 +456,7 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 			return nil, false, err
 		}
 		modelRequest.Model = req.Model
+		modelRequest.PreviousResponseId = req.PreviousResponseId
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/realtime") {
 		//wss://api.openai.com/v1/realtime?model=gpt-4o-realtime-preview-2024-10-01
@@ -366,7 +474,7 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") {
 		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e")
-	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") {
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") || strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
 		//modelRequest.Model = common.GetStringIfEmpty(c.PostForm("model"), "gpt-image-1")
 		contentType := c.ContentType()
 		if slices.Contains([]string{gin.MIMEPOSTForm, gin.MIMEMultipartPOSTForm}, contentType) {
@@ -375,6 +483,10 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 				modelRequest.Model = req.Model
 			}
 		}
+		if strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+			// OpenAI 的变体接口 model 可选，仅支持 dall-e-2
+			modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e-2")
+		}
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		relayMode := relayconstant.RelayModeAudioSpeech
@@ -469,6 +581,12 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	if newAPIError != nil {
 		return newAPIError
 	}
+	// 有状态的 Responses 请求使用生成该 response 的 key
+	if pinnedIndex, ok := service.GetPinnedResponseKeyIndex(c, channel.Id); ok {
+		if pinnedKey, enabled := channel.GetEnabledKeyByIndex(pinnedIndex); enabled {
+			key, index = pinnedKey, pinnedIndex
+		}
+	}
 	if channel.ChannelInfo.IsMultiKey {
 		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
 		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, index)
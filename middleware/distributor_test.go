@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReferencedFileIds(t *testing.T) {
+	chat := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"},{"role":"user","content":[{"type":"text","text":"x"},{"type":"file","file":{"file_id":"file-a"}}]}]}`)
+	assert.Equal(t, []string{"file-a"}, getReferencedFileIds(chat))
+
+	responses := []byte(`{"model":"gpt-4o","input":[{"role":"user","content":[{"type":"input_file","file_id":"file-b"},{"type":"input_image","file_id":"file-c"},{"type":"input_file","file_id":"upstream-id"}]}]}`)
+	assert.Equal(t, []string{"file-b", "file-c"}, getReferencedFileIds(responses))
+
+	assert.Empty(t, getReferencedFileIds([]byte(`{"model":"gpt-4o","input":"hello"}`)))
+}
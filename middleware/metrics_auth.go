@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsAuth 保护 /metrics：未启用时返回 404；启用后需携带配置的 Bearer token，
+// 或来源 IP 位于 AllowedIPs 内。两者均未配置时拒绝所有抓取。
+func MetricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setting := operation_setting.GetMetricsSetting()
+		if !setting.Enabled {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if setting.Token != "" {
+			token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(setting.Token)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		if len(setting.AllowedIPs) > 0 {
+			if ip := common.ParseIP(c.ClientIP()); ip != nil && common.IsIpInCIDRList(ip, setting.AllowedIPs) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
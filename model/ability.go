@@ -122,8 +122,15 @@ func GetChannel(group string, model string, retry int, requestPath string) (*Cha
 		return nil, err
 	}
 	abilities = filterAbilitiesByRequestPathAndModel(abilities, requestPath, model)
+	abilities = filterAbilitiesByBreaker(abilities)
 	channel := Channel{}
-	if len(abilities) > 0 {
+	candidates := make([]channelCandidate, 0, len(abilities))
+	for _, ability_ := range abilities {
+		candidates = append(candidates, channelCandidate{id: ability_.ChannelId, weight: int(ability_.Weight)})
+	}
+	if channelId, ok := selectChannelByStrategy(group, candidates); ok {
+		channel.Id = channelId
+	} else if len(abilities) > 0 {
 		// Randomly choose one
 		weightSum := uint(0)
 		for _, ability_ := range abilities {
@@ -146,6 +153,28 @@ func GetChannel(group string, model string, retry int, requestPath string) (*Cha
 	return &channel, err
 }
 
+// filterAbilitiesByBreaker 过滤掉熔断中的渠道
+func filterAbilitiesByBreaker(abilities []Ability) []Ability {
+	if ChannelBreakerFilterFunc == nil || len(abilities) == 0 {
+		return abilities
+	}
+	channelIds := make([]int, 0, len(abilities))
+	for _, ability := range abilities {
+		channelIds = append(channelIds, ability.ChannelId)
+	}
+	allowed := make(map[int]struct{}, len(abilities))
+	for _, channelId := range filterChannelsByBreaker(channelIds) {
+		allowed[channelId] = struct{}{}
+	}
+	filtered := make([]Ability, 0, len(abilities))
+	for _, ability := range abilities {
+		if _, ok := allowed[ability.ChannelId]; ok {
+			filtered = append(filtered, ability)
+		}
+	}
+	return filtered
+}
+
 // filterAbilitiesByRequestPathAndModel restricts candidates by request path and
 // model for the DB (non-memory-cache) selection path. Only Advanced Custom
 // (type 58) channels are path-checked: kept only when one of their routes matches
@@ -0,0 +1,278 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusFailed     = "failed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+
+	BatchRequestStatusPending   = "pending"
+	BatchRequestStatusRunning   = "running"
+	BatchRequestStatusCompleted = "completed"
+	BatchRequestStatusFailed    = "failed"
+)
+
+// Batch 对应 /v1/batches 创建的批处理任务。
+// 输入文件在创建时拆分为 BatchRequest 行，执行进度全部落库，重启后可继续处理。
+type Batch struct {
+	Id               int    `json:"id"`
+	BatchId          string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	TokenId          int    `json:"token_id" gorm:"index"`
+	ClientIp         string `json:"-" gorm:"type:varchar(64)"`
+	Endpoint         string `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string `json:"output_file_id" gorm:"type:varchar(64)"`
+	ErrorFileId      string `json:"error_file_id" gorm:"type:varchar(64)"`
+	CompletionWindow string `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           string `json:"status" gorm:"type:varchar(20);index"`
+	Metadata         string `json:"metadata" gorm:"type:text"`
+	RequestTotal     int    `json:"request_total"`
+	RequestCompleted int    `json:"request_completed"`
+	RequestFailed    int    `json:"request_failed"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+	InProgressAt     int64  `json:"in_progress_at" gorm:"bigint"`
+	ExpiresAt        int64  `json:"expires_at" gorm:"bigint"`
+	FinalizingAt     int64  `json:"finalizing_at" gorm:"bigint"`
+	CompletedAt      int64  `json:"completed_at" gorm:"bigint"`
+	FailedAt         int64  `json:"failed_at" gorm:"bigint"`
+	ExpiredAt        int64  `json:"expired_at" gorm:"bigint"`
+	CancellingAt     int64  `json:"cancelling_at" gorm:"bigint"`
+	CancelledAt      int64  `json:"cancelled_at" gorm:"bigint"`
+}
+
+// BatchRequest 批处理中的单个请求（输入文件的一行）
+type BatchRequest struct {
+	Id         int    `json:"id"`
+	BatchId    string `json:"batch_id" gorm:"type:varchar(64);index:idx_batch_request_line,priority:1"`
+	LineIndex  int    `json:"line_index" gorm:"index:idx_batch_request_line,priority:2"`
+	CustomId   string `json:"custom_id" gorm:"type:varchar(255)"`
+	Method     string `json:"method" gorm:"type:varchar(16)"`
+	Url        string `json:"url" gorm:"type:varchar(64)"`
+	Body       string `json:"body" gorm:"type:text"`
+	Status     string `json:"status" gorm:"type:varchar(20);index"`
+	StatusCode int    `json:"status_code"`
+	RequestId  string `json:"request_id" gorm:"type:varchar(64)"`
+	Response   string `json:"response" gorm:"type:text"`
+	Error      string `json:"error" gorm:"type:text"`
+}
+
+var activeBatchStatuses = []string{BatchStatusInProgress, BatchStatusFinalizing, BatchStatusCancelling}
+
+// GenerateBatchId 生成对外暴露的 batch_xxxx 格式 ID
+func GenerateBatchId() (string, error) {
+	key, err := common.GenerateRandomCharsKey(24)
+	if err != nil {
+		return "", err
+	}
+	return "batch_" + key, nil
+}
+
+// IsBatchFinished 批处理是否已处于终态
+func IsBatchFinished(status string) bool {
+	switch status {
+	case BatchStatusCompleted, BatchStatusFailed, BatchStatusExpired, BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// CreateBatchWithRequests 在同一事务中写入批处理及其全部请求行
+func CreateBatchWithRequests(batch *Batch, requests []*BatchRequest) error {
+	if batch.CreatedAt == 0 {
+		batch.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		if len(requests) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(requests, 100).Error
+	})
+}
+
+// GetUserBatchByBatchId 获取用户自己的批处理，不存在时返回 (nil, nil)
+func GetUserBatchByBatchId(userId int, batchId string) (*Batch, error) {
+	var batch Batch
+	err := DB.Where("batch_id = ? AND user_id = ?", batchId, userId).First(&batch).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func GetBatchByBatchId(batchId string) (*Batch, error) {
+	var batch Batch
+	err := DB.Where("batch_id = ?", batchId).First(&batch).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ListUserBatches 按创建时间倒序列出用户批处理，after 为游标（上一页最后一个 batch_id）
+func ListUserBatches(userId int, after string, limit int) ([]*Batch, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	query := DB.Where("user_id = ?", userId)
+	if after != "" {
+		cursor, err := GetUserBatchByBatchId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		if cursor != nil {
+			query = query.Where("id < ?", cursor.Id)
+		}
+	}
+	var batches []*Batch
+	err := query.Order("id desc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// HasActiveBatches 是否存在需要后台处理的批处理
+func HasActiveBatches() bool {
+	var count int64
+	if err := DB.Model(&Batch{}).Where("status IN ?", activeBatchStatuses).Limit(1).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// FindActiveBatches 按创建顺序返回需要后台处理的批处理
+func FindActiveBatches(limit int) ([]*Batch, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	var batches []*Batch
+	err := DB.Where("status IN ?", activeBatchStatuses).Order("id asc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// UpdateBatchStatus 仅当批处理仍处于 fromStatuses 之一时更新状态，返回是否更新成功
+func UpdateBatchStatus(batchId string, fromStatuses []string, updates map[string]any) (bool, error) {
+	result := DB.Model(&Batch{}).
+		Where("batch_id = ? AND status IN ?", batchId, fromStatuses).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindPendingBatchRequests 按行号顺序返回待执行的请求
+func FindPendingBatchRequests(batchId string, limit int) ([]*BatchRequest, error) {
+	if limit <= 0 {
+		limit = 1
+	}
+	var requests []*BatchRequest
+	err := DB.Where("batch_id = ? AND status = ?", batchId, BatchRequestStatusPending).
+		Order("line_index asc").
+		Limit(limit).
+		Find(&requests).Error
+	return requests, err
+}
+
+// MarkBatchRequestsRunning 把请求标记为执行中，执行中断（进程重启）的请求不会被重复执行
+func MarkBatchRequestsRunning(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Model(&BatchRequest{}).
+		Where("id IN ? AND status = ?", ids, BatchRequestStatusPending).
+		Update("status", BatchRequestStatusRunning).Error
+}
+
+// FailInterruptedBatchRequests 把上次执行中断的请求标记为失败
+func FailInterruptedBatchRequests(batchId string, reason string) (int64, error) {
+	result := DB.Model(&BatchRequest{}).
+		Where("batch_id = ? AND status = ?", batchId, BatchRequestStatusRunning).
+		Updates(map[string]any{"status": BatchRequestStatusFailed, "error": reason})
+	return result.RowsAffected, result.Error
+}
+
+// FailPendingBatchRequests 把尚未执行的请求标记为失败（取消、过期时使用）
+func FailPendingBatchRequests(batchId string, reason string) (int64, error) {
+	result := DB.Model(&BatchRequest{}).
+		Where("batch_id = ? AND status IN ?", batchId, []string{BatchRequestStatusPending, BatchRequestStatusRunning}).
+		Updates(map[string]any{"status": BatchRequestStatusFailed, "error": reason})
+	return result.RowsAffected, result.Error
+}
+
+// SaveBatchRequestResult 保存单个请求的执行结果
+func SaveBatchRequestResult(request *BatchRequest) error {
+	return DB.Model(&BatchRequest{}).
+		Where("id = ?", request.Id).
+		Updates(map[string]any{
+			"status":      request.Status,
+			"status_code": request.StatusCode,
+			"request_id":  request.RequestId,
+			"response":    request.Response,
+			"error":       request.Error,
+		}).Error
+}
+
+// CountBatchRequestResults 统计已成功和已失败的请求数量
+func CountBatchRequestResults(batchId string) (completed int, failed int, err error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	err = DB.Model(&BatchRequest{}).
+		Select("status, COUNT(*) AS count").
+		Where("batch_id = ?", batchId).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, row := range rows {
+		switch row.Status {
+		case BatchRequestStatusCompleted:
+			completed = row.Count
+		case BatchRequestStatusFailed:
+			failed = row.Count
+		}
+	}
+	return completed, failed, nil
+}
+
+// ListBatchRequests 以 id 游标分页读取请求行，用于生成结果文件
+func ListBatchRequests(batchId string, afterId int, limit int) ([]*BatchRequest, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var requests []*BatchRequest
+	err := DB.Where("batch_id = ? AND id > ?", batchId, afterId).
+		Order("id asc").
+		Limit(limit).
+		Find(&requests).Error
+	return requests, err
+}
+
+// DeleteBatchRequests 结果文件生成后清理请求行
+func DeleteBatchRequests(batchId string) error {
+	return DB.Where("batch_id = ?", batchId).Delete(&BatchRequest{}).Error
+}
@@ -238,6 +238,12 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	if len(enabledIdx) == 0 {
 		return "", 0, types.NewError(errors.New("no enabled keys"), types.ErrorCodeChannelNoAvailableKey)
 	}
+	// 跳过熔断中的 key（只在选 key 时生效，不修改 key 状态）
+	enabledIdx = filterKeysByBreaker(channel.Id, enabledIdx)
+	selectable := make(map[int]struct{}, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		selectable[idx] = struct{}{}
+	}
 
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
@@ -268,7 +274,7 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}
 		for i := 0; i < len(keys); i++ {
 			idx := (start + i) % len(keys)
-			if getStatus(idx) == common.ChannelStatusEnabled {
+			if _, ok := selectable[idx]; ok {
 				// update polling index for next call (point to the next position)
 				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
 				return keys[idx], idx, nil
@@ -282,6 +288,21 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	}
 }
 
+// GetEnabledKeyByIndex 返回指定序号的 key，key 不存在或已禁用时返回 false
+func (channel *Channel) GetEnabledKeyByIndex(index int) (string, bool) {
+	if !channel.ChannelInfo.IsMultiKey {
+		return channel.Key, true
+	}
+	keys := channel.GetKeys()
+	if index < 0 || index >= len(keys) {
+		return "", false
+	}
+	if status, ok := channel.ChannelInfo.MultiKeyStatusList[index]; ok && status != common.ChannelStatusEnabled {
+		return "", false
+	}
+	return keys[index], true
+}
+
 func (channel *Channel) SaveChannelInfo() error {
 	return DB.Model(channel).Update("channel_info", channel.ChannelInfo).Error
 }
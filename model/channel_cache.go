@@ -129,6 +129,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 		channels = filterChannelsByRequestPathAndModel(group2model2channels[group][normalizedModel], requestPath, model)
 	}
 
+	channels = filterChannelsByBreaker(channels)
 	if len(channels) == 0 {
 		return nil, nil
 	}
@@ -177,6 +178,14 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 		return nil, errors.New(fmt.Sprintf("no channel found, group: %s, model: %s, priority: %d", group, model, targetPriority))
 	}
 
+	candidates := make([]channelCandidate, 0, len(targetChannels))
+	for _, channel := range targetChannels {
+		candidates = append(candidates, channelCandidate{id: channel.Id, weight: channel.GetWeight()})
+	}
+	if channelId, ok := selectChannelByStrategy(group, candidates); ok {
+		return channelsIDM[channelId], nil
+	}
+
 	// smoothing factor and adjustment
 	smoothingFactor := 1
 	smoothingAdjustment := 0
@@ -0,0 +1,177 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// ChannelLoad 渠道的实时负载快照，由 perf_metrics 在本节点内存中维护
+type ChannelLoad struct {
+	// Inflight 进行中的上游请求数
+	Inflight int64
+	// LatencyMs / TtftMs 近期成功请求的 EWMA 延迟，HasLatency / HasTtft 为 false 表示暂无有效样本
+	LatencyMs  float64
+	HasLatency bool
+	TtftMs     float64
+	HasTtft    bool
+	// ErrorRate 近期失败率的 EWMA，取值 [0, 1]
+	ErrorRate float64
+}
+
+// ChannelLoadFunc 获取渠道实时负载，由 perf_metrics 初始化时注入（model 不能直接依赖 perf_metrics）。
+// 未注入时所有负载感知策略退化为加权随机。
+var ChannelLoadFunc func(channelId int) ChannelLoad
+
+// ChannelBreakerFilterFunc 过滤掉熔断中的渠道，ChannelKeyBreakerFilterFunc 过滤掉多 key 渠道中熔断中的 key。
+// 由 main 注入 service 的实现，未注入时不做过滤。
+var ChannelBreakerFilterFunc func(channelIds []int) []int
+var ChannelKeyBreakerFilterFunc func(channelId int, keyIndexes []int) []int
+
+func filterChannelsByBreaker(channelIds []int) []int {
+	if ChannelBreakerFilterFunc == nil || len(channelIds) == 0 {
+		return channelIds
+	}
+	return ChannelBreakerFilterFunc(channelIds)
+}
+
+// filterKeysByBreaker 过滤熔断中的 key，全部熔断时保留原列表（由渠道级熔断负责整体跳过）
+func filterKeysByBreaker(channelId int, keyIndexes []int) []int {
+	if ChannelKeyBreakerFilterFunc == nil || len(keyIndexes) == 0 {
+		return keyIndexes
+	}
+	if filtered := ChannelKeyBreakerFilterFunc(channelId, keyIndexes); len(filtered) > 0 {
+		return filtered
+	}
+	return keyIndexes
+}
+
+type channelCandidate struct {
+	id     int
+	weight int
+}
+
+// selectChannelByStrategy 按分组策略从同一优先级的候选渠道中选出一个，返回 false 表示沿用加权随机
+func selectChannelByStrategy(group string, candidates []channelCandidate) (int, bool) {
+	if len(candidates) == 0 || ChannelLoadFunc == nil {
+		return 0, false
+	}
+	strategy := operation_setting.GetChannelSelectStrategy(group)
+	if strategy == operation_setting.ChannelSelectStrategyWeightedRandom {
+		return 0, false
+	}
+	if len(candidates) == 1 {
+		return candidates[0].id, true
+	}
+	loads := make([]ChannelLoad, len(candidates))
+	for i, candidate := range candidates {
+		loads[i] = ChannelLoadFunc(candidate.id)
+	}
+
+	switch strategy {
+	case operation_setting.ChannelSelectStrategyLeastInflight:
+		return pickLowestCost(candidates, func(i int) float64 {
+			return float64(loads[i].Inflight)
+		}), true
+	case operation_setting.ChannelSelectStrategyLeastLatency:
+		cost := latencyCostFunc(loads)
+		return pickLowestCost(candidates, cost), true
+	case operation_setting.ChannelSelectStrategyP2C:
+		cost := latencyCostFunc(loads)
+		first := pickWeightedIndex(candidates, -1)
+		second := pickWeightedIndex(candidates, first)
+		if cost(second) < cost(first) {
+			return candidates[second].id, true
+		}
+		return candidates[first].id, true
+	}
+	return 0, false
+}
+
+// latencyCostFunc 返回基于延迟的负载代价：延迟 × (进行中请求数 + 1) × (1 + 惩罚系数 × 失败率)。
+// 没有有效样本的渠道按其余候选的平均延迟估算，既能获得探测流量又不会被无限偏好。
+func latencyCostFunc(loads []ChannelLoad) func(i int) float64 {
+	setting := operation_setting.GetChannelSelectSetting()
+	useTtft := setting.LatencyMetric != operation_setting.ChannelSelectLatencyMetricLatency
+	latencies := make([]float64, len(loads))
+	known := make([]bool, len(loads))
+	sum, count := 0.0, 0
+	for i, load := range loads {
+		switch {
+		case useTtft && load.HasTtft:
+			latencies[i], known[i] = load.TtftMs, true
+		case load.HasLatency:
+			latencies[i], known[i] = load.LatencyMs, true
+		}
+		if known[i] {
+			sum += latencies[i]
+			count++
+		}
+	}
+	avg := 1.0
+	if count > 0 {
+		avg = math.Max(sum/float64(count), 1)
+	}
+	penalty := math.Max(setting.ErrorPenalty, 0)
+	return func(i int) float64 {
+		latency := avg
+		if known[i] {
+			latency = math.Max(latencies[i], 1)
+		}
+		load := loads[i]
+		return latency * float64(load.Inflight+1) * (1 + penalty*load.ErrorRate)
+	}
+}
+
+// pickLowestCost 选出代价最低的候选，代价相同时随机选择，避免流量集中到固定渠道
+func pickLowestCost(candidates []channelCandidate, cost func(i int) float64) int {
+	best := -1
+	bestCost := 0.0
+	ties := 0
+	for i := range candidates {
+		c := cost(i)
+		switch {
+		case best < 0 || c < bestCost:
+			best, bestCost, ties = i, c, 1
+		case c == bestCost:
+			ties++
+			if rand.Intn(ties) == 0 {
+				best = i
+			}
+		}
+	}
+	return candidates[best].id
+}
+
+// pickWeightedIndex 按权重随机选出一个候选下标，exclude 为需要排除的下标（-1 表示不排除）。
+// 全部权重为 0 时等概率选择。
+func pickWeightedIndex(candidates []channelCandidate, exclude int) int {
+	sumWeight := 0
+	for i, candidate := range candidates {
+		if i != exclude {
+			sumWeight += candidate.weight
+		}
+	}
+	if sumWeight <= 0 {
+		if exclude < 0 {
+			return rand.Intn(len(candidates))
+		}
+		index := rand.Intn(len(candidates) - 1)
+		if index >= exclude {
+			index++
+		}
+		return index
+	}
+	randomWeight := rand.Intn(sumWeight)
+	for i, candidate := range candidates {
+		if i == exclude {
+			continue
+		}
+		randomWeight -= candidate.weight
+		if randomWeight < 0 {
+			return i
+		}
+	}
+	return len(candidates) - 1
+}
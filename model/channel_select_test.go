@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withChannelSelectStrategy(t *testing.T, strategy string, loads map[int]ChannelLoad) {
+	t.Helper()
+	setting := operation_setting.GetChannelSelectSetting()
+	oldStrategy, oldFunc := setting.DefaultStrategy, ChannelLoadFunc
+	setting.DefaultStrategy = strategy
+	ChannelLoadFunc = func(channelId int) ChannelLoad {
+		return loads[channelId]
+	}
+	t.Cleanup(func() {
+		setting.DefaultStrategy = oldStrategy
+		ChannelLoadFunc = oldFunc
+	})
+}
+
+func TestSelectChannelByStrategyWeightedRandomFallsThrough(t *testing.T) {
+	withChannelSelectStrategy(t, operation_setting.ChannelSelectStrategyWeightedRandom, nil)
+	_, ok := selectChannelByStrategy("default", []channelCandidate{{id: 1}, {id: 2}})
+	assert.False(t, ok)
+}
+
+func TestSelectChannelByStrategyLeastInflight(t *testing.T) {
+	withChannelSelectStrategy(t, operation_setting.ChannelSelectStrategyLeastInflight, map[int]ChannelLoad{
+		1: {Inflight: 5},
+		2: {Inflight: 1},
+		3: {Inflight: 3},
+	})
+	for i := 0; i < 20; i++ {
+		id, ok := selectChannelByStrategy("default", []channelCandidate{{id: 1}, {id: 2}, {id: 3}})
+		assert.True(t, ok)
+		assert.Equal(t, 2, id)
+	}
+}
+
+func TestSelectChannelByStrategyLeastLatency(t *testing.T) {
+	withChannelSelectStrategy(t, operation_setting.ChannelSelectStrategyLeastLatency, map[int]ChannelLoad{
+		// 首字延迟更低
+		1: {TtftMs: 200, HasTtft: true, LatencyMs: 5000, HasLatency: true},
+		2: {TtftMs: 800, HasTtft: true, LatencyMs: 1000, HasLatency: true},
+		// 延迟最低但负载高
+		3: {TtftMs: 100, HasTtft: true, Inflight: 4},
+		// 延迟低但近期全部失败
+		4: {TtftMs: 150, HasTtft: true, ErrorRate: 1},
+	})
+	candidates := []channelCandidate{{id: 1}, {id: 2}, {id: 3}, {id: 4}}
+	id, ok := selectChannelByStrategy("default", candidates)
+	assert.True(t, ok)
+	assert.Equal(t, 1, id)
+
+	operation_setting.GetChannelSelectSetting().LatencyMetric = operation_setting.ChannelSelectLatencyMetricLatency
+	t.Cleanup(func() {
+		operation_setting.GetChannelSelectSetting().LatencyMetric = operation_setting.ChannelSelectLatencyMetricTtft
+	})
+	id, _ = selectChannelByStrategy("default", candidates[:2])
+	assert.Equal(t, 2, id)
+}
+
+func TestSelectChannelByStrategyP2C(t *testing.T) {
+	withChannelSelectStrategy(t, operation_setting.ChannelSelectStrategyP2C, map[int]ChannelLoad{
+		1: {LatencyMs: 100, HasLatency: true},
+		2: {LatencyMs: 1000, HasLatency: true},
+	})
+	// 只有两个候选时 P2C 总会比较二者
+	for i := 0; i < 20; i++ {
+		id, ok := selectChannelByStrategy("default", []channelCandidate{{id: 1, weight: 1}, {id: 2, weight: 100}})
+		assert.True(t, ok)
+		assert.Equal(t, 1, id)
+	}
+}
+
+func TestGetChannelSelectStrategyPerGroup(t *testing.T) {
+	setting := operation_setting.GetChannelSelectSetting()
+	setting.GroupStrategies = map[string]string{"vip": operation_setting.ChannelSelectStrategyP2C, "bad": "unknown"}
+	t.Cleanup(func() { setting.GroupStrategies = map[string]string{} })
+	assert.Equal(t, operation_setting.ChannelSelectStrategyP2C, operation_setting.GetChannelSelectStrategy("vip"))
+	assert.Equal(t, operation_setting.ChannelSelectStrategyWeightedRandom, operation_setting.GetChannelSelectStrategy("bad"))
+	assert.Equal(t, operation_setting.ChannelSelectStrategyWeightedRandom, operation_setting.GetChannelSelectStrategy("default"))
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+const (
+	FineTuningJobStatusValidatingFiles = "validating_files"
+	FineTuningJobStatusQueued          = "queued"
+	FineTuningJobStatusRunning         = "running"
+	FineTuningJobStatusSucceeded       = "succeeded"
+	FineTuningJobStatusFailed          = "failed"
+	FineTuningJobStatusCancelled       = "cancelled"
+)
+
+// FineTuningJob 记录代理到上游的微调任务。
+// 任务与渠道、用户绑定，后续查询/取消固定走创建时的渠道；
+// 轮询到终态后按训练 token 计费一次（BilledAt 非 0 表示已计费）。
+type FineTuningJob struct {
+	Id                       int    `json:"id"`
+	JobId                    string `json:"job_id" gorm:"type:varchar(191);uniqueIndex"`
+	UserId                   int    `json:"user_id" gorm:"index"`
+	TokenId                  int    `json:"token_id" gorm:"index"`
+	OrganizationId           int    `json:"organization_id" gorm:"index"`
+	Group                    string `json:"group" gorm:"type:varchar(64)"`
+	ChannelId                int    `json:"channel_id" gorm:"index"`
+	Model                    string `json:"model" gorm:"type:varchar(191)"`
+	FineTunedModel           string `json:"fine_tuned_model" gorm:"type:varchar(255)"`
+	TrainingFileId           string `json:"training_file_id" gorm:"type:varchar(64)"`
+	UpstreamTrainingFileId   string `json:"-" gorm:"type:varchar(191)"`
+	ValidationFileId         string `json:"validation_file_id" gorm:"type:varchar(64)"`
+	UpstreamValidationFileId string `json:"-" gorm:"type:varchar(191)"`
+	Status                   string `json:"status" gorm:"type:varchar(32);index"`
+	TrainedTokens            int    `json:"trained_tokens"`
+	Quota                    int    `json:"quota"`
+	Data                     string `json:"-" gorm:"type:text"`
+	CreatedAt                int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt                int64  `json:"updated_at" gorm:"bigint;index"`
+	FinishTime               int64  `json:"finish_time" gorm:"bigint"`
+	BilledAt                 int64  `json:"billed_at" gorm:"bigint"`
+}
+
+var unfinishedFineTuningJobStatuses = []string{
+	FineTuningJobStatusValidatingFiles,
+	FineTuningJobStatusQueued,
+	FineTuningJobStatusRunning,
+}
+
+// IsFineTuningJobFinished 微调任务是否已处于终态
+func IsFineTuningJobFinished(status string) bool {
+	switch status {
+	case FineTuningJobStatusSucceeded, FineTuningJobStatusFailed, FineTuningJobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (job *FineTuningJob) Insert() error {
+	now := common.GetTimestamp()
+	if job.CreatedAt == 0 {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+	return DB.Create(job).Error
+}
+
+// UpdateWithStatus 仅当任务仍处于 oldStatus 时更新（CAS），返回是否更新成功。
+// 轮询与用户查询/取消可能并发推进同一任务，终态计费依赖此 CAS 只执行一次。
+func (job *FineTuningJob) UpdateWithStatus(oldStatus string) (bool, error) {
+	job.UpdatedAt = common.GetTimestamp()
+	result := DB.Model(&FineTuningJob{}).
+		Where("id = ? AND status = ?", job.Id, oldStatus).
+		Updates(map[string]any{
+			"status":           job.Status,
+			"fine_tuned_model": job.FineTunedModel,
+			"trained_tokens":   job.TrainedTokens,
+			"data":             job.Data,
+			"updated_at":       job.UpdatedAt,
+			"finish_time":      job.FinishTime,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ChargeAndMarkBilled 在同一事务中标记已计费并从资金来源扣费，返回是否由本次调用完成。
+// 扣费失败时不会留下计费标记，下一轮轮询会重试；组织令牌创建的任务从组织钱包扣费并计入成员月度用量。
+func (job *FineTuningJob) ChargeAndMarkBilled(quota int) (bool, error) {
+	now := common.GetTimestamp()
+	won := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&FineTuningJob{}).
+			Where("id = ? AND billed_at = ?", job.Id, 0).
+			Updates(map[string]any{"quota": quota, "billed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		won = true
+		if quota <= 0 {
+			return nil
+		}
+		if job.OrganizationId > 0 {
+			return adjustOrganizationUsage(tx, job.OrganizationId, job.UserId, quota)
+		}
+		return tx.Model(&User{}).Where("id = ?", job.UserId).Update("quota", gorm.Expr("quota - ?", quota)).Error
+	})
+	if err != nil || !won {
+		return false, err
+	}
+	if quota > 0 && job.OrganizationId == 0 {
+		gopool.Go(func() {
+			if err := cacheDecrUserQuota(job.UserId, int64(quota)); err != nil {
+				common.SysLog("failed to decrease user quota cache: " + err.Error())
+			}
+		})
+	}
+	job.Quota = quota
+	job.BilledAt = now
+	return true, nil
+}
+
+// GetUserFineTuningJob 获取用户自己的微调任务，不存在时返回 (nil, nil)
+func GetUserFineTuningJob(userId int, jobId string) (*FineTuningJob, error) {
+	var job FineTuningJob
+	err := DB.Where("job_id = ? AND user_id = ?", jobId, userId).First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListUserFineTuningJobs 按创建时间倒序列出用户的微调任务，after 为游标（上一页最后一个 job_id）
+func ListUserFineTuningJobs(userId int, after string, limit int) ([]*FineTuningJob, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	query := DB.Where("user_id = ?", userId)
+	if after != "" {
+		cursor, err := GetUserFineTuningJob(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		if cursor != nil {
+			query = query.Where("id < ?", cursor.Id)
+		}
+	}
+	var jobs []*FineTuningJob
+	err := query.Order("id desc").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+var finishedFineTuningJobStatuses = []string{
+	FineTuningJobStatusSucceeded,
+	FineTuningJobStatusFailed,
+	FineTuningJobStatusCancelled,
+}
+
+// HasUnfinishedFineTuningJobs 是否存在需要轮询（未完成或已完成但未计费）的微调任务
+func HasUnfinishedFineTuningJobs() bool {
+	var id int64
+	err := DB.Model(&FineTuningJob{}).
+		Where("status IN ? OR (status IN ? AND billed_at = ?)", unfinishedFineTuningJobStatuses, finishedFineTuningJobStatuses, 0).
+		Limit(1).
+		Pluck("id", &id).Error
+	return err == nil && id != 0
+}
+
+// GetFineTuningJobsToPoll 返回未完成且 updatedBefore 之前未刷新的任务，以及已完成但尚未计费的任务
+func GetFineTuningJobsToPoll(updatedBefore int64, limit int) ([]*FineTuningJob, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var jobs []*FineTuningJob
+	err := DB.Where("(status IN ? AND updated_at < ?) OR (status IN ? AND billed_at = ?)",
+		unfinishedFineTuningJobStatuses, updatedBefore, finishedFineTuningJobStatuses, 0).
+		Order("updated_at asc").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
@@ -299,8 +299,15 @@ func migrateDB() error {
 		&SystemInstance{},
 		&SystemTask{},
 		&SystemTaskLock{},
+		&UserFile{},
+		&Batch{},
+		&BatchRequest{},
+		&FineTuningJob{},
 		&CasbinRule{},
 		&AuthzRole{},
+		&SensitiveHit{},
+		&Organization{},
+		&OrganizationMember{},
 	)
 	if err != nil {
 		return err
@@ -355,6 +362,13 @@ func migrateDBFast() error {
 		{&SystemInstance{}, "SystemInstance"},
 		{&SystemTask{}, "SystemTask"},
 		{&SystemTaskLock{}, "SystemTaskLock"},
+		{&UserFile{}, "UserFile"},
+		{&Batch{}, "Batch"},
+		{&BatchRequest{}, "BatchRequest"},
+		{&FineTuningJob{}, "FineTuningJob"},
+		{&SensitiveHit{}, "SensitiveHit"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
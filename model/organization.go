@@ -0,0 +1,488 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"gorm.io/gorm"
+)
+
+// 组织成员角色
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+var (
+	ErrOrganizationNotFound          = errors.New("organization not found")
+	ErrOrganizationDisabled          = errors.New("organization is disabled")
+	ErrOrganizationMemberNotFound    = errors.New("organization member not found")
+	ErrOrganizationMemberExists      = errors.New("user is already a member of the organization")
+	ErrOrganizationRoleInvalid       = errors.New("invalid organization role")
+	ErrOrganizationOwnerImmutable    = errors.New("organization owner cannot be removed or changed")
+	ErrOrganizationQuotaInsufficient = errors.New("organization quota insufficient")
+)
+
+// OrganizationMemberLimitExceededError 成员月度额度上限不足
+type OrganizationMemberLimitExceededError struct {
+	Limit     int
+	Used      int
+	ResetTime int64
+}
+
+func (e *OrganizationMemberLimitExceededError) Error() string {
+	return fmt.Sprintf("organization member monthly limit exceeded: used %s of %s, resets at %s",
+		logger.FormatQuota(e.Used), logger.FormatQuota(e.Limit),
+		time.Unix(e.ResetTime, 0).Format("2006-01-02 15:04:05"))
+}
+
+// Organization 组织，成员共享组织钱包额度
+type Organization struct {
+	Id          int            `json:"id"`
+	Name        string         `json:"name" gorm:"size:64;index"`
+	Description string         `json:"description" gorm:"type:varchar(255);default:''"`
+	OwnerId     int            `json:"owner_id" gorm:"index"`
+	Status      int            `json:"status" gorm:"default:1"`
+	Quota       int            `json:"quota" gorm:"default:0"`      // 组织钱包剩余额度
+	UsedQuota   int            `json:"used_quota" gorm:"default:0"` // 组织累计消耗额度
+	CreatedTime int64          `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64          `json:"updated_time" gorm:"bigint"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// OrganizationMember 组织成员，MonthlyQuotaLimit 为 0 表示不限制
+type OrganizationMember struct {
+	Id                int    `json:"id"`
+	OrganizationId    int    `json:"organization_id" gorm:"uniqueIndex:idx_org_member_user,priority:1"`
+	UserId            int    `json:"user_id" gorm:"uniqueIndex:idx_org_member_user,priority:2;index"`
+	Role              string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	MonthlyQuotaLimit int    `json:"monthly_quota_limit" gorm:"default:0"`
+	MonthlyUsedQuota  int    `json:"monthly_used_quota" gorm:"default:0"`
+	MonthlyResetTime  int64  `json:"monthly_reset_time" gorm:"bigint;default:0"` // 下次重置时间
+	CreatedTime       int64  `json:"created_time" gorm:"bigint"`
+	Username          string `json:"username" gorm:"-:all"`
+	DisplayName       string `json:"display_name" gorm:"-:all"`
+}
+
+// UserOrganization 用户所在组织及其成员信息
+type UserOrganization struct {
+	Organization
+	Role              string `json:"role"`
+	MonthlyQuotaLimit int    `json:"monthly_quota_limit"`
+	MonthlyUsedQuota  int    `json:"monthly_used_quota"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanManage 组织所有者和管理员可以管理成员、查看组织用量
+func (m *OrganizationMember) CanManage() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin
+}
+
+// CreateOrganization 创建组织并将创建者设为所有者
+func CreateOrganization(org *Organization) error {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return errors.New("organization name is required")
+	}
+	now := common.GetTimestamp()
+	org.Status = OrganizationStatusEnabled
+	org.UsedQuota = 0
+	org.CreatedTime = now
+	org.UpdatedTime = now
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         org.OwnerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    now,
+		}).Error
+	})
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var org Organization
+	if err := DB.Where("id = ?", id).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetAllOrganizations 分页查询组织，keyword 按名称模糊匹配
+func GetAllOrganizations(pageInfo *common.PageInfo, keyword string) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&orgs).Error
+	return orgs, total, err
+}
+
+// GetUserOrganizations 查询用户加入的所有组织
+func GetUserOrganizations(userId int) ([]*UserOrganization, error) {
+	var members []OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return []*UserOrganization{}, nil
+	}
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.OrganizationId)
+	}
+	var orgs []Organization
+	if err := DB.Where("id IN ?", ids).Order("id asc").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	memberByOrg := make(map[int]OrganizationMember, len(members))
+	for _, member := range members {
+		memberByOrg[member.OrganizationId] = member
+	}
+	now := common.GetTimestamp()
+	result := make([]*UserOrganization, 0, len(orgs))
+	for _, org := range orgs {
+		member := memberByOrg[org.Id]
+		rollOrganizationMemberWindow(&member, now)
+		result = append(result, &UserOrganization{
+			Organization:      org,
+			Role:              member.Role,
+			MonthlyQuotaLimit: member.MonthlyQuotaLimit,
+			MonthlyUsedQuota:  member.MonthlyUsedQuota,
+		})
+	}
+	return result, nil
+}
+
+// UpdateOrganization 更新组织名称、描述和状态
+func UpdateOrganization(org *Organization) error {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return errors.New("organization name is required")
+	}
+	if org.Status != OrganizationStatusEnabled && org.Status != OrganizationStatusDisabled {
+		org.Status = OrganizationStatusEnabled
+	}
+	result := DB.Model(&Organization{}).Where("id = ?", org.Id).Updates(map[string]interface{}{
+		"name":         org.Name,
+		"description":  org.Description,
+		"status":       org.Status,
+		"updated_time": common.GetTimestamp(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+// DeleteOrganization 删除组织及其成员关系，组织令牌随之失效
+func DeleteOrganization(id int) error {
+	// 先成员后组织，与额度预扣/结算的加锁顺序一致
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&Organization{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationNotFound
+		}
+		return nil
+	})
+}
+
+// AdjustOrganizationQuota 管理员调整组织钱包额度，delta 为负表示扣减
+func AdjustOrganizationQuota(id int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var org Organization
+		if err := lockForUpdate(tx).Where("id = ?", id).First(&org).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return err
+		}
+		if org.Quota+delta < 0 {
+			return ErrOrganizationQuotaInsufficient
+		}
+		return tx.Model(&Organization{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", delta)).Error
+	})
+}
+
+// TransferUserQuotaToOrganization 成员将个人钱包额度转入组织钱包
+func TransferUserQuotaToOrganization(userId int, orgId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("transfer quota must be positive")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := lockForUpdate(tx).Select("id", "quota").Where("id = ?", userId).First(&user).Error; err != nil {
+			return err
+		}
+		if user.Quota < quota {
+			return errors.New("user quota insufficient")
+		}
+		result := tx.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationNotFound
+		}
+		return tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota - ?", quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+	// 个人额度已变动，刷新缓存
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysLog(fmt.Sprintf("failed to invalidate user cache (userId=%d): %s", userId, err.Error()))
+	}
+	return nil
+}
+
+// GetOrganizationMember 查询单个成员，并按当前时间滚动月度用量
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	if err := DB.Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationMemberNotFound
+		}
+		return nil, err
+	}
+	rollOrganizationMemberWindow(&member, common.GetTimestamp())
+	return &member, nil
+}
+
+// GetOrganizationMembers 查询组织的全部成员，并补充用户名
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("organization_id = ?", orgId).Order("id asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return members, nil
+	}
+	userIds := make([]int, 0, len(members))
+	for _, member := range members {
+		userIds = append(userIds, member.UserId)
+	}
+	var users []User
+	if err := DB.Select("id", "username", "display_name").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	userById := make(map[int]User, len(users))
+	for _, user := range users {
+		userById[user.Id] = user
+	}
+	now := common.GetTimestamp()
+	for _, member := range members {
+		rollOrganizationMemberWindow(member, now)
+		member.Username = userById[member.UserId].Username
+		member.DisplayName = userById[member.UserId].DisplayName
+	}
+	return members, nil
+}
+
+// AddOrganizationMember 添加成员，所有者只能在创建组织时产生
+func AddOrganizationMember(orgId int, userId int, role string, monthlyQuotaLimit int) error {
+	if role == "" {
+		role = OrganizationRoleMember
+	}
+	if !IsValidOrganizationRole(role) || role == OrganizationRoleOwner {
+		return ErrOrganizationRoleInvalid
+	}
+	if monthlyQuotaLimit < 0 {
+		return errors.New("monthly quota limit cannot be negative")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&User{}).Where("id = ?", userId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("user not found")
+		}
+		if err := tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgId, userId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrOrganizationMemberExists
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId:    orgId,
+			UserId:            userId,
+			Role:              role,
+			MonthlyQuotaLimit: monthlyQuotaLimit,
+			CreatedTime:       common.GetTimestamp(),
+		}).Error
+	})
+}
+
+// UpdateOrganizationMember 修改成员角色和月度额度上限，所有者角色不可变更
+func UpdateOrganizationMember(orgId int, userId int, role string, monthlyQuotaLimit int) error {
+	if !IsValidOrganizationRole(role) || role == OrganizationRoleOwner {
+		return ErrOrganizationRoleInvalid
+	}
+	if monthlyQuotaLimit < 0 {
+		return errors.New("monthly quota limit cannot be negative")
+	}
+	member, err := GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return err
+	}
+	if member.Role == OrganizationRoleOwner {
+		return ErrOrganizationOwnerImmutable
+	}
+	return DB.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(map[string]interface{}{
+		"role":                role,
+		"monthly_quota_limit": monthlyQuotaLimit,
+	}).Error
+}
+
+// RemoveOrganizationMember 移除成员，其名下的组织令牌将无法继续计费
+func RemoveOrganizationMember(orgId int, userId int) error {
+	member, err := GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return err
+	}
+	if member.Role == OrganizationRoleOwner {
+		return ErrOrganizationOwnerImmutable
+	}
+	return DB.Where("id = ?", member.Id).Delete(&OrganizationMember{}).Error
+}
+
+// rollOrganizationMemberWindow 月度周期到期后清零已用额度并计算下次重置时间
+func rollOrganizationMemberWindow(member *OrganizationMember, now int64) {
+	if member.MonthlyResetTime > 0 && member.MonthlyResetTime > now {
+		return
+	}
+	if member.MonthlyResetTime > 0 {
+		member.MonthlyUsedQuota = 0
+	}
+	member.MonthlyResetTime = calcNextPeriodResetTime(time.Unix(now, 0), SubscriptionResetMonthly, 0)
+}
+
+// ReserveOrganizationQuota 从组织钱包预扣额度并计入成员月度用量。
+// quota 为 0 时只检查组织状态、余额和成员上限，不做扣减。
+func ReserveOrganizationQuota(orgId int, userId int, quota int) error {
+	if quota < 0 {
+		return errors.New("reserve quota cannot be negative")
+	}
+	now := common.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var member OrganizationMember
+		if err := lockForUpdate(tx).Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationMemberNotFound
+			}
+			return err
+		}
+		var org Organization
+		if err := lockForUpdate(tx).Select("id", "status", "quota").Where("id = ?", orgId).First(&org).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return err
+		}
+		if org.Status != OrganizationStatusEnabled {
+			return ErrOrganizationDisabled
+		}
+		rollOrganizationMemberWindow(&member, now)
+		if member.MonthlyQuotaLimit > 0 && (member.MonthlyUsedQuota >= member.MonthlyQuotaLimit || member.MonthlyUsedQuota+quota > member.MonthlyQuotaLimit) {
+			return &OrganizationMemberLimitExceededError{
+				Limit:     member.MonthlyQuotaLimit,
+				Used:      member.MonthlyUsedQuota,
+				ResetTime: member.MonthlyResetTime,
+			}
+		}
+		if org.Quota <= 0 || org.Quota < quota {
+			return fmt.Errorf("%w: remaining %s, need %s", ErrOrganizationQuotaInsufficient,
+				logger.FormatQuota(org.Quota), logger.FormatQuota(quota))
+		}
+		if quota > 0 {
+			if err := tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+				"quota":      gorm.Expr("quota - ?", quota),
+				"used_quota": gorm.Expr("used_quota + ?", quota),
+			}).Error; err != nil {
+				return err
+			}
+			member.MonthlyUsedQuota += quota
+		}
+		return tx.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(map[string]interface{}{
+			"monthly_used_quota": member.MonthlyUsedQuota,
+			"monthly_reset_time": member.MonthlyResetTime,
+		}).Error
+	})
+}
+
+// AdjustOrganizationUsage 按实际消耗调整组织钱包和成员月度用量（delta 为负表示退还）。
+// 结算阶段不再检查余额和上限，与钱包结算一致，允许少量透支。
+// 与 ReserveOrganizationQuota 一样先写成员行再写组织行，避免并发请求交叉加锁导致死锁。
+func AdjustOrganizationUsage(orgId int, userId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return adjustOrganizationUsage(tx, orgId, userId, delta)
+	})
+}
+
+func adjustOrganizationUsage(tx *gorm.DB, orgId int, userId int, delta int) error {
+	if err := tx.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgId, userId).
+		Update("monthly_used_quota", gorm.Expr("CASE WHEN monthly_used_quota + ? > 0 THEN monthly_used_quota + ? ELSE 0 END", delta, delta)).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota - ?", delta),
+		"used_quota": gorm.Expr("CASE WHEN used_quota + ? > 0 THEN used_quota + ? ELSE 0 END", delta, delta),
+	}).Error
+}
+
+// GetOrganizationTokenIds 查询组织名下的全部令牌 ID（含已删除），用于组织用量统计
+func GetOrganizationTokenIds(orgId int) ([]int, error) {
+	var ids []int
+	err := DB.Unscoped().Model(&Token{}).Where("organization_id = ?", orgId).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetOrganizationTokens 查询组织名下的令牌
+func GetOrganizationTokens(orgId int) ([]*Token, error) {
+	var tokens []*Token
+	err := DB.Where("organization_id = ?", orgId).Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
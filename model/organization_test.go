@@ -0,0 +1,75 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganizationMembersAndOwner(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&User{Id: 9401, Username: "org_owner", AffCode: "org1", Status: common.UserStatusEnabled}).Error)
+	require.NoError(t, DB.Create(&User{Id: 9402, Username: "org_member", AffCode: "org2", Status: common.UserStatusEnabled}).Error)
+
+	org := &Organization{Name: " team ", OwnerId: 9401}
+	require.NoError(t, CreateOrganization(org))
+	assert.Equal(t, "team", org.Name)
+
+	owner, err := GetOrganizationMember(org.Id, 9401)
+	require.NoError(t, err)
+	assert.Equal(t, OrganizationRoleOwner, owner.Role)
+
+	require.NoError(t, AddOrganizationMember(org.Id, 9402, "", 500))
+	assert.ErrorIs(t, AddOrganizationMember(org.Id, 9402, OrganizationRoleMember, 0), ErrOrganizationMemberExists)
+	assert.ErrorIs(t, UpdateOrganizationMember(org.Id, 9401, OrganizationRoleMember, 0), ErrOrganizationOwnerImmutable)
+	assert.ErrorIs(t, RemoveOrganizationMember(org.Id, 9401), ErrOrganizationOwnerImmutable)
+
+	members, err := GetOrganizationMembers(org.Id)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, "org_member", members[1].Username)
+	assert.Equal(t, 500, members[1].MonthlyQuotaLimit)
+
+	orgs, err := GetUserOrganizations(9402)
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	assert.Equal(t, OrganizationRoleMember, orgs[0].Role)
+
+	require.NoError(t, RemoveOrganizationMember(org.Id, 9402))
+	assert.True(t, errors.Is(ReserveOrganizationQuota(org.Id, 9402, 0), ErrOrganizationMemberNotFound))
+}
+
+func TestOrganizationUsageQueriesFollowOrganizationTokens(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&Token{Id: 9411, UserId: 1, OrganizationId: 7, Key: common.GetRandomString(48), Name: "org"}).Error)
+	require.NoError(t, DB.Create(&Token{Id: 9412, UserId: 1, Key: common.GetRandomString(48), Name: "personal"}).Error)
+	require.NoError(t, LOG_DB.Create(&Log{UserId: 1, Username: "alice", Type: LogTypeConsume, TokenId: 9411, Quota: 30, Ip: "10.0.0.1", CreatedAt: 100}).Error)
+	require.NoError(t, LOG_DB.Create(&Log{UserId: 1, Username: "alice", Type: LogTypeConsume, TokenId: 9412, Quota: 50, CreatedAt: 100}).Error)
+	require.NoError(t, DB.Create(&QuotaData{UserID: 1, Username: "alice", ModelName: "m", TokenID: 9411, Quota: 30, Count: 1, CreatedAt: 3600}).Error)
+	require.NoError(t, DB.Create(&QuotaData{UserID: 1, Username: "alice", ModelName: "m", TokenID: 9412, Quota: 50, Count: 1, CreatedAt: 3600}).Error)
+
+	tokenIds, err := GetOrganizationTokenIds(7)
+	require.NoError(t, err)
+	assert.Equal(t, []int{9411}, tokenIds)
+
+	logs, total, err := GetOrganizationLogs(tokenIds, LogTypeUnknown, 0, 0, "", "", 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, logs, 1)
+	assert.Equal(t, 30, logs[0].Quota)
+	assert.Empty(t, logs[0].Ip)
+
+	data, err := GetQuotaDataByTokenIds(tokenIds, 0, 7200)
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	assert.Equal(t, 30, data[0].Quota)
+
+	logs, total, err = GetOrganizationLogs(nil, LogTypeUnknown, 0, 0, "", "", 0, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, logs)
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+)
+
+// SensitiveHit 敏感规则命中记录，Sample 为脱敏后的命中片段，不保存原文
+type SensitiveHit struct {
+	Id        int    `json:"id"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Username  string `json:"username" gorm:"type:varchar(64);default:''"`
+	TokenId   int    `json:"token_id" gorm:"index"`
+	Group     string `json:"group" gorm:"type:varchar(64);default:''"`
+	ModelName string `json:"model_name" gorm:"type:varchar(255);default:''"`
+	RuleSet   string `json:"rule_set" gorm:"type:varchar(64);index"`
+	Rule      string `json:"rule" gorm:"type:varchar(64);default:''"`
+	Category  string `json:"category" gorm:"type:varchar(64);index;default:''"`
+	Action    string `json:"action" gorm:"type:varchar(16);index"`
+	Sample    string `json:"sample" gorm:"type:varchar(255);default:''"`
+	RequestId string `json:"request_id" gorm:"type:varchar(64);default:''"`
+}
+
+// SensitiveHitQuery 命中记录查询条件，零值表示不过滤
+type SensitiveHitQuery struct {
+	UserId         int
+	TokenId        int
+	RuleSet        string
+	Category       string
+	Action         string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func RecordSensitiveHits(hits []*SensitiveHit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+	return DB.Create(&hits).Error
+}
+
+func GetSensitiveHits(query SensitiveHitQuery, pageInfo *common.PageInfo) ([]*SensitiveHit, int64, error) {
+	tx := DB.Model(&SensitiveHit{})
+	if query.UserId > 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.TokenId > 0 {
+		tx = tx.Where("token_id = ?", query.TokenId)
+	}
+	if query.RuleSet != "" {
+		tx = tx.Where("rule_set = ?", query.RuleSet)
+	}
+	if query.Category != "" {
+		tx = tx.Where("category = ?", query.Category)
+	}
+	if query.Action != "" {
+		tx = tx.Where("action = ?", query.Action)
+	}
+	if query.StartTimestamp > 0 {
+		tx = tx.Where("created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp > 0 {
+		tx = tx.Where("created_at <= ?", query.EndTimestamp)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var hits []*SensitiveHit
+	err := tx.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&hits).Error
+	return hits, total, err
+}
@@ -23,6 +23,8 @@ const (
 	SystemTaskTypeAsyncTaskPoll     = "async_task_poll"
 	SystemTaskTypeActiveTaskHistory = "active_task_history"
 	SystemTaskTypeEpayReconcile     = "epay_reconcile"
+	SystemTaskTypeBatchProcess      = "batch_process"
+	SystemTaskTypeFineTuningPoll    = "fine_tuning_poll"
 )
 
 var ErrSystemTaskLockLost = errors.New("system task lock lost")
@@ -464,3 +466,20 @@ func decodeSystemTaskJSONValue(data string) any {
 	}
 	return value
 }
+
+type SystemTaskCount struct {
+	Type   string           `json:"type"`
+	Status SystemTaskStatus `json:"status"`
+	Count  int64            `json:"count"`
+}
+
+// CountActiveSystemTasks 按类型和状态统计待执行/执行中的系统任务
+func CountActiveSystemTasks() ([]SystemTaskCount, error) {
+	var counts []SystemTaskCount
+	err := DB.Model(&SystemTask{}).
+		Select("type, status, count(*) as count").
+		Where("status IN ?", activeSystemTaskStatuses()).
+		Group("type, status").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -14,6 +14,7 @@ import (
 type Token struct {
 	Id                 int            `json:"id"`
 	UserId             int            `json:"user_id" gorm:"index"`
+	OrganizationId     int            `json:"organization_id" gorm:"index;default:0"` // 组织令牌从组织钱包计费，0 表示个人令牌
 	Key                string         `json:"key" gorm:"type:varchar(128);uniqueIndex"`
 	Status             int            `json:"status" gorm:"default:1"`
 	Name               string         `json:"name" gorm:"index" `
@@ -27,7 +28,20 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
+	CrossGroupRetry    bool           `json:"cross_group_retry"`                  // 跨分组重试，仅auto分组有效
+	ResponseCache      bool           `json:"response_cache"`                     // 启用响应缓存（需系统开启）
+	DailyQuotaLimit    int            `json:"daily_quota_limit" gorm:"default:0"` // 周期预算，0 表示不限制
+	WeeklyQuotaLimit   int            `json:"weekly_quota_limit" gorm:"default:0"`
+	MonthlyQuotaLimit  int            `json:"monthly_quota_limit" gorm:"default:0"`
+	DailyUsedQuota     int            `json:"daily_used_quota" gorm:"default:0"`
+	WeeklyUsedQuota    int            `json:"weekly_used_quota" gorm:"default:0"`
+	MonthlyUsedQuota   int            `json:"monthly_used_quota" gorm:"default:0"`
+	DailyResetTime     int64          `json:"daily_reset_time" gorm:"bigint;default:0"` // 下次重置时间
+	WeeklyResetTime    int64          `json:"weekly_reset_time" gorm:"bigint;default:0"`
+	MonthlyResetTime   int64          `json:"monthly_reset_time" gorm:"bigint;default:0"`
+	RpmLimit           int            `json:"rpm_limit" gorm:"default:0"` // 每分钟请求数上限，0 表示不限制
+	TpmLimit           int            `json:"tpm_limit" gorm:"default:0"` // 每分钟 token 数上限，0 表示不限制
+	SensitiveRuleSets  string         `json:"sensitive_rule_sets" gorm:"type:varchar(255);default:''"`
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -302,7 +316,9 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "response_cache",
+		"daily_quota_limit", "weekly_quota_limit", "monthly_quota_limit", "rpm_limit", "tpm_limit",
+		"sensitive_rule_sets").Updates(token).Error
 	return err
 }
 
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"gorm.io/gorm"
+)
+
+// TokenBudgetExceededError 令牌周期预算不足
+type TokenBudgetExceededError struct {
+	Period    string
+	Limit     int
+	Used      int
+	ResetTime int64
+}
+
+func (e *TokenBudgetExceededError) Error() string {
+	return fmt.Sprintf("token %s budget exceeded: used %s of %s, resets at %s",
+		e.Period, logger.FormatQuota(e.Used), logger.FormatQuota(e.Limit),
+		time.Unix(e.ResetTime, 0).Format("2006-01-02 15:04:05"))
+}
+
+var tokenBudgetColumns = []string{
+	"id",
+	"daily_quota_limit", "weekly_quota_limit", "monthly_quota_limit",
+	"daily_used_quota", "weekly_used_quota", "monthly_used_quota",
+	"daily_reset_time", "weekly_reset_time", "monthly_reset_time",
+}
+
+type tokenBudgetWindow struct {
+	period    string
+	limit     int
+	used      *int
+	resetTime *int64
+}
+
+func (token *Token) budgetWindows() []tokenBudgetWindow {
+	return []tokenBudgetWindow{
+		{period: SubscriptionResetDaily, limit: token.DailyQuotaLimit, used: &token.DailyUsedQuota, resetTime: &token.DailyResetTime},
+		{period: SubscriptionResetWeekly, limit: token.WeeklyQuotaLimit, used: &token.WeeklyUsedQuota, resetTime: &token.WeeklyResetTime},
+		{period: SubscriptionResetMonthly, limit: token.MonthlyQuotaLimit, used: &token.MonthlyUsedQuota, resetTime: &token.MonthlyResetTime},
+	}
+}
+
+// HasQuotaBudget 是否设置了任一周期预算
+func (token *Token) HasQuotaBudget() bool {
+	return token.DailyQuotaLimit > 0 || token.WeeklyQuotaLimit > 0 || token.MonthlyQuotaLimit > 0
+}
+
+// rollTokenBudgetWindows 将已到期的周期清零并计算下次重置时间，未设置预算的周期不再记录用量
+func rollTokenBudgetWindows(token *Token, now int64) {
+	for _, window := range token.budgetWindows() {
+		if window.limit <= 0 {
+			*window.used = 0
+			*window.resetTime = 0
+			continue
+		}
+		if *window.resetTime > 0 && *window.resetTime > now {
+			continue
+		}
+		if *window.resetTime > 0 {
+			*window.used = 0
+		}
+		*window.resetTime = calcNextPeriodResetTime(time.Unix(now, 0), window.period, 0)
+	}
+}
+
+func saveTokenBudgetTx(tx *gorm.DB, token *Token) error {
+	return tx.Model(&Token{}).Where("id = ?", token.Id).Updates(map[string]interface{}{
+		"daily_used_quota":   token.DailyUsedQuota,
+		"weekly_used_quota":  token.WeeklyUsedQuota,
+		"monthly_used_quota": token.MonthlyUsedQuota,
+		"daily_reset_time":   token.DailyResetTime,
+		"weekly_reset_time":  token.WeeklyResetTime,
+		"monthly_reset_time": token.MonthlyResetTime,
+	}).Error
+}
+
+// ReserveTokenBudget 检查并占用令牌周期预算，任一周期不足时返回 *TokenBudgetExceededError
+func ReserveTokenBudget(tokenId int, quota int) error {
+	if quota <= 0 {
+		return nil
+	}
+	now := common.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var token Token
+		if err := lockForUpdate(tx).Select(tokenBudgetColumns).Where("id = ?", tokenId).First(&token).Error; err != nil {
+			return err
+		}
+		rollTokenBudgetWindows(&token, now)
+		windows := token.budgetWindows()
+		for _, window := range windows {
+			if window.limit > 0 && *window.used+quota > window.limit {
+				return &TokenBudgetExceededError{
+					Period:    window.period,
+					Limit:     window.limit,
+					Used:      *window.used,
+					ResetTime: *window.resetTime,
+				}
+			}
+		}
+		for _, window := range windows {
+			if window.limit > 0 {
+				*window.used += quota
+			}
+		}
+		return saveTokenBudgetTx(tx, &token)
+	})
+}
+
+// AdjustTokenBudgetUsage 按实际消耗调整周期预算已用额度（delta 为负表示退还），未设置预算的令牌不会被更新
+func AdjustTokenBudgetUsage(tokenId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	clamp := func(column string) interface{} {
+		return gorm.Expr(fmt.Sprintf("CASE WHEN %s + ? > 0 THEN %s + ? ELSE 0 END", column, column), delta, delta)
+	}
+	return DB.Model(&Token{}).
+		Where("id = ? AND (daily_quota_limit > 0 OR weekly_quota_limit > 0 OR monthly_quota_limit > 0)", tokenId).
+		Updates(map[string]interface{}{
+			"daily_used_quota":   clamp("daily_used_quota"),
+			"weekly_used_quota":  clamp("weekly_used_quota"),
+			"monthly_used_quota": clamp("monthly_used_quota"),
+		}).Error
+}
+
+// ResetDueTokenBudgets 清零已到重置时间的令牌周期预算，返回处理的令牌数
+func ResetDueTokenBudgets(limit int) (int, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	now := common.GetTimestamp()
+	var tokens []Token
+	if err := DB.Select(tokenBudgetColumns).
+		Where("(daily_reset_time > 0 AND daily_reset_time <= ?) OR (weekly_reset_time > 0 AND weekly_reset_time <= ?) OR (monthly_reset_time > 0 AND monthly_reset_time <= ?)", now, now, now).
+		Limit(limit).
+		Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+	resetCount := 0
+	for _, candidate := range tokens {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var token Token
+			if err := lockForUpdate(tx).Select(tokenBudgetColumns).Where("id = ?", candidate.Id).First(&token).Error; err != nil {
+				return nil
+			}
+			rollTokenBudgetWindows(&token, now)
+			if err := saveTokenBudgetTx(tx, &token); err != nil {
+				return err
+			}
+			resetCount++
+			return nil
+		})
+		if err != nil {
+			return resetCount, err
+		}
+	}
+	return resetCount, nil
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedBudgetToken(t *testing.T, token *Token) {
+	t.Helper()
+	token.Key = common.GetRandomString(48)
+	require.NoError(t, DB.Create(token).Error)
+}
+
+func getBudgetToken(t *testing.T, id int) Token {
+	t.Helper()
+	var token Token
+	require.NoError(t, DB.Where("id = ?", id).First(&token).Error)
+	return token
+}
+
+func TestReserveTokenBudgetEnforcesEveryWindow(t *testing.T) {
+	truncateTables(t)
+	seedBudgetToken(t, &Token{Id: 9301, UserId: 1, Name: "budget", DailyQuotaLimit: 100, MonthlyQuotaLimit: 150})
+
+	require.NoError(t, ReserveTokenBudget(9301, 60))
+	token := getBudgetToken(t, 9301)
+	assert.Equal(t, 60, token.DailyUsedQuota)
+	assert.Equal(t, 60, token.MonthlyUsedQuota)
+	assert.Equal(t, 0, token.WeeklyUsedQuota)
+	assert.Greater(t, token.DailyResetTime, common.GetTimestamp())
+	assert.Zero(t, token.WeeklyResetTime)
+
+	err := ReserveTokenBudget(9301, 50)
+	var budgetErr *TokenBudgetExceededError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, SubscriptionResetDaily, budgetErr.Period)
+	assert.Equal(t, 60, budgetErr.Used)
+	assert.Equal(t, token.DailyResetTime, budgetErr.ResetTime)
+
+	// 退还后可以继续使用，且不会减到负数
+	require.NoError(t, AdjustTokenBudgetUsage(9301, -80))
+	token = getBudgetToken(t, 9301)
+	assert.Equal(t, 0, token.DailyUsedQuota)
+	require.NoError(t, ReserveTokenBudget(9301, 100))
+	require.NoError(t, AdjustTokenBudgetUsage(9301, 40))
+	err = ReserveTokenBudget(9301, 20)
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, SubscriptionResetDaily, budgetErr.Period)
+}
+
+func TestResetDueTokenBudgetsClearsExpiredWindows(t *testing.T) {
+	truncateTables(t)
+	now := common.GetTimestamp()
+	seedBudgetToken(t, &Token{Id: 9302, UserId: 1, Name: "due", DailyQuotaLimit: 100, WeeklyQuotaLimit: 500,
+		DailyUsedQuota: 90, WeeklyUsedQuota: 300, DailyResetTime: now - 10, WeeklyResetTime: now + 3600})
+	seedBudgetToken(t, &Token{Id: 9303, UserId: 1, Name: "not-due", DailyQuotaLimit: 100,
+		DailyUsedQuota: 90, DailyResetTime: now + 3600})
+
+	count, err := ResetDueTokenBudgets(10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	token := getBudgetToken(t, 9302)
+	assert.Equal(t, 0, token.DailyUsedQuota)
+	assert.Greater(t, token.DailyResetTime, now)
+	assert.Equal(t, 300, token.WeeklyUsedQuota)
+	assert.Equal(t, now+3600, token.WeeklyResetTime)
+	assert.Equal(t, 90, getBudgetToken(t, 9303).DailyUsedQuota)
+
+	// 已处理的令牌不会被重复重置
+	count, err = ResetDueTokenBudgets(10)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestAdjustTokenBudgetUsageSkipsTokensWithoutBudget(t *testing.T) {
+	truncateTables(t)
+	seedBudgetToken(t, &Token{Id: 9304, UserId: 1, Name: "plain"})
+	require.NoError(t, AdjustTokenBudgetUsage(9304, 10))
+	assert.Equal(t, 0, getBudgetToken(t, 9304).DailyUsedQuota)
+}
@@ -160,6 +160,20 @@ func GetQuotaDataByUserId(userId int, startTime int64, endTime int64) (quotaData
 	return quotaDatas, err
 }
 
+// GetQuotaDataByTokenIds 按令牌统计用量，用于组织维度的数据看板
+func GetQuotaDataByTokenIds(tokenIds []int, startTime int64, endTime int64) (quotaData []*QuotaData, err error) {
+	if len(tokenIds) == 0 {
+		return []*QuotaData{}, nil
+	}
+	var quotaDatas []*QuotaData
+	err = DB.Table("quota_data").
+		Select("user_id, username, model_name, created_at, sum(count) as count, sum(quota) as quota, sum(token_used) as token_used").
+		Where("token_id IN ? and created_at >= ? and created_at <= ?", tokenIds, startTime, endTime).
+		Group("user_id, username, model_name, created_at").
+		Find(&quotaDatas).Error
+	return quotaDatas, err
+}
+
 func GetQuotaDataGroupByUser(startTime int64, endTime int64) (quotaData []*QuotaData, err error) {
 	var quotaDatas []*QuotaData
 	err = DB.Table("quota_data").
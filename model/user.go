@@ -288,7 +288,8 @@ func GetMaxUserId() int {
 	return user.Id
 }
 
-func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err error) {
+// GetAllUsers 分页获取用户；groups 非 nil 时只返回这些分组内的用户
+func GetAllUsers(pageInfo *common.PageInfo, groups []string) (users []*User, total int64, err error) {
 	// Start transaction
 	tx := DB.Begin()
 	if tx.Error != nil {
@@ -300,15 +301,17 @@ func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err err
 		}
 	}()
 
+	query := applyUserGroupsFilter(tx.Unscoped().Model(&User{}), groups)
+
 	// Get total count within transaction
-	err = tx.Unscoped().Model(&User{}).Count(&total).Error
+	err = query.Count(&total).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, 0, err
 	}
 
 	// Get paginated users within same transaction
-	err = tx.Unscoped().Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Omit("password", "access_token").Find(&users).Error
+	err = applyUserGroupsFilter(tx.Unscoped(), groups).Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Omit("password", "access_token").Find(&users).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, 0, err
@@ -322,7 +325,15 @@ func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err err
 	return users, total, nil
 }
 
-func SearchUsers(keyword string, group string, role *int, status *int, startIdx int, num int) ([]*User, int64, error) {
+func applyUserGroupsFilter(query *gorm.DB, groups []string) *gorm.DB {
+	if groups == nil {
+		return query
+	}
+	return query.Where(commonGroupCol+" IN ?", groups)
+}
+
+// SearchUsers 搜索用户；groups 非 nil 时只在这些分组内搜索
+func SearchUsers(keyword string, group string, groups []string, role *int, status *int, startIdx int, num int) ([]*User, int64, error) {
 	var users []*User
 	var total int64
 	var err error
@@ -357,6 +368,7 @@ func SearchUsers(keyword string, group string, role *int, status *int, startIdx
 	if group != "" {
 		query = query.Where(commonGroupCol+" = ?", group)
 	}
+	query = applyUserGroupsFilter(query, groups)
 	if role != nil {
 		query = query.Where("role = ?", *role)
 	}
@@ -1245,6 +1257,26 @@ func GetUsernameById(id int, fromDB bool) (username string, err error) {
 	return username, nil
 }
 
+// GetUserIdByUsername 按用户名查询用户 ID，用户不存在时返回错误
+func GetUserIdByUsername(username string) (int, error) {
+	var user User
+	if err := DB.Select("id").Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("user not found")
+		}
+		return 0, err
+	}
+	return user.Id, nil
+}
+
+// UpdateUserGroup 更新用户分组并同步缓存，用于外部身份源（如 LDAP 组映射）下发分组
+func UpdateUserGroup(userId int, group string) error {
+	if err := DB.Model(&User{}).Where("id = ?", userId).Update("group", group).Error; err != nil {
+		return err
+	}
+	return updateUserGroupCache(userId, group)
+}
+
 func IsLinuxDOIdAlreadyTaken(linuxDOId string) bool {
 	var user User
 	err := DB.Unscoped().Where("linux_do_id = ?", linuxDOId).First(&user).Error
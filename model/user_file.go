@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	UserFileStatusUploaded  = "uploaded"
+	UserFileStatusProcessed = "processed"
+	UserFileStatusError     = "error"
+)
+
+// UserFile 记录通过 /v1/files 上传的文件。
+// 本地存储的文件由 StorageBackend + StorageKey 定位；透传到上游的文件
+// 额外记录 ChannelId 与 UpstreamFileId，后续读取/删除固定走同一个渠道。
+type UserFile struct {
+	Id             int    `json:"id"`
+	FileId         string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	Purpose        string `json:"purpose" gorm:"type:varchar(32);index"`
+	Filename       string `json:"filename" gorm:"type:varchar(255)"`
+	MimeType       string `json:"mime_type" gorm:"type:varchar(128)"`
+	Bytes          int64  `json:"bytes" gorm:"bigint"`
+	Status         string `json:"status" gorm:"type:varchar(20)"`
+	StorageBackend string `json:"storage_backend" gorm:"type:varchar(32)"`
+	StorageKey     string `json:"-" gorm:"type:varchar(128)"`
+	ChannelId      int    `json:"channel_id" gorm:"index"`
+	UpstreamFileId string `json:"-" gorm:"type:varchar(191)"`
+	ExpiresAt      int64  `json:"expires_at" gorm:"bigint"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// GenerateUserFileId 生成对外暴露的 file-xxxx 格式 ID
+func GenerateUserFileId() (string, error) {
+	key, err := common.GenerateRandomCharsKey(24)
+	if err != nil {
+		return "", err
+	}
+	return "file-" + key, nil
+}
+
+// IsUpstream 文件是否存放在上游渠道
+func (f *UserFile) IsUpstream() bool {
+	return f.ChannelId > 0 && f.UpstreamFileId != ""
+}
+
+func (f *UserFile) Insert() error {
+	if f.CreatedAt == 0 {
+		f.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(f).Error
+}
+
+// GetUserFileByFileId 获取用户自己的文件，不存在时返回 (nil, nil)
+func GetUserFileByFileId(userId int, fileId string) (*UserFile, error) {
+	var file UserFile
+	err := DB.Where("file_id = ? AND user_id = ?", fileId, userId).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ListUserFiles 按创建时间倒序列出用户文件，after 为游标（上一页最后一个 file_id）
+func ListUserFiles(userId int, purpose string, after string, limit int) ([]*UserFile, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 10000 {
+		limit = 10000
+	}
+	query := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	if after != "" {
+		cursor, err := GetUserFileByFileId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		if cursor != nil {
+			query = query.Where("id < ?", cursor.Id)
+		}
+	}
+	var files []*UserFile
+	err := query.Order("id desc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// GetUserFileUsage 统计用户已上传文件数量和总字节数
+func GetUserFileUsage(userId int) (count int64, totalBytes int64, err error) {
+	var result struct {
+		Count int64
+		Total int64
+	}
+	err = DB.Model(&UserFile{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(bytes), 0) AS total").
+		Where("user_id = ?", userId).
+		Scan(&result).Error
+	return result.Count, result.Total, err
+}
+
+func DeleteUserFileById(id int) error {
+	return DB.Delete(&UserFile{}, id).Error
+}
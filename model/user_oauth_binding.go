@@ -7,6 +7,13 @@ import (
 	"gorm.io/gorm"
 )
 
+// Built-in identity sources share this table with custom OAuth providers. They use
+// reserved negative provider IDs so they can never collide with auto-increment IDs.
+const (
+	OAuthBindingProviderLDAP = -1
+	OAuthBindingProviderSAML = -2
+)
+
 // UserOAuthBinding stores the binding relationship between users and custom OAuth providers
 type UserOAuthBinding struct {
 	Id             int       `json:"id" gorm:"primaryKey"`
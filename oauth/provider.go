@@ -34,3 +34,12 @@ type Provider interface {
 	// GetProviderPrefix returns the prefix for auto-generated usernames (e.g., "github_")
 	GetProviderPrefix() string
 }
+
+// BindingProvider is implemented by providers whose identities are stored in the
+// user_oauth_bindings table instead of a dedicated column on the user model.
+type BindingProvider interface {
+	Provider
+
+	// GetProviderId returns the provider ID used in user_oauth_bindings
+	GetProviderId() int
+}
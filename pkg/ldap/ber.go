@@ -0,0 +1,268 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER 编码的最小实现，仅覆盖 LDAPv3 消息用到的类型（RFC 4511 §5.1）
+
+const (
+	ClassUniversal   = 0x00
+	ClassApplication = 0x40
+	ClassContext     = 0x80
+
+	TagBoolean     = 0x01
+	TagInteger     = 0x02
+	TagOctetString = 0x04
+	TagNull        = 0x05
+	TagEnumerated  = 0x0a
+	TagSequence    = 0x10
+	TagSet         = 0x11
+)
+
+// 单个 LDAP 消息的上限，防止恶意服务端声明超大长度耗尽内存
+const maxPacketLength = 16 << 20
+
+var errPacketTooLarge = errors.New("ldap: packet too large")
+
+// Packet 是一个 BER TLV 节点；构造类型的内容保存在 Children 中
+type Packet struct {
+	Class       byte
+	Constructed bool
+	Tag         byte
+	Value       []byte
+	Children    []*Packet
+}
+
+func NewSequence(children ...*Packet) *Packet {
+	return NewConstructed(ClassUniversal, TagSequence, children...)
+}
+
+func NewConstructed(class byte, tag byte, children ...*Packet) *Packet {
+	return &Packet{Class: class, Constructed: true, Tag: tag, Children: children}
+}
+
+func NewPrimitive(class byte, tag byte, value []byte) *Packet {
+	return &Packet{Class: class, Tag: tag, Value: value}
+}
+
+func NewString(value string) *Packet {
+	return NewPrimitive(ClassUniversal, TagOctetString, []byte(value))
+}
+
+func NewInteger(value int64) *Packet {
+	return NewPrimitive(ClassUniversal, TagInteger, encodeInteger(value))
+}
+
+func NewEnumerated(value int64) *Packet {
+	return NewPrimitive(ClassUniversal, TagEnumerated, encodeInteger(value))
+}
+
+func NewBoolean(value bool) *Packet {
+	if value {
+		return NewPrimitive(ClassUniversal, TagBoolean, []byte{0xff})
+	}
+	return NewPrimitive(ClassUniversal, TagBoolean, []byte{0x00})
+}
+
+func (p *Packet) AppendChild(child *Packet) {
+	p.Children = append(p.Children, child)
+}
+
+// Is 判断节点的类别与标签
+func (p *Packet) Is(class byte, tag byte) bool {
+	return p != nil && p.Class == class && p.Tag == tag
+}
+
+func (p *Packet) String() string {
+	return string(p.Value)
+}
+
+func (p *Packet) Int() (int64, error) {
+	return decodeInteger(p.Value)
+}
+
+func (p *Packet) Bool() bool {
+	return len(p.Value) > 0 && p.Value[0] != 0
+}
+
+// Bytes 序列化为 BER 字节
+func (p *Packet) Bytes() []byte {
+	content := p.Value
+	if p.Constructed {
+		content = nil
+		for _, child := range p.Children {
+			content = append(content, child.Bytes()...)
+		}
+	}
+	identifier := p.Class | p.Tag
+	if p.Constructed {
+		identifier |= 0x20
+	}
+	out := []byte{identifier}
+	out = append(out, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+// ReadPacket 从流中读取并解析一个完整的 BER 节点
+func ReadPacket(r *bufio.Reader) (*Packet, error) {
+	identifier, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return parsePacket(identifier, content)
+}
+
+// ParsePacket 解析内存中的单个 BER 节点
+func ParsePacket(data []byte) (*Packet, error) {
+	p, rest, err := parseOne(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("ldap: trailing data after packet")
+	}
+	return p, nil
+}
+
+func parsePacket(identifier byte, content []byte) (*Packet, error) {
+	if identifier&0x1f == 0x1f {
+		return nil, errors.New("ldap: multi-byte tags are not supported")
+	}
+	p := &Packet{
+		Class:       identifier & 0xc0,
+		Constructed: identifier&0x20 != 0,
+		Tag:         identifier & 0x1f,
+	}
+	if !p.Constructed {
+		p.Value = content
+		return p, nil
+	}
+	for len(content) > 0 {
+		child, rest, err := parseOne(content)
+		if err != nil {
+			return nil, err
+		}
+		p.Children = append(p.Children, child)
+		content = rest
+	}
+	return p, nil
+}
+
+func parseOne(data []byte) (*Packet, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	identifier := data[0]
+	length, n, err := decodeLength(data[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	start := 1 + n
+	if length > len(data)-start {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	p, err := parsePacket(identifier, data[start:start+length])
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, data[start+length:], nil
+}
+
+func encodeLength(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+	var buf []byte
+	for l := length; l > 0; l >>= 8 {
+		buf = append([]byte{byte(l)}, buf...)
+	}
+	return append([]byte{0x80 | byte(len(buf))}, buf...)
+}
+
+func readLength(r *bufio.Reader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if first < 0x80 {
+		return int(first), nil
+	}
+	n := int(first & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, fmt.Errorf("ldap: unsupported length encoding 0x%02x", first)
+	}
+	length := 0
+	for i := 0; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	if length > maxPacketLength {
+		return 0, errPacketTooLarge
+	}
+	return length, nil
+}
+
+func decodeLength(data []byte) (int, int, error) {
+	first := data[0]
+	if first < 0x80 {
+		return int(first), 1, nil
+	}
+	n := int(first & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, 0, fmt.Errorf("ldap: unsupported length encoding 0x%02x", first)
+	}
+	if len(data) < 1+n {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	length := 0
+	for _, b := range data[1 : 1+n] {
+		length = length<<8 | int(b)
+	}
+	if length > maxPacketLength {
+		return 0, 0, errPacketTooLarge
+	}
+	return length, 1 + n, nil
+}
+
+func encodeInteger(value int64) []byte {
+	// 最短的二进制补码表示
+	n := 1
+	for v := value; v > 127 || v < -128; v >>= 8 {
+		n++
+	}
+	out := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		out[i] = byte(value)
+		value >>= 8
+	}
+	return out
+}
+
+func decodeInteger(data []byte) (int64, error) {
+	if len(data) == 0 || len(data) > 8 {
+		return 0, fmt.Errorf("ldap: invalid integer length %d", len(data))
+	}
+	var value int64
+	if data[0]&0x80 != 0 {
+		value = -1
+	}
+	for _, b := range data {
+		value = value<<8 | int64(b)
+	}
+	return value, nil
+}
@@ -0,0 +1,387 @@
+// Package ldap 是一个精简的 LDAPv3 客户端，只实现登录认证需要的
+// Simple Bind、Search 与 StartTLS 扩展操作。
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 协议操作标签（RFC 4511 §4.2 - §4.12）
+const (
+	ApplicationBindRequest           = 0
+	ApplicationBindResponse          = 1
+	ApplicationUnbindRequest         = 2
+	ApplicationSearchRequest         = 3
+	ApplicationSearchResultEntry     = 4
+	ApplicationSearchResultDone      = 5
+	ApplicationSearchResultReference = 19
+	ApplicationExtendedRequest       = 23
+	ApplicationExtendedResponse      = 24
+)
+
+const (
+	ScopeBaseObject   = 0
+	ScopeSingleLevel  = 1
+	ScopeWholeSubtree = 2
+
+	NeverDerefAliases = 0
+)
+
+const (
+	ResultSuccess            = 0
+	ResultSizeLimitExceeded  = 4
+	ResultInvalidCredentials = 49
+)
+
+// OIDStartTLS 是 StartTLS 扩展操作的 OID（RFC 4511 §4.14）
+const OIDStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// Error 表示服务端返回的非成功 LDAPResult
+type Error struct {
+	ResultCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.ResultCode)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.ResultCode, e.Message)
+}
+
+// IsResultCode 判断 err 是否为指定结果码的 LDAP 错误
+func IsResultCode(err error, code int) bool {
+	var ldapErr *Error
+	return errors.As(err, &ldapErr) && ldapErr.ResultCode == code
+}
+
+// Entry 是一条搜索结果
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// GetAttributeValues 按属性名（不区分大小写）取值
+func (e *Entry) GetAttributeValues(name string) []string {
+	if values, ok := e.Attributes[name]; ok {
+		return values
+	}
+	for key, values := range e.Attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func (e *Entry) GetAttributeValue(name string) string {
+	values := e.GetAttributeValues(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	SizeLimit  int
+	TimeLimit  int
+	Filter     string
+	Attributes []string
+}
+
+// Conn 是单个 LDAP 连接，不支持并发请求
+type Conn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	messageID int64
+	timeout   time.Duration
+	isTLS     bool
+	// 连接地址中的主机名，StartTLS 用它校验证书
+	host string
+}
+
+// DialURL 连接 ldap:// 或 ldaps:// 地址；未指定端口时分别使用 389 与 636
+func DialURL(rawURL string, tlsConfig *tls.Config, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid url: %w", err)
+	}
+	host := u.Host
+	if host == "" {
+		return nil, errors.New("ldap: url is missing host")
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	switch strings.ToLower(u.Scheme) {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+		conn, err := dialer.Dial("tcp", host)
+		if err != nil {
+			return nil, err
+		}
+		c := NewConn(conn, false, timeout)
+		c.host = u.Hostname()
+		return c, nil
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+		conn, err := tls.DialWithDialer(dialer, "tcp", host, withServerName(tlsConfig, u.Hostname()))
+		if err != nil {
+			return nil, err
+		}
+		c := NewConn(conn, true, timeout)
+		c.host = u.Hostname()
+		return c, nil
+	default:
+		return nil, fmt.Errorf("ldap: unsupported url scheme %q", u.Scheme)
+	}
+}
+
+// NewConn 包装已建立的连接；timeout 为每个请求的读写超时，0 表示不限制
+func NewConn(conn net.Conn, isTLS bool, timeout time.Duration) *Conn {
+	return &Conn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+		isTLS:   isTLS,
+	}
+}
+
+func (c *Conn) IsTLS() bool {
+	return c.isTLS
+}
+
+// Close 发送 UnbindRequest 后关闭连接
+func (c *Conn) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	_ = c.send(NewPrimitive(ClassApplication, ApplicationUnbindRequest, nil))
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// StartTLS 在明文连接上升级为 TLS
+func (c *Conn) StartTLS(tlsConfig *tls.Config) error {
+	if c.isTLS {
+		return errors.New("ldap: connection is already using TLS")
+	}
+	req := NewConstructed(ClassApplication, ApplicationExtendedRequest,
+		NewPrimitive(ClassContext, 0, []byte(OIDStartTLS)),
+	)
+	resp, err := c.roundTrip(req, ApplicationExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if err := parseResult(resp); err != nil {
+		return err
+	}
+
+	// 证书通常只签发给域名，应使用连接时的主机名而不是解析后的 IP
+	host := c.host
+	if host == "" {
+		host, _, _ = net.SplitHostPort(c.conn.RemoteAddr().String())
+	}
+	tlsConn := tls.Client(c.conn, withServerName(tlsConfig, host))
+	if c.timeout > 0 {
+		_ = tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	c.isTLS = true
+	return nil
+}
+
+// Bind 执行 Simple Bind。空密码会被服务端视为匿名绑定（RFC 4513 §5.1.2），
+// 因此这里直接拒绝，避免把未认证绑定误判为登录成功。
+func (c *Conn) Bind(dn string, password string) error {
+	if password == "" {
+		return errors.New("ldap: empty password is not allowed")
+	}
+	req := NewConstructed(ClassApplication, ApplicationBindRequest,
+		NewInteger(3),
+		NewString(dn),
+		NewPrimitive(ClassContext, 0, []byte(password)),
+	)
+	resp, err := c.roundTrip(req, ApplicationBindResponse)
+	if err != nil {
+		return err
+	}
+	return parseResult(resp)
+}
+
+// Search 执行搜索并收集全部条目，忽略引用（referral）结果
+func (c *Conn) Search(req *SearchRequest) ([]*Entry, error) {
+	filter, err := CompileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	attributes := NewSequence()
+	for _, attr := range req.Attributes {
+		attributes.AppendChild(NewString(attr))
+	}
+	op := NewConstructed(ClassApplication, ApplicationSearchRequest,
+		NewString(req.BaseDN),
+		NewEnumerated(int64(req.Scope)),
+		NewEnumerated(NeverDerefAliases),
+		NewInteger(int64(req.SizeLimit)),
+		NewInteger(int64(req.TimeLimit)),
+		NewBoolean(false),
+		filter,
+		attributes,
+	)
+	id, err := c.sendRequest(op)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		resp, err := c.readResponse(id)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.Is(ClassApplication, ApplicationSearchResultEntry):
+			entry, err := parseEntry(resp)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case resp.Is(ClassApplication, ApplicationSearchResultReference):
+			continue
+		case resp.Is(ClassApplication, ApplicationSearchResultDone):
+			if err := parseResult(resp); err != nil {
+				return entries, err
+			}
+			return entries, nil
+		default:
+			return nil, fmt.Errorf("ldap: unexpected search response tag %d", resp.Tag)
+		}
+	}
+}
+
+func (c *Conn) roundTrip(op *Packet, expectTag byte) (*Packet, error) {
+	id, err := c.sendRequest(op)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.readResponse(id)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Is(ClassApplication, expectTag) {
+		return nil, fmt.Errorf("ldap: unexpected response tag %d", resp.Tag)
+	}
+	return resp, nil
+}
+
+func (c *Conn) sendRequest(op *Packet) (int64, error) {
+	if c.conn == nil {
+		return 0, errors.New("ldap: connection is closed")
+	}
+	c.messageID++
+	if err := c.send(op); err != nil {
+		return 0, err
+	}
+	return c.messageID, nil
+}
+
+func (c *Conn) send(op *Packet) error {
+	if c.timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	msg := NewSequence(NewInteger(c.messageID), op)
+	_, err := c.conn.Write(msg.Bytes())
+	return err
+}
+
+// readResponse 读取下一条消息并返回其中的协议操作
+func (c *Conn) readResponse(id int64) (*Packet, error) {
+	if c.timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	msg, err := ReadPacket(c.reader)
+	if err != nil {
+		return nil, err
+	}
+	if !msg.Is(ClassUniversal, TagSequence) || len(msg.Children) < 2 {
+		return nil, errors.New("ldap: malformed message")
+	}
+	msgID, err := msg.Children[0].Int()
+	if err != nil {
+		return nil, err
+	}
+	if msgID != id {
+		// 0 号消息是服务端主动断开通知（RFC 4511 §4.4.1）
+		if msgID == 0 {
+			if err := parseResult(msg.Children[1]); err != nil {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("ldap: unexpected message id %d, want %d", msgID, id)
+	}
+	return msg.Children[1], nil
+}
+
+// parseResult 解析 LDAPResult 的前三个字段：resultCode、matchedDN、diagnosticMessage
+func parseResult(op *Packet) error {
+	if len(op.Children) < 3 {
+		return errors.New("ldap: malformed result")
+	}
+	code, err := op.Children[0].Int()
+	if err != nil {
+		return err
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+	return &Error{ResultCode: int(code), Message: op.Children[2].String()}
+}
+
+func parseEntry(op *Packet) (*Entry, error) {
+	if len(op.Children) < 2 {
+		return nil, errors.New("ldap: malformed search entry")
+	}
+	entry := &Entry{
+		DN:         op.Children[0].String(),
+		Attributes: make(map[string][]string),
+	}
+	for _, attr := range op.Children[1].Children {
+		if len(attr.Children) < 2 {
+			return nil, errors.New("ldap: malformed attribute")
+		}
+		name := attr.Children[0].String()
+		for _, value := range attr.Children[1].Children {
+			entry.Attributes[name] = append(entry.Attributes[name], value.String())
+		}
+	}
+	return entry, nil
+}
+
+func withServerName(cfg *tls.Config, host string) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	} else {
+		cfg = cfg.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	return cfg
+}
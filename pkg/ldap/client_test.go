@@ -0,0 +1,121 @@
+package ldap_test
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/pkg/ldap"
+	"github.com/QuantumNous/new-api/pkg/ldap/ldaptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *ldaptest.Server {
+	t.Helper()
+	srv, err := ldaptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+	srv.AddEntry("cn=admin,dc=example,dc=com", "admin-secret", nil)
+	srv.AddEntry("uid=alice,ou=people,dc=example,dc=com", "alice-pass", map[string][]string{
+		"uid":      {"alice"},
+		"mail":     {"alice@example.com"},
+		"cn":       {"Alice Liddell"},
+		"memberOf": {"cn=dev,ou=groups,dc=example,dc=com", "cn=ops,ou=groups,dc=example,dc=com"},
+	})
+	srv.AddEntry("uid=bob,ou=people,dc=example,dc=com", "bob-pass", map[string][]string{
+		"uid": {"bob"},
+	})
+	return srv
+}
+
+func TestBindAndSearch(t *testing.T) {
+	srv := newTestServer(t)
+	conn, err := ldap.DialURL(srv.URL, nil, 5*time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.Bind("cn=admin,dc=example,dc=com", "admin-secret"))
+	entries, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     "dc=example,dc=com",
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     "(&(uid=" + ldap.EscapeFilter("alice") + ")(mail=*))",
+		Attributes: []string{"uid", "mail", "memberOf"},
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "uid=alice,ou=people,dc=example,dc=com", entries[0].DN)
+	assert.Equal(t, "alice@example.com", entries[0].GetAttributeValue("MAIL"))
+	assert.Len(t, entries[0].GetAttributeValues("memberof"), 2)
+	assert.Empty(t, entries[0].GetAttributeValue("cn"))
+
+	// 注入的通配符被转义后不会匹配到其他用户
+	entries, err = conn.Search(&ldap.SearchRequest{
+		BaseDN: "dc=example,dc=com",
+		Scope:  ldap.ScopeWholeSubtree,
+		Filter: "(uid=" + ldap.EscapeFilter("*") + ")",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	err = conn.Bind("uid=alice,ou=people,dc=example,dc=com", "wrong")
+	assert.True(t, ldap.IsResultCode(err, ldap.ResultInvalidCredentials))
+	require.NoError(t, conn.Bind("uid=alice,ou=people,dc=example,dc=com", "alice-pass"))
+}
+
+func TestBindRejectsEmptyPassword(t *testing.T) {
+	srv := newTestServer(t)
+	conn, err := ldap.DialURL(srv.URL, nil, 5*time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Error(t, conn.Bind("uid=alice,ou=people,dc=example,dc=com", ""))
+	assert.Empty(t, srv.Binds())
+}
+
+func TestStartTLS(t *testing.T) {
+	srv := newTestServer(t)
+	srv.RequireTLS = true
+	pool, err := srv.EnableStartTLS()
+	require.NoError(t, err)
+
+	conn, err := ldap.DialURL(srv.URL, nil, 5*time.Second)
+	require.NoError(t, err)
+	err = conn.Bind("uid=bob,ou=people,dc=example,dc=com", "bob-pass")
+	assert.Error(t, err, "plaintext bind must be refused")
+	conn.Close()
+
+	conn, err = ldap.DialURL(srv.URL, nil, 5*time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.StartTLS(&tls.Config{RootCAs: pool}))
+	assert.True(t, conn.IsTLS())
+	require.NoError(t, conn.Bind("uid=bob,ou=people,dc=example,dc=com", "bob-pass"))
+	assert.Equal(t, []string{"uid=bob,ou=people,dc=example,dc=com"}, srv.Binds())
+}
+
+func TestStartTLSRejectsUntrustedCertificate(t *testing.T) {
+	srv := newTestServer(t)
+	_, err := srv.EnableStartTLS()
+	require.NoError(t, err)
+
+	conn, err := ldap.DialURL(srv.URL, nil, 5*time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Error(t, conn.StartTLS(&tls.Config{}))
+}
+
+func TestStartTLSVerifiesURLHostname(t *testing.T) {
+	srv := newTestServer(t)
+	srv.RequireTLS = true
+	pool, err := srv.EnableStartTLS("localhost")
+	require.NoError(t, err)
+
+	// 证书只有 DNS SAN，必须按 URL 中的主机名校验
+	conn, err := ldap.DialURL(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1), nil, 5*time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.StartTLS(&tls.Config{RootCAs: pool}))
+	require.NoError(t, conn.Bind("uid=bob,ou=people,dc=example,dc=com", "bob-pass"))
+}
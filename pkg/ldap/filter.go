@@ -0,0 +1,195 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 过滤器选择标签（RFC 4511 §4.5.1.7）
+const (
+	FilterAnd            = 0
+	FilterOr             = 1
+	FilterNot            = 2
+	FilterEqualityMatch  = 3
+	FilterSubstrings     = 4
+	FilterGreaterOrEqual = 5
+	FilterLessOrEqual    = 6
+	FilterPresent        = 7
+	FilterApproxMatch    = 8
+
+	SubstringInitial = 0
+	SubstringAny     = 1
+	SubstringFinal   = 2
+)
+
+// 限制嵌套深度，避免配置错误的过滤器导致深度递归
+const maxFilterDepth = 32
+
+// EscapeFilter 按 RFC 4515 转义过滤器中的断言值，用户输入拼接进过滤器前必须调用
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// CompileFilter 将字符串形式的过滤器（如 "(&(objectClass=person)(uid=alice))"）编码为 BER
+func CompileFilter(filter string) (*Packet, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, errors.New("ldap: empty filter")
+	}
+	if !strings.HasPrefix(filter, "(") {
+		filter = "(" + filter + ")"
+	}
+	p, rest, err := compileFilter(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("ldap: unexpected trailing filter data %q", rest)
+	}
+	return p, nil
+}
+
+func compileFilter(s string, depth int) (*Packet, string, error) {
+	if depth > maxFilterDepth {
+		return nil, "", errors.New("ldap: filter nested too deeply")
+	}
+	if len(s) < 2 || s[0] != '(' {
+		return nil, "", fmt.Errorf("ldap: malformed filter %q", s)
+	}
+	s = s[1:]
+	switch s[0] {
+	case '&', '|':
+		tag := byte(FilterAnd)
+		if s[0] == '|' {
+			tag = FilterOr
+		}
+		p := NewConstructed(ClassContext, tag)
+		s = s[1:]
+		for len(s) > 0 && s[0] == '(' {
+			child, rest, err := compileFilter(s, depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			p.AppendChild(child)
+			s = rest
+		}
+		if len(p.Children) == 0 {
+			return nil, "", errors.New("ldap: empty filter set")
+		}
+		return closeFilter(p, s)
+	case '!':
+		child, rest, err := compileFilter(s[1:], depth+1)
+		if err != nil {
+			return nil, "", err
+		}
+		return closeFilter(NewConstructed(ClassContext, FilterNot, child), rest)
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, "", errors.New("ldap: unterminated filter")
+	}
+	item, rest := s[:end], s[end:]
+	p, err := compileItem(item)
+	if err != nil {
+		return nil, "", err
+	}
+	return closeFilter(p, rest)
+}
+
+func closeFilter(p *Packet, rest string) (*Packet, string, error) {
+	if rest == "" || rest[0] != ')' {
+		return nil, "", errors.New("ldap: unterminated filter")
+	}
+	return p, rest[1:], nil
+}
+
+func compileItem(item string) (*Packet, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("ldap: malformed filter item %q", item)
+	}
+	attr, value := item[:eq], item[eq+1:]
+	tag := byte(FilterEqualityMatch)
+	switch attr[len(attr)-1] {
+	case '>':
+		tag, attr = FilterGreaterOrEqual, attr[:len(attr)-1]
+	case '<':
+		tag, attr = FilterLessOrEqual, attr[:len(attr)-1]
+	case '~':
+		tag, attr = FilterApproxMatch, attr[:len(attr)-1]
+	}
+	if attr == "" {
+		return nil, fmt.Errorf("ldap: malformed filter item %q", item)
+	}
+
+	if tag == FilterEqualityMatch && strings.Contains(value, "*") {
+		if value == "*" {
+			return NewPrimitive(ClassContext, FilterPresent, []byte(attr)), nil
+		}
+		return compileSubstrings(attr, value)
+	}
+	decoded, err := unescapeFilterValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return NewConstructed(ClassContext, tag, NewString(attr), NewString(decoded)), nil
+}
+
+func compileSubstrings(attr string, value string) (*Packet, error) {
+	parts := strings.Split(value, "*")
+	subs := NewSequence()
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		decoded, err := unescapeFilterValue(part)
+		if err != nil {
+			return nil, err
+		}
+		tag := byte(SubstringAny)
+		switch i {
+		case 0:
+			tag = SubstringInitial
+		case len(parts) - 1:
+			tag = SubstringFinal
+		}
+		subs.AppendChild(NewPrimitive(ClassContext, tag, []byte(decoded)))
+	}
+	return NewConstructed(ClassContext, FilterSubstrings, NewString(attr), subs), nil
+}
+
+func unescapeFilterValue(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", value)
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", value)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,74 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeFilter(t *testing.T) {
+	assert.Equal(t, `alice`, EscapeFilter("alice"))
+	assert.Equal(t, `\2a\29\28uid=\2a\29\5c`, EscapeFilter(`*)(uid=*)\`))
+	assert.Equal(t, `a\00b`, EscapeFilter("a\x00b"))
+}
+
+func TestCompileFilterEquality(t *testing.T) {
+	p, err := CompileFilter("(&(objectClass=person)(uid=" + EscapeFilter("al*ce") + "))")
+	require.NoError(t, err)
+	require.True(t, p.Is(ClassContext, FilterAnd))
+	require.Len(t, p.Children, 2)
+
+	uid := p.Children[1]
+	require.True(t, uid.Is(ClassContext, FilterEqualityMatch))
+	assert.Equal(t, "uid", uid.Children[0].String())
+	// 转义后的 * 是字面值，不会变成子串匹配
+	assert.Equal(t, "al*ce", uid.Children[1].String())
+
+	// 编码后可以被完整解析回来
+	parsed, err := ParsePacket(p.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, p.Bytes(), parsed.Bytes())
+}
+
+func TestCompileFilterPresenceSubstringsAndNot(t *testing.T) {
+	p, err := CompileFilter("(|(mail=*)(cn=Al*ce*Smith)(!(uid>=b)))")
+	require.NoError(t, err)
+	require.Len(t, p.Children, 3)
+
+	assert.True(t, p.Children[0].Is(ClassContext, FilterPresent))
+	assert.Equal(t, "mail", p.Children[0].String())
+
+	subs := p.Children[1]
+	require.True(t, subs.Is(ClassContext, FilterSubstrings))
+	parts := subs.Children[1].Children
+	require.Len(t, parts, 3)
+	assert.Equal(t, byte(SubstringInitial), parts[0].Tag)
+	assert.Equal(t, byte(SubstringAny), parts[1].Tag)
+	assert.Equal(t, byte(SubstringFinal), parts[2].Tag)
+
+	not := p.Children[2]
+	require.True(t, not.Is(ClassContext, FilterNot))
+	assert.True(t, not.Children[0].Is(ClassContext, FilterGreaterOrEqual))
+
+	// 不带括号的简单过滤器
+	p, err = CompileFilter("uid=alice")
+	require.NoError(t, err)
+	assert.True(t, p.Is(ClassContext, FilterEqualityMatch))
+}
+
+func TestCompileFilterRejectsMalformed(t *testing.T) {
+	for _, filter := range []string{"", "(uid=alice", "(&)", "(=alice)", "(uid=a)(cn=b)", `(uid=\zz)`, `(uid=a\2)`} {
+		_, err := CompileFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
+
+func TestIntegerEncoding(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, 1 << 40} {
+		got, err := decodeInteger(encodeInteger(v))
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+	assert.Equal(t, []byte{0x00, 0x80}, encodeInteger(128))
+}
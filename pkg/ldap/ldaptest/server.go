@@ -0,0 +1,337 @@
+// Package ldaptest 提供一个进程内的 LDAP 假服务端，用于在没有 OpenLDAP 容器时
+// 测试 Bind / Search / StartTLS 流程。只实现了 pkg/ldap 客户端会用到的操作。
+package ldaptest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/pkg/ldap"
+)
+
+const resultConfidentialityRequired = 13
+
+type Server struct {
+	URL string
+	// RequireTLS 为 true 时拒绝明文连接上的 Bind，用于验证 StartTLS 确实生效
+	RequireTLS bool
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	mu        sync.Mutex
+	entries   []*ldap.Entry
+	passwords map[string]string
+	binds     []string
+
+	wg sync.WaitGroup
+}
+
+// NewServer 在 127.0.0.1 的随机端口上启动假服务端
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		URL:       "ldap://" + listener.Addr().String(),
+		listener:  listener,
+		passwords: make(map[string]string),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// EnableStartTLS 生成自签名证书并开启 StartTLS，返回客户端可信任的根证书池。
+// 指定 dnsNames 时证书只包含这些域名，否则只包含 127.0.0.1
+func (s *Server) EnableStartTLS(dnsNames ...string) (*x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldaptest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if len(dnsNames) > 0 {
+		template.DNSNames = dnsNames
+	} else {
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	s.mu.Lock()
+	s.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}
+	s.mu.Unlock()
+	return pool, nil
+}
+
+// AddEntry 添加一条目录条目；password 非空时该 DN 可以绑定
+func (s *Server) AddEntry(dn string, password string, attributes map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, &ldap.Entry{DN: dn, Attributes: attributes})
+	if password != "" {
+		s.passwords[strings.ToLower(dn)] = password
+	}
+}
+
+// Binds 返回成功绑定过的 DN，按时间顺序
+func (s *Server) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	isTLS := false
+	bound := false
+	for {
+		_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+		msg, err := ldap.ReadPacket(reader)
+		if err != nil || len(msg.Children) < 2 {
+			return
+		}
+		id, _ := msg.Children[0].Int()
+		op := msg.Children[1]
+		reply := func(resp *ldap.Packet) bool {
+			_, err := conn.Write(ldap.NewSequence(ldap.NewInteger(id), resp).Bytes())
+			return err == nil
+		}
+
+		switch {
+		case op.Is(ldap.ClassApplication, ldap.ApplicationUnbindRequest):
+			return
+		case op.Is(ldap.ClassApplication, ldap.ApplicationBindRequest):
+			code := s.bind(op, isTLS)
+			bound = code == ldap.ResultSuccess
+			if !reply(result(ldap.ApplicationBindResponse, code)) {
+				return
+			}
+		case op.Is(ldap.ClassApplication, ldap.ApplicationExtendedRequest):
+			s.mu.Lock()
+			tlsConfig := s.tlsConfig
+			s.mu.Unlock()
+			if tlsConfig == nil || isTLS || len(op.Children) == 0 || op.Children[0].String() != ldap.OIDStartTLS {
+				if !reply(result(ldap.ApplicationExtendedResponse, 2)) {
+					return
+				}
+				continue
+			}
+			if !reply(result(ldap.ApplicationExtendedResponse, ldap.ResultSuccess)) {
+				return
+			}
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(tlsConn)
+			isTLS = true
+		case op.Is(ldap.ClassApplication, ldap.ApplicationSearchRequest):
+			if !bound {
+				if !reply(result(ldap.ApplicationSearchResultDone, 50)) {
+					return
+				}
+				continue
+			}
+			for _, entry := range s.search(op) {
+				if !reply(entry) {
+					return
+				}
+			}
+			if !reply(result(ldap.ApplicationSearchResultDone, ldap.ResultSuccess)) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *Server) bind(op *ldap.Packet, isTLS bool) int {
+	if len(op.Children) < 3 {
+		return 2
+	}
+	if s.RequireTLS && !isTLS {
+		return resultConfidentialityRequired
+	}
+	dn := op.Children[1].String()
+	password := op.Children[2].String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expected, ok := s.passwords[strings.ToLower(dn)]
+	if !ok || password == "" || expected != password {
+		return ldap.ResultInvalidCredentials
+	}
+	s.binds = append(s.binds, dn)
+	return ldap.ResultSuccess
+}
+
+func (s *Server) search(op *ldap.Packet) []*ldap.Packet {
+	if len(op.Children) < 8 {
+		return nil
+	}
+	baseDN := strings.ToLower(op.Children[0].String())
+	filter := op.Children[6]
+	var wanted []string
+	for _, attr := range op.Children[7].Children {
+		wanted = append(wanted, attr.String())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*ldap.Packet
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), baseDN) || !matches(entry, filter) {
+			continue
+		}
+		attrs := ldap.NewSequence()
+		for name, values := range entry.Attributes {
+			if len(wanted) > 0 && !containsFold(wanted, name) {
+				continue
+			}
+			set := ldap.NewConstructed(ldap.ClassUniversal, ldap.TagSet)
+			for _, v := range values {
+				set.AppendChild(ldap.NewString(v))
+			}
+			attrs.AppendChild(ldap.NewSequence(ldap.NewString(name), set))
+		}
+		out = append(out, ldap.NewConstructed(ldap.ClassApplication, ldap.ApplicationSearchResultEntry,
+			ldap.NewString(entry.DN), attrs))
+	}
+	return out
+}
+
+func matches(entry *ldap.Entry, filter *ldap.Packet) bool {
+	if filter.Class != ldap.ClassContext {
+		return false
+	}
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(filter.Children) == 1 && !matches(entry, filter.Children[0])
+	case ldap.FilterPresent:
+		return len(entry.GetAttributeValues(filter.String())) > 0
+	case ldap.FilterEqualityMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		return containsFold(entry.GetAttributeValues(filter.Children[0].String()), filter.Children[1].String())
+	case ldap.FilterSubstrings:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		for _, value := range entry.GetAttributeValues(filter.Children[0].String()) {
+			if matchSubstrings(strings.ToLower(value), filter.Children[1].Children) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func matchSubstrings(value string, parts []*ldap.Packet) bool {
+	for _, part := range parts {
+		sub := strings.ToLower(part.String())
+		switch part.Tag {
+		case ldap.SubstringInitial:
+			if !strings.HasPrefix(value, sub) {
+				return false
+			}
+			value = value[len(sub):]
+		case ldap.SubstringFinal:
+			return strings.HasSuffix(value, sub)
+		default:
+			idx := strings.Index(value, sub)
+			if idx < 0 {
+				return false
+			}
+			value = value[idx+len(sub):]
+		}
+	}
+	return true
+}
+
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func result(tag byte, code int) *ldap.Packet {
+	return ldap.NewConstructed(ldap.ClassApplication, tag,
+		ldap.NewEnumerated(int64(code)),
+		ldap.NewString(""),
+		ldap.NewString(""),
+	)
+}
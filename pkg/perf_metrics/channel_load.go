@@ -0,0 +1,121 @@
+package perfmetrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+const (
+	// channelLoadEwmaAlpha 新样本在 EWMA 中的权重
+	channelLoadEwmaAlpha = 0.2
+	// channelLoadStaleAfter 超过该时长未更新的延迟视为无效，避免一次慢请求让渠道永久得不到流量
+	channelLoadStaleAfter = 5 * time.Minute
+)
+
+// channelLoad 单个渠道在本节点内的实时负载。进行中请求数无锁维护，EWMA 由 mu 保护。
+type channelLoad struct {
+	inflight atomic.Int64
+
+	mu          sync.Mutex
+	latencyMs   float64
+	latencyAt   time.Time
+	ttftMs      float64
+	ttftAt      time.Time
+	errorRate   float64
+	errorRateAt time.Time
+}
+
+var channelLoads sync.Map // channelId -> *channelLoad
+
+func getChannelLoad(channelId int) *channelLoad {
+	if v, ok := channelLoads.Load(channelId); ok {
+		return v.(*channelLoad)
+	}
+	v, _ := channelLoads.LoadOrStore(channelId, &channelLoad{})
+	return v.(*channelLoad)
+}
+
+func ewma(old float64, oldAt time.Time, sample float64, now time.Time) float64 {
+	if oldAt.IsZero() || now.Sub(oldAt) > channelLoadStaleAfter {
+		return sample
+	}
+	return old + channelLoadEwmaAlpha*(sample-old)
+}
+
+// ChannelAttempt 一次上游尝试，由 StartChannelAttempt 创建，尝试结束时调用 Finish
+type ChannelAttempt struct {
+	channelId int
+	start     time.Time
+	finished  atomic.Bool
+}
+
+// StartChannelAttempt 记录渠道开始一次上游请求，进行中请求数 +1
+func StartChannelAttempt(channelId int) *ChannelAttempt {
+	getChannelLoad(channelId).inflight.Add(1)
+	return &ChannelAttempt{channelId: channelId, start: time.Now()}
+}
+
+// Release 结束本次尝试但不计入延迟与失败率（如客户端参数错误），重复调用无副作用
+func (a *ChannelAttempt) Release() {
+	if a == nil || !a.finished.CompareAndSwap(false, true) {
+		return
+	}
+	getChannelLoad(a.channelId).inflight.Add(-1)
+}
+
+// Finish 结束本次尝试：进行中请求数 -1，并更新渠道的延迟与失败率 EWMA。
+// 成功时更新延迟（流式请求同时更新首字延迟），失败只计入失败率。重复调用无副作用。
+func (a *ChannelAttempt) Finish(info *relaycommon.RelayInfo, success bool) {
+	if a == nil || !a.finished.CompareAndSwap(false, true) {
+		return
+	}
+	load := getChannelLoad(a.channelId)
+	load.inflight.Add(-1)
+
+	now := time.Now()
+	load.mu.Lock()
+	defer load.mu.Unlock()
+	if success {
+		load.errorRate = ewma(load.errorRate, load.errorRateAt, 0, now)
+		load.errorRateAt = now
+		load.latencyMs = ewma(load.latencyMs, load.latencyAt, float64(now.Sub(a.start).Milliseconds()), now)
+		load.latencyAt = now
+		if info != nil && info.IsStream && info.HasSendResponse() && info.FirstResponseTime.After(a.start) {
+			load.ttftMs = ewma(load.ttftMs, load.ttftAt, float64(info.FirstResponseTime.Sub(a.start).Milliseconds()), now)
+			load.ttftAt = now
+		}
+		return
+	}
+	load.errorRate = ewma(load.errorRate, load.errorRateAt, 1, now)
+	load.errorRateAt = now
+}
+
+// GetChannelLoad 返回渠道在本节点内的实时负载快照
+func GetChannelLoad(channelId int) model.ChannelLoad {
+	v, ok := channelLoads.Load(channelId)
+	if !ok {
+		return model.ChannelLoad{}
+	}
+	load := v.(*channelLoad)
+	now := time.Now()
+	result := model.ChannelLoad{Inflight: load.inflight.Load()}
+	if result.Inflight < 0 {
+		result.Inflight = 0
+	}
+	load.mu.Lock()
+	defer load.mu.Unlock()
+	if !load.latencyAt.IsZero() && now.Sub(load.latencyAt) <= channelLoadStaleAfter {
+		result.LatencyMs, result.HasLatency = load.latencyMs, true
+	}
+	if !load.ttftAt.IsZero() && now.Sub(load.ttftAt) <= channelLoadStaleAfter {
+		result.TtftMs, result.HasTtft = load.ttftMs, true
+	}
+	if !load.errorRateAt.IsZero() && now.Sub(load.errorRateAt) <= channelLoadStaleAfter {
+		result.ErrorRate = load.errorRate
+	}
+	return result
+}
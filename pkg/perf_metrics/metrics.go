@@ -21,6 +21,7 @@ var hotBuckets sync.Map
 const seriesSchema = "dbcd0a3c01b55203"
 
 func Init() {
+	model.ChannelLoadFunc = GetChannelLoad
 	go flushLoop()
 }
 
@@ -0,0 +1,89 @@
+package prommetrics
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Init 注册依赖数据库连接的指标，需在 model.InitDB/InitLogDB 之后调用
+func Init() {
+	if sqlDB, err := model.DB.DB(); err == nil {
+		registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, "main"))
+	}
+	if model.LOG_DB != nil && model.LOG_DB != model.DB {
+		if sqlDB, err := model.LOG_DB.DB(); err == nil {
+			registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, "log"))
+		}
+	}
+}
+
+var systemTaskQueueDepthDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "system_task", "queue_depth"),
+	"Pending and running system tasks by type.",
+	[]string{"type", "status"}, nil,
+)
+
+// systemTaskCollector 在抓取时查询系统任务队列深度
+type systemTaskCollector struct{}
+
+func (systemTaskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- systemTaskQueueDepthDesc
+}
+
+func (systemTaskCollector) Collect(ch chan<- prometheus.Metric) {
+	if model.DB == nil {
+		return
+	}
+	counts, err := model.CountActiveSystemTasks()
+	if err != nil {
+		common.SysError(fmt.Sprintf("collect system task queue depth failed: %v", err))
+		return
+	}
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(systemTaskQueueDepthDesc, prometheus.GaugeValue,
+			float64(count.Count), count.Type, string(count.Status))
+	}
+}
+
+var (
+	redisPoolHitsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "redis_pool", "hits_total"),
+		"Times a free connection was found in the Redis pool.", nil, nil)
+	redisPoolMissesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "redis_pool", "misses_total"),
+		"Times a free connection was not found in the Redis pool.", nil, nil)
+	redisPoolTimeoutsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "redis_pool", "timeouts_total"),
+		"Times a wait for a Redis connection timed out.", nil, nil)
+	redisPoolConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "redis_pool", "connections"),
+		"Redis pool connections by state.", []string{"state"}, nil)
+)
+
+// redisPoolCollector 导出 go-redis 连接池统计，未启用 Redis 时不输出
+type redisPoolCollector struct{}
+
+func (redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisPoolHitsDesc
+	ch <- redisPoolMissesDesc
+	ch <- redisPoolTimeoutsDesc
+	ch <- redisPoolConnsDesc
+}
+
+func (redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	if !common.RedisEnabled || common.RDB == nil {
+		return
+	}
+	stats := common.RDB.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisPoolHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(redisPoolMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(redisPoolTimeoutsDesc, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(redisPoolConnsDesc, prometheus.GaugeValue, float64(stats.TotalConns), "total")
+	ch <- prometheus.MustNewConstMetric(redisPoolConnsDesc, prometheus.GaugeValue, float64(stats.IdleConns), "idle")
+	ch <- prometheus.MustNewConstMetric(redisPoolConnsDesc, prometheus.GaugeValue, float64(stats.StaleConns), "stale")
+}
@@ -0,0 +1,127 @@
+package prommetrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "newapi"
+
+// 使用独立 registry，避免第三方库注册到默认 registry 的指标混入
+var registry = prometheus.NewRegistry()
+
+var relayLabels = []string{"model", "group", "channel_id", "relay_format"}
+
+var (
+	relayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_requests_total",
+		Help:      "Relay requests that reached an upstream channel, by final status.",
+	}, append(relayLabels, "status"))
+
+	relayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "End-to-end relay latency including retries.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, relayLabels)
+
+	relayTtft = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_time_to_first_token_seconds",
+		Help:      "Time to first streamed response chunk.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, relayLabels)
+
+	billingQuota = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "billing_quota_total",
+		Help:      "Quota consumed by settled relay requests.",
+	}, []string{"model", "group", "channel_id"})
+
+	billingTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "billing_tokens_total",
+		Help:      "Tokens billed by settled relay requests.",
+	}, []string{"model", "group", "channel_id", "type"})
+
+	channelRateLimitSkips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_rate_limit_skips_total",
+		Help:      "Channel selections skipped because the channel rate limit was saturated.",
+	}, []string{"channel_id"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		relayRequests,
+		relayDuration,
+		relayTtft,
+		billingQuota,
+		billingTokens,
+		channelRateLimitSkips,
+		systemTaskCollector{},
+		redisPoolCollector{},
+	)
+}
+
+// Handler 返回 /metrics 的 HTTP handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func relayLabelValues(info *relaycommon.RelayInfo) []string {
+	group := info.UsingGroup
+	if group == "" {
+		group = "default"
+	}
+	return []string{info.OriginModelName, group, strconv.Itoa(info.ChannelId), string(info.RelayFormat)}
+}
+
+// RecordRelay 记录一次到达上游的 relay 请求（成功或最终失败）
+func RecordRelay(info *relaycommon.RelayInfo, success bool) {
+	if info == nil || info.OriginModelName == "" {
+		return
+	}
+	labels := relayLabelValues(info)
+	status := "success"
+	if !success {
+		status = "error"
+	}
+	relayRequests.WithLabelValues(append(labels, status)...).Inc()
+	relayDuration.WithLabelValues(labels...).Observe(time.Since(info.StartTime).Seconds())
+	if info.IsStream && info.HasSendResponse() {
+		relayTtft.WithLabelValues(labels...).Observe(info.FirstResponseTime.Sub(info.StartTime).Seconds())
+	}
+}
+
+// RecordBilling 记录一次结算的额度与 token
+func RecordBilling(info *relaycommon.RelayInfo, quota int, promptTokens int, completionTokens int) {
+	if info == nil {
+		return
+	}
+	labels := relayLabelValues(info)[:3]
+	if quota > 0 {
+		billingQuota.WithLabelValues(labels...).Add(float64(quota))
+	}
+	if promptTokens > 0 {
+		billingTokens.WithLabelValues(append(labels, "prompt")...).Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		billingTokens.WithLabelValues(append(labels, "completion")...).Add(float64(completionTokens))
+	}
+}
+
+// RecordChannelRateLimitSkip 记录一次因渠道限流而跳过的渠道选择
+func RecordChannelRateLimitSkip(channelId int) {
+	channelRateLimitSkips.WithLabelValues(strconv.Itoa(channelId)).Inc()
+}
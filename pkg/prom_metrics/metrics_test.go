@@ -0,0 +1,46 @@
+package prommetrics
+
+import (
+	"testing"
+	"time"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	var metric dto.Metric
+	require.NoError(t, counter.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestRecordRelayAndBilling(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		OriginModelName: "gpt-4o-metrics-test",
+		UsingGroup:      "vip",
+		RelayFormat:     types.RelayFormatOpenAI,
+		StartTime:       time.Now().Add(-time.Second),
+	}
+	info.ChannelMeta = &relaycommon.ChannelMeta{ChannelId: 42}
+
+	RecordRelay(info, true)
+	RecordRelay(info, false)
+	RecordBilling(info, 1500, 100, 20)
+
+	assert.Equal(t, float64(1), counterValue(t, relayRequests.WithLabelValues("gpt-4o-metrics-test", "vip", "42", "openai", "success")))
+	assert.Equal(t, float64(1), counterValue(t, relayRequests.WithLabelValues("gpt-4o-metrics-test", "vip", "42", "openai", "error")))
+	assert.Equal(t, float64(1500), counterValue(t, billingQuota.WithLabelValues("gpt-4o-metrics-test", "vip", "42")))
+	assert.Equal(t, float64(100), counterValue(t, billingTokens.WithLabelValues("gpt-4o-metrics-test", "vip", "42", "prompt")))
+	assert.Equal(t, float64(20), counterValue(t, billingTokens.WithLabelValues("gpt-4o-metrics-test", "vip", "42", "completion")))
+}
+
+func TestRecordRelayIgnoresMissingModel(t *testing.T) {
+	RecordRelay(&relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}, true)
+	RecordRelay(nil, true)
+	assert.Equal(t, float64(0), counterValue(t, relayRequests.WithLabelValues("", "default", "0", "", "success")))
+}
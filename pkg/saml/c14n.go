@@ -0,0 +1,147 @@
+package saml
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+// Exclusive XML Canonicalization 1.0（不含注释），https://www.w3.org/TR/xml-exc-c14n/
+// 只渲染元素与属性实际用到的命名空间；inclusivePrefixes 对应 InclusiveNamespaces PrefixList。
+
+// Canonicalize 规范化以 el 为顶点的子树，exclude 指定要剔除的子元素（enveloped-signature 变换）
+func Canonicalize(el *Element, exclude *Element, inclusivePrefixes []string) []byte {
+	c := &canonicalizer{exclude: exclude, inclusive: make(map[string]bool)}
+	for _, prefix := range inclusivePrefixes {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		c.inclusive[prefix] = true
+	}
+	c.writeElement(el, map[string]string{})
+	return []byte(c.b.String())
+}
+
+type canonicalizer struct {
+	b         strings.Builder
+	exclude   *Element
+	inclusive map[string]bool
+}
+
+type canonicalAttr struct {
+	namespace string
+	local     string
+	qname     string
+	value     string
+}
+
+func (c *canonicalizer) writeElement(el *Element, rendered map[string]string) {
+	// 收集需要渲染的前缀：元素名、带前缀的属性、以及 PrefixList 中的前缀
+	prefixes := map[string]bool{el.Prefix: true}
+	var attrs []canonicalAttr
+	for _, attr := range el.Attrs {
+		if isNamespaceDecl(attr) {
+			continue
+		}
+		namespace := ""
+		if attr.Name.Space != "" {
+			prefixes[attr.Name.Space] = true
+			namespace, _ = el.LookupNamespace(attr.Name.Space)
+		}
+		attrs = append(attrs, canonicalAttr{
+			namespace: namespace,
+			local:     attr.Name.Local,
+			qname:     qualifiedName(attr.Name.Space, attr.Name.Local),
+			value:     attr.Value,
+		})
+	}
+	for prefix := range c.inclusive {
+		if _, ok := el.LookupNamespace(prefix); ok {
+			prefixes[prefix] = true
+		}
+	}
+
+	next := make(map[string]string, len(rendered))
+	for k, v := range rendered {
+		next[k] = v
+	}
+	var decls []string
+	for prefix := range prefixes {
+		if prefix == "xml" {
+			continue
+		}
+		uri, ok := el.LookupNamespace(prefix)
+		if !ok {
+			continue
+		}
+		previous, wasRendered := rendered[prefix]
+		if prefix == "" && !wasRendered {
+			// 从未输出过默认命名空间时，空默认命名空间无需声明
+			wasRendered = uri == ""
+		}
+		if wasRendered && previous == uri {
+			continue
+		}
+		next[prefix] = uri
+		decls = append(decls, prefix)
+	}
+	sort.Strings(decls)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].namespace != attrs[j].namespace {
+			return attrs[i].namespace < attrs[j].namespace
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	name := qualifiedName(el.Prefix, el.Local)
+	c.b.WriteByte('<')
+	c.b.WriteString(name)
+	for _, prefix := range decls {
+		if prefix == "" {
+			c.b.WriteString(` xmlns="`)
+		} else {
+			c.b.WriteString(` xmlns:` + prefix + `="`)
+		}
+		c.b.WriteString(escapeAttr(next[prefix]))
+		c.b.WriteByte('"')
+	}
+	for _, attr := range attrs {
+		c.b.WriteString(" " + attr.qname + `="` + escapeAttr(attr.value) + `"`)
+	}
+	c.b.WriteByte('>')
+	for _, child := range el.Children {
+		switch v := child.(type) {
+		case string:
+			c.b.WriteString(escapeText(v))
+		case *Element:
+			if v != c.exclude {
+				c.writeElement(v, next)
+			}
+		}
+	}
+	c.b.WriteString("</" + name + ">")
+}
+
+func isNamespaceDecl(attr xml.Attr) bool {
+	return attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns")
+}
+
+func qualifiedName(prefix string, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
@@ -0,0 +1,52 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 期望输出由 xmllint --exc-c14n 生成
+func TestCanonicalizeMatchesReference(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "saml response",
+			input: "<samlp:Response xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\" xmlns:saml=\"urn:oasis:names:tc:SAML:2.0:assertion\" xmlns:unused=\"urn:x\" ID=\"_r1\" Version=\"2.0\"   IssueInstant=\"2026-01-01T00:00:00Z\"><saml:Issuer>https://idp&amp;x</saml:Issuer><saml:Assertion xmlns=\"urn:default\" b=\"2\" a=\"1\" saml:z=\"q&quot;&lt;\" ID=\"_a1\"><Foo/><bar xmlns=\"\">t&gt;x&#13;</bar><saml:AttributeValue xmlns:xs=\"http://www.w3.org/2001/XMLSchema\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:type=\"xs:string\">v</saml:AttributeValue></saml:Assertion></samlp:Response>",
+			want:  "<samlp:Response xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\" ID=\"_r1\" IssueInstant=\"2026-01-01T00:00:00Z\" Version=\"2.0\"><saml:Issuer xmlns:saml=\"urn:oasis:names:tc:SAML:2.0:assertion\">https://idp&amp;x</saml:Issuer><saml:Assertion xmlns:saml=\"urn:oasis:names:tc:SAML:2.0:assertion\" ID=\"_a1\" a=\"1\" b=\"2\" saml:z=\"q&quot;&lt;\"><Foo xmlns=\"urn:default\"></Foo><bar>t&gt;x&#xD;</bar><saml:AttributeValue xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:type=\"xs:string\">v</saml:AttributeValue></saml:Assertion></samlp:Response>",
+		},
+		{
+			name:  "default namespace and redeclared prefix",
+			input: "<a:root xmlns:a=\"urn:a\" xmlns:b=\"urn:b\" xmlns=\"urn:d\" b:y=\"1\" a:x=\"2\" z=\"3\">\n  <child b:k=\"v&#9;w&#10;\">text\n<inner xmlns=\"\"><deep xmlns=\"urn:d\"/></inner><b:x xmlns:b=\"urn:b2\"><b:y/></b:x></child>\n<a:e/>\n</a:root>",
+			want:  "<a:root xmlns:a=\"urn:a\" xmlns:b=\"urn:b\" z=\"3\" a:x=\"2\" b:y=\"1\">\n  <child xmlns=\"urn:d\" b:k=\"v&#x9;w&#xA;\">text\n<inner xmlns=\"\"><deep xmlns=\"urn:d\"></deep></inner><b:x xmlns:b=\"urn:b2\"><b:y></b:y></b:x></child>\n<a:e></a:e>\n</a:root>",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ParseXML([]byte(tc.input))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(Canonicalize(root, nil, nil)))
+		})
+	}
+}
+
+func TestCanonicalizeSubtreeAndExclusion(t *testing.T) {
+	root, err := ParseXML([]byte(`<a:r xmlns:a="urn:a" xmlns:b="urn:b"><a:s ID="x"><b:t/><a:sig/></a:s></a:r>`))
+	require.NoError(t, err)
+	signed := root.ChildElements()[0]
+	sig := signed.ChildElements()[1]
+
+	// 祖先上声明的命名空间只在被使用时输出
+	assert.Equal(t, `<a:s xmlns:a="urn:a" ID="x"><b:t xmlns:b="urn:b"></b:t></a:s>`, string(Canonicalize(signed, sig, nil)))
+	// PrefixList 中的前缀即使未使用也会输出
+	assert.Equal(t, `<a:s xmlns:a="urn:a" xmlns:b="urn:b" ID="x"><b:t></b:t></a:s>`, string(Canonicalize(signed, sig, []string{"b"})))
+}
+
+func TestParseXMLRejectsDTD(t *testing.T) {
+	_, err := ParseXML([]byte(`<?xml version="1.0"?><!DOCTYPE r [<!ENTITY x "y">]><r>&x;</r>`))
+	assert.Error(t, err)
+}
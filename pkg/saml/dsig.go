@@ -0,0 +1,185 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// XML-DSig 校验，只接受 SAML IdP 实际使用的组合：
+// 单个 Reference 指向被签名元素自身、enveloped-signature + exc-c14n 变换、RSA-SHA256/512。
+// SHA-1 与 inclusive c14n 一律拒绝。
+
+const (
+	nsDSig = "http://www.w3.org/2000/09/xmldsig#"
+
+	AlgExcC14N      = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	AlgEnveloped    = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	AlgRSASHA256    = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	AlgRSASHA512    = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	AlgDigestSHA256 = "http://www.w3.org/2001/04/xmlenc#sha256"
+	AlgDigestSHA512 = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+var (
+	errNotSigned        = errors.New("saml: element is not signed")
+	errInvalidSignature = errors.New("saml: invalid signature")
+)
+
+// signatureOf 返回元素的直接子 Signature，多于一个视为非法
+func signatureOf(el *Element) (*Element, error) {
+	sigs := el.FindChildren(nsDSig, "Signature")
+	switch len(sigs) {
+	case 0:
+		return nil, errNotSigned
+	case 1:
+		return sigs[0], nil
+	default:
+		return nil, errors.New("saml: multiple signatures on one element")
+	}
+}
+
+// verifySignature 校验 el 上的 enveloped 签名。调用方之后只能使用 el 本身的内容，
+// 不能重新在文档中按 ID 查找，以防签名包装（XSW）攻击。
+func verifySignature(el *Element, certs []*x509.Certificate) error {
+	sig, err := signatureOf(el)
+	if err != nil {
+		return err
+	}
+	id := el.Attr("ID")
+	if id == "" {
+		return errors.New("saml: signed element has no ID")
+	}
+
+	signedInfo := sig.FindChild(nsDSig, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("saml: signature has no SignedInfo")
+	}
+	c14nMethod := signedInfo.FindChild(nsDSig, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.Attr("Algorithm") != AlgExcC14N {
+		return errors.New("saml: unsupported canonicalization method")
+	}
+	signatureMethod := signedInfo.FindChild(nsDSig, "SignatureMethod")
+	if signatureMethod == nil {
+		return errors.New("saml: signature has no SignatureMethod")
+	}
+	signatureHash, err := signatureHashFor(signatureMethod.Attr("Algorithm"))
+	if err != nil {
+		return err
+	}
+
+	references := signedInfo.FindChildren(nsDSig, "Reference")
+	if len(references) != 1 {
+		return errors.New("saml: signature must have exactly one reference")
+	}
+	reference := references[0]
+	if reference.Attr("URI") != "#"+id {
+		return errors.New("saml: signature reference does not match signed element")
+	}
+	prefixes, err := referenceTransforms(reference)
+	if err != nil {
+		return err
+	}
+	digestMethod := reference.FindChild(nsDSig, "DigestMethod")
+	if digestMethod == nil {
+		return errors.New("saml: reference has no DigestMethod")
+	}
+	digestHash, err := digestHashFor(digestMethod.Attr("Algorithm"))
+	if err != nil {
+		return err
+	}
+	expectedDigest, err := decodeBase64(reference.FindChild(nsDSig, "DigestValue").Text())
+	if err != nil {
+		return fmt.Errorf("saml: invalid digest value: %w", err)
+	}
+	h := digestHash()
+	h.Write(Canonicalize(el, sig, prefixes))
+	if subtle.ConstantTimeCompare(h.Sum(nil), expectedDigest) != 1 {
+		return errors.New("saml: digest mismatch")
+	}
+
+	signatureValue, err := decodeBase64(sig.FindChild(nsDSig, "SignatureValue").Text())
+	if err != nil {
+		return fmt.Errorf("saml: invalid signature value: %w", err)
+	}
+	hashed := signatureHash.New()
+	hashed.Write(Canonicalize(signedInfo, nil, inclusivePrefixes(c14nMethod)))
+	digest := hashed.Sum(nil)
+	for _, cert := range certs {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		if rsa.VerifyPKCS1v15(pub, signatureHash, digest, signatureValue) == nil {
+			return nil
+		}
+	}
+	return errInvalidSignature
+}
+
+func referenceTransforms(reference *Element) ([]string, error) {
+	transforms := reference.FindChild(nsDSig, "Transforms")
+	if transforms == nil {
+		return nil, errors.New("saml: reference has no transforms")
+	}
+	var prefixes []string
+	hasC14N := false
+	for _, transform := range transforms.FindChildren(nsDSig, "Transform") {
+		switch transform.Attr("Algorithm") {
+		case AlgEnveloped:
+		case AlgExcC14N:
+			hasC14N = true
+			prefixes = inclusivePrefixes(transform)
+		default:
+			return nil, fmt.Errorf("saml: unsupported transform %q", transform.Attr("Algorithm"))
+		}
+	}
+	if !hasC14N {
+		return nil, errors.New("saml: reference must use exclusive canonicalization")
+	}
+	return prefixes, nil
+}
+
+func inclusivePrefixes(el *Element) []string {
+	for _, child := range el.ChildElements() {
+		if child.Local == "InclusiveNamespaces" && child.Namespace() == AlgExcC14N {
+			return strings.Fields(child.Attr("PrefixList"))
+		}
+	}
+	return nil
+}
+
+func signatureHashFor(alg string) (crypto.Hash, error) {
+	switch alg {
+	case AlgRSASHA256:
+		return crypto.SHA256, nil
+	case AlgRSASHA512:
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("saml: unsupported signature method %q", alg)
+	}
+}
+
+func digestHashFor(alg string) (func() hash.Hash, error) {
+	switch alg {
+	case AlgDigestSHA256:
+		return sha256.New, nil
+	case AlgDigestSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("saml: unsupported digest method %q", alg)
+	}
+}
+
+// decodeBase64 忽略 IdP 常见的换行与缩进
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
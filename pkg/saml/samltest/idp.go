@@ -0,0 +1,225 @@
+// Package samltest 提供一个进程内的 SAML IdP，用于在没有真实 IdP 时签发已签名的 Response，
+// 以及从 SP 生成的登录地址中解出 AuthnRequest。
+package samltest
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/pkg/saml"
+)
+
+const (
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+)
+
+type IdP struct {
+	EntityID    string
+	Certificate *x509.Certificate
+	Key         *rsa.PrivateKey
+}
+
+// NewIdP 生成自签名的 RSA 签名证书
+func NewIdP(entityID string) (*IdP, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := selfSigned(key, entityID)
+	if err != nil {
+		return nil, err
+	}
+	return &IdP{EntityID: entityID, Certificate: cert, Key: key}, nil
+}
+
+// CertificatePEM 返回 PEM 格式的签名证书，便于写入设置
+func (idp *IdP) CertificatePEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: idp.Certificate.Raw}))
+}
+
+// Response 描述要签发的登录结果，零值字段使用合理默认值
+type Response struct {
+	InResponseTo string
+	ACSURL       string
+	Audience     string
+	NameID       string
+	NameIDFormat string
+	Attributes   map[string][]string
+
+	IssueInstant time.Time
+	// 断言有效期，默认 5 分钟
+	Lifetime time.Duration
+
+	SignResponse  bool
+	SignAssertion bool
+}
+
+// XML 生成 Response 文档，签名按 SignAssertion / SignResponse 添加
+func (idp *IdP) XML(r Response) (string, error) {
+	now := r.IssueInstant
+	if now.IsZero() {
+		now = time.Now()
+	}
+	lifetime := r.Lifetime
+	if lifetime == 0 {
+		lifetime = 5 * time.Minute
+	}
+	format := r.NameIDFormat
+	if format == "" {
+		format = saml.NameIDFormatUnspecified
+	}
+	issueInstant := now.UTC().Format(time.RFC3339)
+	notOnOrAfter := now.Add(lifetime).UTC().Format(time.RFC3339)
+	responseID, assertionID := newID(), newID()
+
+	var attrs strings.Builder
+	if len(r.Attributes) > 0 {
+		attrs.WriteString("<saml:AttributeStatement>")
+		for name, values := range r.Attributes {
+			fmt.Fprintf(&attrs, `<saml:Attribute Name="%s">`, escape(name))
+			for _, value := range values {
+				fmt.Fprintf(&attrs, `<saml:AttributeValue xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">%s</saml:AttributeValue>`, escape(value))
+			}
+			attrs.WriteString("</saml:Attribute>")
+		}
+		attrs.WriteString("</saml:AttributeStatement>")
+	}
+
+	assertion := fmt.Sprintf(`<saml:Assertion xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s"><saml:Issuer>%s</saml:Issuer>`+
+		`<saml:Subject><saml:NameID Format="%s">%s</saml:NameID><saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">`+
+		`<saml:SubjectConfirmationData InResponseTo="%s" NotOnOrAfter="%s" Recipient="%s"/></saml:SubjectConfirmation></saml:Subject>`+
+		`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s"><saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+		`<saml:AuthnStatement AuthnInstant="%s" SessionIndex="%s"><saml:AuthnContext><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport</saml:AuthnContextClassRef></saml:AuthnContext></saml:AuthnStatement>`+
+		`%s</saml:Assertion>`,
+		nsAssertion, assertionID, issueInstant, escape(idp.EntityID),
+		escape(format), escape(r.NameID),
+		escape(r.InResponseTo), notOnOrAfter, escape(r.ACSURL),
+		issueInstant, notOnOrAfter, escape(r.Audience),
+		issueInstant, assertionID, attrs.String())
+	if r.SignAssertion {
+		signed, err := idp.sign(assertion, assertionID)
+		if err != nil {
+			return "", err
+		}
+		assertion = signed
+	}
+
+	response := fmt.Sprintf(`<samlp:Response xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" InResponseTo="%s">`+
+		`<saml:Issuer>%s</saml:Issuer><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>%s</samlp:Response>`,
+		nsProtocol, nsAssertion, responseID, issueInstant, escape(r.ACSURL), escape(r.InResponseTo),
+		escape(idp.EntityID), assertion)
+	if r.SignResponse {
+		return idp.sign(response, responseID)
+	}
+	return response, nil
+}
+
+// EncodedResponse 返回 HTTP-POST 绑定中 SAMLResponse 表单字段的值
+func (idp *IdP) EncodedResponse(r Response) (string, error) {
+	doc, err := idp.XML(r)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(doc)), nil
+}
+
+// sign 为 ID 对应的元素生成 enveloped 签名，插入到其 Issuer 之后
+func (idp *IdP) sign(doc string, id string) (string, error) {
+	root, err := saml.ParseXML([]byte(doc))
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(saml.Canonicalize(root, nil, nil))
+	signedInfo := fmt.Sprintf(`<ds:SignedInfo xmlns:ds="%s"><ds:CanonicalizationMethod Algorithm="%s"/><ds:SignatureMethod Algorithm="%s"/>`+
+		`<ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="%s"/><ds:Transform Algorithm="%s"/></ds:Transforms>`+
+		`<ds:DigestMethod Algorithm="%s"/><ds:DigestValue>%s</ds:DigestValue></ds:Reference></ds:SignedInfo>`,
+		nsDSig, saml.AlgExcC14N, saml.AlgRSASHA256, id, saml.AlgEnveloped, saml.AlgExcC14N,
+		saml.AlgDigestSHA256, base64.StdEncoding.EncodeToString(digest[:]))
+	signedInfoEl, err := saml.ParseXML([]byte(signedInfo))
+	if err != nil {
+		return "", err
+	}
+	hashed := sha256.Sum256(saml.Canonicalize(signedInfoEl, nil, nil))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.Key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	block := fmt.Sprintf(`<ds:Signature xmlns:ds="%s">%s<ds:SignatureValue>%s</ds:SignatureValue>`+
+		`<ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>`,
+		nsDSig, signedInfo, base64.StdEncoding.EncodeToString(signature),
+		base64.StdEncoding.EncodeToString(idp.Certificate.Raw))
+
+	const issuerEnd = "</saml:Issuer>"
+	i := strings.Index(doc, issuerEnd)
+	if i < 0 {
+		return "", errors.New("samltest: issuer not found")
+	}
+	i += len(issuerEnd)
+	return doc[:i] + block + doc[i:], nil
+}
+
+// ParseAuthnRequestURL 从 HTTP-Redirect 绑定的登录地址中解出 AuthnRequest 与 RelayState
+func ParseAuthnRequestURL(rawURL string) (*saml.Element, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	query := u.Query()
+	compressed, err := base64.StdEncoding.DecodeString(query.Get("SAMLRequest"))
+	if err != nil {
+		return nil, "", err
+	}
+	raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return nil, "", err
+	}
+	request, err := saml.ParseXML(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	return request, query.Get("RelayState"), nil
+}
+
+func selfSigned(key *rsa.PrivateKey, commonName string) (*x509.Certificate, error) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func newID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("_%x", buf)
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
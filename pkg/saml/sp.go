@@ -0,0 +1,451 @@
+// Package saml 实现 SAML 2.0 Web SSO 的 SP 端：元数据、HTTP-Redirect 绑定的签名
+// AuthnRequest，以及 HTTP-POST 绑定的 Response / Assertion 校验。不支持加密断言与单点登出。
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+	BindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	BindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+
+	NameIDFormatUnspecified  = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	NameIDFormatEmailAddress = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	NameIDFormatPersistent   = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+
+	statusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	confirmationBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	defaultClockSkew   = 2 * time.Minute
+)
+
+// ServiceProvider 描述本系统作为 SP 的配置与信任的 IdP
+type ServiceProvider struct {
+	EntityID string
+	ACSURL   string
+	// 用于签名 AuthnRequest，可为空（此时请求不签名）
+	Certificate *x509.Certificate
+	PrivateKey  *rsa.PrivateKey
+
+	IdPEntityID string
+	IdPSSOURL   string
+	// 支持同时配置多张证书，便于 IdP 轮换签名证书
+	IdPCertificates []*x509.Certificate
+
+	NameIDFormat string
+	ClockSkew    time.Duration
+}
+
+// Assertion 是校验通过的断言内容
+type Assertion struct {
+	ID           string
+	Issuer       string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	// 以 Name 为键，若有 FriendlyName 也会以其为键重复收录
+	Attributes   map[string][]string
+	NotOnOrAfter time.Time
+}
+
+// AttributeValue 返回属性的第一个值
+func (a *Assertion) AttributeValue(name string) string {
+	if values := a.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Metadata 生成 SP 元数据，供 IdP 导入
+func (sp *ServiceProvider) Metadata() []byte {
+	var b bytes.Buffer
+	signed := sp.Certificate != nil && sp.PrivateKey != nil
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&b, `<md:EntityDescriptor xmlns:md="%s" entityID="%s">`, nsMetadata, escapeAttr(sp.EntityID))
+	fmt.Fprintf(&b, `<md:SPSSODescriptor AuthnRequestsSigned="%t" WantAssertionsSigned="true" protocolSupportEnumeration="%s">`, signed, nsProtocol)
+	if sp.Certificate != nil {
+		fmt.Fprintf(&b, `<md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="%s"><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`,
+			nsDSig, base64.StdEncoding.EncodeToString(sp.Certificate.Raw))
+	}
+	fmt.Fprintf(&b, `<md:NameIDFormat>%s</md:NameIDFormat>`, escapeText(sp.nameIDFormat()))
+	fmt.Fprintf(&b, `<md:AssertionConsumerService Binding="%s" Location="%s" index="0" isDefault="true"/>`, BindingHTTPPost, escapeAttr(sp.ACSURL))
+	b.WriteString(`</md:SPSSODescriptor></md:EntityDescriptor>`)
+	return b.Bytes()
+}
+
+// AuthnRequestURL 生成 HTTP-Redirect 绑定的登录地址，返回请求 ID 供校验 InResponseTo。
+// 配置了 SP 私钥时按 SAML Bindings §3.4.4.1 对查询串签名。
+func (sp *ServiceProvider) AuthnRequestURL(relayState string, now time.Time) (string, string, error) {
+	if sp.IdPSSOURL == "" {
+		return "", "", errors.New("saml: idp sso url is not configured")
+	}
+	id, err := newID()
+	if err != nil {
+		return "", "", err
+	}
+	request := fmt.Sprintf(`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s"><saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy Format="%s" AllowCreate="true"/></samlp:AuthnRequest>`,
+		nsProtocol, nsAssertion, id, now.UTC().Format(time.RFC3339),
+		escapeAttr(sp.IdPSSOURL), escapeAttr(sp.ACSURL), BindingHTTPPost,
+		escapeText(sp.EntityID), escapeAttr(sp.nameIDFormat()))
+
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := writer.Write([]byte(request)); err != nil {
+		return "", "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
+
+	// 签名覆盖的是编码后的原始查询串，参数顺序由规范固定
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	if sp.PrivateKey != nil {
+		query += "&SigAlg=" + url.QueryEscape(AlgRSASHA256)
+		digest := sha256.Sum256([]byte(query))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, sp.PrivateKey, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", "", err
+		}
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+	}
+
+	separator := "?"
+	if strings.Contains(sp.IdPSSOURL, "?") {
+		separator = "&"
+	}
+	return sp.IdPSSOURL + separator + query, id, nil
+}
+
+// ParseResponse 解码并校验 HTTP-POST 绑定提交的 SAMLResponse。
+// requestID 为发起登录时的 AuthnRequest ID，不接受 IdP 主动发起的登录。
+func (sp *ServiceProvider) ParseResponse(encoded string, requestID string, now time.Time) (*Assertion, error) {
+	if len(sp.IdPCertificates) == 0 {
+		return nil, errors.New("saml: idp certificate is not configured")
+	}
+	if requestID == "" {
+		return nil, errors.New("saml: missing authn request id")
+	}
+	raw, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid response encoding: %w", err)
+	}
+	response, err := ParseXML(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Is(nsProtocol, "Response") {
+		return nil, errors.New("saml: document is not a SAML response")
+	}
+	if err := checkUniqueIDs(response); err != nil {
+		return nil, err
+	}
+	if response.Attr("Version") != "2.0" {
+		return nil, errors.New("saml: unsupported response version")
+	}
+	if destination := response.Attr("Destination"); destination != "" && destination != sp.ACSURL {
+		return nil, fmt.Errorf("saml: unexpected response destination %q", destination)
+	}
+	if response.Attr("InResponseTo") != requestID {
+		return nil, errors.New("saml: response does not match the authn request")
+	}
+	if issuer := response.FindChild(nsAssertion, "Issuer"); issuer != nil && strings.TrimSpace(issuer.Text()) != sp.IdPEntityID {
+		return nil, fmt.Errorf("saml: unexpected response issuer %q", strings.TrimSpace(issuer.Text()))
+	}
+	if err := checkStatus(response); err != nil {
+		return nil, err
+	}
+	if response.FindChild(nsAssertion, "EncryptedAssertion") != nil {
+		return nil, errors.New("saml: encrypted assertions are not supported")
+	}
+	assertions := response.FindChildren(nsAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("saml: response must contain exactly one assertion")
+	}
+	assertion := assertions[0]
+
+	// Response 与 Assertion 至少一个签名有效；存在但无效的签名直接拒绝
+	responseErr := verifySignature(response, sp.IdPCertificates)
+	if responseErr != nil && !errors.Is(responseErr, errNotSigned) {
+		return nil, responseErr
+	}
+	assertionErr := verifySignature(assertion, sp.IdPCertificates)
+	if assertionErr != nil && !errors.Is(assertionErr, errNotSigned) {
+		return nil, assertionErr
+	}
+	if responseErr != nil && assertionErr != nil {
+		return nil, errNotSigned
+	}
+
+	return sp.validateAssertion(assertion, requestID, now)
+}
+
+func (sp *ServiceProvider) validateAssertion(el *Element, requestID string, now time.Time) (*Assertion, error) {
+	skew := sp.ClockSkew
+	if skew <= 0 {
+		skew = defaultClockSkew
+	}
+	if el.Attr("Version") != "2.0" {
+		return nil, errors.New("saml: unsupported assertion version")
+	}
+	issuer := strings.TrimSpace(el.FindChild(nsAssertion, "Issuer").Text())
+	if issuer != sp.IdPEntityID {
+		return nil, fmt.Errorf("saml: unexpected assertion issuer %q", issuer)
+	}
+
+	subject := el.FindChild(nsAssertion, "Subject")
+	if subject == nil {
+		return nil, errors.New("saml: assertion has no subject")
+	}
+	nameID := subject.FindChild(nsAssertion, "NameID")
+	if nameID == nil || strings.TrimSpace(nameID.Text()) == "" {
+		return nil, errors.New("saml: assertion has no NameID")
+	}
+	if err := checkSubjectConfirmation(subject, sp.ACSURL, requestID, now, skew); err != nil {
+		return nil, err
+	}
+
+	result := &Assertion{
+		ID:           el.Attr("ID"),
+		Issuer:       issuer,
+		NameID:       strings.TrimSpace(nameID.Text()),
+		NameIDFormat: nameID.Attr("Format"),
+		Attributes:   make(map[string][]string),
+	}
+	conditions := el.FindChild(nsAssertion, "Conditions")
+	if conditions == nil {
+		return nil, errors.New("saml: assertion has no conditions")
+	}
+	notOnOrAfter, err := checkConditions(conditions, sp.EntityID, now, skew)
+	if err != nil {
+		return nil, err
+	}
+	result.NotOnOrAfter = notOnOrAfter
+
+	if authn := el.FindChild(nsAssertion, "AuthnStatement"); authn != nil {
+		result.SessionIndex = authn.Attr("SessionIndex")
+	}
+	for _, statement := range el.FindChildren(nsAssertion, "AttributeStatement") {
+		for _, attr := range statement.FindChildren(nsAssertion, "Attribute") {
+			var values []string
+			for _, value := range attr.FindChildren(nsAssertion, "AttributeValue") {
+				values = append(values, strings.TrimSpace(value.Text()))
+			}
+			for _, key := range []string{attr.Attr("Name"), attr.Attr("FriendlyName")} {
+				if key != "" {
+					result.Attributes[key] = append(result.Attributes[key], values...)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func checkStatus(response *Element) error {
+	status := response.FindChild(nsProtocol, "Status")
+	if status == nil {
+		return errors.New("saml: response has no status")
+	}
+	code := status.FindChild(nsProtocol, "StatusCode")
+	if code == nil {
+		return errors.New("saml: response has no status code")
+	}
+	if value := code.Attr("Value"); value != statusSuccess {
+		message := strings.TrimSpace(status.FindChild(nsProtocol, "StatusMessage").Text())
+		if sub := code.FindChild(nsProtocol, "StatusCode"); sub != nil {
+			value = sub.Attr("Value")
+		}
+		return fmt.Errorf("saml: idp returned status %s %s", value, message)
+	}
+	return nil
+}
+
+// checkSubjectConfirmation 要求至少一个 bearer 确认的接收方、有效期与请求 ID 均匹配
+func checkSubjectConfirmation(subject *Element, acsURL string, requestID string, now time.Time, skew time.Duration) error {
+	for _, confirmation := range subject.FindChildren(nsAssertion, "SubjectConfirmation") {
+		if confirmation.Attr("Method") != confirmationBearer {
+			continue
+		}
+		data := confirmation.FindChild(nsAssertion, "SubjectConfirmationData")
+		if data == nil || data.Attr("Recipient") != acsURL {
+			continue
+		}
+		if inResponseTo := data.Attr("InResponseTo"); inResponseTo != "" && inResponseTo != requestID {
+			continue
+		}
+		if v := data.Attr("NotBefore"); v != "" {
+			notBefore, err := parseTime(v)
+			if err != nil || now.Add(skew).Before(notBefore) {
+				continue
+			}
+		}
+		notOnOrAfter, err := parseTime(data.Attr("NotOnOrAfter"))
+		if err != nil || !now.Add(-skew).Before(notOnOrAfter) {
+			continue
+		}
+		return nil
+	}
+	return errors.New("saml: no valid bearer subject confirmation")
+}
+
+func checkConditions(conditions *Element, entityID string, now time.Time, skew time.Duration) (time.Time, error) {
+	if v := conditions.Attr("NotBefore"); v != "" {
+		notBefore, err := parseTime(v)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if now.Add(skew).Before(notBefore) {
+			return time.Time{}, errors.New("saml: assertion is not yet valid")
+		}
+	}
+	var notOnOrAfter time.Time
+	if v := conditions.Attr("NotOnOrAfter"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !now.Add(-skew).Before(t) {
+			return time.Time{}, errors.New("saml: assertion has expired")
+		}
+		notOnOrAfter = t
+	}
+	// 每个 AudienceRestriction 都必须包含本 SP
+	for _, restriction := range conditions.FindChildren(nsAssertion, "AudienceRestriction") {
+		matched := false
+		for _, audience := range restriction.FindChildren(nsAssertion, "Audience") {
+			if strings.TrimSpace(audience.Text()) == entityID {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return time.Time{}, errors.New("saml: assertion audience does not match")
+		}
+	}
+	return notOnOrAfter, nil
+}
+
+// checkUniqueIDs 拒绝出现重复 ID 的文档，配合只使用已验签元素的做法防御签名包装
+func checkUniqueIDs(root *Element) error {
+	seen := make(map[string]bool)
+	var dup string
+	root.walk(func(el *Element) {
+		if id := el.Attr("ID"); id != "" {
+			if seen[id] {
+				dup = id
+			}
+			seen[id] = true
+		}
+	})
+	if dup != "" {
+		return fmt.Errorf("saml: duplicate ID %q", dup)
+	}
+	return nil
+}
+
+func (sp *ServiceProvider) nameIDFormat() string {
+	if sp.NameIDFormat == "" {
+		return NameIDFormatUnspecified
+	}
+	return sp.NameIDFormat
+}
+
+func parseTime(v string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(v))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("saml: invalid time %q", v)
+	}
+	return t, nil
+}
+
+// newID 生成 xs:ID，必须以字母或下划线开头
+func newID() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "_" + hex.EncodeToString(buf), nil
+}
+
+// ParseCertificates 解析 PEM 证书；也接受 IdP 元数据中常见的裸 base64 DER
+func ParseCertificates(data string) ([]*x509.Certificate, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, nil
+	}
+	if !strings.Contains(data, "-----BEGIN") {
+		der, err := decodeBase64(data)
+		if err != nil {
+			return nil, fmt.Errorf("saml: invalid certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("saml: no certificate found")
+	}
+	return certs, nil
+}
+
+// ParsePrivateKey 解析 PKCS#1 或 PKCS#8 格式的 RSA 私钥
+func ParsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(data)))
+	if block == nil {
+		return nil, errors.New("saml: invalid private key pem")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("saml: private key is not RSA")
+	}
+	return key, nil
+}
@@ -0,0 +1,265 @@
+package saml_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/pkg/saml"
+	"github.com/QuantumNous/new-api/pkg/saml/samltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSPEntityID  = "https://sp.example.com/api/saml/metadata"
+	testACSURL      = "https://sp.example.com/api/saml/acs"
+	testIdPEntityID = "https://idp.example.com"
+	testRequestID   = "_req1"
+)
+
+func newTestSP(t *testing.T) (*saml.ServiceProvider, *samltest.IdP) {
+	t.Helper()
+	idp, err := samltest.NewIdP(testIdPEntityID)
+	require.NoError(t, err)
+	return &saml.ServiceProvider{
+		EntityID:        testSPEntityID,
+		ACSURL:          testACSURL,
+		IdPEntityID:     testIdPEntityID,
+		IdPSSOURL:       "https://idp.example.com/sso",
+		IdPCertificates: []*x509.Certificate{idp.Certificate},
+	}, idp
+}
+
+func testResponse() samltest.Response {
+	return samltest.Response{
+		InResponseTo: testRequestID,
+		ACSURL:       testACSURL,
+		Audience:     testSPEntityID,
+		NameID:       "alice@example.com",
+		NameIDFormat: saml.NameIDFormatEmailAddress,
+		Attributes: map[string][]string{
+			"uid":    {"alice"},
+			"groups": {"staff", "vip"},
+		},
+		SignAssertion: true,
+	}
+}
+
+func encode(doc string) string {
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestParseResponseSignedAssertion(t *testing.T) {
+	sp, idp := newTestSP(t)
+	encoded, err := idp.EncodedResponse(testResponse())
+	require.NoError(t, err)
+
+	assertion, err := sp.ParseResponse(encoded, testRequestID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", assertion.NameID)
+	assert.Equal(t, saml.NameIDFormatEmailAddress, assertion.NameIDFormat)
+	assert.Equal(t, "alice", assertion.AttributeValue("uid"))
+	assert.Equal(t, []string{"staff", "vip"}, assertion.Attributes["groups"])
+	assert.NotEmpty(t, assertion.SessionIndex)
+}
+
+func TestParseResponseSignedResponseOnly(t *testing.T) {
+	sp, idp := newTestSP(t)
+	r := testResponse()
+	r.SignAssertion = false
+	r.SignResponse = true
+	encoded, err := idp.EncodedResponse(r)
+	require.NoError(t, err)
+
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	require.NoError(t, err)
+
+	r.SignAssertion = true
+	encoded, err = idp.EncodedResponse(r)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	require.NoError(t, err)
+}
+
+func TestParseResponseRejectsUnsigned(t *testing.T) {
+	sp, idp := newTestSP(t)
+	r := testResponse()
+	r.SignAssertion = false
+	encoded, err := idp.EncodedResponse(r)
+	require.NoError(t, err)
+
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	assert.Error(t, err)
+}
+
+func TestParseResponseRejectsUntrustedSigner(t *testing.T) {
+	sp, _ := newTestSP(t)
+	other, err := samltest.NewIdP(testIdPEntityID)
+	require.NoError(t, err)
+	encoded, err := other.EncodedResponse(testResponse())
+	require.NoError(t, err)
+
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	assert.Error(t, err)
+
+	// 证书轮换期间可同时信任新旧证书
+	sp.IdPCertificates = append(sp.IdPCertificates, other.Certificate)
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	assert.NoError(t, err)
+}
+
+func TestParseResponseRejectsTampering(t *testing.T) {
+	sp, idp := newTestSP(t)
+	doc, err := idp.XML(testResponse())
+	require.NoError(t, err)
+
+	tampered := strings.Replace(doc, "alice@example.com", "admin@example.com", 1)
+	_, err = sp.ParseResponse(encode(tampered), testRequestID, time.Now())
+	assert.ErrorContains(t, err, "digest mismatch")
+
+	// 不是本次登录发起的请求对应的响应
+	_, err = sp.ParseResponse(encode(doc), "_other", time.Now())
+	assert.Error(t, err)
+}
+
+func TestParseResponseRejectsSignatureWrapping(t *testing.T) {
+	sp, idp := newTestSP(t)
+	doc, err := idp.XML(testResponse())
+	require.NoError(t, err)
+
+	// 在合法断言之外再塞一个未签名的断言
+	start := strings.Index(doc, "<saml:Assertion")
+	end := strings.Index(doc, "</saml:Assertion>") + len("</saml:Assertion>")
+	evil := strings.Replace(doc[start:end], "alice@example.com", "admin@example.com", 1)
+	wrapped := doc[:start] + evil + doc[start:]
+	_, err = sp.ParseResponse(encode(wrapped), testRequestID, time.Now())
+	assert.Error(t, err)
+
+	// 把合法断言藏进扩展元素，只保留伪造断言在顶层
+	evil = strings.Replace(evil, `ID="`, `ID="x`, 1)
+	hidden := doc[:start] + `<samlp:Extensions>` + doc[start:end] + `</samlp:Extensions>` + stripSignature(evil) + doc[end:]
+	_, err = sp.ParseResponse(encode(hidden), testRequestID, time.Now())
+	assert.Error(t, err)
+}
+
+func stripSignature(doc string) string {
+	start := strings.Index(doc, "<ds:Signature")
+	end := strings.Index(doc, "</ds:Signature>") + len("</ds:Signature>")
+	return doc[:start] + doc[end:]
+}
+
+func TestParseResponseValidatesConditions(t *testing.T) {
+	sp, idp := newTestSP(t)
+
+	r := testResponse()
+	r.Audience = "https://other.example.com"
+	encoded, err := idp.EncodedResponse(r)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	assert.ErrorContains(t, err, "audience")
+
+	r = testResponse()
+	r.IssueInstant = time.Now().Add(-time.Hour)
+	encoded, err = idp.EncodedResponse(r)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	assert.Error(t, err)
+
+	// 允许的时钟偏差内仍然有效
+	r = testResponse()
+	r.IssueInstant = time.Now().Add(time.Minute)
+	encoded, err = idp.EncodedResponse(r)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	assert.NoError(t, err)
+
+	r = testResponse()
+	r.ACSURL = "https://evil.example.com/acs"
+	encoded, err = idp.EncodedResponse(r)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded, testRequestID, time.Now())
+	assert.Error(t, err)
+}
+
+func TestParseResponseRejectsDTD(t *testing.T) {
+	sp, _ := newTestSP(t)
+	doc := `<?xml version="1.0"?><!DOCTYPE r [<!ENTITY x "y">]><samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol">&x;</samlp:Response>`
+	_, err := sp.ParseResponse(encode(doc), testRequestID, time.Now())
+	assert.Error(t, err)
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	sp, _ := newTestSP(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	sp.PrivateKey = key
+
+	loginURL, requestID, err := sp.AuthnRequestURL("relay&state", time.Now())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(loginURL, "https://idp.example.com/sso?SAMLRequest="))
+
+	request, relayState, err := samltest.ParseAuthnRequestURL(loginURL)
+	require.NoError(t, err)
+	assert.Equal(t, "relay&state", relayState)
+	assert.Equal(t, requestID, request.Attr("ID"))
+	assert.Equal(t, testACSURL, request.Attr("AssertionConsumerServiceURL"))
+
+	// 签名覆盖 SAMLRequest、RelayState、SigAlg 的原始编码
+	u, err := url.Parse(loginURL)
+	require.NoError(t, err)
+	signed := u.RawQuery[:strings.Index(u.RawQuery, "&Signature=")]
+	assert.Equal(t, saml.AlgRSASHA256, u.Query().Get("SigAlg"))
+	signature, err := base64.StdEncoding.DecodeString(u.Query().Get("Signature"))
+	require.NoError(t, err)
+	require.NoError(t, verifyRedirectSignature(&key.PublicKey, signed, signature))
+}
+
+func TestMetadata(t *testing.T) {
+	sp, _ := newTestSP(t)
+	metadata, err := saml.ParseXML(sp.Metadata())
+	require.NoError(t, err)
+	assert.Equal(t, testSPEntityID, metadata.Attr("entityID"))
+	descriptor := metadata.FindChild("urn:oasis:names:tc:SAML:2.0:metadata", "SPSSODescriptor")
+	require.NotNil(t, descriptor)
+	assert.Equal(t, "false", descriptor.Attr("AuthnRequestsSigned"))
+	acs := descriptor.FindChild("urn:oasis:names:tc:SAML:2.0:metadata", "AssertionConsumerService")
+	require.NotNil(t, acs)
+	assert.Equal(t, testACSURL, acs.Attr("Location"))
+	assert.Equal(t, saml.BindingHTTPPost, acs.Attr("Binding"))
+}
+
+func TestParseCertificatesAndPrivateKey(t *testing.T) {
+	_, idp := newTestSP(t)
+	certs, err := saml.ParseCertificates(idp.CertificatePEM())
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+
+	// 元数据中常见的裸 base64 证书
+	certs, err = saml.ParseCertificates(base64.StdEncoding.EncodeToString(idp.Certificate.Raw))
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(idp.Key)
+	require.NoError(t, err)
+	key, err := saml.ParsePrivateKey(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})))
+	require.NoError(t, err)
+	assert.True(t, key.Equal(idp.Key))
+	key, err = saml.ParsePrivateKey(string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(idp.Key)})))
+	require.NoError(t, err)
+	assert.True(t, key.Equal(idp.Key))
+}
+
+func verifyRedirectSignature(pub *rsa.PublicKey, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature)
+}
@@ -0,0 +1,160 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const nsXML = "http://www.w3.org/XML/1998/namespace"
+
+// Element 是保留原始前缀与属性顺序的 XML 元素，供规范化与签名校验使用。
+// encoding/xml 的 Unmarshal 会丢失前缀和命名空间声明，无法用于 XML-DSig。
+type Element struct {
+	Prefix   string
+	Local    string
+	Attrs    []xml.Attr
+	Children []any // *Element 或 string（字符数据）
+	Parent   *Element
+}
+
+// ParseXML 解析文档并返回根元素。拒绝 DOCTYPE，避免实体扩展类攻击。
+func ParseXML(data []byte) (*Element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *Element
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			el := &Element{Prefix: t.Name.Space, Local: t.Name.Local, Parent: current}
+			for _, attr := range t.Attr {
+				el.Attrs = append(el.Attrs, xml.Attr{Name: attr.Name, Value: attr.Value})
+			}
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("saml: multiple root elements")
+				}
+				root = el
+			} else {
+				current.Children = append(current.Children, el)
+			}
+			current = el
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.Prefix || t.Name.Local != current.Local {
+				return nil, fmt.Errorf("saml: unexpected end element %s", t.Name.Local)
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, string(t))
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, errors.New("saml: text outside root element")
+			}
+		case xml.Directive:
+			return nil, errors.New("saml: DTD is not allowed")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("saml: incomplete document")
+	}
+	return root, nil
+}
+
+// LookupNamespace 按作用域解析前缀对应的命名空间，未声明的默认命名空间为空串
+func (e *Element) LookupNamespace(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return nsXML, true
+	}
+	for el := e; el != nil; el = el.Parent {
+		for _, attr := range el.Attrs {
+			if prefix == "" && attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+				return attr.Value, true
+			}
+			if prefix != "" && attr.Name.Space == "xmlns" && attr.Name.Local == prefix {
+				return attr.Value, true
+			}
+		}
+	}
+	return "", prefix == ""
+}
+
+// Namespace 返回元素自身的命名空间
+func (e *Element) Namespace() string {
+	ns, _ := e.LookupNamespace(e.Prefix)
+	return ns
+}
+
+// Is 判断元素的命名空间与本地名
+func (e *Element) Is(namespace string, local string) bool {
+	return e != nil && e.Local == local && e.Namespace() == namespace
+}
+
+// Attr 返回无前缀属性的值
+func (e *Element) Attr(local string) string {
+	for _, attr := range e.Attrs {
+		if attr.Name.Space == "" && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func (e *Element) ChildElements() []*Element {
+	var out []*Element
+	for _, child := range e.Children {
+		if el, ok := child.(*Element); ok {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+func (e *Element) FindChildren(namespace string, local string) []*Element {
+	var out []*Element
+	for _, child := range e.ChildElements() {
+		if child.Is(namespace, local) {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+func (e *Element) FindChild(namespace string, local string) *Element {
+	for _, child := range e.ChildElements() {
+		if child.Is(namespace, local) {
+			return child
+		}
+	}
+	return nil
+}
+
+// Text 拼接直接子节点中的字符数据
+func (e *Element) Text() string {
+	if e == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, child := range e.Children {
+		if s, ok := child.(string); ok {
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+// walk 深度优先遍历当前元素及其后代
+func (e *Element) walk(fn func(*Element)) {
+	fn(e)
+	for _, child := range e.ChildElements() {
+		child.walk(fn)
+	}
+}
@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/QuantumNous/new-api"
+
+// AttrRequestId 所有 span 上携带的 new-api 请求 ID
+const AttrRequestId = attribute.Key("newapi.request_id")
+
+var enabled bool
+
+// Init 根据环境变量初始化链路追踪，未启用时什么都不做，返回的 shutdown 负责刷出缓冲的 span。
+//
+//	OTEL_TRACING_ENABLED  是否启用（默认 false）
+//	OTEL_TRACES_EXPORTER  otlp（默认，读取标准 OTEL_EXPORTER_OTLP_* 变量）或 file
+//	OTEL_TRACES_FILE      file 导出器的输出文件，每行一个 span 的 JSON
+//	OTEL_SERVICE_NAME     服务名（默认 new-api）
+func Init() (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !common.GetEnvOrDefaultBool("OTEL_TRACING_ENABLED", false) {
+		return noop, nil
+	}
+	// 只透传 traceparent；baggage 可能携带客户端数据，不转发给上游
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName := common.GetEnvOrDefaultString("OTEL_TRACES_EXPORTER", "otlp"); exporterName {
+	case "otlp":
+		exporter, err = otlptracehttp.New(context.Background())
+	case "file":
+		exporter, err = newFileExporter(common.GetEnvOrDefaultString("OTEL_TRACES_FILE", "traces.jsonl"))
+	default:
+		err = fmt.Errorf("unsupported OTEL_TRACES_EXPORTER: %s", exporterName)
+	}
+	if err != nil {
+		return noop, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(common.GetEnvOrDefaultString("OTEL_SERVICE_NAME", "new-api")),
+		semconv.ServiceVersion(common.Version),
+	))
+	if err != nil {
+		return noop, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	enabled = true
+	return provider.Shutdown, nil
+}
+
+func newFileExporter(path string) (sdktrace.SpanExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return stdouttrace.New(stdouttrace.WithWriter(file))
+}
+
+// Enabled 是否正在导出 span，用于跳过仅为追踪而做的额外计算
+func Enabled() bool {
+	return enabled
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start 以 ctx 为父级开启子 span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if requestId, ok := ctx.Value(common.RequestIdKey).(string); ok && requestId != "" {
+		attrs = append(attrs, AttrRequestId.String(requestId))
+	}
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Span 绑定到 gin 请求的 span，开启时把新上下文写回 c.Request，使后续 span 挂在其下
+type Span struct {
+	span   trace.Span
+	c      *gin.Context
+	parent context.Context
+	ended  bool
+}
+
+// StartSpan 以当前请求上下文为父级开启 span，结束时需调用 End 恢复原上下文
+func StartSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) *Span {
+	parent := c.Request.Context()
+	ctx, span := Start(parent, name, attrs...)
+	c.Request = c.Request.WithContext(ctx)
+	return &Span{span: span, c: c, parent: parent}
+}
+
+func (s *Span) SetAttributes(attrs ...attribute.KeyValue) {
+	s.span.SetAttributes(attrs...)
+}
+
+// End 结束 span 并恢复开启前的请求上下文，err 非空时记录为错误状态；重复调用无副作用
+func (s *Span) End(err error) {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	End(s.span, err)
+	s.c.Request = s.c.Request.WithContext(s.parent)
+}
+
+// End 结束 span，err 非空时记录为错误状态
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// RecordSpan 补记一个已经发生的区间（例如流式首字），父级为 ctx 中的当前 span
+func RecordSpan(ctx context.Context, name string, start time.Time, end time.Time, attrs ...attribute.KeyValue) {
+	if !enabled {
+		return
+	}
+	if requestId, ok := ctx.Value(common.RequestIdKey).(string); ok && requestId != "" {
+		attrs = append(attrs, AttrRequestId.String(requestId))
+	}
+	_, span := tracer().Start(ctx, name, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	span.End(trace.WithTimestamp(end))
+}
+
+// Inject 把当前 span 以 W3C traceparent 写入上游请求头，未启用追踪时不写入
+func Inject(ctx context.Context, header propagation.HeaderCarrier) {
+	if !enabled {
+		return
+	}
+	otel.GetTextMapPropagator().Inject(ctx, header)
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	enabled = true
+	t.Cleanup(func() {
+		enabled = false
+		_ = provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func TestMiddlewarePropagatesTraceparentAndNestsSpans(t *testing.T) {
+	recorder := setupRecorder(t)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), common.RequestIdKey, "req-1")
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	})
+	engine.Use(Middleware())
+	engine.GET("/v1/test", func(c *gin.Context) {
+		parent := c.Request.Context()
+		span := StartSpan(c, "relay.attempt")
+		_, child := Start(c.Request.Context(), "upstream.do_request")
+		End(child, errors.New("boom"))
+		span.End(nil)
+		span.End(nil)
+		assert.Equal(t, parent, c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	upstream, attempt, server := spans[0], spans[1], spans[2]
+	assert.Equal(t, "GET /v1/test", server.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Equal(t, server.SpanContext().SpanID(), attempt.Parent().SpanID())
+	assert.Equal(t, attempt.SpanContext().SpanID(), upstream.Parent().SpanID())
+	assert.Equal(t, codes.Error, upstream.Status().Code)
+	assert.Contains(t, upstream.Attributes(), AttrRequestId.String("req-1"))
+}
+
+func TestRecordSpanUsesGivenTimestamps(t *testing.T) {
+	recorder := setupRecorder(t)
+	start := time.Now().Add(-time.Second)
+	end := time.Now()
+	RecordSpan(context.Background(), "relay.first_byte", start, end)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, start.UnixNano(), spans[0].StartTime().UnixNano())
+	assert.Equal(t, end.UnixNano(), spans[0].EndTime().UnixNano())
+}
+
+func TestInitPropagatesOnlyTraceContext(t *testing.T) {
+	t.Setenv("OTEL_TRACING_ENABLED", "true")
+	t.Setenv("OTEL_TRACES_EXPORTER", "file")
+	t.Setenv("OTEL_TRACES_FILE", filepath.Join(t.TempDir(), "traces.jsonl"))
+	shutdown, err := Init()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		enabled = false
+		_ = shutdown(context.Background())
+	})
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate"}, otel.GetTextMapPropagator().Fields())
+
+	ctx, span := Start(context.Background(), "upstream.do_request")
+	defer span.End()
+	header := http.Header{}
+	Inject(ctx, propagation.HeaderCarrier(header))
+	assert.NotEmpty(t, header.Get("traceparent"))
+
+	// 未启用追踪时不向上游写入任何追踪头
+	enabled = false
+	header = http.Header{}
+	Inject(ctx, propagation.HeaderCarrier(header))
+	assert.Empty(t, header.Get("traceparent"))
+}
@@ -0,0 +1,48 @@
+package ali
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// aliTranscriptionHandler qwen-asr 通过 compatible-mode 对话接口识别，返回的回复即转写文本
+func aliTranscriptionHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.Usage) {
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError), nil
+	}
+	var chatResponse dto.OpenAITextResponse
+	if err := common.Unmarshal(responseBody, &chatResponse); err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError), nil
+	}
+	if oaiError := chatResponse.GetOpenAIError(); oaiError != nil && oaiError.Type != "" {
+		return types.WithOpenAIError(*oaiError, resp.StatusCode), nil
+	}
+	result, err := relayconvert.ConvertResponse(c, info, types.RelayFormatOpenAIAudio, &chatResponse)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError), nil
+	}
+	audioResponse, ok := result.Value.(*dto.AudioResponse)
+	if !ok {
+		return types.NewOpenAIError(errors.New("invalid transcription response"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError), nil
+	}
+
+	responseFormat := ""
+	if audioRequest, ok := info.Request.(*dto.AudioRequest); ok {
+		responseFormat = audioRequest.ResponseFormat
+	}
+	openai.WriteAudioTranscriptionResponse(c, audioResponse.Text, responseFormat)
+	return nil, service.BuildTranscriptionUsage(c, info, audioResponse.Text)
+}
@@ -346,6 +346,10 @@ func handleNovaRequest(c *gin.Context, info *relaycommon.RelayInfo, a *Adaptor)
 		},
 	}
 
-	c.JSON(http.StatusOK, response)
+	jsonResponse, err := common.Marshal(response)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody), nil
+	}
+	c.Data(http.StatusOK, "application/json", service.ProcessCompletionBody(c, jsonResponse))
 	return nil, &response.Usage
 }
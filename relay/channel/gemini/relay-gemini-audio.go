@@ -0,0 +1,108 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const geminiSpeechDefaultSampleRate = 24000
+
+// geminiSpeechPCM 取出 Gemini TTS 返回的 PCM 音频，mimeType 形如 audio/L16;codec=pcm;rate=24000
+func geminiSpeechPCM(response *dto.GeminiChatResponse) ([]byte, int, error) {
+	for _, candidate := range response.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+			if err != nil {
+				return nil, 0, err
+			}
+			sampleRate := geminiSpeechDefaultSampleRate
+			for _, param := range strings.Split(part.InlineData.MimeType, ";") {
+				if value, ok := strings.CutPrefix(strings.TrimSpace(param), "rate="); ok {
+					if rate, err := strconv.Atoi(value); err == nil && rate > 0 {
+						sampleRate = rate
+					}
+				}
+			}
+			return data, sampleRate, nil
+		}
+	}
+	return nil, 0, errors.New("no audio data in gemini response")
+}
+
+func readGeminiChatResponse(resp *http.Response) (*dto.GeminiChatResponse, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	return &geminiResponse, nil
+}
+
+// GeminiSpeechHandler 将 Gemini TTS 的 PCM 输出按 response_format 返回，
+// Gemini 只输出 PCM，pcm 以外的格式统一封装为 wav
+func GeminiSpeechHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	geminiResponse, apiErr := readGeminiChatResponse(resp)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	pcm, sampleRate, err := geminiSpeechPCM(geminiResponse)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	wav := common.PCMToWAV(pcm, sampleRate, 1, 16)
+
+	responseFormat := ""
+	if audioRequest, ok := info.Request.(*dto.AudioRequest); ok {
+		responseFormat = audioRequest.ResponseFormat
+	}
+	if responseFormat == "pcm" && sampleRate == service.SpeechPCMSampleRate {
+		c.Data(http.StatusOK, "audio/pcm", pcm)
+	} else {
+		c.Data(http.StatusOK, "audio/wav", wav)
+	}
+	return service.BuildSpeechUsage(c, info, wav, "wav"), nil
+}
+
+// GeminiTranscriptionHandler 将 generateContent 返回的文本转换为 OpenAI 转写响应
+func GeminiTranscriptionHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	geminiResponse, apiErr := readGeminiChatResponse(resp)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	result, err := relayconvert.ConvertResponse(c, info, types.RelayFormatOpenAIAudio, geminiResponse)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	audioResponse, ok := result.Value.(*dto.AudioResponse)
+	if !ok {
+		return nil, types.NewOpenAIError(errors.New("invalid transcription response"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	responseFormat := ""
+	if audioRequest, ok := info.Request.(*dto.AudioRequest); ok {
+		responseFormat = audioRequest.ResponseFormat
+	}
+	openai.WriteAudioTranscriptionResponse(c, audioResponse.Text, responseFormat)
+	return service.BuildTranscriptionUsage(c, info, audioResponse.Text), nil
+}
@@ -0,0 +1,711 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// OpenAI realtime 的 pcm16 为 24kHz 单声道，Gemini Live 输出音频同样为 24kHz PCM
+	geminiRealtimeAudioMimeType = "audio/pcm;rate=24000"
+	geminiRealtimeSetupTimeout  = 30 * time.Second
+)
+
+// openAIRealtimeVoices OpenAI 的音色在 Gemini 中不存在，使用 Gemini 默认音色
+var openAIRealtimeVoices = map[string]bool{
+	"alloy": true, "ash": true, "ballad": true, "coral": true, "echo": true,
+	"sage": true, "shimmer": true, "verse": true, "marin": true, "cedar": true,
+}
+
+// geminiRealtimeBridge 将 OpenAI realtime 协议转换为 Gemini Live（BidiGenerateContent）协议。
+// Gemini 的 setup 只能在连接开始时发送一次，因此在客户端首次发送对话内容前根据 session.update 生成
+type geminiRealtimeBridge struct {
+	c          *gin.Context
+	info       *relaycommon.RelayInfo
+	clientConn *websocket.Conn
+	targetConn *websocket.Conn
+	clientMu   sync.Mutex
+
+	// 以下字段仅由客户端读取协程访问
+	session        dto.RealtimeSession
+	manualActivity bool
+	activityOpen   bool
+	pendingTurn    bool
+	skipResponse   bool
+	setupSent      bool
+
+	setupDone     chan struct{}
+	setupDoneOnce sync.Once
+
+	mu            sync.Mutex
+	functionNames map[string]string
+	localUsage    *dto.RealtimeUsage
+	reportedUsage bool
+
+	// 以下字段仅由上游读取协程访问
+	responseId      string
+	itemId          string
+	outputItems     []dto.RealtimeItem
+	hasAudio        bool
+	text            strings.Builder
+	transcript      strings.Builder
+	inputTranscript strings.Builder
+	lastUsage       *dto.RealtimeUsage
+	sumUsage        *dto.RealtimeUsage
+}
+
+func newGeminiRealtimeBridge(c *gin.Context, info *relaycommon.RelayInfo) *geminiRealtimeBridge {
+	return &geminiRealtimeBridge{
+		c:          c,
+		info:       info,
+		clientConn: info.ClientWs,
+		targetConn: info.TargetWs,
+		session: dto.RealtimeSession{
+			Modalities:        []string{"text", "audio"},
+			InputAudioFormat:  "pcm16",
+			OutputAudioFormat: "pcm16",
+		},
+		setupDone:     make(chan struct{}),
+		functionNames: make(map[string]string),
+		localUsage:    &dto.RealtimeUsage{},
+		sumUsage:      &dto.RealtimeUsage{},
+	}
+}
+
+func GeminiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.RealtimeUsage) {
+	if info == nil || info.ClientWs == nil || info.TargetWs == nil {
+		return types.NewError(fmt.Errorf("invalid websocket connection"), types.ErrorCodeBadResponse), nil
+	}
+	info.IsStream = true
+	b := newGeminiRealtimeBridge(c, info)
+
+	clientClosed := make(chan struct{})
+	targetClosed := make(chan struct{})
+	errChan := make(chan error, 2)
+
+	if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionCreated, Session: &b.session}); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponse), nil
+	}
+
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic in client reader: %v", r)
+			}
+		}()
+		for {
+			_, message, err := b.clientConn.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					errChan <- fmt.Errorf("error reading from client: %v", err)
+				}
+				close(clientClosed)
+				return
+			}
+			if err = b.handleClientMessage(message, targetClosed); err != nil {
+				errChan <- err
+				return
+			}
+		}
+	})
+
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic in target reader: %v", r)
+			}
+		}()
+		for {
+			_, message, err := b.targetConn.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					errChan <- fmt.Errorf("error reading from target: %v", err)
+				}
+				close(targetClosed)
+				return
+			}
+			info.SetFirstResponseTime()
+			if err = b.handleServerMessage(message); err != nil {
+				errChan <- err
+				return
+			}
+		}
+	})
+
+	select {
+	case <-clientClosed:
+	case <-targetClosed:
+	case err := <-errChan:
+		logger.LogError(c, "gemini realtime error: "+err.Error())
+	case <-c.Done():
+	}
+
+	// 上游未返回用量时按本地统计计费
+	b.mu.Lock()
+	localUsage, reported := b.localUsage, b.reportedUsage
+	b.mu.Unlock()
+	if !reported && localUsage.TotalTokens != 0 {
+		_ = b.consumeUsage(localUsage)
+	}
+	return nil, b.sumUsage
+}
+
+func (b *geminiRealtimeBridge) writeClient(event *dto.RealtimeEvent) error {
+	if event.EventId == "" {
+		event.EventId = "evt_" + common.GetRandomString(16)
+	}
+	b.clientMu.Lock()
+	defer b.clientMu.Unlock()
+	return b.clientConn.WriteJSON(event)
+}
+
+func (b *geminiRealtimeBridge) writeClientError(code string, message string) error {
+	return b.writeClient(&dto.RealtimeEvent{
+		Type:  dto.RealtimeEventTypeError,
+		Error: &types.OpenAIError{Type: "invalid_request_error", Code: code, Message: message},
+	})
+}
+
+func (b *geminiRealtimeBridge) writeTarget(message *dto.GeminiLiveClientMessage) error {
+	data, err := common.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return b.targetConn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (b *geminiRealtimeBridge) addLocalUsage(input bool, textTokens int, audioTokens int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.localUsage.TotalTokens += textTokens + audioTokens
+	if input {
+		b.localUsage.InputTokens += textTokens + audioTokens
+		b.localUsage.InputTokenDetails.TextTokens += textTokens
+		b.localUsage.InputTokenDetails.AudioTokens += audioTokens
+	} else {
+		b.localUsage.OutputTokens += textTokens + audioTokens
+		b.localUsage.OutputTokenDetails.TextTokens += textTokens
+		b.localUsage.OutputTokenDetails.AudioTokens += audioTokens
+	}
+}
+
+// buildSetup 根据 OpenAI realtime 会话配置生成 Gemini Live setup 消息
+func (b *geminiRealtimeBridge) buildSetup() *dto.GeminiLiveSetup {
+	session := b.session
+	setup := &dto.GeminiLiveSetup{
+		Model:            "models/" + b.info.UpstreamModelName,
+		GenerationConfig: &dto.GeminiLiveGenerationConfig{ResponseModalities: []string{"AUDIO"}},
+	}
+	if !common.StringsContains(session.Modalities, "audio") && common.StringsContains(session.Modalities, "text") {
+		setup.GenerationConfig.ResponseModalities = []string{"TEXT"}
+	} else {
+		setup.OutputAudioTranscription = &struct{}{}
+		if session.Voice != "" && !openAIRealtimeVoices[session.Voice] {
+			setup.GenerationConfig.SpeechConfig = &dto.GeminiLiveSpeechConfig{}
+			setup.GenerationConfig.SpeechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName = session.Voice
+		}
+	}
+	if session.Temperature > 0 {
+		temperature := session.Temperature
+		setup.GenerationConfig.Temperature = &temperature
+	}
+	if session.Instructions != "" {
+		setup.SystemInstruction = &dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: session.Instructions}}}
+	}
+	if session.InputAudioTranscription.Model != "" {
+		setup.InputAudioTranscription = &struct{}{}
+	}
+	if b.manualActivity {
+		setup.RealtimeInputConfig = &dto.GeminiLiveRealtimeInputConfig{
+			AutomaticActivityDetection: &dto.GeminiLiveActivityDetection{Disabled: true},
+		}
+	}
+	if len(session.Tools) > 0 {
+		functions := make([]dto.FunctionRequest, 0, len(session.Tools))
+		for _, tool := range session.Tools {
+			if tool.Type != "" && tool.Type != "function" {
+				continue
+			}
+			functions = append(functions, dto.FunctionRequest{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  relayconvert.CleanGeminiFunctionParameters(tool.Parameters),
+			})
+		}
+		if len(functions) > 0 {
+			setup.Tools = []dto.GeminiChatTool{{FunctionDeclarations: functions}}
+		}
+	}
+	return setup
+}
+
+// ensureSetup 首次发送对话内容前发送 setup 并等待上游确认
+func (b *geminiRealtimeBridge) ensureSetup(targetClosed <-chan struct{}) error {
+	if b.setupSent {
+		return nil
+	}
+	b.setupSent = true
+	if err := b.writeTarget(&dto.GeminiLiveClientMessage{Setup: b.buildSetup()}); err != nil {
+		return fmt.Errorf("error writing setup to target: %v", err)
+	}
+	select {
+	case <-b.setupDone:
+		return nil
+	case <-targetClosed:
+		return errors.New("target closed before setup complete")
+	case <-b.c.Done():
+		return b.c.Err()
+	case <-time.After(geminiRealtimeSetupTimeout):
+		return errors.New("timeout waiting for gemini live setup")
+	}
+}
+
+func (b *geminiRealtimeBridge) handleClientMessage(message []byte, targetClosed <-chan struct{}) error {
+	event := &dto.RealtimeEvent{}
+	if err := common.Unmarshal(message, event); err != nil {
+		return fmt.Errorf("error unmarshalling message: %v", err)
+	}
+	switch event.Type {
+	case dto.RealtimeEventTypeSessionUpdate:
+		return b.handleSessionUpdate(message, event)
+	case dto.RealtimeEventInputAudioBufferAppend:
+		if err := b.ensureSetup(targetClosed); err != nil {
+			return err
+		}
+		_, audioTokens, err := service.CountTokenRealtime(b.info, *event, b.info.UpstreamModelName)
+		if err != nil {
+			return fmt.Errorf("error counting audio token: %v", err)
+		}
+		b.addLocalUsage(true, 0, audioTokens)
+		if b.manualActivity && !b.activityOpen {
+			b.activityOpen = true
+			if err := b.writeTarget(&dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{ActivityStart: &struct{}{}}}); err != nil {
+				return err
+			}
+		}
+		return b.writeTarget(&dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{
+			Audio: &dto.GeminiInlineData{MimeType: geminiRealtimeAudioMimeType, Data: event.Audio},
+		}})
+	case dto.RealtimeEventInputAudioBufferCommit:
+		if b.manualActivity && b.activityOpen {
+			b.activityOpen = false
+			if err := b.writeTarget(&dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{ActivityEnd: &struct{}{}}}); err != nil {
+				return err
+			}
+		}
+		return b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCommitted, ItemId: "item_" + common.GetRandomString(16)})
+	case dto.RealtimeEventInputAudioBufferClear:
+		return b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCleared})
+	case dto.RealtimeEventTypeConversationCreate:
+		if event.Item == nil {
+			return nil
+		}
+		if err := b.ensureSetup(targetClosed); err != nil {
+			return err
+		}
+		return b.handleConversationItem(event.Item)
+	case dto.RealtimeEventTypeResponseCreate:
+		if b.skipResponse {
+			// 返回函数调用结果后 Gemini 会自动继续生成
+			b.skipResponse = false
+			return nil
+		}
+		if b.pendingTurn {
+			b.pendingTurn = false
+			return b.writeTarget(&dto.GeminiLiveClientMessage{ClientContent: &dto.GeminiLiveClientContent{TurnComplete: true}})
+		}
+		if b.manualActivity && b.activityOpen {
+			b.activityOpen = false
+			return b.writeTarget(&dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{ActivityEnd: &struct{}{}}})
+		}
+	default:
+		logger.LogDebug(b.c, "gemini realtime ignored client event: %s", event.Type)
+	}
+	return nil
+}
+
+func (b *geminiRealtimeBridge) handleSessionUpdate(message []byte, event *dto.RealtimeEvent) error {
+	if event.Session == nil {
+		return nil
+	}
+	if b.setupSent {
+		return b.writeClientError("session_update_not_supported", "session cannot be updated after the conversation has started on this channel")
+	}
+	update := event.Session
+	if update.InputAudioFormat != "" && update.InputAudioFormat != "pcm16" ||
+		update.OutputAudioFormat != "" && update.OutputAudioFormat != "pcm16" {
+		return b.writeClientError("unsupported_audio_format", "only pcm16 audio is supported on this channel")
+	}
+	if len(update.Modalities) > 0 {
+		b.session.Modalities = update.Modalities
+	}
+	if update.Instructions != "" {
+		b.session.Instructions = update.Instructions
+	}
+	if update.Voice != "" {
+		b.session.Voice = update.Voice
+	}
+	if update.Temperature > 0 {
+		b.session.Temperature = update.Temperature
+	}
+	if update.InputAudioTranscription.Model != "" {
+		b.session.InputAudioTranscription = update.InputAudioTranscription
+	}
+	if update.Tools != nil {
+		b.session.Tools = update.Tools
+		b.info.RealtimeTools = update.Tools
+	}
+	if update.ToolChoice != "" {
+		b.session.ToolChoice = update.ToolChoice
+	}
+	if turnDetection := gjson.GetBytes(message, "session.turn_detection"); turnDetection.Exists() {
+		b.manualActivity = turnDetection.Type == gjson.Null
+		b.session.TurnDetection = update.TurnDetection
+	}
+	textTokens, _, err := service.CountTokenRealtime(b.info, *event, b.info.UpstreamModelName)
+	if err != nil {
+		return fmt.Errorf("error counting text token: %v", err)
+	}
+	b.addLocalUsage(true, textTokens, 0)
+	return b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionUpdated, Session: &b.session})
+}
+
+func (b *geminiRealtimeBridge) handleConversationItem(item *dto.RealtimeItem) error {
+	if item.Id == "" {
+		item.Id = "item_" + common.GetRandomString(16)
+	}
+	switch item.Type {
+	case "function_call_output":
+		b.mu.Lock()
+		name := b.functionNames[item.CallId]
+		b.mu.Unlock()
+		var response any = map[string]any{"output": item.Output}
+		if gjson.Valid(item.Output) && gjson.Parse(item.Output).IsObject() {
+			response = gjson.Parse(item.Output).Value()
+		}
+		b.addLocalUsage(true, service.CountTextToken(item.Output, b.info.UpstreamModelName), 0)
+		if err := b.writeTarget(&dto.GeminiLiveClientMessage{ToolResponse: &dto.GeminiLiveToolResponse{
+			FunctionResponses: []dto.GeminiLiveFunctionResponse{{Id: item.CallId, Name: name, Response: response}},
+		}}); err != nil {
+			return err
+		}
+		b.skipResponse = true
+	case "message", "":
+		content := dto.GeminiChatContent{Role: "user"}
+		if item.Role == "assistant" {
+			content.Role = "model"
+		}
+		for _, part := range item.Content {
+			switch part.Type {
+			case "input_text", "text", "output_text":
+				content.Parts = append(content.Parts, dto.GeminiPart{Text: part.Text})
+				b.addLocalUsage(true, service.CountTextToken(part.Text, b.info.UpstreamModelName), 0)
+			case "input_audio":
+				content.Parts = append(content.Parts, dto.GeminiPart{
+					InlineData: &dto.GeminiInlineData{MimeType: geminiRealtimeAudioMimeType, Data: part.Audio},
+				})
+				audioTokens, err := service.CountAudioTokenInput(part.Audio, b.info.InputAudioFormat)
+				if err != nil {
+					return fmt.Errorf("error counting audio token: %v", err)
+				}
+				b.addLocalUsage(true, 0, audioTokens)
+			}
+		}
+		if len(content.Parts) == 0 {
+			return nil
+		}
+		if err := b.writeTarget(&dto.GeminiLiveClientMessage{ClientContent: &dto.GeminiLiveClientContent{
+			Turns: []dto.GeminiChatContent{content},
+		}}); err != nil {
+			return err
+		}
+		b.pendingTurn = true
+	default:
+		return b.writeClientError("unsupported_item_type", fmt.Sprintf("conversation item type %s is not supported on this channel", item.Type))
+	}
+	item.Status = "completed"
+	return b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, Item: item})
+}
+
+func (b *geminiRealtimeBridge) handleServerMessage(message []byte) error {
+	msg := &dto.GeminiLiveServerMessage{}
+	if err := common.Unmarshal(message, msg); err != nil {
+		return fmt.Errorf("error unmarshalling message: %v", err)
+	}
+	if msg.SetupComplete != nil {
+		b.setupDoneOnce.Do(func() { close(b.setupDone) })
+	}
+	if msg.Error != nil {
+		if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeError, Error: &types.OpenAIError{
+			Type: "server_error", Code: msg.Error.Status, Message: msg.Error.Message,
+		}}); err != nil {
+			return err
+		}
+	}
+	if msg.UsageMetadata != nil {
+		if err := b.handleUsage(msg.UsageMetadata); err != nil {
+			return err
+		}
+	}
+	if msg.ToolCall != nil {
+		if err := b.handleToolCall(msg.ToolCall); err != nil {
+			return err
+		}
+	}
+	if msg.ToolCallCancellation != nil {
+		logger.LogInfo(b.c, fmt.Sprintf("gemini realtime tool calls cancelled: %v", msg.ToolCallCancellation.Ids))
+	}
+	if msg.ServerContent != nil {
+		return b.handleServerContent(msg.ServerContent)
+	}
+	return nil
+}
+
+// handleUsage 上游每次返回用量时立即计费，此后不再使用本地统计
+func (b *geminiRealtimeBridge) handleUsage(metadata *dto.GeminiLiveUsageMetadata) error {
+	usage := geminiLiveUsageToRealtime(metadata)
+	b.lastUsage = usage
+	b.mu.Lock()
+	b.reportedUsage = true
+	b.localUsage = &dto.RealtimeUsage{}
+	b.mu.Unlock()
+	if err := b.consumeUsage(usage); err != nil {
+		return fmt.Errorf("error consume usage: %v", err)
+	}
+	return nil
+}
+
+func geminiLiveUsageToRealtime(metadata *dto.GeminiLiveUsageMetadata) *dto.RealtimeUsage {
+	usage := &dto.RealtimeUsage{
+		InputTokens:  metadata.PromptTokenCount + metadata.ToolUsePromptTokenCount,
+		OutputTokens: metadata.ResponseTokenCount + metadata.ThoughtsTokenCount,
+	}
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	for _, detail := range metadata.PromptTokensDetails {
+		if strings.EqualFold(detail.Modality, "AUDIO") {
+			usage.InputTokenDetails.AudioTokens += detail.TokenCount
+		}
+	}
+	for _, detail := range metadata.ResponseTokensDetails {
+		if strings.EqualFold(detail.Modality, "AUDIO") {
+			usage.OutputTokenDetails.AudioTokens += detail.TokenCount
+		}
+	}
+	usage.InputTokenDetails.AudioTokens = min(usage.InputTokenDetails.AudioTokens, usage.InputTokens)
+	usage.OutputTokenDetails.AudioTokens = min(usage.OutputTokenDetails.AudioTokens, usage.OutputTokens)
+	// 图片、视频等其他模态按文本计费
+	usage.InputTokenDetails.TextTokens = usage.InputTokens - usage.InputTokenDetails.AudioTokens
+	usage.OutputTokenDetails.TextTokens = usage.OutputTokens - usage.OutputTokenDetails.AudioTokens
+	usage.InputTokenDetails.CachedTokens = metadata.CachedContentTokenCount
+	return usage
+}
+
+func (b *geminiRealtimeBridge) consumeUsage(usage *dto.RealtimeUsage) error {
+	b.sumUsage.TotalTokens += usage.TotalTokens
+	b.sumUsage.InputTokens += usage.InputTokens
+	b.sumUsage.OutputTokens += usage.OutputTokens
+	b.sumUsage.InputTokenDetails.CachedTokens += usage.InputTokenDetails.CachedTokens
+	b.sumUsage.InputTokenDetails.TextTokens += usage.InputTokenDetails.TextTokens
+	b.sumUsage.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
+	b.sumUsage.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
+	b.sumUsage.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+	return service.PreWssConsumeQuota(b.c, b.info, usage)
+}
+
+// ensureResponse 上游开始输出时创建响应，needMessage 为 true 时同时创建 assistant 消息
+func (b *geminiRealtimeBridge) ensureResponse(needMessage bool) error {
+	if b.responseId == "" {
+		b.responseId = "resp_" + common.GetRandomString(16)
+		if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeResponseCreated, Response: &dto.RealtimeResponse{
+			Id: b.responseId, Object: "realtime.response", Status: "in_progress",
+		}}); err != nil {
+			return err
+		}
+	}
+	if !needMessage || b.itemId != "" {
+		return nil
+	}
+	b.itemId = "item_" + common.GetRandomString(16)
+	outputIndex, contentIndex := len(b.outputItems), 0
+	item := dto.RealtimeItem{Id: b.itemId, Type: "message", Status: "in_progress", Role: "assistant"}
+	b.outputItems = append(b.outputItems, item)
+	partType := "audio"
+	if !b.audioOutput() {
+		partType = "text"
+	}
+	if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseOutputItemAdded, ResponseId: b.responseId,
+		OutputIndex: &outputIndex, Item: &item}); err != nil {
+		return err
+	}
+	return b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseContentPartAdded, ResponseId: b.responseId,
+		ItemId: b.itemId, OutputIndex: &outputIndex, ContentIndex: &contentIndex, Part: &dto.RealtimeContent{Type: partType}})
+}
+
+func (b *geminiRealtimeBridge) audioOutput() bool {
+	return common.StringsContains(b.session.Modalities, "audio") || !common.StringsContains(b.session.Modalities, "text")
+}
+
+func (b *geminiRealtimeBridge) messageDelta(eventType string, delta string) error {
+	if err := b.ensureResponse(true); err != nil {
+		return err
+	}
+	outputIndex, contentIndex := len(b.outputItems)-1, 0
+	return b.writeClient(&dto.RealtimeEvent{Type: eventType, ResponseId: b.responseId, ItemId: b.itemId,
+		OutputIndex: &outputIndex, ContentIndex: &contentIndex, Delta: delta})
+}
+
+func (b *geminiRealtimeBridge) handleServerContent(content *dto.GeminiLiveServerContent) error {
+	if content.InputTranscription != nil {
+		b.inputTranscript.WriteString(content.InputTranscription.Text)
+	}
+	if content.ModelTurn != nil {
+		for _, part := range content.ModelTurn.Parts {
+			switch {
+			case part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "audio/"):
+				b.hasAudio = true
+				audioTokens, err := service.CountAudioTokenOutput(part.InlineData.Data, b.info.OutputAudioFormat)
+				if err != nil {
+					return fmt.Errorf("error counting audio token: %v", err)
+				}
+				b.addLocalUsage(false, 0, audioTokens)
+				if err = b.messageDelta(dto.RealtimeEventResponseAudioDelta, part.InlineData.Data); err != nil {
+					return err
+				}
+			case part.Text != "" && !part.Thought:
+				b.text.WriteString(part.Text)
+				b.addLocalUsage(false, service.CountTextToken(part.Text, b.info.UpstreamModelName), 0)
+				if err := b.messageDelta(dto.RealtimeEventResponseTextDelta, part.Text); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if content.OutputTranscription != nil && content.OutputTranscription.Text != "" {
+		b.transcript.WriteString(content.OutputTranscription.Text)
+		if err := b.messageDelta(dto.RealtimeEventResponseAudioTranscriptionDelta, content.OutputTranscription.Text); err != nil {
+			return err
+		}
+	}
+	if content.Interrupted {
+		// 用户打断时通知客户端停止播放
+		if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferSpeechStarted}); err != nil {
+			return err
+		}
+		return b.finishResponse("cancelled")
+	}
+	if content.TurnComplete {
+		return b.finishResponse("completed")
+	}
+	return nil
+}
+
+func (b *geminiRealtimeBridge) handleToolCall(toolCall *dto.GeminiLiveToolCall) error {
+	if err := b.ensureResponse(false); err != nil {
+		return err
+	}
+	for _, call := range toolCall.FunctionCalls {
+		arguments := "{}"
+		if call.Args != nil {
+			if data, err := common.Marshal(call.Args); err == nil {
+				arguments = string(data)
+			}
+		}
+		b.mu.Lock()
+		b.functionNames[call.Id] = call.Name
+		b.mu.Unlock()
+		b.addLocalUsage(false, service.CountTextToken(arguments, b.info.UpstreamModelName), 0)
+
+		outputIndex := len(b.outputItems)
+		name := call.Name
+		item := dto.RealtimeItem{Id: "item_" + common.GetRandomString(16), Type: "function_call", Status: "in_progress",
+			Name: &name, CallId: call.Id}
+		if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseOutputItemAdded, ResponseId: b.responseId,
+			OutputIndex: &outputIndex, Item: &item}); err != nil {
+			return err
+		}
+		if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseFunctionCallArgumentsDone, ResponseId: b.responseId,
+			ItemId: item.Id, OutputIndex: &outputIndex, CallId: call.Id, Name: call.Name, Arguments: arguments}); err != nil {
+			return err
+		}
+		item.Status, item.Arguments = "completed", arguments
+		b.outputItems = append(b.outputItems, item)
+		if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseOutputItemDone, ResponseId: b.responseId,
+			OutputIndex: &outputIndex, Item: &item}); err != nil {
+			return err
+		}
+	}
+	// Gemini 等待函数结果期间不会结束本轮，客户端需要 response.done 后才会提交结果
+	return b.finishResponse("completed")
+}
+
+// finishResponse 结束当前响应，补发各内容的 done 事件与 response.done
+func (b *geminiRealtimeBridge) finishResponse(status string) error {
+	if b.inputTranscript.Len() > 0 {
+		contentIndex := 0
+		if err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioTranscriptionCompleted,
+			ItemId: "item_" + common.GetRandomString(16), ContentIndex: &contentIndex, Transcript: b.inputTranscript.String()}); err != nil {
+			return err
+		}
+		b.inputTranscript.Reset()
+	}
+	if b.responseId == "" {
+		return nil
+	}
+	if b.itemId != "" {
+		outputIndex, contentIndex := len(b.outputItems)-1, 0
+		for i := range b.outputItems {
+			if b.outputItems[i].Id == b.itemId {
+				outputIndex = i
+			}
+		}
+		base := dto.RealtimeEvent{ResponseId: b.responseId, ItemId: b.itemId, OutputIndex: &outputIndex, ContentIndex: &contentIndex}
+		part := dto.RealtimeContent{Type: "text", Text: b.text.String()}
+		events := make([]dto.RealtimeEvent, 0, 4)
+		if b.hasAudio || b.audioOutput() && b.text.Len() == 0 {
+			part = dto.RealtimeContent{Type: "audio", Transcript: b.transcript.String()}
+			audioDone, transcriptDone := base, base
+			audioDone.Type = dto.RealtimeEventResponseAudioDone
+			transcriptDone.Type, transcriptDone.Transcript = dto.RealtimeEventResponseAudioTranscriptionDone, b.transcript.String()
+			events = append(events, audioDone, transcriptDone)
+		} else {
+			textDone := base
+			textDone.Type, textDone.Text = dto.RealtimeEventResponseTextDone, b.text.String()
+			events = append(events, textDone)
+		}
+		partDone := base
+		partDone.Type, partDone.Part = dto.RealtimeEventResponseContentPartDone, &part
+		events = append(events, partDone)
+		item := &b.outputItems[outputIndex]
+		item.Status, item.Content = "completed", []dto.RealtimeContent{part}
+		events = append(events, dto.RealtimeEvent{Type: dto.RealtimeEventResponseOutputItemDone, ResponseId: b.responseId,
+			OutputIndex: &outputIndex, Item: item})
+		for i := range events {
+			if err := b.writeClient(&events[i]); err != nil {
+				return err
+			}
+		}
+	}
+	err := b.writeClient(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeResponseDone, Response: &dto.RealtimeResponse{
+		Id: b.responseId, Object: "realtime.response", Status: status, Output: b.outputItems, Usage: b.lastUsage,
+	}})
+	b.responseId, b.itemId, b.outputItems, b.hasAudio, b.lastUsage = "", "", nil, false, nil
+	b.text.Reset()
+	b.transcript.Reset()
+	return err
+}
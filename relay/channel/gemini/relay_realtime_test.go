@@ -0,0 +1,82 @@
+package gemini
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestGeminiRealtimeBuildSetup(t *testing.T) {
+	t.Parallel()
+
+	b := &geminiRealtimeBridge{
+		info: &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "gemini-2.5-flash-native-audio"}},
+		session: dto.RealtimeSession{
+			Modalities:   []string{"text", "audio"},
+			Instructions: "be brief",
+			Voice:        "alloy",
+			Temperature:  0.6,
+			Tools: []dto.RealTimeTool{{
+				Type:        "function",
+				Name:        "get_weather",
+				Description: "weather",
+				Parameters:  map[string]any{"type": "object", "additionalProperties": false, "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+			}},
+		},
+		manualActivity: true,
+	}
+	data, err := common.Marshal(&dto.GeminiLiveClientMessage{Setup: b.buildSetup()})
+	require.NoError(t, err)
+	setup := gjson.GetBytes(data, "setup")
+
+	require.Equal(t, "models/gemini-2.5-flash-native-audio", setup.Get("model").String())
+	require.Equal(t, "AUDIO", setup.Get("generationConfig.responseModalities.0").String())
+	// OpenAI 音色不透传
+	require.False(t, setup.Get("generationConfig.speechConfig").Exists())
+	require.Equal(t, 0.6, setup.Get("generationConfig.temperature").Float())
+	require.Equal(t, "be brief", setup.Get("systemInstruction.parts.0.text").String())
+	require.True(t, setup.Get("outputAudioTranscription").Exists())
+	require.True(t, setup.Get("realtimeInputConfig.automaticActivityDetection.disabled").Bool())
+	require.Equal(t, "get_weather", setup.Get("tools.0.functionDeclarations.0.name").String())
+	require.False(t, setup.Get("tools.0.functionDeclarations.0.parameters.additionalProperties").Exists())
+
+	b.session.Modalities = []string{"text"}
+	b.session.Voice = "Puck"
+	setupText := b.buildSetup()
+	require.Equal(t, []string{"TEXT"}, setupText.GenerationConfig.ResponseModalities)
+	require.Nil(t, setupText.GenerationConfig.SpeechConfig)
+	require.Nil(t, setupText.OutputAudioTranscription)
+}
+
+func TestGeminiLiveUsageToRealtime(t *testing.T) {
+	t.Parallel()
+
+	usage := geminiLiveUsageToRealtime(&dto.GeminiLiveUsageMetadata{
+		PromptTokenCount:        120,
+		CachedContentTokenCount: 20,
+		ToolUsePromptTokenCount: 10,
+		ResponseTokenCount:      300,
+		ThoughtsTokenCount:      5,
+		PromptTokensDetails: []dto.GeminiPromptTokensDetails{
+			{Modality: "TEXT", TokenCount: 30},
+			{Modality: "AUDIO", TokenCount: 90},
+		},
+		ResponseTokensDetails: []dto.GeminiPromptTokensDetails{
+			{Modality: "AUDIO", TokenCount: 280},
+		},
+	})
+
+	require.Equal(t, 130, usage.InputTokens)
+	require.Equal(t, 305, usage.OutputTokens)
+	require.Equal(t, 435, usage.TotalTokens)
+	require.Equal(t, 90, usage.InputTokenDetails.AudioTokens)
+	require.Equal(t, 40, usage.InputTokenDetails.TextTokens)
+	require.Equal(t, 20, usage.InputTokenDetails.CachedTokens)
+	require.Equal(t, 280, usage.OutputTokenDetails.AudioTokens)
+	require.Equal(t, 25, usage.OutputTokenDetails.TextTokens)
+}
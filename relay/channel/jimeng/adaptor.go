@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service/relayconvert"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -33,7 +36,10 @@ func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
 }
 
 func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
-	return fmt.Sprintf("%s/?Action=CVProcess&Version=2022-08-31", info.ChannelBaseUrl), nil
+	if isAsyncModel(info.UpstreamModelName) {
+		return actionURL(info, jimengSubmitTaskAction), nil
+	}
+	return actionURL(info, "CVProcess"), nil
 }
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, header *http.Header, info *relaycommon.RelayInfo) error {
@@ -77,6 +83,30 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 	if request.ResponseFormat == "" || request.ResponseFormat == "url" {
 		payload.ReturnURL = true // Default to returning image URLs
 	}
+	if width, height, ok := parseImageSize(request.Size); ok && isAsyncModel(info.UpstreamModelName) {
+		payload.Width = width
+		payload.Height = height
+	}
+
+	// 编辑、变体请求的输入图片以 base64 传入，需要使用支持图生图的模型
+	if relayconvert.IsImageInputMode(info) {
+		if !isAsyncModel(info.UpstreamModelName) {
+			return nil, fmt.Errorf("model %s does not support image input", info.UpstreamModelName)
+		}
+		inputs, err := relayconvert.ReadImageInputs(c, &request)
+		if err != nil {
+			return nil, err
+		}
+		if len(inputs) == 0 {
+			return nil, errors.New("image is required")
+		}
+		for _, input := range inputs {
+			payload.BinaryData = append(payload.BinaryData, input.Data)
+		}
+		if strings.TrimSpace(payload.Prompt) == "" && info.RelayMode == relayconstant.RelayModeImagesVariations {
+			payload.Prompt = relayconvert.ImageVariationPrompt
+		}
+	}
 
 	if len(request.ExtraFields) > 0 {
 		if err := json.Unmarshal(request.ExtraFields, &payload); err != nil {
@@ -87,6 +117,23 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 	return payload, nil
 }
 
+// parseImageSize 解析 WxH 形式的 size
+func parseImageSize(size string) (int, int, bool) {
+	widthStr, heightStr, ok := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	if !ok {
+		return 0, 0, false
+	}
+	width, err := strconv.Atoi(widthStr)
+	if err != nil || width <= 0 {
+		return 0, 0, false
+	}
+	height, err := strconv.Atoi(heightStr)
+	if err != nil || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
 func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
 	return nil, errors.New("not implemented")
 }
@@ -124,7 +171,9 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
-	if info.RelayMode == relayconstant.RelayModeImagesGenerations {
+	if isAsyncModel(info.UpstreamModelName) {
+		usage, err = jimengAsyncImageHandler(c, resp, info)
+	} else if info.RelayMode == relayconstant.RelayModeImagesGenerations {
 		usage, err = jimengImageHandler(c, resp, info)
 	} else if info.IsStream {
 		usage, err = openai.OaiStreamHandler(c, info, resp)
@@ -0,0 +1,158 @@
+package jimeng
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 即梦 3.0 及之后的图片模型只提供异步接口：提交任务后轮询结果
+const (
+	jimengSubmitTaskAction = "CVSync2AsyncSubmitTask"
+	jimengGetResultAction  = "CVSync2AsyncGetResult"
+	jimengAPIVersion       = "2022-08-31"
+
+	jimengCodeSuccess = 10000
+
+	jimengTaskStatusDone     = "done"
+	jimengTaskStatusNotFound = "not_found"
+	jimengTaskStatusExpired  = "expired"
+)
+
+var asyncModels = map[string]bool{
+	"jimeng_t2i_v30": true,
+	"jimeng_t2i_v31": true,
+	"jimeng_t2i_v40": true,
+	"jimeng_i2i_v30": true,
+}
+
+func isAsyncModel(model string) bool {
+	return asyncModels[model]
+}
+
+func actionURL(info *relaycommon.RelayInfo, action string) string {
+	return fmt.Sprintf("%s/?Action=%s&Version=%s", info.ChannelBaseUrl, action, jimengAPIVersion)
+}
+
+type getResultRequest struct {
+	ReqKey  string `json:"req_key"`
+	TaskID  string `json:"task_id"`
+	ReqJson string `json:"req_json,omitempty"`
+}
+
+func getTaskResult(c *gin.Context, info *relaycommon.RelayInfo, taskID string, returnURL bool) (*ImageResponse, error) {
+	reqJson, err := common.Marshal(map[string]any{"return_url": returnURL})
+	if err != nil {
+		return nil, err
+	}
+	body, err := common.Marshal(getResultRequest{
+		ReqKey:  info.UpstreamModelName,
+		TaskID:  taskID,
+		ReqJson: string(reqJson),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, actionURL(info, jimengGetResultAction), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if err := Sign(c, req, info.ApiKey); err != nil {
+		return nil, err
+	}
+	resp, err := channel.DoRequest(c, req, info)
+	if err != nil {
+		return nil, err
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result ImageResponse
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func asyncTaskWait(c *gin.Context, info *relaycommon.RelayInfo, taskID string, returnURL bool) (*ImageResponse, error) {
+	waitSeconds := 3
+	step := 0
+	maxStep := 60
+
+	for {
+		time.Sleep(time.Duration(waitSeconds) * time.Second)
+		step++
+		logger.LogDebug(c, "jimeng asyncTaskWait step %d/%d", step, maxStep)
+		result, err := getTaskResult(c, info, taskID, returnURL)
+		if err != nil {
+			logger.LogWarn(c, "jimeng asyncTaskWait getTaskResult err: "+err.Error())
+		} else if result.Code != jimengCodeSuccess {
+			return result, nil
+		} else {
+			switch result.Data.Status {
+			case jimengTaskStatusDone, jimengTaskStatusNotFound, jimengTaskStatusExpired:
+				return result, nil
+			}
+		}
+		if step >= maxStep {
+			break
+		}
+	}
+	return nil, fmt.Errorf("jimeng task %s wait timeout", taskID)
+}
+
+// jimengAsyncImageHandler 读取提交任务的响应，等待任务完成后按 OpenAI 图片格式返回
+func jimengAsyncImageHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+
+	var submitResponse ImageResponse
+	if err := json.Unmarshal(responseBody, &submitResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if submitResponse.Code != jimengCodeSuccess {
+		return nil, jimengError(&submitResponse, resp.StatusCode)
+	}
+
+	returnURL := true
+	if imageRequest, ok := info.Request.(*dto.ImageRequest); ok {
+		returnURL = imageRequest.ResponseFormat == "" || imageRequest.ResponseFormat == "url"
+	}
+	result, err := asyncTaskWait(c, info, submitResponse.Data.TaskID, returnURL)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	if result.Code != jimengCodeSuccess {
+		return nil, jimengError(result, http.StatusBadRequest)
+	}
+	if result.Data.Status != jimengTaskStatusDone {
+		return nil, types.NewOpenAIError(fmt.Errorf("jimeng task %s status: %s", submitResponse.Data.TaskID, result.Data.Status), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+
+	jsonResponse, err := json.Marshal(responseJimeng2OpenAIImage(c, result, info))
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write(jsonResponse)
+	return &dto.Usage{}, nil
+}
@@ -6,4 +6,8 @@ const (
 
 var ModelList = []string{
 	"jimeng_high_aes_general_v21_L",
+	"jimeng_t2i_v30",
+	"jimeng_t2i_v31",
+	"jimeng_t2i_v40",
+	"jimeng_i2i_v30",
 }
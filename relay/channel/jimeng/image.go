@@ -22,6 +22,9 @@ type ImageResponse struct {
 		ImageUrls        []string `json:"image_urls"`
 		RephraseResult   string   `json:"rephraser_result"`
 		RequestID        string   `json:"request_id"`
+		// 异步任务
+		TaskID string `json:"task_id"`
+		Status string `json:"status"`
 		// Other fields are omitted for brevity
 	} `json:"data"`
 	RequestID   string `json:"request_id"`
@@ -48,6 +51,15 @@ func responseJimeng2OpenAIImage(_ *gin.Context, response *ImageResponse, info *r
 	return &imageResponse
 }
 
+func jimengError(response *ImageResponse, statusCode int) *types.NewAPIError {
+	return types.WithOpenAIError(types.OpenAIError{
+		Message: response.Message,
+		Type:    "jimeng_error",
+		Param:   "",
+		Code:    fmt.Sprintf("%d", response.Code),
+	}, statusCode)
+}
+
 // jimengImageHandler handles the Jimeng image generation response
 func jimengImageHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
 	var jimengResponse ImageResponse
@@ -63,13 +75,8 @@ func jimengImageHandler(c *gin.Context, resp *http.Response, info *relaycommon.R
 	}
 
 	// Check if the response indicates an error
-	if jimengResponse.Code != 10000 {
-		return nil, types.WithOpenAIError(types.OpenAIError{
-			Message: jimengResponse.Message,
-			Type:    "jimeng_error",
-			Param:   "",
-			Code:    fmt.Sprintf("%d", jimengResponse.Code),
-		}, resp.StatusCode)
+	if jimengResponse.Code != jimengCodeSuccess {
+		return nil, jimengError(&jimengResponse, resp.StatusCode)
 	}
 
 	// Convert Jimeng response to OpenAI format
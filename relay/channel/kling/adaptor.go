@@ -0,0 +1,117 @@
+package kling
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// Adaptor 可灵图片生成，视频生成见 task/kling
+type Adaptor struct {
+}
+
+func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertClaudeRequest(*gin.Context, *relaycommon.RelayInfo, *dto.ClaudeRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
+}
+
+func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return imageGenerationsURL(info), nil
+}
+
+func (a *Adaptor) SetupRequestHeader(c *gin.Context, header *http.Header, info *relaycommon.RelayInfo) error {
+	return setupHeader(header, info.ApiKey)
+}
+
+func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	info.IsStream = false
+	payload := imageRequestPayload{
+		ModelName:   info.UpstreamModelName,
+		Prompt:      strings.TrimSpace(request.Prompt),
+		N:           int(lo.FromPtrOr(request.N, uint(1))),
+		AspectRatio: relayconvert.ImageSizeToAspectRatio(request.Size),
+	}
+
+	// 编辑、变体请求以第一张输入图片作为参考图
+	if relayconvert.IsImageInputMode(info) {
+		inputs, err := relayconvert.ReadImageInputs(c, &request)
+		if err != nil {
+			return nil, err
+		}
+		if len(inputs) == 0 {
+			return nil, errors.New("image is required")
+		}
+		payload.Image = inputs[0].Data
+		if payload.Prompt == "" && info.RelayMode == relayconstant.RelayModeImagesVariations {
+			payload.Prompt = relayconvert.ImageVariationPrompt
+		}
+	}
+	if payload.Prompt == "" {
+		return nil, errors.New("prompt is required")
+	}
+
+	if len(request.ExtraFields) > 0 {
+		if err := common.Unmarshal(request.ExtraFields, &payload); err != nil {
+			return nil, err
+		}
+	}
+	return payload, nil
+}
+
+func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	switch info.RelayMode {
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
+		return channel.DoApiRequest(a, c, info, requestBody)
+	}
+	return nil, errors.New("kling channel only supports image generation on this endpoint")
+}
+
+func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	return klingImageHandler(c, resp, info)
+}
+
+func (a *Adaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *Adaptor) GetChannelName() string {
+	return ChannelName
+}
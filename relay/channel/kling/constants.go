@@ -0,0 +1,12 @@
+package kling
+
+const (
+	ChannelName = "kling"
+)
+
+var ModelList = []string{
+	"kling-v1",
+	"kling-v1-5",
+	"kling-v2",
+	"kling-v2-1",
+}
@@ -0,0 +1,191 @@
+package kling
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	taskkling "github.com/QuantumNous/new-api/relay/channel/task/kling"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	imageGenerationsPath = "/v1/images/generations"
+
+	taskStatusSucceed = "succeed"
+	taskStatusFailed  = "failed"
+)
+
+type imageRequestPayload struct {
+	ModelName      string `json:"model_name,omitempty"`
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	Image          string `json:"image,omitempty"`
+	ImageReference string `json:"image_reference,omitempty"`
+	ImageFidelity  string `json:"image_fidelity,omitempty"`
+	N              int    `json:"n,omitempty"`
+	AspectRatio    string `json:"aspect_ratio,omitempty"`
+	CallbackUrl    string `json:"callback_url,omitempty"`
+}
+
+type imageTaskResponse struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestId string `json:"request_id"`
+	Data      struct {
+		TaskId        string `json:"task_id"`
+		TaskStatus    string `json:"task_status"`
+		TaskStatusMsg string `json:"task_status_msg"`
+		TaskResult    struct {
+			Images []struct {
+				Index int    `json:"index"`
+				Url   string `json:"url"`
+			} `json:"images"`
+		} `json:"task_result"`
+		CreatedAt int64 `json:"created_at"`
+	} `json:"data"`
+}
+
+func imageGenerationsURL(info *relaycommon.RelayInfo) string {
+	if taskkling.IsNewAPIRelay(info.ApiKey) {
+		return fmt.Sprintf("%s/kling%s", info.ChannelBaseUrl, imageGenerationsPath)
+	}
+	return fmt.Sprintf("%s%s", info.ChannelBaseUrl, imageGenerationsPath)
+}
+
+func setupHeader(header *http.Header, apiKey string) error {
+	token, err := taskkling.CreateJWTToken(apiKey)
+	if err != nil {
+		return fmt.Errorf("failed to create JWT token: %w", err)
+	}
+	header.Set("Content-Type", "application/json")
+	header.Set("Accept", "application/json")
+	header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func klingError(response *imageTaskResponse, statusCode int) *types.NewAPIError {
+	return types.WithOpenAIError(types.OpenAIError{
+		Message: response.Message,
+		Type:    "kling_error",
+		Param:   "",
+		Code:    fmt.Sprintf("%d", response.Code),
+	}, statusCode)
+}
+
+func readImageTaskResponse(resp *http.Response) (*imageTaskResponse, error) {
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var response imageTaskResponse
+	if err := common.Unmarshal(responseBody, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func fetchImageTask(c *gin.Context, info *relaycommon.RelayInfo, taskID string) (*imageTaskResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", imageGenerationsURL(info), taskID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := setupHeader(&req.Header, info.ApiKey); err != nil {
+		return nil, err
+	}
+	resp, err := channel.DoRequest(c, req, info)
+	if err != nil {
+		return nil, err
+	}
+	return readImageTaskResponse(resp)
+}
+
+func asyncTaskWait(c *gin.Context, info *relaycommon.RelayInfo, taskID string) (*imageTaskResponse, error) {
+	waitSeconds := 3
+	step := 0
+	maxStep := 60
+
+	for {
+		time.Sleep(time.Duration(waitSeconds) * time.Second)
+		step++
+		logger.LogDebug(c, "kling asyncTaskWait step %d/%d", step, maxStep)
+		result, err := fetchImageTask(c, info, taskID)
+		if err != nil {
+			logger.LogWarn(c, "kling asyncTaskWait fetchImageTask err: "+err.Error())
+		} else if result.Code != 0 {
+			return result, nil
+		} else {
+			switch result.Data.TaskStatus {
+			case taskStatusSucceed, taskStatusFailed:
+				return result, nil
+			}
+		}
+		if step >= maxStep {
+			break
+		}
+	}
+	return nil, fmt.Errorf("kling task %s wait timeout", taskID)
+}
+
+// klingImageHandler 读取提交任务的响应，等待任务完成后按 OpenAI 图片格式返回，
+// 可灵只返回图片链接，b64_json 时下载后转为 base64
+func klingImageHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
+	statusCode := resp.StatusCode
+	submitResponse, err := readImageTaskResponse(resp)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if submitResponse.Code != 0 {
+		return nil, klingError(submitResponse, statusCode)
+	}
+
+	result, err := asyncTaskWait(c, info, submitResponse.Data.TaskId)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	if result.Code != 0 {
+		return nil, klingError(result, http.StatusBadRequest)
+	}
+	if result.Data.TaskStatus != taskStatusSucceed {
+		return nil, types.NewOpenAIError(fmt.Errorf("kling task %s failed: %s", submitResponse.Data.TaskId, result.Data.TaskStatusMsg), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+
+	responseFormat := ""
+	if imageRequest, ok := info.Request.(*dto.ImageRequest); ok {
+		responseFormat = imageRequest.ResponseFormat
+	}
+	imageResponse := dto.ImageResponse{
+		Created: info.StartTime.Unix(),
+		Data:    make([]dto.ImageData, 0, len(result.Data.TaskResult.Images)),
+	}
+	for _, image := range result.Data.TaskResult.Images {
+		if responseFormat != "b64_json" {
+			imageResponse.Data = append(imageResponse.Data, dto.ImageData{Url: image.Url})
+			continue
+		}
+		base64Data, _, err := service.GetBase64Data(c, types.NewURLFileSource(image.Url), "kling image")
+		if err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		imageResponse.Data = append(imageResponse.Data, dto.ImageData{B64Json: base64Data})
+	}
+
+	jsonResponse, err := common.Marshal(imageResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write(jsonResponse)
+	return &dto.Usage{}, nil
+}
@@ -39,7 +39,10 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 
 	voiceID := request.Voice
 	speed := lo.FromPtrOr(request.Speed, 0.0)
-	outputFormat := request.ResponseFormat
+	audioFormat := request.ResponseFormat
+	if !lo.Contains(supportedTTSFormats, audioFormat) {
+		audioFormat = "mp3"
+	}
 
 	minimaxRequest := MiniMaxTTSRequest{
 		Model: info.OriginModelName,
@@ -49,9 +52,10 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 			Speed:   speed,
 		},
 		AudioSetting: &AudioSetting{
-			Format: outputFormat,
+			Format: audioFormat,
 		},
-		OutputFormat: outputFormat,
+		// hex 直接返回音频数据，便于按时长计费
+		OutputFormat: "hex",
 	}
 
 	// 同步扩展字段的厂商自定义metadata
@@ -65,11 +69,11 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 	if err != nil {
 		return nil, fmt.Errorf("error marshalling minimax request: %w", err)
 	}
-	if outputFormat != "hex" {
-		outputFormat = "url"
+	if minimaxRequest.AudioSetting != nil && minimaxRequest.AudioSetting.Format != "" {
+		audioFormat = minimaxRequest.AudioSetting.Format
 	}
 
-	c.Set("response_format", outputFormat)
+	c.Set("response_format", audioFormat)
 
 	// Debug: log the request structure
 	// fmt.Printf("MiniMax TTS Request: %s\n", string(jsonData))
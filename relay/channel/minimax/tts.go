@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/QuantumNous/new-api/dto"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
@@ -83,9 +82,13 @@ type MiniMaxTTSData struct {
 }
 
 type MiniMaxExtraInfo struct {
-	UsageCharacters int64 `json:"usage_characters"`
+	UsageCharacters int64  `json:"usage_characters"`
+	AudioLength     int64  `json:"audio_length"` // 毫秒
+	AudioFormat     string `json:"audio_format"`
 }
 
+var supportedTTSFormats = []string{"mp3", "wav", "flac", "pcm"}
+
 type MiniMaxBaseResp struct {
 	StatusCode int64  `json:"status_code"`
 	StatusMsg  string `json:"status_msg"`
@@ -144,11 +147,18 @@ func handleTTSResponse(c *gin.Context, resp *http.Response, info *relaycommon.Re
 		)
 	}
 
+	audioFormat := c.GetString("response_format")
+	if minimaxResp.ExtraInfo.AudioFormat != "" {
+		audioFormat = minimaxResp.ExtraInfo.AudioFormat
+	}
+
+	var audioData []byte
 	if strings.HasPrefix(minimaxResp.Data.Audio, "http") {
 		c.Redirect(http.StatusFound, minimaxResp.Data.Audio)
 	} else {
 		// Handle hex-encoded audio data
-		audioData, decodeErr := hex.DecodeString(minimaxResp.Data.Audio)
+		var decodeErr error
+		audioData, decodeErr = hex.DecodeString(minimaxResp.Data.Audio)
 		if decodeErr != nil {
 			return nil, types.NewErrorWithStatusCode(
 				fmt.Errorf("failed to decode hex audio data: %w", decodeErr),
@@ -157,19 +167,17 @@ func handleTTSResponse(c *gin.Context, resp *http.Response, info *relaycommon.Re
 			)
 		}
 
-		// Determine content type - default to mp3
-		contentType := "audio/mpeg"
-
-		c.Data(http.StatusOK, contentType, audioData)
+		c.Data(http.StatusOK, getContentTypeByFormat(audioFormat), audioData)
 	}
 
-	usage = &dto.Usage{
-		PromptTokens:     info.GetEstimatePromptTokens(),
-		CompletionTokens: 0,
-		TotalTokens:      int(minimaxResp.ExtraInfo.UsageCharacters),
+	// 优先使用上游返回的音频时长，否则解析音频数据
+	if minimaxResp.ExtraInfo.AudioLength > 0 {
+		return service.SpeechUsageFromDuration(info, float64(minimaxResp.ExtraInfo.AudioLength)/1000.0), nil
 	}
-
-	return usage, nil
+	if len(audioData) > 0 {
+		return service.BuildSpeechUsage(c, info, audioData, audioFormat), nil
+	}
+	return service.SpeechUsageFromDuration(info, 0), nil
 }
 
 func handleChatCompletionResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
@@ -1,10 +1,8 @@
 package openai
 
 import (
-	"bytes"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 
 	"github.com/QuantumNous/new-api/common"
@@ -76,40 +74,7 @@ func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat != "" {
 			audioFormat = audioReq.ResponseFormat
 		}
-
-		var duration float64
-		var durationErr error
-
-		if audioFormat == "pcm" {
-			// PCM 格式没有文件头，根据 OpenAI TTS 的 PCM 参数计算时长
-			// 采样率: 24000 Hz, 位深度: 16-bit (2 bytes), 声道数: 1
-			const sampleRate = 24000
-			const bytesPerSample = 2
-			const channels = 1
-			duration = float64(len(bodyBytes)) / float64(sampleRate*bytesPerSample*channels)
-		} else {
-			ext := "." + audioFormat
-			reader := bytes.NewReader(bodyBytes)
-			duration, durationErr = common.GetAudioDuration(c.Request.Context(), reader, ext)
-		}
-
-		usage.PromptTokensDetails.TextTokens = usage.PromptTokens
-
-		if durationErr != nil {
-			logger.LogWarn(c, fmt.Sprintf("failed to get audio duration: %v", durationErr))
-			// 如果无法获取时长，则设置保底的 CompletionTokens，根据body大小计算
-			sizeInKB := float64(len(bodyBytes)) / 1000.0
-			estimatedTokens := int(math.Ceil(sizeInKB)) // 粗略估算每KB约等于1 token
-			usage.CompletionTokens = estimatedTokens
-			usage.CompletionTokenDetails.AudioTokens = estimatedTokens
-		} else if duration > 0 {
-			// 计算 token: ceil(duration) / 60.0 * 1000，即每分钟 1000 tokens。
-			// duration 解析自上游返回的音频元数据，饱和转换防止 int 回绕。
-			completionTokens := common.QuotaRound(math.Ceil(duration) / 60.0 * 1000)
-			usage.CompletionTokens = completionTokens
-			usage.CompletionTokenDetails.AudioTokens = completionTokens
-		}
-		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		usage = service.BuildSpeechUsage(c, info, bodyBytes, audioFormat)
 	}
 
 	return usage
@@ -147,3 +112,13 @@ func OpenaiSTTHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	return nil, usage
 }
+
+// WriteAudioTranscriptionResponse 按 response_format 输出非 OpenAI 渠道转换得到的转写文本，
+// 上游没有时间戳信息，srt/vtt 等格式退化为 json
+func WriteAudioTranscriptionResponse(c *gin.Context, text string, responseFormat string) {
+	if responseFormat == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+		return
+	}
+	c.JSON(http.StatusOK, dto.AudioResponse{Text: text})
+}
@@ -126,7 +126,23 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 	// 检查是否为音频模型
 	isAudioModel := strings.Contains(strings.ToLower(model), "audio")
 
+	// 启用工具调用校验时，工具调用增量缓存到 choice 结束后校验、修复再整体输出
+	toolCallValidator := service.NewToolCallValidator(info)
+	var toolCallErr *types.NewAPIError
+
 	helper.StreamScannerHandler(c, resp, info, func(data string, sr *helper.StreamResult) {
+		if toolCallValidator != nil && len(data) > 0 {
+			filtered, keep, err := toolCallValidator.FilterChatStreamChunk(data)
+			if err != nil {
+				toolCallErr = service.NewInvalidToolCallError(err)
+				sr.Stop(err)
+				return
+			}
+			if !keep {
+				return
+			}
+			data = filtered
+		}
 		if lastStreamData != "" {
 			if err := HandleStreamFormat(c, info, lastStreamData, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent); err != nil {
 				common.SysLog("error handling stream format: " + err.Error())
@@ -147,6 +163,15 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 		}
 	})
 
+	if toolCallErr == nil && toolCallValidator != nil {
+		if err := toolCallValidator.FinishChatStream(); err != nil {
+			toolCallErr = service.NewInvalidToolCallError(err)
+		}
+	}
+	if toolCallErr != nil {
+		return nil, toolCallErr
+	}
+
 	// 对音频模型，从倒数第二个stream data中提取usage信息
 	if isAudioModel && secondLastStreamData != "" {
 		var streamResp struct {
@@ -225,6 +250,17 @@ func OpenaiHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Respo
 		return nil, types.WithOpenAIError(*oaiError, resp.StatusCode)
 	}
 
+	if toolCallValidator := service.NewToolCallValidator(info); toolCallValidator != nil {
+		responseBody, err = toolCallValidator.ValidateChatResponseBody(responseBody)
+		if err != nil {
+			return nil, service.NewInvalidToolCallError(err)
+		}
+		// 参数可能已被修复，重新解析
+		if err = common.Unmarshal(responseBody, &simpleResponse); err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+	}
+
 	for _, choice := range simpleResponse.Choices {
 		if choice.FinishReason == constant.FinishReasonContentFilter {
 			common.SetContextKey(c, constant.ContextKeyAdminRejectReason, "openai_finish_reason=content_filter")
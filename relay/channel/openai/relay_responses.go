@@ -33,6 +33,13 @@ func OaiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 	if oaiError := responsesResponse.GetOpenAIError(); oaiError != nil && oaiError.Type != "" {
 		return nil, types.WithOpenAIError(*oaiError, resp.StatusCode)
 	}
+	if toolCallValidator := service.NewToolCallValidator(info); toolCallValidator != nil {
+		responseBody, err = toolCallValidator.ValidateResponsesBody(responseBody)
+		if err != nil {
+			return nil, service.NewInvalidToolCallError(err)
+		}
+	}
+	service.RecordResponseAffinity(c, responsesResponse.ID)
 
 	if responsesResponse.HasImageGenerationCall() {
 		c.Set("image_generation_call", true)
@@ -80,7 +87,20 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 	var usage = &dto.Usage{}
 	var responseTextBuilder strings.Builder
 
+	// 启用工具调用校验时，在 function_call 完成的事件中校验、修复参数
+	toolCallValidator := service.NewToolCallValidator(info)
+	var toolCallErr *types.NewAPIError
+
 	helper.StreamScannerHandler(c, resp, info, func(data string, sr *helper.StreamResult) {
+		if toolCallValidator != nil {
+			validated, err := toolCallValidator.ValidateResponsesStreamEvent(data)
+			if err != nil {
+				toolCallErr = service.NewInvalidToolCallError(err)
+				sr.Stop(err)
+				return
+			}
+			data = validated
+		}
 
 		// 检查当前数据是否包含 completed 状态和 usage 信息
 		var streamResponse dto.ResponsesStreamResponse
@@ -91,6 +111,10 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 		}
 		sendResponsesStreamData(c, streamResponse, data)
 		switch streamResponse.Type {
+		case "response.created":
+			if streamResponse.Response != nil {
+				service.RecordResponseAffinity(c, streamResponse.Response.ID)
+			}
 		case "response.completed":
 			if streamResponse.Response != nil {
 				if streamResponse.Response.Usage != nil {
@@ -131,6 +155,9 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 			}
 		}
 	})
+	if toolCallErr != nil {
+		return nil, toolCallErr
+	}
 
 	if usage.CompletionTokens == 0 {
 		// 计算输出文本的 token 数量
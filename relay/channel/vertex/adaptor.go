@@ -214,6 +214,10 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, req)
+	if info.RelayMode == constant.RelayModeImagesEdits || info.RelayMode == constant.RelayModeImagesVariations {
+		// multipart 图片请求已转换为 generateContent 的 JSON
+		req.Set("Content-Type", "application/json")
+	}
 	if info.ChannelOtherSettings.VertexKeyType != dto.VertexKeyTypeAPIKey {
 		accessToken, err := getAccessToken(a, info)
 		if err != nil {
@@ -362,6 +366,9 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 				if strings.HasPrefix(info.UpstreamModelName, "imagen") {
 					return gemini.GeminiImageHandler(c, info, resp)
 				}
+				if isImageRelayMode(info.RelayMode) {
+					return gemini.GeminiChatImageHandler(c, info, resp)
+				}
 				return gemini.GeminiChatHandler(c, info, resp)
 			}
 		case RequestModeOpenSource:
@@ -371,6 +378,12 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	return
 }
 
+func isImageRelayMode(relayMode int) bool {
+	return relayMode == constant.RelayModeImagesGenerations ||
+		relayMode == constant.RelayModeImagesEdits ||
+		relayMode == constant.RelayModeImagesVariations
+}
+
 func (a *Adaptor) GetModelList() []string {
 	var modelList []string
 	for i, s := range ModelList {
@@ -47,6 +47,18 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 }
 
 func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	if info.RelayMode == constant.RelayModeAudioTranscription {
+		asrRequest, err := convertASRRequest(c)
+		if err != nil {
+			return nil, err
+		}
+		info.IsStream = false
+		jsonData, err := json.Marshal(asrRequest)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling volcengine asr request: %w", err)
+		}
+		return bytes.NewReader(jsonData), nil
+	}
 	if info.RelayMode != constant.RelayModeAudioSpeech {
 		return nil, errors.New("unsupported audio relay mode")
 	}
@@ -278,6 +290,11 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 				return "wss://openspeech.bytedance.com/api/v1/tts/ws_binary", nil
 			}
 			return fmt.Sprintf("%s/v1/audio/speech", baseUrl), nil
+		case constant.RelayModeAudioTranscription:
+			if baseUrl == channelconstant.ChannelBaseURLs[channelconstant.ChannelTypeVolcEngine] {
+				return volcengineASRFlashURL, nil
+			}
+			return baseUrl + volcengineASRFlashPath, nil
 		default:
 		}
 	}
@@ -294,6 +311,8 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *rel
 		}
 		req.Set("Content-Type", "application/json")
 		return nil
+	} else if info.RelayMode == constant.RelayModeAudioTranscription {
+		return setupASRRequestHeader(req, info.ApiKey)
 	} else if info.RelayMode == constant.RelayModeImagesEdits {
 		req.Set("Content-Type", gin.MIMEJSON)
 	}
@@ -387,6 +406,9 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		}
 		return handleTTSResponse(c, resp, info, encoding)
 	}
+	if info.RelayMode == constant.RelayModeAudioTranscription {
+		return handleASRResponse(c, resp, info)
+	}
 
 	adaptor := openai.Adaptor{}
 	usage, err = adaptor.DoResponse(c, resp, info)
@@ -0,0 +1,129 @@
+package volcengine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/relayconvert"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 豆包大模型录音文件识别极速版，一次请求同步返回识别结果
+const (
+	volcengineASRFlashURL        = "https://openspeech.bytedance.com/api/v3/auc/bigmodel/recognize/flash"
+	volcengineASRFlashPath       = "/api/v3/auc/bigmodel/recognize/flash"
+	volcengineASRResourceID      = "volc.bigasr.auc_turbo"
+	volcengineASRStatusSucceeded = "20000000"
+)
+
+type VolcengineASRRequest struct {
+	User    VolcengineTTSUser       `json:"user"`
+	Audio   VolcengineASRAudio      `json:"audio"`
+	Request VolcengineASRReqOptions `json:"request"`
+}
+
+type VolcengineASRAudio struct {
+	Data   string `json:"data,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+type VolcengineASRReqOptions struct {
+	ModelName  string `json:"model_name"`
+	EnableITN  bool   `json:"enable_itn,omitempty"`
+	EnablePunc bool   `json:"enable_punc,omitempty"`
+	Context    string `json:"context,omitempty"`
+}
+
+type VolcengineASRResponse struct {
+	AudioInfo struct {
+		Duration int64 `json:"duration"`
+	} `json:"audio_info"`
+	Result struct {
+		Text string `json:"text"`
+	} `json:"result"`
+}
+
+func convertASRRequest(c *gin.Context) (*VolcengineASRRequest, error) {
+	form, err := relayconvert.ReadAudioTranscriptionForm(c)
+	if err != nil {
+		return nil, err
+	}
+	volcRequest := &VolcengineASRRequest{
+		User: VolcengineTTSUser{
+			UID: "openai_relay_user",
+		},
+		Audio: VolcengineASRAudio{
+			Data: base64.StdEncoding.EncodeToString(form.File.Data),
+		},
+		Request: VolcengineASRReqOptions{
+			ModelName:  "bigmodel",
+			EnableITN:  true,
+			EnablePunc: true,
+		},
+	}
+	if form.Prompt != "" {
+		// 热词、上下文等通过 context 传入
+		volcRequest.Request.Context = form.Prompt
+	}
+	return volcRequest, nil
+}
+
+func setupASRRequestHeader(req *http.Header, apiKey string) error {
+	appID, token, err := parseVolcengineAuth(apiKey)
+	if err != nil {
+		return err
+	}
+	req.Set("Content-Type", "application/json")
+	req.Set("X-Api-App-Key", appID)
+	req.Set("X-Api-Access-Key", token)
+	req.Set("X-Api-Resource-Id", volcengineASRResourceID)
+	req.Set("X-Api-Request-Id", generateRequestID())
+	req.Set("X-Api-Sequence", "-1")
+	return nil
+}
+
+func handleASRResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	// 识别结果状态码通过响应头返回
+	if statusCode := resp.Header.Get("X-Api-Status-Code"); statusCode != "" && statusCode != volcengineASRStatusSucceeded {
+		return nil, types.NewErrorWithStatusCode(
+			fmt.Errorf("volcengine asr error: %s - %s", statusCode, resp.Header.Get("X-Api-Message")),
+			types.ErrorCodeBadResponse,
+			http.StatusBadRequest,
+		)
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, types.NewErrorWithStatusCode(
+			errors.New("failed to read volcengine response"),
+			types.ErrorCodeReadResponseBodyFailed,
+			http.StatusInternalServerError,
+		)
+	}
+	var volcResp VolcengineASRResponse
+	if unmarshalErr := json.Unmarshal(body, &volcResp); unmarshalErr != nil {
+		return nil, types.NewErrorWithStatusCode(
+			errors.New("failed to parse volcengine response"),
+			types.ErrorCodeBadResponseBody,
+			http.StatusInternalServerError,
+		)
+	}
+
+	responseFormat := ""
+	if audioRequest, ok := info.Request.(*dto.AudioRequest); ok {
+		responseFormat = audioRequest.ResponseFormat
+	}
+	openai.WriteAudioTranscriptionResponse(c, volcResp.Result.Text, responseFormat)
+	return service.BuildTranscriptionUsage(c, info, volcResp.Result.Text), nil
+}
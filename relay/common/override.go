@@ -10,10 +10,12 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/samber/lo"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var negativeIndexRegexp = regexp.MustCompile(`\.(-\d+)`)
@@ -181,6 +183,8 @@ func ApplyParamOverrideWithRelayInfo(jsonData []byte, info *RelayInfo) ([]byte,
 		return jsonData, nil
 	}
 
+	_, span := tracing.Start(info.TraceContext(), "relay.param_override",
+		attribute.Int("newapi.param_override_keys", len(paramOverride)))
 	overrideCtx := BuildParamOverrideContext(info)
 	var recorder *paramOverrideAuditRecorder
 	if shouldEnableParamOverrideAudit(paramOverride) {
@@ -188,6 +192,7 @@ func ApplyParamOverrideWithRelayInfo(jsonData []byte, info *RelayInfo) ([]byte,
 		overrideCtx[paramOverrideContextAuditRecorder] = recorder
 	}
 	result, err := ApplyParamOverride(jsonData, paramOverride, overrideCtx)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -20,6 +22,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ThinkingContentInfo struct {
@@ -127,12 +130,22 @@ type RelayInfo struct {
 	// 强制预扣全额。用于异步任务（视频/音乐生成等），因为请求返回后任务仍在运行，
 	// 必须在提交前锁定全额。
 	ForcePreConsume bool
+	// TokenQuotaBudgeted 令牌设置了周期预算，结算/退款时需同步调整预算用量；
+	// TokenRateLimited 令牌设置了 TPM 限制，TokenRateLimitChecked 表示本次请求已计入（RPM 在 TokenAuth 入口计入）。
+	// 两者都会禁用信任额度旁路，保证每次请求经过 PreConsumeTokenQuota。
+	// TokenTPMReserved 表示令牌 TPM 已在请求入口由 ReserveTPM 预占，预扣费时不再重复计入。
+	TokenQuotaBudgeted    bool
+	TokenRateLimited      bool
+	TokenRateLimitChecked bool
+	TokenTPMReserved      bool
 	// Billing 是计费会话，封装了预扣费/结算/退款的统一生命周期。
 	// 免费模型时为 nil。
 	Billing BillingSettler
-	// BillingSource indicates whether this request is billed from wallet quota or subscription.
-	// "" or "wallet" => wallet; "subscription" => subscription
+	// BillingSource indicates whether this request is billed from wallet quota, subscription or an organization wallet.
+	// "" or "wallet" => wallet; "subscription" => subscription; "organization" => organization wallet
 	BillingSource string
+	// OrganizationId is the organization owning the token; such requests are billed from the organization wallet.
+	OrganizationId int
 	// SubscriptionId is the user_subscriptions.id used when BillingSource == "subscription"
 	SubscriptionId int
 	// SubscriptionPreConsumed is the amount pre-consumed on subscription item (quota units or 1)
@@ -185,6 +198,9 @@ type RelayInfo struct {
 
 	StreamStatus *StreamStatus
 
+	// traceCtx 当前重试的追踪上下文，见 SetTraceContext
+	traceCtx context.Context
+
 	ThinkingContentInfo
 	TokenCountMeta
 	*ClaudeConvertInfo
@@ -484,6 +500,10 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
 		TokenGroup:     tokenGroup,
 
+		TokenQuotaBudgeted: common.GetContextKeyBool(c, constant.ContextKeyTokenQuotaBudget),
+		TokenRateLimited:   common.GetContextKeyBool(c, constant.ContextKeyTokenRateLimit),
+		OrganizationId:     common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
+
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
 		RequestURLPath:  c.Request.URL.String(),
@@ -501,6 +521,9 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 			estimatePromptTokens: common.GetContextKeyInt(c, constant.ContextKeyEstimatedTokens),
 		},
 	}
+	if c.Request != nil {
+		info.traceCtx = c.Request.Context()
+	}
 
 	if info.RelayMode == relayconstant.RelayModeUnknown {
 		info.RelayMode = c.GetInt("relay_mode")
@@ -654,6 +677,18 @@ func GenRelayInfoResponsesCompaction(c *gin.Context, request *dto.OpenAIResponse
 //	info.promptTokens = promptTokens
 //}
 
+// SetTraceContext 记录当前重试所在的追踪上下文，供不持有 gin.Context 的步骤挂载 span
+func (info *RelayInfo) SetTraceContext(ctx context.Context) {
+	info.traceCtx = ctx
+}
+
+func (info *RelayInfo) TraceContext() context.Context {
+	if info == nil || info.traceCtx == nil {
+		return context.Background()
+	}
+	return info.traceCtx
+}
+
 func (info *RelayInfo) SetEstimatePromptTokens(promptTokens int) {
 	info.estimatePromptTokens = promptTokens
 }
@@ -666,6 +701,10 @@ func (info *RelayInfo) SetFirstResponseTime() {
 	if info.isFirstResponse {
 		info.FirstResponseTime = time.Now()
 		info.isFirstResponse = false
+		tracing.RecordSpan(info.TraceContext(), "relay.first_byte", info.StartTime, info.FirstResponseTime,
+			attribute.Bool("newapi.stream", info.IsStream),
+			attribute.Int64("newapi.ttft_ms", info.FirstResponseTime.Sub(info.StartTime).Milliseconds()),
+		)
 	}
 }
 
@@ -162,6 +162,9 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 			}
 		}
 
+		// redact pii before sending upstream
+		jsonData = service.RedactRequestPII(c, info, jsonData)
+
 		logger.LogDebug(c, "Gemini request body: %s", jsonData)
 
 		body, size, closer, err := relaycommon.NewOutboundJSONBody(jsonData)
@@ -10,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -245,13 +246,22 @@ func ClaudeData(c *gin.Context, resp dto.ClaudeResponse) error {
 	if err != nil {
 		common.SysError("error marshalling stream response: " + err.Error())
 	} else {
-		if resp.Type == "error" || resp.Type == "message_stop" {
-			markTerminalWritten(c)
-		} else {
-			markSemanticWritten(c)
+		data, flushes, keep, stopped := processStreamChunk(c, string(jsonData))
+		for _, flush := range flushes {
+			writeClaudeRestoredDelta(c, flush)
+		}
+		if keep {
+			if resp.Type == "error" || resp.Type == "message_stop" {
+				markTerminalWritten(c)
+			} else {
+				markSemanticWritten(c)
+			}
+			c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("event: %s\n", resp.Type)})
+			c.Render(-1, common.CustomEvent{Data: "data: " + data})
+		}
+		if stopped {
+			writeClaudeSensitiveStop(c, resp.Index)
 		}
-		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("event: %s\n", resp.Type)})
-		c.Render(-1, common.CustomEvent{Data: "data: " + string(jsonData)})
 	}
 	_ = maybeFlushWriter(c, false, len(resp.Type)+len(jsonData)+16)
 	return nil
@@ -265,16 +275,75 @@ func ClaudeChunkData(c *gin.Context, resp dto.ClaudeResponse, data string) {
 		return
 	}
 
-	if resp.Type == "error" || resp.Type == "message_stop" {
-		markTerminalWritten(c)
-	} else {
-		markSemanticWritten(c)
+	data, flushes, keep, stopped := processStreamChunk(c, data)
+	for _, flush := range flushes {
+		writeClaudeRestoredDelta(c, flush)
+	}
+	if keep {
+		if resp.Type == "error" || resp.Type == "message_stop" {
+			markTerminalWritten(c)
+		} else {
+			markSemanticWritten(c)
+		}
+		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("event: %s\n", resp.Type)})
+		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("data: %s\n", data)})
+	}
+	if stopped {
+		writeClaudeSensitiveStop(c, resp.Index)
 	}
-	c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("event: %s\n", resp.Type)})
-	c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("data: %s\n", data)})
 	_ = maybeFlushWriter(c, false, len(resp.Type)+len(data)+16)
 }
 
+// processStreamChunk 依次还原脱敏占位符并检查输出敏感词，返回处理后的数据、需要在其之前补发的增量事件、
+// 是否输出该条数据以及是否触发终止。脱敏还原补发的文本同样经过敏感词检查
+func processStreamChunk(c *gin.Context, data string) (string, []string, bool, bool) {
+	data, restored := service.RestorePIIStreamChunk(c, data)
+	flushes := make([]string, 0, 2)
+	if restored != "" {
+		restored, _, keep, stopped := service.ModerateCompletionStreamChunk(c, restored)
+		if keep {
+			flushes = append(flushes, restored)
+		}
+		if stopped {
+			return data, flushes, false, true
+		}
+	}
+	data, flush, keep, stopped := service.ModerateCompletionStreamChunk(c, data)
+	if flush != "" {
+		flushes = append(flushes, flush)
+	}
+	return data, flushes, keep, stopped
+}
+
+// writeClaudeRestoredDelta 内容块结束前补发暂存的文本
+func writeClaudeRestoredDelta(c *gin.Context, data string) {
+	c.Render(-1, common.CustomEvent{Data: "event: content_block_delta\n"})
+	c.Render(-1, common.CustomEvent{Data: "data: " + data})
+}
+
+// writeClaudeSensitiveStop 输出因敏感词终止时补发 Claude 的结束事件，上游后续事件不再输出
+func writeClaudeSensitiveStop(c *gin.Context, index *int) {
+	blockIndex := 0
+	if index != nil {
+		blockIndex = *index
+	}
+	stopReason := service.ClaudeRefusalStopReason
+	events := []dto.ClaudeResponse{
+		{Type: "content_block_stop", Index: &blockIndex},
+		{Type: "message_delta", Delta: &dto.ClaudeMediaMessage{StopReason: &stopReason}, Usage: &dto.ClaudeUsage{}},
+		{Type: "message_stop"},
+	}
+	for _, event := range events {
+		jsonData, err := common.Marshal(event)
+		if err != nil {
+			continue
+		}
+		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("event: %s\n", event.Type)})
+		c.Render(-1, common.CustomEvent{Data: "data: " + string(jsonData)})
+	}
+	markTerminalWritten(c)
+}
+
 func ResponseChunkData(c *gin.Context, resp dto.ResponsesStreamResponse, data string) error {
 	if shouldSuppressSemanticOutput(c) {
 		return nil
@@ -283,15 +352,35 @@ func ResponseChunkData(c *gin.Context, resp dto.ResponsesStreamResponse, data st
 		return fmt.Errorf("request context done: %w", c.Request.Context().Err())
 	}
 
-	if resp.Type == "response.completed" || resp.Type == "response.done" || resp.Type == "response.incomplete" ||
-		resp.Type == "response.failed" || resp.Type == "response.error" || resp.Type == "error" {
-		markTerminalWritten(c)
-	} else {
-		markSemanticWritten(c)
+	data, flushes, keep, stopped := processStreamChunk(c, data)
+	for _, flush := range flushes {
+		c.Render(-1, common.CustomEvent{Data: "event: response.output_text.delta\n"})
+		c.Render(-1, common.CustomEvent{Data: "data: " + flush})
+	}
+	if !keep && !stopped {
+		return nil
+	}
+	if keep {
+		if resp.Type == "response.completed" || resp.Type == "response.done" || resp.Type == "response.incomplete" ||
+			resp.Type == "response.failed" || resp.Type == "response.error" || resp.Type == "error" {
+			markTerminalWritten(c)
+		} else {
+			markSemanticWritten(c)
+		}
+		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("event: %s\n", resp.Type)})
+		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("data: %s", data)})
+	}
+	if stopped {
+		writeResponsesSensitiveStop(c)
 	}
-	c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("event: %s\n", resp.Type)})
-	c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("data: %s", data)})
-	return maybeFlushWriter(c, false, len(resp.Type)+len(data)+16)
+	return maybeFlushWriter(c, stopped, len(resp.Type)+len(data)+16)
+}
+
+// writeResponsesSensitiveStop 输出因敏感词终止时补发 response.incomplete，上游后续事件不再输出
+func writeResponsesSensitiveStop(c *gin.Context) {
+	c.Render(-1, common.CustomEvent{Data: "event: response.incomplete\n"})
+	c.Render(-1, common.CustomEvent{Data: "data: " + service.ResponsesSensitiveIncompleteEvent(c)})
+	markTerminalWritten(c)
 }
 
 func StringData(c *gin.Context, str string) error {
@@ -306,6 +395,10 @@ func StringData(c *gin.Context, str string) error {
 		return fmt.Errorf("request context done: %w", c.Request.Context().Err())
 	}
 
+	str, _, keep, _ := processStreamChunk(c, str)
+	if !keep {
+		return nil
+	}
 	markSemanticWritten(c)
 	c.Render(-1, common.CustomEvent{Data: "data: " + str})
 	return maybeFlushWriter(c, false, len(str)+8)
@@ -3,9 +3,13 @@ package helper
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting"
+
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 )
@@ -56,3 +60,32 @@ func TestStreamFlushPolicy_FinalFlushReleasesPendingData(t *testing.T) {
 	require.NoError(t, FlushPendingWriter(c))
 	require.Equal(t, streamImmediateEventCount+1, writer.flushes)
 }
+
+func TestResponseChunkDataEndsStreamOnSensitiveStop(t *testing.T) {
+	oldWords, oldEnabled, oldCompletion, oldStop := setting.SensitiveWords, setting.CheckSensitiveEnabled,
+		setting.CheckSensitiveOnCompletionEnabled, setting.StopOnSensitiveEnabled
+	t.Cleanup(func() {
+		setting.SensitiveWords, setting.CheckSensitiveEnabled = oldWords, oldEnabled
+		setting.CheckSensitiveOnCompletionEnabled, setting.StopOnSensitiveEnabled = oldCompletion, oldStop
+	})
+	setting.SensitiveWords = []string{"forbidden"}
+	setting.CheckSensitiveEnabled = true
+	setting.CheckSensitiveOnCompletionEnabled = true
+	setting.StopOnSensitiveEnabled = true
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	require.NoError(t, ResponseChunkData(c, dto.ResponsesStreamResponse{Type: "response.output_text.delta"},
+		`{"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"a forbidden b"}`))
+	require.NoError(t, ResponseChunkData(c, dto.ResponsesStreamResponse{Type: "response.completed"},
+		`{"type":"response.completed","response":{"object":"response","status":"completed"}}`))
+
+	body := recorder.Body.String()
+	require.Contains(t, body, `"delta":"a "`)
+	require.NotContains(t, body, "forbidden")
+	require.Contains(t, body, "event: response.incomplete")
+	require.NotContains(t, body, "event: response.completed")
+	require.Equal(t, 1, strings.Count(body, `"reason":"content_filter"`))
+	require.True(t, HasSemanticOutput(c))
+}
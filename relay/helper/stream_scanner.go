@@ -231,6 +231,10 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				ExtendWriteDeadline(c)
 				dataHandler(data, sr)
 			}()
+			// 输出命中敏感词被终止后不再消费上游
+			if !sr.IsStopped() && service.IsCompletionSensitiveStopped(c) {
+				sr.Stop(nil)
+			}
 			if sr.IsStopped() {
 				return
 			}
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,14 +13,28 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 )
 
+// imageVariationsAPITypes 支持 /v1/images/variations 的渠道，OpenAI 兼容渠道原样转发，
+// 其余渠道以输入图片加变体提示词实现
+var imageVariationsAPITypes = []int{
+	constant.APITypeOpenAI,
+	constant.APITypeOpenRouter,
+	constant.APITypeXinference,
+	constant.APITypeGemini,
+	constant.APITypeVertexAi,
+	constant.APITypeJimeng,
+	constant.APITypeKling,
+}
+
 func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types.NewAPIError) {
 	info.InitChannelMeta(c)
 
@@ -44,6 +59,10 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 	}
 	adaptor.Init(info)
 
+	if info.RelayMode == relayconstant.RelayModeImagesVariations && !lo.Contains(imageVariationsAPITypes, info.ApiType) {
+		return types.NewErrorWithStatusCode(errors.New("image variations are not supported by this channel"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+
 	var requestBody io.Reader
 
 	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
@@ -27,6 +27,27 @@ func TestChannelDeleteRoutesUseSensitiveWritePermission(t *testing.T) {
 	assertChannelRoutePermission(t, http.MethodPost, "/batch/tag", authz.ChannelWrite, controller.BatchSetChannelTag)
 }
 
+func TestOnlyTagAwareChannelRoutesAreScoped(t *testing.T) {
+	scoped := map[string]bool{}
+	for _, route := range channelPermissionRoutes {
+		if route.scoped {
+			scoped[route.method+" "+route.path] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		"GET /":              true,
+		"GET /search":        true,
+		"GET /:id":           true,
+		"GET /test/:id":      true,
+		"PUT /":              true,
+		"POST /:id/status":   true,
+		"POST /tag/disabled": true,
+		"POST /tag/enabled":  true,
+		"PUT /tag":           true,
+		"GET /tag/models":    true,
+	}, scoped)
+}
+
 func TestChannelStatusRoutesRegisterWithoutConflict(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	engine := gin.New()
@@ -79,6 +79,44 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// files/batches/fine_tuning/responses 查询不属于某个模型，不经过 Distribute 选择渠道
+		filesRouter := relayV1Router.Group("/files")
+		filesRouter.GET("", controller.ListFiles)
+		filesRouter.POST("", controller.UploadFile)
+		filesRouter.GET("/:id", controller.RetrieveFile)
+		filesRouter.DELETE("/:id", controller.DeleteFile)
+		filesRouter.GET("/:id/content", controller.RetrieveFileContent)
+
+		batchesRouter := relayV1Router.Group("/batches")
+		batchesRouter.POST("", controller.CreateBatch)
+		batchesRouter.GET("", controller.ListBatches)
+		batchesRouter.GET("/:id", controller.RetrieveBatch)
+		batchesRouter.POST("/:id/cancel", controller.CancelBatch)
+
+		fineTuningRouter := relayV1Router.Group("/fine_tuning/jobs")
+		fineTuningRouter.POST("", controller.CreateFineTuningJob)
+		fineTuningRouter.GET("", controller.ListFineTuningJobs)
+		fineTuningRouter.GET("/:id", controller.RetrieveFineTuningJob)
+		fineTuningRouter.POST("/:id/cancel", controller.CancelFineTuningJob)
+		fineTuningRouter.GET("/:id/events", controller.ListFineTuningJobEvents)
+		fineTuningRouter.GET("/:id/checkpoints", controller.ListFineTuningJobCheckpoints)
+
+		// 旧版 /v1/fine-tunes 接口转到同一套微调任务处理
+		legacyFineTuneRouter := relayV1Router.Group("/fine-tunes")
+		legacyFineTuneRouter.POST("", controller.CreateFineTuningJob)
+		legacyFineTuneRouter.GET("", controller.ListFineTuningJobs)
+		legacyFineTuneRouter.GET("/:id", controller.RetrieveFineTuningJob)
+		legacyFineTuneRouter.POST("/:id/cancel", controller.CancelFineTuningJob)
+		legacyFineTuneRouter.GET("/:id/events", controller.ListFineTuningJobEvents)
+
+		// response 检索等请求转发到生成该 response 的渠道
+		responsesRouter := relayV1Router.Group("/responses")
+		responsesRouter.GET("/:id", controller.RetrieveResponse)
+		responsesRouter.DELETE("/:id", controller.DeleteResponse)
+		responsesRouter.POST("/:id/cancel", controller.CancelResponse)
+		responsesRouter.GET("/:id/input_items", controller.ListResponseInputItems)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
@@ -115,6 +153,9 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/images/edits", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIImage)
 		})
+		httpRouter.POST("/images/variations", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAIImage)
+		})
 
 		// embedding related routes
 		httpRouter.POST("/embeddings", func(c *gin.Context) {
@@ -151,17 +192,6 @@ func SetRelayRouter(router *gin.Engine) {
 		})
 
 		// not implemented
-		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
-		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
-		httpRouter.POST("/fine-tunes/:id/cancel", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes/:id/events", controller.RelayNotImplemented)
 		httpRouter.DELETE("/models/:model", controller.RelayNotImplemented)
 	}
 
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAI TTS 返回 pcm 时的采样参数：24kHz、16bit、单声道
+const (
+	SpeechPCMSampleRate    = 24000
+	SpeechPCMChannels      = 1
+	SpeechPCMBitsPerSample = 16
+)
+
+// AudioDurationToTokens 按每分钟 1000 token 换算音频时长，与 $price / minute 对齐。
+// 时长来自音频元数据，可被伪造，负值钳到 0，饱和转换防止 int 回绕
+func AudioDurationToTokens(duration float64) int {
+	if duration <= 0 || math.IsNaN(duration) {
+		return 0
+	}
+	return common.QuotaRound(math.Ceil(duration) / 60.0 * 1000)
+}
+
+// CountTranscriptionAudioTokens 按上传音频文件的时长计算转写请求的输入 token
+func CountTranscriptionAudioTokens(c *gin.Context) (int, error) {
+	multiForm, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing multipart form: %v", err)
+	}
+	totalAudioToken := 0
+	for _, fileHeader := range multiForm.File["file"] {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return 0, fmt.Errorf("error opening audio file: %v", err)
+		}
+		duration, err := common.GetAudioDuration(c.Request.Context(), file, filepath.Ext(fileHeader.Filename))
+		file.Close()
+		if err != nil {
+			return 0, fmt.Errorf("error getting audio duration: %v", err)
+		}
+		totalAudioToken += AudioDurationToTokens(duration)
+	}
+	return totalAudioToken, nil
+}
+
+// SpeechUsageFromDuration 以合成音频时长作为 TTS 的输出音频 token
+func SpeechUsageFromDuration(info *relaycommon.RelayInfo, duration float64) *dto.Usage {
+	usage := &dto.Usage{}
+	usage.PromptTokens = info.GetEstimatePromptTokens()
+	usage.PromptTokensDetails.TextTokens = usage.PromptTokens
+	completionTokens := AudioDurationToTokens(duration)
+	usage.CompletionTokens = completionTokens
+	usage.CompletionTokenDetails.AudioTokens = completionTokens
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// BuildSpeechUsage 解析合成音频的时长计算 TTS 用量，无法解析时按每 KB 1 token 兜底
+func BuildSpeechUsage(c *gin.Context, info *relaycommon.RelayInfo, audio []byte, format string) *dto.Usage {
+	var duration float64
+	var err error
+	if format == "pcm" {
+		duration = common.GetPCMDuration(len(audio), SpeechPCMSampleRate, SpeechPCMChannels, SpeechPCMBitsPerSample)
+	} else {
+		duration, err = common.GetAudioDuration(c.Request.Context(), bytes.NewReader(audio), "."+format)
+	}
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to get audio duration: %v", err))
+		usage := SpeechUsageFromDuration(info, 0)
+		estimatedTokens := int(math.Ceil(float64(len(audio)) / 1000.0))
+		usage.CompletionTokens = estimatedTokens
+		usage.CompletionTokenDetails.AudioTokens = estimatedTokens
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		return usage
+	}
+	return SpeechUsageFromDuration(info, duration)
+}
+
+// BuildTranscriptionUsage 输入音频按时长计为音频 token，转写文本计为输出文本 token
+func BuildTranscriptionUsage(c *gin.Context, info *relaycommon.RelayInfo, text string) *dto.Usage {
+	audioTokens, err := CountTranscriptionAudioTokens(c)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to count transcription audio tokens: %v", err))
+		audioTokens = info.GetEstimatePromptTokens()
+	}
+	usage := &dto.Usage{}
+	usage.PromptTokens = audioTokens
+	usage.PromptTokensDetails.AudioTokens = audioTokens
+	usage.CompletionTokens = CountTextToken(text, info.OriginModelName)
+	usage.CompletionTokenDetails.TextTokens = usage.CompletionTokens
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
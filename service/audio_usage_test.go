@@ -0,0 +1,36 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSpeechUsageFromAudioDuration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/speech", nil)
+	info := &relaycommon.RelayInfo{}
+
+	// 61 秒的 24kHz 单声道 PCM，向上取整按 61 秒计费
+	pcm := make([]byte, 61*SpeechPCMSampleRate*2)
+	usage := BuildSpeechUsage(c, info, common.PCMToWAV(pcm, SpeechPCMSampleRate, 1, 16), "wav")
+	require.Equal(t, AudioDurationToTokens(61), usage.CompletionTokens)
+	require.Equal(t, usage.CompletionTokens, usage.CompletionTokenDetails.AudioTokens)
+
+	usage = BuildSpeechUsage(c, info, pcm, "pcm")
+	require.Equal(t, AudioDurationToTokens(61), usage.CompletionTokenDetails.AudioTokens)
+
+	// 无法解析时按每 KB 1 token 兜底
+	usage = BuildSpeechUsage(c, info, make([]byte, 2500), "unknown")
+	require.Equal(t, 3, usage.CompletionTokenDetails.AudioTokens)
+
+	require.Equal(t, 0, AudioDurationToTokens(-5))
+	require.Equal(t, 1000, AudioDurationToTokens(59.2))
+}
@@ -1,20 +1,169 @@
 package authz
 
-import "github.com/QuantumNous/new-api/common"
+import (
+	"fmt"
+	"sort"
+	"strings"
 
-// resolveSubjectRoles returns the role keys assigned to a subject. The mapping
-// is derived from the caller's system role.
-var resolveSubjectRoles = func(userID int, systemRole int) []string {
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/casbin/casbin/v2"
+	"gorm.io/gorm"
+)
+
+// RoleAssignment binds a role to a user, optionally narrowed by a scope.
+type RoleAssignment struct {
+	Role  string    `json:"role"`
+	Scope RoleScope `json:"scope"`
+}
+
+// resolveSubjectAssignments returns the role assignments in effect for a
+// subject. Root always resolves to the root role. Admins resolve to their
+// assigned enabled roles, or to the built-in admin role when nothing is
+// assigned.
+var resolveSubjectAssignments = func(userID int, systemRole int) []RoleAssignment {
 	switch {
 	case systemRole >= common.RoleRootUser:
-		return []string{BuiltInRoleRoot}
+		return []RoleAssignment{{Role: BuiltInRoleRoot}}
 	case systemRole >= common.RoleAdminUser:
-		return []string{BuiltInRoleAdmin}
+		assignments := UserRoleAssignments(userID)
+		if len(assignments) == 0 {
+			return []RoleAssignment{{Role: BuiltInRoleAdmin}}
+		}
+		// Never fall back to the admin baseline once roles are assigned, even if
+		// every assigned role is disabled.
+		enabled := make([]RoleAssignment, 0, len(assignments))
+		for _, assignment := range assignments {
+			if spec, ok := roleSpec(assignment.Role); ok && spec.Enabled {
+				enabled = append(enabled, assignment)
+			}
+		}
+		return enabled
 	default:
 		return nil
 	}
 }
 
 // managedRoleKey is the role whose baseline per-user overrides are expressed
-// relative to.
+// relative to when the user has no explicit role assignment.
 const managedRoleKey = BuiltInRoleAdmin
+
+// UserRoleAssignments returns the roles explicitly assigned to a user, sorted by
+// role key. Users without assignments fall back to the role derived from their
+// system role.
+func UserRoleAssignments(userID int) []RoleAssignment {
+	e := currentEnforcer()
+	if e == nil {
+		return nil
+	}
+	rules, err := e.GetFilteredGroupingPolicy(0, UserSubject(userID))
+	if err != nil {
+		return nil
+	}
+	return assignmentsFromRules(rules)
+}
+
+func assignmentsFromRules(rules [][]string) []RoleAssignment {
+	scopes := make(map[string]*RoleScope)
+	unscoped := make(map[string]bool)
+	for _, rule := range rules {
+		if len(rule) < 2 || !strings.HasPrefix(rule[1], RoleSubject("")) {
+			continue
+		}
+		roleKey := strings.TrimPrefix(rule[1], RoleSubject(""))
+		scope, ok := scopes[roleKey]
+		if !ok {
+			scope = &RoleScope{}
+			scopes[roleKey] = scope
+		}
+		token := ""
+		if len(rule) >= 3 {
+			token = rule[2]
+		}
+		if !scope.addToken(token) {
+			unscoped[roleKey] = true
+		}
+	}
+
+	result := make([]RoleAssignment, 0, len(scopes))
+	for roleKey, scope := range scopes {
+		assignment := RoleAssignment{Role: roleKey}
+		if !unscoped[roleKey] {
+			assignment.Scope = scope.normalize()
+		}
+		result = append(result, assignment)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Role < result[j].Role
+	})
+	return result
+}
+
+// SetUserRoleAssignmentsInTx replaces the roles assigned to a user. An empty
+// list removes every assignment so the user falls back to the role derived from
+// the system role. The caller must ReloadPolicy after the transaction commits.
+func SetUserRoleAssignmentsInTx(tx *gorm.DB, userID int, assignments []RoleAssignment) error {
+	seen := make(map[string]bool, len(assignments))
+	rules := make([]model.CasbinRule, 0, len(assignments))
+	for _, assignment := range assignments {
+		spec, ok := roleSpec(assignment.Role)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrRoleNotFound, assignment.Role)
+		}
+		if spec.Superuser {
+			return fmt.Errorf("role %s cannot be assigned", assignment.Role)
+		}
+		if seen[assignment.Role] {
+			return fmt.Errorf("role %s is assigned more than once", assignment.Role)
+		}
+		seen[assignment.Role] = true
+		for _, token := range assignment.Scope.normalize().tokens() {
+			rules = append(rules, newRule("g", []string{UserSubject(userID), RoleSubject(assignment.Role), token}))
+		}
+	}
+
+	if err := clearUserRoleAssignmentsInTx(tx, userID); err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return tx.Create(&rules).Error
+}
+
+// SetUserRoleAssignments replaces the roles assigned to a user and refreshes the
+// local policy snapshot.
+func SetUserRoleAssignments(userID int, assignments []RoleAssignment) error {
+	db := currentDB()
+	if db == nil {
+		return fmt.Errorf("authz enforcer is not initialized")
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return SetUserRoleAssignmentsInTx(tx, userID, assignments)
+	}); err != nil {
+		return err
+	}
+	return ReloadPolicy()
+}
+
+func clearUserRoleAssignmentsInTx(tx *gorm.DB, userID int) error {
+	return tx.Where("ptype = ? AND v0 = ?", "g", UserSubject(userID)).Delete(&model.CasbinRule{}).Error
+}
+
+// baselineAllows reports whether the user's unscoped roles grant the
+// permission. Per-user overrides are stored relative to this baseline.
+func baselineAllows(e *casbin.SyncedEnforcer, userID int, permission Permission) bool {
+	assignments := UserRoleAssignments(userID)
+	if len(assignments) == 0 {
+		return roleBaselineAllows(e, managedRoleKey, permission)
+	}
+	for _, assignment := range assignments {
+		if !assignment.Scope.IsZero() {
+			continue
+		}
+		if spec, ok := roleSpec(assignment.Role); ok && spec.Enabled && roleBaselineAllows(e, assignment.Role, permission) {
+			return true
+		}
+	}
+	return false
+}
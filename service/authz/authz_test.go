@@ -97,15 +97,13 @@ func TestSetUserPermissionsStoresOnlyOverrides(t *testing.T) {
 
 	assert.True(t, Can(42, common.RoleAdminUser, ChannelSensitiveWrite))
 	assert.False(t, Can(42, common.RoleAdminUser, ChannelWrite))
-	assert.Equal(t, PermissionsMap{
-		ResourceChannel: {
-			ActionRead:           true,
-			ActionOperate:        true,
-			ActionWrite:          false,
-			ActionSensitiveWrite: true,
-			ActionSecretView:     false,
-		},
-	}, ExplicitUserPermissions(42))
+	assert.Equal(t, map[string]bool{
+		ActionRead:           true,
+		ActionOperate:        true,
+		ActionWrite:          false,
+		ActionSensitiveWrite: true,
+		ActionSecretView:     false,
+	}, ExplicitUserPermissions(42)[ResourceChannel])
 	assert.Equal(t, PermissionsMap{
 		ResourceChannel: {
 			ActionSensitiveWrite: true,
@@ -125,15 +123,13 @@ func TestSetUserPermissionsStoresOnlyOverrides(t *testing.T) {
 		ActionSecretView:     false,
 	}}))
 	assert.False(t, Can(42, common.RoleAdminUser, ChannelSensitiveWrite))
-	assert.Equal(t, PermissionsMap{
-		ResourceChannel: {
-			ActionRead:           true,
-			ActionOperate:        true,
-			ActionWrite:          true,
-			ActionSensitiveWrite: false,
-			ActionSecretView:     false,
-		},
-	}, ExplicitUserPermissions(42))
+	assert.Equal(t, map[string]bool{
+		ActionRead:           true,
+		ActionOperate:        true,
+		ActionWrite:          true,
+		ActionSensitiveWrite: false,
+		ActionSecretView:     false,
+	}, ExplicitUserPermissions(42)[ResourceChannel])
 	assert.Empty(t, ExplicitUserOverrides(42))
 }
 
@@ -227,3 +223,122 @@ func TestCapabilitiesUseCatalogShape(t *testing.T) {
 	assert.False(t, capabilities[ResourceChannel][ActionSensitiveWrite])
 	assert.False(t, capabilities[ResourceChannel][ActionSecretView])
 }
+
+func TestAdminBaselineKeepsRootOnlyAreasDelegable(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	for _, permission := range []Permission{UserRead, UserWrite, TopUpOperate, SubscriptionOperate, RedemptionWrite, LogRead, LogSensitiveView, ModelWrite} {
+		assert.True(t, Can(2, common.RoleAdminUser, permission), permission)
+	}
+	for _, permission := range []Permission{OptionRead, OptionWrite, PricingWrite, LogDelete, SystemTaskWrite} {
+		assert.False(t, Can(2, common.RoleAdminUser, permission), permission)
+		assert.True(t, Can(1, common.RoleRootUser, permission), permission)
+	}
+
+	// support staff: complete orders and read logs, but never edit users or pricing
+	require.NoError(t, SetUserPermissions(60, PermissionsMap{
+		ResourceUser:  {ActionWrite: false, ActionSensitiveWrite: false},
+		ResourceLog:   {ActionSensitiveView: false},
+		ResourceModel: {ActionWrite: false},
+	}))
+	assert.True(t, Can(60, common.RoleAdminUser, TopUpOperate))
+	assert.True(t, Can(60, common.RoleAdminUser, LogRead))
+	assert.False(t, Can(60, common.RoleAdminUser, LogSensitiveView))
+	assert.False(t, Can(60, common.RoleAdminUser, UserWrite))
+	assert.False(t, Can(60, common.RoleAdminUser, PricingWrite))
+}
+
+func TestCustomRoleWithScopedAssignment(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	require.NoError(t, CreateRole(RoleInput{
+		Key:  "operator",
+		Name: "Operator",
+		Grants: PermissionsMap{
+			ResourceChannel: {ActionRead: true, ActionOperate: true},
+			ResourceLog:     {ActionRead: true},
+		},
+	}))
+	assert.ErrorIs(t, CreateRole(RoleInput{Key: "operator", Name: "Again"}), ErrRoleExists)
+	assert.ErrorIs(t, CreateRole(RoleInput{Key: "Bad Key", Name: "Bad"}), ErrRoleKeyInvalid)
+	assert.ErrorIs(t, UpdateRole(BuiltInRoleAdmin, RoleInput{Name: "Admin"}), ErrBuiltInRole)
+
+	role, ok := Role("operator")
+	require.True(t, ok)
+	assert.True(t, role.Enabled)
+	assert.True(t, role.Grants[ResourceChannel][ActionOperate])
+	assert.False(t, role.Grants[ResourceChannel][ActionWrite])
+
+	require.NoError(t, SetUserRoleAssignments(70, []RoleAssignment{{
+		Role:  "operator",
+		Scope: RoleScope{ChannelTags: []string{"claude-pool", " claude-pool "}},
+	}}))
+	assert.Equal(t, []RoleAssignment{{
+		Role:  "operator",
+		Scope: RoleScope{ChannelTags: []string{"claude-pool"}},
+	}}, UserRoleAssignments(70))
+
+	// the assignment replaces the admin baseline and only reaches the tag
+	assert.False(t, Can(70, common.RoleAdminUser, ChannelOperate))
+	assert.False(t, Can(70, common.RoleAdminUser, ChannelWrite))
+	assert.False(t, Can(70, common.RoleAdminUser, UserRead))
+	scope := ScopeOf(70, common.RoleAdminUser, ChannelOperate)
+	assert.Equal(t, PermissionScope{Values: []string{"claude-pool"}}, scope)
+	assert.True(t, scope.Allows("claude-pool"))
+	assert.False(t, scope.Allows("openai-pool"))
+	assert.True(t, ScopeOf(70, common.RoleAdminUser, ChannelWrite).Empty())
+	// logs cannot be scoped, so a scoped assignment grants nothing there
+	assert.True(t, ScopeOf(70, common.RoleAdminUser, LogRead).Empty())
+
+	require.NoError(t, SetUserRoleAssignments(70, []RoleAssignment{{Role: "operator"}}))
+	assert.True(t, Can(70, common.RoleAdminUser, ChannelOperate))
+	assert.True(t, Can(70, common.RoleAdminUser, LogRead))
+	assert.True(t, ScopeOf(70, common.RoleAdminUser, ChannelRead).All)
+
+	disabled := false
+	require.NoError(t, UpdateRole("operator", RoleInput{Name: "Operator", Enabled: &disabled, Grants: role.Grants}))
+	assert.False(t, Can(70, common.RoleAdminUser, ChannelRead))
+	assert.False(t, Can(70, common.RoleAdminUser, UserRead))
+
+	require.NoError(t, DeleteRole("operator"))
+	assert.Empty(t, UserRoleAssignments(70))
+	assert.True(t, Can(70, common.RoleAdminUser, UserRead))
+	assert.ErrorIs(t, DeleteRole("operator"), ErrRoleNotFound)
+
+	var count int64
+	require.NoError(t, db.Model(&model.CasbinRule{}).Where("v0 = ? OR v1 = ?", RoleSubject("operator"), RoleSubject("operator")).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRoleAssignmentRejectsSuperuserAndUnknownRoles(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	assert.Error(t, SetUserRoleAssignments(71, []RoleAssignment{{Role: BuiltInRoleRoot}}))
+	assert.ErrorIs(t, SetUserRoleAssignments(71, []RoleAssignment{{Role: "missing"}}), ErrRoleNotFound)
+	assert.Empty(t, UserRoleAssignments(71))
+
+	require.NoError(t, SetUserRoleAssignments(71, []RoleAssignment{{
+		Role:  BuiltInRoleAdmin,
+		Scope: RoleScope{Groups: []string{"vip"}},
+	}}))
+	assert.False(t, Can(71, common.RoleAdminUser, UserWrite))
+	assert.Equal(t, []string{"vip"}, ScopeOf(71, common.RoleAdminUser, UserWrite).Values)
+
+	require.NoError(t, ClearUserAuthorization(71))
+	assert.Empty(t, UserRoleAssignments(71))
+	assert.True(t, Can(71, common.RoleAdminUser, UserWrite))
+}
+
+func TestCanGrantOnlyHeldPermissions(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	assert.True(t, CanGrant(2, common.RoleAdminUser, PermissionsMap{ResourceChannel: {ActionWrite: true, ActionSecretView: false}}))
+	assert.False(t, CanGrant(2, common.RoleAdminUser, PermissionsMap{ResourcePricing: {ActionWrite: true}}))
+	assert.True(t, CanGrant(1, common.RoleRootUser, PermissionsMap{ResourcePricing: {ActionWrite: true}}))
+	assert.False(t, RoleAssignable(2, common.RoleAdminUser, BuiltInRoleRoot))
+	assert.True(t, RoleAssignable(2, common.RoleAdminUser, BuiltInRoleAdmin))
+}
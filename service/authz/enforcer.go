@@ -14,6 +14,7 @@ import (
 var (
 	enforcerMu sync.RWMutex
 	enforcer   *casbin.SyncedEnforcer
+	authzDB    *gorm.DB
 )
 
 const modelText = `
@@ -23,6 +24,9 @@ r = sub, obj, act
 [policy_definition]
 p = sub, obj, act, eft
 
+[role_definition]
+g = _, _, _
+
 [policy_effect]
 e = some(where (p.eft == allow))
 
@@ -52,8 +56,13 @@ func Init(db *gorm.DB) error {
 
 	enforcerMu.Lock()
 	enforcer = e
+	authzDB = db
 	enforcerMu.Unlock()
 
+	if err := loadCustomRoles(db); err != nil {
+		return err
+	}
+
 	if !common.IsMasterNode {
 		return nil
 	}
@@ -72,9 +81,18 @@ func ReloadPolicy() error {
 	if enforcer == nil {
 		return fmt.Errorf("authz enforcer is not initialized")
 	}
+	if err := loadCustomRoles(authzDB); err != nil {
+		return err
+	}
 	return enforcer.LoadPolicy()
 }
 
+func currentDB() *gorm.DB {
+	enforcerMu.RLock()
+	defer enforcerMu.RUnlock()
+	return authzDB
+}
+
 // StartPolicySync periodically reloads the authorization policy from the database.
 // The enforcer keeps an in-memory snapshot, and permission changes are written
 // straight to the DB (see SetUserPermissionsInTx) with only the local node's
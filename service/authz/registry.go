@@ -9,10 +9,12 @@ type ActionDefinition struct {
 	DefaultRoles   []string `json:"-"`
 }
 
-// ResourceDefinition describes a resource and the actions it exposes.
+// ResourceDefinition describes a resource and the actions it exposes. Scope is
+// the scope kind role assignments may narrow the resource by, if any.
 type ResourceDefinition struct {
 	Resource string             `json:"resource"`
 	LabelKey string             `json:"label_key"`
+	Scope    string             `json:"scope,omitempty"`
 	Actions  []ActionDefinition `json:"actions"`
 }
 
@@ -30,6 +32,7 @@ func Catalog() []ResourceDefinition {
 		result = append(result, ResourceDefinition{
 			Resource: resource.Resource,
 			LabelKey: resource.LabelKey,
+			Scope:    resource.Scope,
 			Actions:  append([]ActionDefinition(nil), resource.Actions...),
 		})
 	}
@@ -84,6 +87,15 @@ func isKnownResource(resource string) bool {
 	return false
 }
 
+func resourceScopeKind(resource string) string {
+	for _, known := range registry {
+		if known.Resource == resource {
+			return known.Scope
+		}
+	}
+	return ""
+}
+
 func catalogActions(resource string) []ActionDefinition {
 	for _, known := range registry {
 		if known.Resource == resource {
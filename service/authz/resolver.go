@@ -2,16 +2,17 @@ package authz
 
 import "github.com/casbin/casbin/v2"
 
-// Can reports whether the subject may perform the permission. A superuser role
-// short-circuits to allow. Otherwise a per-user override wins, then the union of
-// the subject's role baselines applies.
+// Can reports whether the subject may perform the permission everywhere. A
+// superuser role short-circuits to allow. Otherwise a per-user override wins,
+// then the union of the subject's unscoped role baselines applies. Scoped role
+// assignments never satisfy Can; see ScopeOf.
 func Can(userID int, systemRole int, permission Permission) bool {
-	roles := resolveSubjectRoles(userID, systemRole)
-	if len(roles) == 0 {
+	assignments := resolveSubjectAssignments(userID, systemRole)
+	if len(assignments) == 0 {
 		return false
 	}
-	for _, role := range roles {
-		if isSuperuserRole(role) {
+	for _, assignment := range assignments {
+		if isSuperuserRole(assignment.Role) {
 			return true
 		}
 	}
@@ -26,8 +27,8 @@ func Can(userID int, systemRole int, permission Permission) bool {
 	if effect, ok := explicitSubjectEffect(e, UserSubject(userID), permission); ok {
 		return effect == EffectAllow
 	}
-	for _, role := range roles {
-		if roleBaselineAllows(e, role, permission) {
+	for _, assignment := range assignments {
+		if assignment.Scope.IsZero() && roleBaselineAllows(e, assignment.Role, permission) {
 			return true
 		}
 	}
@@ -8,6 +8,8 @@ const (
 	ActionWrite          = "write"
 	ActionSensitiveWrite = "sensitive_write"
 	ActionSecretView     = "secret_view"
+	ActionSensitiveView  = "sensitive_view"
+	ActionDelete         = "delete"
 )
 
 var (
@@ -22,6 +24,7 @@ func init() {
 	RegisterResource(ResourceDefinition{
 		Resource: ResourceChannel,
 		LabelKey: "Channel Management",
+		Scope:    ScopeKindChannelTag,
 		Actions: []ActionDefinition{
 			{
 				Action:         ActionRead,
@@ -0,0 +1,37 @@
+package authz
+
+const (
+	ResourceLog = "log"
+)
+
+var (
+	LogRead          = Permission{Resource: ResourceLog, Action: ActionRead}
+	LogSensitiveView = Permission{Resource: ResourceLog, Action: ActionSensitiveView}
+	LogDelete        = Permission{Resource: ResourceLog, Action: ActionDelete}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceLog,
+		LabelKey: "Logs & Usage",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read logs",
+				DescriptionKey: "View usage logs, statistics, and task records of all users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionSensitiveView,
+				LabelKey:       "View prompts and IPs",
+				DescriptionKey: "View client IPs, recent request bodies, and prompt excerpts from sensitive word and moderation hits.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionDelete,
+				LabelKey:       "Delete logs",
+				DescriptionKey: "Delete historical logs.",
+			},
+		},
+	})
+}
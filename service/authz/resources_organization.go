@@ -0,0 +1,38 @@
+package authz
+
+const (
+	ResourceOrganization = "organization"
+)
+
+var (
+	OrganizationRead    = Permission{Resource: ResourceOrganization, Action: ActionRead}
+	OrganizationWrite   = Permission{Resource: ResourceOrganization, Action: ActionWrite}
+	OrganizationOperate = Permission{Resource: ResourceOrganization, Action: ActionOperate}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceOrganization,
+		LabelKey: "Organizations",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read organizations",
+				DescriptionKey: "View all organizations, their members, tokens and usage.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit organizations",
+				DescriptionKey: "Create, edit, and delete organizations and manage their members.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionOperate,
+				LabelKey:       "Adjust organization quota",
+				DescriptionKey: "Add or deduct quota in organization wallets.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
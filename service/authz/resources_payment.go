@@ -0,0 +1,82 @@
+package authz
+
+const (
+	ResourceRedemption   = "redemption"
+	ResourceTopUp        = "topup"
+	ResourceSubscription = "subscription"
+)
+
+var (
+	RedemptionRead  = Permission{Resource: ResourceRedemption, Action: ActionRead}
+	RedemptionWrite = Permission{Resource: ResourceRedemption, Action: ActionWrite}
+
+	TopUpRead    = Permission{Resource: ResourceTopUp, Action: ActionRead}
+	TopUpOperate = Permission{Resource: ResourceTopUp, Action: ActionOperate}
+
+	SubscriptionRead    = Permission{Resource: ResourceSubscription, Action: ActionRead}
+	SubscriptionOperate = Permission{Resource: ResourceSubscription, Action: ActionOperate}
+	SubscriptionWrite   = Permission{Resource: ResourceSubscription, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceRedemption,
+		LabelKey: "Redemption Codes",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read redemption codes",
+				DescriptionKey: "View and search redemption codes.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit redemption codes",
+				DescriptionKey: "Create, edit, and delete redemption codes.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceTopUp,
+		LabelKey: "Top-ups & Orders",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read top-up orders",
+				DescriptionKey: "View top-up orders of all users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionOperate,
+				LabelKey:       "Operate top-up orders",
+				DescriptionKey: "Manually complete orders and reconcile payments with the payment provider.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceSubscription,
+		LabelKey: "Subscriptions",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read subscriptions",
+				DescriptionKey: "View subscription plans and the subscriptions of users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionOperate,
+				LabelKey:       "Operate user subscriptions",
+				DescriptionKey: "Bind, reset, invalidate, and delete the subscriptions of users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit subscription plans",
+				DescriptionKey: "Create and edit subscription plans, including their prices.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
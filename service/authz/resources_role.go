@@ -0,0 +1,40 @@
+package authz
+
+const (
+	ResourceRole = "role"
+
+	ActionAssign = "assign"
+)
+
+var (
+	RoleRead   = Permission{Resource: ResourceRole, Action: ActionRead}
+	RoleWrite  = Permission{Resource: ResourceRole, Action: ActionWrite}
+	RoleAssign = Permission{Resource: ResourceRole, Action: ActionAssign}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceRole,
+		LabelKey: "Authorization Roles",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read roles",
+				DescriptionKey: "View authorization roles and the roles assigned to users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit roles",
+				DescriptionKey: "Create, edit, and delete custom roles. Only permissions you hold yourself can be granted.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionAssign,
+				LabelKey:       "Assign roles",
+				DescriptionKey: "Assign roles to administrators, optionally limited to user groups or channel tags.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
@@ -0,0 +1,85 @@
+package authz
+
+const (
+	ResourceOption     = "option"
+	ResourcePricing    = "pricing"
+	ResourceModel      = "model"
+	ResourceSystemTask = "system_task"
+)
+
+var (
+	OptionRead  = Permission{Resource: ResourceOption, Action: ActionRead}
+	OptionWrite = Permission{Resource: ResourceOption, Action: ActionWrite}
+
+	PricingWrite = Permission{Resource: ResourcePricing, Action: ActionWrite}
+
+	ModelRead  = Permission{Resource: ResourceModel, Action: ActionRead}
+	ModelWrite = Permission{Resource: ResourceModel, Action: ActionWrite}
+
+	SystemTaskRead  = Permission{Resource: ResourceSystemTask, Action: ActionRead}
+	SystemTaskWrite = Permission{Resource: ResourceSystemTask, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceOption,
+		LabelKey: "System Settings",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read settings",
+				DescriptionKey: "View system settings without secrets.",
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit settings",
+				DescriptionKey: "Edit system settings other than pricing. Secrets and payment compliance remain root-only.",
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourcePricing,
+		LabelKey: "Pricing",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit pricing",
+				DescriptionKey: "Edit model ratios, prices, and group ratios, and sync ratios from upstream.",
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceModel,
+		LabelKey: "Models",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read models",
+				DescriptionKey: "View model and vendor metadata.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit models",
+				DescriptionKey: "Create, edit, and delete model and vendor metadata, and sync it from upstream.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceSystemTask,
+		LabelKey: "System Tasks",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read system tasks",
+				DescriptionKey: "View background system tasks and their progress.",
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Run system tasks",
+				DescriptionKey: "Start background system tasks such as log cleanup.",
+			},
+		},
+	})
+}
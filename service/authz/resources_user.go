@@ -0,0 +1,39 @@
+package authz
+
+const (
+	ResourceUser = "user"
+)
+
+var (
+	UserRead           = Permission{Resource: ResourceUser, Action: ActionRead}
+	UserWrite          = Permission{Resource: ResourceUser, Action: ActionWrite}
+	UserSensitiveWrite = Permission{Resource: ResourceUser, Action: ActionSensitiveWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceUser,
+		LabelKey: "User Management",
+		Scope:    ScopeKindUserGroup,
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read users",
+				DescriptionKey: "View user lists, details, bindings, and device fingerprints.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit users",
+				DescriptionKey: "Create, edit, enable/disable, and delete users, and adjust their quota.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionSensitiveWrite,
+				LabelKey:       "Reset user security settings",
+				DescriptionKey: "Disable two-factor authentication, reset passkeys, and remove login bindings.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
@@ -1,5 +1,12 @@
 package authz
 
+import (
+	"sync"
+
+	"github.com/QuantumNous/new-api/model"
+	"gorm.io/gorm"
+)
+
 const (
 	BuiltInRoleRoot  = "root"
 	BuiltInRoleAdmin = "admin"
@@ -13,6 +20,7 @@ type RoleSpec struct {
 	Description string
 	BuiltIn     bool
 	Superuser   bool
+	Enabled     bool
 	Sort        int
 }
 
@@ -23,6 +31,7 @@ var builtInRoles = []RoleSpec{
 		Description: "Built-in root authorization role",
 		BuiltIn:     true,
 		Superuser:   true,
+		Enabled:     true,
 		Sort:        0,
 	},
 	{
@@ -31,52 +40,101 @@ var builtInRoles = []RoleSpec{
 		Description: "Built-in admin authorization role",
 		BuiltIn:     true,
 		Superuser:   false,
+		Enabled:     true,
 		Sort:        10,
 	},
 }
 
+var (
+	customRolesMu sync.RWMutex
+	customRoles   []RoleSpec
+)
+
 // RoleDescriptor exposes a role together with its baseline grant matrix.
 type RoleDescriptor struct {
-	Key       string         `json:"key"`
-	Name      string         `json:"name"`
-	BuiltIn   bool           `json:"built_in"`
-	Superuser bool           `json:"superuser"`
-	Grants    PermissionsMap `json:"grants"`
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	BuiltIn     bool           `json:"built_in"`
+	Superuser   bool           `json:"superuser"`
+	Enabled     bool           `json:"enabled"`
+	Grants      PermissionsMap `json:"grants"`
 }
 
-// Roles returns the role descriptors with their baseline grants.
+// Roles returns the role descriptors with their baseline grants, built-in
+// roles first.
 func Roles() []RoleDescriptor {
-	result := make([]RoleDescriptor, 0, len(builtInRoles))
-	for _, spec := range builtInRoles {
+	specs := allRoleSpecs()
+	result := make([]RoleDescriptor, 0, len(specs))
+	for _, spec := range specs {
 		result = append(result, RoleDescriptor{
-			Key:       spec.Key,
-			Name:      spec.Name,
-			BuiltIn:   spec.BuiltIn,
-			Superuser: spec.Superuser,
-			Grants:    roleGrants(spec),
+			Key:         spec.Key,
+			Name:        spec.Name,
+			Description: spec.Description,
+			BuiltIn:     spec.BuiltIn,
+			Superuser:   spec.Superuser,
+			Enabled:     spec.Enabled,
+			Grants:      roleGrants(spec),
 		})
 	}
 	return result
 }
 
+// Role returns the descriptor of a single role.
+func Role(roleKey string) (RoleDescriptor, bool) {
+	for _, descriptor := range Roles() {
+		if descriptor.Key == roleKey {
+			return descriptor, true
+		}
+	}
+	return RoleDescriptor{}, false
+}
+
 func roleGrants(spec RoleSpec) PermissionsMap {
+	e := currentEnforcer()
 	grants := make(PermissionsMap, len(registry))
 	for _, resource := range registry {
 		actions := make(map[string]bool, len(resource.Actions))
 		for _, action := range resource.Actions {
-			actions[action.Action] = spec.Superuser || actionHasRole(action, spec.Key)
+			switch {
+			case spec.Superuser:
+				actions[action.Action] = true
+			case spec.BuiltIn:
+				actions[action.Action] = actionHasRole(action, spec.Key)
+			default:
+				// custom role grants only live in the policy table
+				actions[action.Action] = e != nil && roleBaselineAllows(e, spec.Key, Permission{
+					Resource: resource.Resource,
+					Action:   action.Action,
+				})
+			}
 		}
 		grants[resource.Resource] = actions
 	}
 	return grants
 }
 
+func allRoleSpecs() []RoleSpec {
+	customRolesMu.RLock()
+	defer customRolesMu.RUnlock()
+	specs := make([]RoleSpec, 0, len(builtInRoles)+len(customRoles))
+	specs = append(specs, builtInRoles...)
+	return append(specs, customRoles...)
+}
+
 func roleSpec(roleKey string) (RoleSpec, bool) {
 	for _, spec := range builtInRoles {
 		if spec.Key == roleKey {
 			return spec, true
 		}
 	}
+	customRolesMu.RLock()
+	defer customRolesMu.RUnlock()
+	for _, spec := range customRoles {
+		if spec.Key == roleKey {
+			return spec, true
+		}
+	}
 	return RoleSpec{}, false
 }
 
@@ -84,3 +142,41 @@ func isSuperuserRole(roleKey string) bool {
 	spec, ok := roleSpec(roleKey)
 	return ok && spec.Superuser
 }
+
+func isBuiltInRole(roleKey string) bool {
+	for _, spec := range builtInRoles {
+		if spec.Key == roleKey {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCustomRoles refreshes the in-memory copy of the custom roles. Built-in
+// roles are defined in code and never read back from the table.
+func loadCustomRoles(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	var roles []model.AuthzRole
+	if err := db.Where("built_in = ?", false).Order("sort asc, id asc").Find(&roles).Error; err != nil {
+		return err
+	}
+	specs := make([]RoleSpec, 0, len(roles))
+	for _, role := range roles {
+		if isBuiltInRole(role.Key) {
+			continue
+		}
+		specs = append(specs, RoleSpec{
+			Key:         role.Key,
+			Name:        role.Name,
+			Description: role.Description,
+			Enabled:     role.Enabled,
+			Sort:        role.Sort,
+		})
+	}
+	customRolesMu.Lock()
+	customRoles = specs
+	customRolesMu.Unlock()
+	return nil
+}
@@ -0,0 +1,531 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 渠道熔断器：closed（正常）→ open（冷却中，跳过）→ half-open（放行少量探测请求）→ closed。
+// 渠道整体与多 key 渠道的单个 key 各自独立熔断；状态在启用 Redis 时跨节点共享。
+// 熔断只影响选路，不修改渠道数据库状态，也不发送渠道禁用通知。
+
+const (
+	channelBreakerClosed   = 0
+	channelBreakerOpen     = 1
+	channelBreakerHalfOpen = 2
+
+	// channelBreakerChannelLevel 表示渠道整体的熔断器（非单个 key）
+	channelBreakerChannelLevel = -1
+	// channelBreakerProbeTimeout 探测请求迟迟未上报结果（如节点重启）时，超时后释放探测名额
+	channelBreakerProbeTimeout = 5 * time.Minute
+	// channelBreakerStateTTL 熔断状态在 Redis 中的保留时间，每次写入刷新
+	channelBreakerStateTTL = 24 * time.Hour
+)
+
+// ChannelBreakerResult 一次上游尝试对熔断器的影响
+type ChannelBreakerResult int
+
+const (
+	ChannelBreakerSuccess ChannelBreakerResult = iota
+	ChannelBreakerFailure
+	// ChannelBreakerNeutral 不计入成功或失败（如客户端参数错误），仅释放探测名额
+	ChannelBreakerNeutral
+)
+
+type channelBreakerConfig struct {
+	consecutiveFailures int
+	errorRateThreshold  float64
+	minRequests         int
+	windowMs            int64
+	cooldownMs          int64
+	maxCooldownMs       int64
+	halfOpenProbes      int
+}
+
+func getChannelBreakerConfig() channelBreakerConfig {
+	setting := operation_setting.GetChannelBreakerSetting()
+	config := channelBreakerConfig{
+		consecutiveFailures: setting.ConsecutiveFailures,
+		errorRateThreshold:  setting.ErrorRateThreshold,
+		minRequests:         setting.MinRequests,
+		windowMs:            int64(setting.WindowSeconds) * 1000,
+		cooldownMs:          int64(setting.CooldownSeconds) * 1000,
+		maxCooldownMs:       int64(setting.MaxCooldownSeconds) * 1000,
+		halfOpenProbes:      setting.HalfOpenProbes,
+	}
+	if config.minRequests <= 0 {
+		config.minRequests = 1
+	}
+	if config.windowMs <= 0 {
+		config.windowMs = 60 * 1000
+	}
+	if config.cooldownMs <= 0 {
+		config.cooldownMs = 30 * 1000
+	}
+	if config.maxCooldownMs < config.cooldownMs {
+		config.maxCooldownMs = config.cooldownMs
+	}
+	if config.halfOpenProbes <= 0 {
+		config.halfOpenProbes = 1
+	}
+	return config
+}
+
+// channelBreakerState 单个熔断器的状态，时间均为毫秒时间戳
+type channelBreakerState struct {
+	state          int
+	failures       int // 连续失败次数
+	windowStart    int64
+	windowTotal    int
+	windowFailures int
+	openUntil      int64
+	trips          int // 连续熔断次数，用于冷却时间翻倍
+	probes         int // 进行中的探测请求数
+	probeSuccesses int
+	probeDeadline  int64
+}
+
+func (s *channelBreakerState) allowed(now int64, config channelBreakerConfig) bool {
+	switch s.state {
+	case channelBreakerOpen:
+		return now >= s.openUntil
+	case channelBreakerHalfOpen:
+		return s.probes < config.halfOpenProbes || now >= s.probeDeadline
+	}
+	return true
+}
+
+func (s *channelBreakerState) acquire(now int64, config channelBreakerConfig) bool {
+	switch s.state {
+	case channelBreakerClosed:
+		return true
+	case channelBreakerOpen:
+		if now < s.openUntil {
+			return false
+		}
+		s.state = channelBreakerHalfOpen
+		s.probes = 0
+		s.probeSuccesses = 0
+	}
+	if s.probes >= config.halfOpenProbes {
+		if now < s.probeDeadline {
+			return false
+		}
+		s.probes = 0
+	}
+	s.probes++
+	s.probeDeadline = now + channelBreakerProbeTimeout.Milliseconds()
+	return true
+}
+
+// record 记录一次尝试结果，返回本次是否触发熔断
+func (s *channelBreakerState) record(now int64, config channelBreakerConfig, result ChannelBreakerResult) bool {
+	switch s.state {
+	case channelBreakerOpen:
+		// 熔断前已发出的请求，结果不再影响状态
+		return false
+	case channelBreakerHalfOpen:
+		if s.probes > 0 {
+			s.probes--
+		}
+		switch result {
+		case ChannelBreakerSuccess:
+			s.probeSuccesses++
+			if s.probeSuccesses >= config.halfOpenProbes {
+				*s = channelBreakerState{}
+			}
+		case ChannelBreakerFailure:
+			s.trip(now, config)
+			return true
+		}
+		return false
+	}
+
+	if result == ChannelBreakerNeutral {
+		return false
+	}
+	// 健康渠道（无失败记录）的成功请求不保存状态，失败率窗口从首次失败开始统计
+	if result == ChannelBreakerSuccess && s.failures == 0 && s.windowFailures == 0 {
+		return false
+	}
+	if s.windowStart == 0 || now-s.windowStart >= config.windowMs {
+		s.windowStart = now
+		s.windowTotal = 0
+		s.windowFailures = 0
+	}
+	s.windowTotal++
+	if result == ChannelBreakerSuccess {
+		s.failures = 0
+		return false
+	}
+	s.failures++
+	s.windowFailures++
+	if (config.consecutiveFailures > 0 && s.failures >= config.consecutiveFailures) ||
+		(config.errorRateThreshold > 0 && s.windowTotal >= config.minRequests &&
+			float64(s.windowFailures)/float64(s.windowTotal) >= config.errorRateThreshold) {
+		s.trip(now, config)
+		return true
+	}
+	return false
+}
+
+func (s *channelBreakerState) trip(now int64, config channelBreakerConfig) {
+	trips := s.trips + 1
+	cooldown := float64(config.cooldownMs) * math.Pow(2, float64(trips-1))
+	*s = channelBreakerState{
+		state:     channelBreakerOpen,
+		openUntil: now + int64(math.Min(cooldown, float64(config.maxCooldownMs))),
+		trips:     trips,
+	}
+}
+
+type channelBreakerKey struct {
+	channelID int
+	keyIndex  int
+}
+
+func (k channelBreakerKey) redisKey() string {
+	if k.keyIndex == channelBreakerChannelLevel {
+		return fmt.Sprintf("new-api:channel-breaker:v1:%d", k.channelID)
+	}
+	return fmt.Sprintf("new-api:channel-breaker:v1:%d:key:%d", k.channelID, k.keyIndex)
+}
+
+type channelBreaker struct {
+	mu    sync.Mutex
+	local map[channelBreakerKey]*channelBreakerState
+}
+
+var globalChannelBreaker = &channelBreaker{local: make(map[channelBreakerKey]*channelBreakerState)}
+
+func (b *channelBreaker) filter(ctx context.Context, keys []channelBreakerKey) ([]bool, error) {
+	config := getChannelBreakerConfig()
+	now := time.Now().UnixMilli()
+	if common.RedisEnabled {
+		return b.redisFilter(ctx, keys, now, config)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	allowed := make([]bool, len(keys))
+	for i, key := range keys {
+		state := b.local[key]
+		allowed[i] = state == nil || state.allowed(now, config)
+	}
+	return allowed, nil
+}
+
+func (b *channelBreaker) acquire(ctx context.Context, key channelBreakerKey) (bool, error) {
+	config := getChannelBreakerConfig()
+	now := time.Now().UnixMilli()
+	if common.RedisEnabled {
+		result, err := b.redisUpdate(ctx, key, "acquire", now, config)
+		return result == 1, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.local[key]
+	if state == nil {
+		return true, nil
+	}
+	return state.acquire(now, config), nil
+}
+
+func (b *channelBreaker) record(ctx context.Context, key channelBreakerKey, result ChannelBreakerResult) (bool, error) {
+	config := getChannelBreakerConfig()
+	now := time.Now().UnixMilli()
+	if common.RedisEnabled {
+		op := "success"
+		switch result {
+		case ChannelBreakerFailure:
+			op = "failure"
+		case ChannelBreakerNeutral:
+			op = "neutral"
+		}
+		ret, err := b.redisUpdate(ctx, key, op, now, config)
+		return ret == 2, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.local[key]
+	if state == nil {
+		if result != ChannelBreakerFailure {
+			return false, nil
+		}
+		state = &channelBreakerState{}
+		b.local[key] = state
+	}
+	return state.record(now, config, result), nil
+}
+
+func channelBreakerEnabled() bool {
+	return operation_setting.GetChannelBreakerSetting().Enabled
+}
+
+func channelKeyBreakerEnabled() bool {
+	setting := operation_setting.GetChannelBreakerSetting()
+	return setting.Enabled && setting.KeyLevelEnabled
+}
+
+// FilterChannelsByBreaker 过滤掉处于熔断冷却中的渠道（只读，不占用探测名额）。
+// 由 main 注入 model.ChannelBreakerFilterFunc，在选路时调用；熔断状态不可读时不做过滤。
+func FilterChannelsByBreaker(channelIDs []int) []int {
+	if !channelBreakerEnabled() || len(channelIDs) == 0 {
+		return channelIDs
+	}
+	keys := make([]channelBreakerKey, len(channelIDs))
+	for i, channelID := range channelIDs {
+		keys[i] = channelBreakerKey{channelID: channelID, keyIndex: channelBreakerChannelLevel}
+	}
+	return filterByBreaker(channelIDs, keys)
+}
+
+// FilterChannelKeysByBreaker 过滤掉多 key 渠道中处于熔断冷却中的 key
+func FilterChannelKeysByBreaker(channelID int, keyIndexes []int) []int {
+	if !channelKeyBreakerEnabled() || len(keyIndexes) == 0 {
+		return keyIndexes
+	}
+	keys := make([]channelBreakerKey, len(keyIndexes))
+	for i, keyIndex := range keyIndexes {
+		keys[i] = channelBreakerKey{channelID: channelID, keyIndex: keyIndex}
+	}
+	return filterByBreaker(keyIndexes, keys)
+}
+
+func filterByBreaker(values []int, keys []channelBreakerKey) []int {
+	allowed, err := globalChannelBreaker.filter(context.Background(), keys)
+	if err != nil {
+		common.SysError("failed to read channel breaker state: " + err.Error())
+		return values
+	}
+	filtered := make([]int, 0, len(values))
+	for i, value := range values {
+		if allowed[i] {
+			filtered = append(filtered, value)
+		}
+	}
+	return filtered
+}
+
+// TryAcquireChannelBreaker 在请求发往上游前检查熔断器，半开状态下会占用一个探测名额。
+// 返回 false 表示渠道或 key 熔断中，属于本地选路跳过，调用方应换一个渠道。
+func TryAcquireChannelBreaker(ctx context.Context, channelID int, keyIndex int, isMultiKey bool) (bool, error) {
+	if channelID <= 0 || !channelBreakerEnabled() {
+		return true, nil
+	}
+	channelKey := channelBreakerKey{channelID: channelID, keyIndex: channelBreakerChannelLevel}
+	acquired, err := globalChannelBreaker.acquire(ctx, channelKey)
+	if err != nil || !acquired {
+		return acquired, err
+	}
+	if !isMultiKey || !channelKeyBreakerEnabled() {
+		return true, nil
+	}
+	acquired, err = globalChannelBreaker.acquire(ctx, channelBreakerKey{channelID: channelID, keyIndex: keyIndex})
+	if err != nil || !acquired {
+		// 归还渠道级的探测名额
+		if _, releaseErr := globalChannelBreaker.record(ctx, channelKey, ChannelBreakerNeutral); releaseErr != nil {
+			common.SysError(fmt.Sprintf("failed to release channel #%d breaker probe: %s", channelID, releaseErr.Error()))
+		}
+	}
+	return acquired, err
+}
+
+// RecordChannelBreakerResult 记录一次上游尝试的结果，必须与成功的 TryAcquireChannelBreaker 成对调用
+func RecordChannelBreakerResult(ctx context.Context, channelID int, keyIndex int, isMultiKey bool, result ChannelBreakerResult) {
+	if channelID <= 0 || !channelBreakerEnabled() {
+		return
+	}
+	tripped, err := globalChannelBreaker.record(ctx, channelBreakerKey{channelID: channelID, keyIndex: channelBreakerChannelLevel}, result)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to record channel #%d breaker result: %s", channelID, err.Error()))
+	} else if tripped {
+		common.SysLog(fmt.Sprintf("channel #%d circuit breaker opened", channelID))
+	}
+	if !isMultiKey || !channelKeyBreakerEnabled() {
+		return
+	}
+	tripped, err = globalChannelBreaker.record(ctx, channelBreakerKey{channelID: channelID, keyIndex: keyIndex}, result)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to record channel #%d key #%d breaker result: %s", channelID, keyIndex, err.Error()))
+	} else if tripped {
+		common.SysLog(fmt.Sprintf("channel #%d key #%d circuit breaker opened", channelID, keyIndex))
+	}
+}
+
+func channelBreakerKeyFromContext(c *gin.Context) (int, bool) {
+	isMultiKey := common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey)
+	if !isMultiKey {
+		return 0, false
+	}
+	return common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex), true
+}
+
+// TryAcquireChannelBreakerForContext 使用 Gin 上下文中当前选中的渠道 key 检查熔断器
+func TryAcquireChannelBreakerForContext(c *gin.Context, channelID int) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	keyIndex, isMultiKey := channelBreakerKeyFromContext(c)
+	return TryAcquireChannelBreaker(c.Request.Context(), channelID, keyIndex, isMultiKey)
+}
+
+func RecordChannelBreakerResultForContext(c *gin.Context, channelID int, result ChannelBreakerResult) {
+	if c == nil {
+		return
+	}
+	keyIndex, isMultiKey := channelBreakerKeyFromContext(c)
+	// 客户端断开后请求上下文已取消，结果仍需写入
+	RecordChannelBreakerResult(context.WithoutCancel(c.Request.Context()), channelID, keyIndex, isMultiKey, result)
+}
+
+// channelBreakerFilterLua 批量读取熔断状态，返回每个 key 是否允许选中（1/0），不修改状态
+const channelBreakerFilterLua = `
+local now = tonumber(ARGV[1])
+local probes_limit = tonumber(ARGV[2])
+local result = {}
+for i, key in ipairs(KEYS) do
+  local f = redis.call('HMGET', key, 'state', 'open_until', 'probes', 'probe_deadline')
+  local state = tonumber(f[1]) or 0
+  local allowed = 1
+  if state == 1 and now < (tonumber(f[2]) or 0) then
+    allowed = 0
+  elseif state == 2 and (tonumber(f[3]) or 0) >= probes_limit and now < (tonumber(f[4]) or 0) then
+    allowed = 0
+  end
+  result[i] = allowed
+end
+return result`
+
+// channelBreakerUpdateLua 与 channelBreakerState 的 acquire / record 逻辑一致。
+// acquire 返回 1 放行 / 0 拒绝；success / failure / neutral 返回 2 表示本次触发熔断，否则返回 1。
+const channelBreakerUpdateLua = `
+local op = ARGV[1]
+local now = tonumber(ARGV[2])
+local consecutive = tonumber(ARGV[3])
+local error_rate = tonumber(ARGV[4])
+local min_requests = tonumber(ARGV[5])
+local window_ms = tonumber(ARGV[6])
+local cooldown_ms = tonumber(ARGV[7])
+local max_cooldown_ms = tonumber(ARGV[8])
+local probes_limit = tonumber(ARGV[9])
+local probe_timeout_ms = tonumber(ARGV[10])
+local ttl = tonumber(ARGV[11])
+
+local f = redis.call('HMGET', KEYS[1], 'state', 'failures', 'window_start', 'window_total', 'window_failures',
+  'open_until', 'trips', 'probes', 'probe_successes', 'probe_deadline')
+local s = {
+  state = tonumber(f[1]) or 0, failures = tonumber(f[2]) or 0, window_start = tonumber(f[3]) or 0,
+  window_total = tonumber(f[4]) or 0, window_failures = tonumber(f[5]) or 0, open_until = tonumber(f[6]) or 0,
+  trips = tonumber(f[7]) or 0, probes = tonumber(f[8]) or 0, probe_successes = tonumber(f[9]) or 0,
+  probe_deadline = tonumber(f[10]) or 0,
+}
+
+local function reset()
+  s = { state = 0, failures = 0, window_start = 0, window_total = 0, window_failures = 0, open_until = 0,
+    trips = 0, probes = 0, probe_successes = 0, probe_deadline = 0 }
+end
+
+local function trip()
+  local trips = s.trips + 1
+  local cooldown = math.min(cooldown_ms * (2 ^ (trips - 1)), max_cooldown_ms)
+  reset()
+  s.state = 1
+  s.trips = trips
+  s.open_until = now + math.floor(cooldown)
+end
+
+local function save()
+  redis.call('HSET', KEYS[1], 'state', s.state, 'failures', s.failures, 'window_start', s.window_start,
+    'window_total', s.window_total, 'window_failures', s.window_failures, 'open_until', s.open_until,
+    'trips', s.trips, 'probes', s.probes, 'probe_successes', s.probe_successes, 'probe_deadline', s.probe_deadline)
+  redis.call('EXPIRE', KEYS[1], ttl)
+end
+
+if op == 'acquire' then
+  if s.state == 0 then return 1 end
+  if s.state == 1 then
+    if now < s.open_until then return 0 end
+    s.state = 2
+    s.probes = 0
+    s.probe_successes = 0
+  end
+  if s.probes >= probes_limit then
+    if now < s.probe_deadline then return 0 end
+    s.probes = 0
+  end
+  s.probes = s.probes + 1
+  s.probe_deadline = now + probe_timeout_ms
+  save()
+  return 1
+end
+
+if s.state == 1 then return 1 end
+if s.state == 2 then
+  if s.probes > 0 then s.probes = s.probes - 1 end
+  if op == 'success' then
+    s.probe_successes = s.probe_successes + 1
+    if s.probe_successes >= probes_limit then reset() end
+  elseif op == 'failure' then
+    trip()
+    save()
+    return 2
+  end
+  save()
+  return 1
+end
+
+if op == 'neutral' then return 1 end
+if op == 'success' and s.failures == 0 and s.window_failures == 0 then return 1 end
+if s.window_start == 0 or now - s.window_start >= window_ms then
+  s.window_start = now
+  s.window_total = 0
+  s.window_failures = 0
+end
+s.window_total = s.window_total + 1
+if op == 'success' then
+  s.failures = 0
+  save()
+  return 1
+end
+s.failures = s.failures + 1
+s.window_failures = s.window_failures + 1
+if (consecutive > 0 and s.failures >= consecutive) or
+  (error_rate > 0 and s.window_total >= min_requests and s.window_failures / s.window_total >= error_rate) then
+  trip()
+  save()
+  return 2
+end
+save()
+return 1`
+
+func (b *channelBreaker) redisFilter(ctx context.Context, keys []channelBreakerKey, now int64, config channelBreakerConfig) ([]bool, error) {
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = key.redisKey()
+	}
+	values, err := common.RDB.Eval(ctx, channelBreakerFilterLua, redisKeys, now, config.halfOpenProbes).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]bool, len(keys))
+	for i := range keys {
+		allowed[i] = i >= len(values) || values[i] == 1
+	}
+	return allowed, nil
+}
+
+func (b *channelBreaker) redisUpdate(ctx context.Context, key channelBreakerKey, op string, now int64, config channelBreakerConfig) (int, error) {
+	return common.RDB.Eval(ctx, channelBreakerUpdateLua, []string{key.redisKey()},
+		op, now, config.consecutiveFailures, config.errorRateThreshold, config.minRequests, config.windowMs,
+		config.cooldownMs, config.maxCooldownMs, config.halfOpenProbes, channelBreakerProbeTimeout.Milliseconds(),
+		int64(channelBreakerStateTTL/time.Second)).Int()
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testChannelBreakerConfig() channelBreakerConfig {
+	return channelBreakerConfig{
+		consecutiveFailures: 3,
+		errorRateThreshold:  0.5,
+		minRequests:         4,
+		windowMs:            60_000,
+		cooldownMs:          10_000,
+		maxCooldownMs:       30_000,
+		halfOpenProbes:      2,
+	}
+}
+
+func TestChannelBreakerTripsOnConsecutiveFailures(t *testing.T) {
+	config := testChannelBreakerConfig()
+	state := &channelBreakerState{}
+	now := int64(1_000_000)
+
+	assert.False(t, state.record(now, config, ChannelBreakerFailure))
+	assert.False(t, state.record(now, config, ChannelBreakerFailure))
+	assert.True(t, state.record(now, config, ChannelBreakerFailure))
+	assert.Equal(t, channelBreakerOpen, state.state)
+	assert.Equal(t, now+10_000, state.openUntil)
+
+	assert.False(t, state.allowed(now+5_000, config))
+	assert.False(t, state.acquire(now+5_000, config))
+	// 冷却期间回来的旧请求结果不影响状态
+	assert.False(t, state.record(now+5_000, config, ChannelBreakerSuccess))
+	assert.Equal(t, channelBreakerOpen, state.state)
+}
+
+func TestChannelBreakerTripsOnErrorRate(t *testing.T) {
+	config := testChannelBreakerConfig()
+	state := &channelBreakerState{}
+	now := int64(1_000_000)
+
+	state.record(now, config, ChannelBreakerFailure)
+	state.record(now, config, ChannelBreakerSuccess)
+	state.record(now, config, ChannelBreakerFailure)
+	assert.Equal(t, channelBreakerClosed, state.state)
+	// 第 4 次请求达到 MinRequests，失败率 3/4
+	assert.True(t, state.record(now, config, ChannelBreakerFailure))
+
+	// 窗口过期后重新统计
+	state = &channelBreakerState{}
+	state.record(now, config, ChannelBreakerFailure)
+	state.record(now, config, ChannelBreakerSuccess)
+	state.record(now+61_000, config, ChannelBreakerFailure)
+	state.record(now+61_000, config, ChannelBreakerSuccess)
+	assert.Equal(t, channelBreakerClosed, state.state)
+}
+
+func TestChannelBreakerHalfOpenProbes(t *testing.T) {
+	config := testChannelBreakerConfig()
+	state := &channelBreakerState{}
+	now := int64(1_000_000)
+	state.trip(now, config)
+
+	now += 10_000
+	assert.True(t, state.allowed(now, config))
+	assert.True(t, state.acquire(now, config))
+	assert.True(t, state.acquire(now, config))
+	assert.Equal(t, channelBreakerHalfOpen, state.state)
+	// 探测名额用尽
+	assert.False(t, state.allowed(now, config))
+	assert.False(t, state.acquire(now, config))
+
+	state.record(now, config, ChannelBreakerSuccess)
+	assert.Equal(t, channelBreakerHalfOpen, state.state)
+	state.record(now, config, ChannelBreakerSuccess)
+	assert.Equal(t, channelBreakerClosed, state.state)
+	assert.Equal(t, 0, state.trips)
+}
+
+func TestChannelBreakerHalfOpenFailureDoublesCooldown(t *testing.T) {
+	config := testChannelBreakerConfig()
+	state := &channelBreakerState{}
+	now := int64(1_000_000)
+	state.trip(now, config)
+
+	now += 10_000
+	require.True(t, state.acquire(now, config))
+	assert.True(t, state.record(now, config, ChannelBreakerFailure))
+	assert.Equal(t, now+20_000, state.openUntil)
+
+	now += 20_000
+	require.True(t, state.acquire(now, config))
+	state.record(now, config, ChannelBreakerFailure)
+	// 冷却时间不超过 MaxCooldownSeconds
+	assert.Equal(t, now+30_000, state.openUntil)
+}
+
+func TestChannelBreakerHalfOpenNeutralReleasesProbe(t *testing.T) {
+	config := testChannelBreakerConfig()
+	config.halfOpenProbes = 1
+	state := &channelBreakerState{}
+	now := int64(1_000_000)
+	state.trip(now, config)
+
+	now += 10_000
+	require.True(t, state.acquire(now, config))
+	require.False(t, state.acquire(now, config))
+	state.record(now, config, ChannelBreakerNeutral)
+	assert.Equal(t, channelBreakerHalfOpen, state.state)
+	assert.True(t, state.acquire(now, config))
+}
+
+func TestChannelBreakerFiltersChannelsAndKeys(t *testing.T) {
+	setting := operation_setting.GetChannelBreakerSetting()
+	oldSetting := *setting
+	setting.Enabled = true
+	setting.KeyLevelEnabled = true
+	setting.ConsecutiveFailures = 1
+	t.Cleanup(func() {
+		*setting = oldSetting
+		globalChannelBreaker = &channelBreaker{local: make(map[channelBreakerKey]*channelBreakerState)}
+	})
+	globalChannelBreaker = &channelBreaker{local: make(map[channelBreakerKey]*channelBreakerState)}
+	ctx := context.Background()
+
+	acquired, err := TryAcquireChannelBreaker(ctx, 1, 0, false)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	RecordChannelBreakerResult(ctx, 1, 0, false, ChannelBreakerFailure)
+	assert.Equal(t, []int{2}, FilterChannelsByBreaker([]int{1, 2}))
+
+	acquired, err = TryAcquireChannelBreaker(ctx, 1, 0, false)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	// 多 key 渠道：单个 key 失败只熔断该 key
+	setting.ConsecutiveFailures = 2
+	RecordChannelBreakerResult(ctx, 3, 1, true, ChannelBreakerSuccess)
+	globalChannelBreaker.record(ctx, channelBreakerKey{channelID: 3, keyIndex: 1}, ChannelBreakerFailure)
+	globalChannelBreaker.record(ctx, channelBreakerKey{channelID: 3, keyIndex: 1}, ChannelBreakerFailure)
+	assert.Equal(t, []int{3}, FilterChannelsByBreaker([]int{3}))
+	assert.Equal(t, []int{0, 2}, FilterChannelKeysByBreaker(3, []int{0, 1, 2}))
+
+	// 关闭后不再过滤
+	setting.Enabled = false
+	assert.Equal(t, []int{1, 2}, FilterChannelsByBreaker([]int{1, 2}))
+}
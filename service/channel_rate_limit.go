@@ -10,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	prommetrics "github.com/QuantumNous/new-api/pkg/prom_metrics"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 
 	"github.com/gin-gonic/gin"
@@ -79,10 +80,17 @@ func TryAcquireChannelRequest(ctx context.Context, channelID int, config Channel
 	if channelID <= 0 || !config.Enabled() {
 		return true, nil
 	}
+	var acquired bool
+	var err error
 	if common.RedisEnabled {
-		return globalChannelRateLimiter.redisAcquire(ctx, channelID, config)
+		acquired, err = globalChannelRateLimiter.redisAcquire(ctx, channelID, config)
+	} else {
+		acquired = globalChannelRateLimiter.localAcquire(channelID, config)
 	}
-	return globalChannelRateLimiter.localAcquire(channelID, config), nil
+	if err == nil && !acquired {
+		prommetrics.RecordChannelRateLimitSkip(channelID)
+	}
+	return acquired, err
 }
 
 // RecordChannelRequestSuccess records a successful upstream attempt. It must be
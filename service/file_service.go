@@ -101,6 +101,11 @@ func LoadFileSource(c *gin.Context, source types.FileSource, reason ...string) (
 			}
 		}
 		cachedData, err = loadFromBase64(s.Base64Data, s.MimeType)
+	case *types.FileIDSource:
+		if c == nil {
+			return nil, fmt.Errorf("file_id source requires request context")
+		}
+		cachedData, err = loadFromUserFile(c, s.FileID)
 	default:
 		return nil, fmt.Errorf("unsupported file source type: %T", source)
 	}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// FileStorage 是 Files API 的存储后端抽象。
+// key 由调用方生成且只包含安全字符，后端负责把它映射为实际位置。
+type FileStorage interface {
+	Name() string
+	// Save 将 reader 的内容写入 key，超过 maxBytes 时返回错误并清理已写入部分
+	Save(key string, reader io.Reader, maxBytes int64) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+var (
+	fileStoragesMu sync.RWMutex
+	fileStorages   = map[string]func() FileStorage{
+		operation_setting.FileStorageBackendLocal: func() FileStorage {
+			return &localFileStorage{basePath: operation_setting.GetFileSetting().StoragePath}
+		},
+	}
+)
+
+// RegisterFileStorage 注册额外的存储后端（例如对象存储），重复注册会覆盖
+func RegisterFileStorage(name string, factory func() FileStorage) {
+	if name == "" || factory == nil {
+		return
+	}
+	fileStoragesMu.Lock()
+	defer fileStoragesMu.Unlock()
+	fileStorages[name] = factory
+}
+
+// GetFileStorage 按名称获取存储后端，名称为空时使用当前配置的后端
+func GetFileStorage(name string) (FileStorage, error) {
+	if name == "" {
+		name = operation_setting.GetFileSetting().StorageBackend
+	}
+	if name == "" {
+		name = operation_setting.FileStorageBackendLocal
+	}
+	fileStoragesMu.RLock()
+	factory, ok := fileStorages[name]
+	fileStoragesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("file storage backend %q is not registered", name)
+	}
+	return factory(), nil
+}
+
+// localFileStorage 本地磁盘存储，目录规则与磁盘缓存一致
+type localFileStorage struct {
+	basePath string
+}
+
+func (s *localFileStorage) Name() string { return operation_setting.FileStorageBackendLocal }
+
+func (s *localFileStorage) Save(key string, reader io.Reader, maxBytes int64) (int64, error) {
+	if err := common.EnsureFileStoreDir(s.basePath); err != nil {
+		return 0, fmt.Errorf("failed to create file store directory: %w", err)
+	}
+	filePath, err := common.GetFileStorePath(s.basePath, key)
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	written, err := io.Copy(file, io.LimitReader(reader, maxBytes+1))
+	if err == nil && written > maxBytes {
+		err = fmt.Errorf("file size exceeds maximum allowed size: %d bytes", maxBytes)
+	}
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return 0, err
+	}
+	return written, nil
+}
+
+func (s *localFileStorage) Open(key string) (io.ReadCloser, error) {
+	filePath, err := common.GetFileStorePath(s.basePath, key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filePath)
+}
+
+func (s *localFileStorage) Delete(key string) error {
+	filePath, err := common.GetFileStorePath(s.basePath, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalFileStorageRoundTrip(t *testing.T) {
+	storage := &localFileStorage{basePath: t.TempDir()}
+
+	written, err := storage.Save("file-abc", strings.NewReader("hello"), 16)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), written)
+
+	reader, err := storage.Open("file-abc")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	require.NoError(t, storage.Delete("file-abc"))
+	// 重复删除不报错
+	require.NoError(t, storage.Delete("file-abc"))
+	_, err = storage.Open("file-abc")
+	assert.Error(t, err)
+}
+
+func TestLocalFileStorageRejectsOversizedFile(t *testing.T) {
+	storage := &localFileStorage{basePath: t.TempDir()}
+
+	_, err := storage.Save("file-big", strings.NewReader("0123456789"), 4)
+	require.Error(t, err)
+
+	// 超限时已写入的部分应被清理
+	_, err = storage.Open("file-big")
+	assert.Error(t, err)
+}
+
+func TestLocalFileStorageRejectsUnsafeKey(t *testing.T) {
+	storage := &localFileStorage{basePath: t.TempDir()}
+
+	for _, key := range []string{"", "../escape", "a/b", `a\b`, "file.txt"} {
+		_, err := storage.Save(key, strings.NewReader("x"), 16)
+		assert.Error(t, err, key)
+	}
+}
@@ -41,10 +41,18 @@ func ShouldCopyUpstreamHeader(c *gin.Context, k string, v []string) bool {
 	return true
 }
 
+// ProcessCompletionBody 还原非流式响应中的脱敏占位符并检查输出敏感词，
+// 自行构造响应体而不经过 IOCopyBytesGracefully 的渠道在写出前调用
+func ProcessCompletionBody(c *gin.Context, data []byte) []byte {
+	data = RestorePIIBody(c, data)
+	return ModerateCompletionBody(c, data)
+}
+
 func IOCopyBytesGracefully(c *gin.Context, src *http.Response, data []byte) {
 	if c.Writer == nil {
 		return
 	}
+	data = ProcessCompletionBody(c, data)
 
 	body := io.NopCloser(bytes.NewBuffer(data))
 
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/pkg/ldap"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+var (
+	ErrLDAPDisabled = errors.New("ldap login is disabled")
+	// 用户不存在与密码错误返回同一个错误，避免泄露目录中的账户信息
+	ErrLDAPInvalidCredentials = errors.New("invalid ldap credentials")
+	ErrLDAPAmbiguousUser      = errors.New("ldap user filter matched multiple entries")
+)
+
+// LDAPIdentity 是目录认证通过后的用户信息
+type LDAPIdentity struct {
+	DN          string
+	Username    string
+	Email       string
+	DisplayName string
+	Groups      []string
+	// 按 GroupMapping 映射后的本系统分组，未命中时为空
+	Group string
+}
+
+// ProviderUserId 用于 user_oauth_bindings 的外部账户标识，目录用户名不区分大小写
+func (i *LDAPIdentity) ProviderUserId() string {
+	return strings.ToLower(i.Username)
+}
+
+// AuthenticateLDAP 使用服务账号搜索用户条目，再以用户 DN 和密码绑定校验
+func AuthenticateLDAP(username string, password string) (*LDAPIdentity, error) {
+	settings := system_setting.GetLDAPSettings()
+	if !settings.Enabled {
+		return nil, ErrLDAPDisabled
+	}
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	conn, err := dialLDAP(settings)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if settings.BindDN != "" {
+		if err := conn.Bind(settings.BindDN, settings.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service account bind failed: %w", err)
+		}
+	}
+
+	entry, err := searchLDAPUser(conn, settings, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsResultCode(err, ldap.ResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, err
+	}
+	return buildLDAPIdentity(settings, entry, username), nil
+}
+
+func dialLDAP(settings *system_setting.LDAPSettings) (*ldap.Conn, error) {
+	timeout := time.Duration(settings.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	conn, err := ldap.DialURL(settings.Url, tlsConfig, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if settings.StartTLS && !conn.IsTLS() {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("ldap starttls failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func searchLDAPUser(conn *ldap.Conn, settings *system_setting.LDAPSettings, username string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(settings.UserFilter, "{username}", ldap.EscapeFilter(username))
+	var attributes []string
+	for _, attr := range []string{
+		settings.UsernameAttribute,
+		settings.EmailAttribute,
+		settings.DisplayNameAttribute,
+		settings.GroupAttribute,
+	} {
+		if attr != "" {
+			attributes = append(attributes, attr)
+		}
+	}
+	entries, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     settings.BaseDN,
+		Scope:      ldap.ScopeWholeSubtree,
+		SizeLimit:  2,
+		TimeLimit:  settings.Timeout,
+		Filter:     filter,
+		Attributes: attributes,
+	})
+	if err != nil {
+		if ldap.IsResultCode(err, ldap.ResultSizeLimitExceeded) {
+			return nil, ErrLDAPAmbiguousUser
+		}
+		return nil, err
+	}
+	switch len(entries) {
+	case 0:
+		return nil, ErrLDAPInvalidCredentials
+	case 1:
+		return entries[0], nil
+	default:
+		return nil, ErrLDAPAmbiguousUser
+	}
+}
+
+func buildLDAPIdentity(settings *system_setting.LDAPSettings, entry *ldap.Entry, loginName string) *LDAPIdentity {
+	identity := &LDAPIdentity{
+		DN:       entry.DN,
+		Username: loginName,
+	}
+	if settings.UsernameAttribute != "" {
+		if v := entry.GetAttributeValue(settings.UsernameAttribute); v != "" {
+			identity.Username = v
+		}
+	}
+	if settings.EmailAttribute != "" {
+		identity.Email = entry.GetAttributeValue(settings.EmailAttribute)
+	}
+	if settings.DisplayNameAttribute != "" {
+		identity.DisplayName = entry.GetAttributeValue(settings.DisplayNameAttribute)
+	}
+	if settings.GroupAttribute != "" {
+		identity.Groups = entry.GetAttributeValues(settings.GroupAttribute)
+	}
+	identity.Group = MapLDAPGroup(identity.Groups, settings.GroupMapping)
+	return identity
+}
+
+// MapLDAPGroup 按用户所属组的顺序返回第一个命中映射的分组，DN 比较不区分大小写
+func MapLDAPGroup(groups []string, mapping map[string]string) string {
+	if len(groups) == 0 || len(mapping) == 0 {
+		return ""
+	}
+	normalized := make(map[string]string, len(mapping))
+	for dn, group := range mapping {
+		normalized[normalizeLDAPDN(dn)] = strings.TrimSpace(group)
+	}
+	for _, dn := range groups {
+		if group := normalized[normalizeLDAPDN(dn)]; group != "" {
+			return group
+		}
+	}
+	return ""
+}
+
+// normalizeLDAPDN 去掉 RDN 之间的空白并转小写，"CN=Dev, OU=Groups" 与 "cn=dev,ou=groups" 视为相同
+func normalizeLDAPDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.ToLower(strings.Join(parts, ","))
+}
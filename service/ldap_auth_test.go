@@ -0,0 +1,118 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/pkg/ldap/ldaptest"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLDAPTestServer(t *testing.T) *ldaptest.Server {
+	t.Helper()
+	srv, err := ldaptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+	srv.AddEntry("cn=svc,dc=corp,dc=example", "svc-secret", nil)
+	srv.AddEntry("cn=Alice,ou=people,dc=corp,dc=example", "alice-pass", map[string][]string{
+		"sAMAccountName": {"Alice"},
+		"mail":           {"alice@corp.example"},
+		"displayName":    {"Alice Liddell"},
+		"memberOf":       {"CN=Staff,OU=Groups,DC=corp,DC=example", "CN=VIP,OU=Groups,DC=corp,DC=example"},
+	})
+
+	settings := system_setting.GetLDAPSettings()
+	original := *settings
+	t.Cleanup(func() { *settings = original })
+	*settings = system_setting.LDAPSettings{
+		Enabled:              true,
+		Url:                  srv.URL,
+		BindDN:               "cn=svc,dc=corp,dc=example",
+		BindPassword:         "svc-secret",
+		BaseDN:               "dc=corp,dc=example",
+		UserFilter:           "(&(mail=*)(sAMAccountName={username}))",
+		UsernameAttribute:    "sAMAccountName",
+		EmailAttribute:       "mail",
+		DisplayNameAttribute: "displayName",
+		GroupAttribute:       "memberOf",
+		GroupMapping:         map[string]string{"cn=vip, ou=groups, dc=corp, dc=example": "vip"},
+		Timeout:              5,
+	}
+	return srv
+}
+
+func TestAuthenticateLDAP(t *testing.T) {
+	srv := setupLDAPTestServer(t)
+
+	identity, err := AuthenticateLDAP("alice", "alice-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "cn=Alice,ou=people,dc=corp,dc=example", identity.DN)
+	assert.Equal(t, "Alice", identity.Username)
+	assert.Equal(t, "alice", identity.ProviderUserId())
+	assert.Equal(t, "alice@corp.example", identity.Email)
+	assert.Equal(t, "Alice Liddell", identity.DisplayName)
+	assert.Equal(t, "vip", identity.Group)
+	assert.Equal(t, []string{"cn=svc,dc=corp,dc=example", "cn=Alice,ou=people,dc=corp,dc=example"}, srv.Binds())
+}
+
+func TestAuthenticateLDAPRejectsBadCredentials(t *testing.T) {
+	setupLDAPTestServer(t)
+
+	_, err := AuthenticateLDAP("alice", "wrong")
+	assert.ErrorIs(t, err, ErrLDAPInvalidCredentials)
+	_, err = AuthenticateLDAP("nobody", "alice-pass")
+	assert.ErrorIs(t, err, ErrLDAPInvalidCredentials)
+	// 过滤器注入：转义后的 * 不会匹配任意用户
+	_, err = AuthenticateLDAP("*", "alice-pass")
+	assert.ErrorIs(t, err, ErrLDAPInvalidCredentials)
+	_, err = AuthenticateLDAP("alice", "")
+	assert.ErrorIs(t, err, ErrLDAPInvalidCredentials)
+
+	system_setting.GetLDAPSettings().Enabled = false
+	_, err = AuthenticateLDAP("alice", "alice-pass")
+	assert.ErrorIs(t, err, ErrLDAPDisabled)
+}
+
+func TestAuthenticateLDAPAmbiguousFilter(t *testing.T) {
+	srv := setupLDAPTestServer(t)
+	srv.AddEntry("cn=Alice2,ou=people,dc=corp,dc=example", "alice2-pass", map[string][]string{
+		"sAMAccountName": {"alice"},
+		"mail":           {"alice2@corp.example"},
+	})
+
+	_, err := AuthenticateLDAP("alice", "alice-pass")
+	assert.ErrorIs(t, err, ErrLDAPAmbiguousUser)
+}
+
+func TestAuthenticateLDAPStartTLS(t *testing.T) {
+	srv := setupLDAPTestServer(t)
+	srv.RequireTLS = true
+	_, err := srv.EnableStartTLS()
+	require.NoError(t, err)
+	settings := system_setting.GetLDAPSettings()
+
+	_, err = AuthenticateLDAP("alice", "alice-pass")
+	assert.Error(t, err, "plaintext bind must be refused")
+
+	// 自签名证书不被信任，StartTLS 握手失败
+	settings.StartTLS = true
+	_, err = AuthenticateLDAP("alice", "alice-pass")
+	assert.Error(t, err)
+
+	settings.InsecureSkipVerify = true
+	identity, err := AuthenticateLDAP("alice", "alice-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", identity.Username)
+}
+
+func TestMapLDAPGroup(t *testing.T) {
+	mapping := map[string]string{
+		"cn=dev,ou=groups,dc=example":   "dev",
+		"CN=Ops, OU=Groups, DC=example": "ops",
+	}
+	assert.Equal(t, "ops", MapLDAPGroup([]string{"cn=ops,ou=groups,dc=example", "cn=dev,ou=groups,dc=example"}, mapping))
+	assert.Equal(t, "dev", MapLDAPGroup([]string{"cn=other,dc=example", "CN=DEV,OU=GROUPS,DC=EXAMPLE"}, mapping))
+	assert.Empty(t, MapLDAPGroup([]string{"cn=other,dc=example"}, mapping))
+	assert.Empty(t, MapLDAPGroup(nil, mapping))
+}
@@ -55,6 +55,23 @@ func CheckRequestLeakProtection(request dto.Request) (bool, string) {
 	return true, "matched gitleaks rule"
 }
 
+// findLeakSecrets returns the credentials detected in text, using the same
+// rules as CheckRequestLeakProtection. Callers must not log the result.
+func findLeakSecrets(text string) []string {
+	secrets := leakProtectionSK.FindAllString(text, -1)
+	detector, err := getLeakProtectionDetector()
+	if err != nil {
+		return secrets
+	}
+	defer leakProtectionPool.Put(detector)
+	for _, finding := range detector.DetectString(text) {
+		if finding.Secret != "" {
+			secrets = append(secrets, finding.Secret)
+		}
+	}
+	return secrets
+}
+
 // leakProtectionTokenCountMeta limits conversational scanning to the suffix
 // beginning with the second-most-recent user message. Clients resend the full
 // conversation on every turn; rescanning older history is both redundant and
@@ -11,6 +11,7 @@ import (
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/pkg/billingexpr"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -91,6 +92,15 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	if isSystemPromptOverwritten {
 		other["is_system_prompt_overwritten"] = true
 	}
+	if batchId := common.GetContextKeyString(ctx, constant.ContextKeyBatchId); batchId != "" {
+		other["batch_id"] = batchId
+		other["batch_discount_ratio"] = operation_setting.GetBatchDiscountRatio(relayInfo.OriginModelName)
+	}
+	appendResponseCacheLogInfo(ctx, other)
+	appendCompletionSensitiveLogInfo(ctx, other)
+	appendModerationLogInfo(ctx, other)
+	appendSensitiveRuleLogInfo(ctx, other)
+	appendPIIRedactionLogInfo(ctx, other)
 
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
@@ -13,12 +13,51 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/setting"
 
 	"github.com/gin-gonic/gin"
 )
 
+// PreConsumeMidjourneyQuota 提交前检查额度。组织令牌从组织钱包预扣并计入成员月度上限，
+// 之后由 SettleBilling 结算、提交失败时由 relayInfo.Billing.Refund 退还；
+// 个人钱包只检查余额，提交成功后再扣费。
+func PreConsumeMidjourneyQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, quota int) *dto.MidjourneyResponse {
+	if relayInfo.OrganizationId > 0 {
+		if apiErr := PreConsumeBilling(c, quota, relayInfo); apiErr != nil {
+			return &dto.MidjourneyResponse{
+				Code:        4,
+				Description: apiErr.Error(),
+			}
+		}
+		return nil
+	}
+	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	if err != nil {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: err.Error(),
+		}
+	}
+	if userQuota-quota < 0 {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: "quota_not_enough",
+		}
+	}
+	return nil
+}
+
+// RefundMidjourneyTaskQuota 任务失败时退还额度，组织令牌提交的任务退回组织钱包
+func RefundMidjourneyTaskQuota(task *model.Midjourney) error {
+	if task.OrganizationId > 0 {
+		return model.AdjustOrganizationUsage(task.OrganizationId, task.UserId, -task.Quota)
+	}
+	return model.IncreaseUserQuota(task.UserId, task.Quota, false)
+}
+
 func CovertMjpActionToModelName(mjAction string) string {
 	modelName := "mj_" + strings.ToLower(mjAction)
 	if mjAction == constant.MjActionSwapFace {
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const moderationResponseMaxBytes = 1 << 20
+
+// ModerationResult 审核服务返回的结果，多条结果按类别取最高分合并
+type ModerationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// ModerationProvider 外部审核服务
+type ModerationProvider interface {
+	Name() string
+	Moderate(ctx context.Context, input string) (*ModerationResult, error)
+}
+
+// ModerationDecision 本次请求的审核结论，记录到消费日志或错误日志
+type ModerationDecision struct {
+	Provider   string   `json:"provider"`
+	Action     string   `json:"action"`
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// newModerationProvider 按配置创建审核服务
+var newModerationProvider = func(setting *operation_setting.ModerationSetting) (ModerationProvider, error) {
+	switch setting.Provider {
+	case operation_setting.ModerationProviderHTTP:
+		if setting.Endpoint == "" {
+			return nil, errors.New("moderation endpoint is not configured")
+		}
+		return &httpModerationProvider{endpoint: setting.Endpoint, apiKey: setting.ApiKey, model: setting.Model}, nil
+	case operation_setting.ModerationProviderChannel, "":
+		if setting.ChannelId <= 0 {
+			return nil, errors.New("moderation channel is not configured")
+		}
+		return &channelModerationProvider{channelId: setting.ChannelId, model: setting.Model}, nil
+	}
+	return nil, fmt.Errorf("unknown moderation provider: %s", setting.Provider)
+}
+
+type moderationRequest struct {
+	Model string `json:"model,omitempty"`
+	Input string `json:"input"`
+}
+
+// channelModerationProvider 调用 OpenAI/Azure 渠道的 /v1/moderations
+type channelModerationProvider struct {
+	channelId int
+	model     string
+}
+
+func (p *channelModerationProvider) Name() string {
+	return fmt.Sprintf("channel#%d", p.channelId)
+}
+
+func (p *channelModerationProvider) Moderate(ctx context.Context, input string) (*ModerationResult, error) {
+	channel, err := model.CacheGetChannel(p.channelId)
+	if err != nil {
+		return nil, err
+	}
+	body, err := common.Marshal(moderationRequest{Model: p.model, Input: input})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := DoOpenAIUpstreamRequest(ctx, channel, OpenAIUpstreamRequest{
+		Method:      http.MethodPost,
+		Path:        "/v1/moderations",
+		Body:        bytes.NewReader(body),
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer CloseResponseBodyGracefully(resp)
+	return readModerationResponse(resp)
+}
+
+// httpModerationProvider 调用自定义分类接口，请求体与 /v1/moderations 相同，
+// 响应可以是 OpenAI moderation 格式，也可以直接返回 flagged/categories/category_scores
+type httpModerationProvider struct {
+	endpoint string
+	apiKey   string
+	model    string
+}
+
+func (p *httpModerationProvider) Name() string {
+	return "http"
+}
+
+func (p *httpModerationProvider) Moderate(ctx context.Context, input string) (*ModerationResult, error) {
+	if err := ValidateSSRFProtectedFetchURL(p.endpoint); err != nil {
+		return nil, fmt.Errorf("moderation endpoint rejected: %w", err)
+	}
+	body, err := common.Marshal(moderationRequest{Model: p.model, Input: input})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	resp, err := GetSSRFProtectedHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseResponseBodyGracefully(resp)
+	return readModerationResponse(resp)
+}
+
+func readModerationResponse(resp *http.Response) (*ModerationResult, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, moderationResponseMaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation service returned status %d: %s", resp.StatusCode, common.MaskSensitiveInfo(string(body)))
+	}
+	return parseModerationResponse(body)
+}
+
+func parseModerationResponse(body []byte) (*ModerationResult, error) {
+	var response struct {
+		ModerationResult
+		Results []ModerationResult `json:"results"`
+	}
+	if err := common.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("invalid moderation response: %w", err)
+	}
+	if len(response.Results) == 0 {
+		return &response.ModerationResult, nil
+	}
+	merged := &ModerationResult{Categories: map[string]bool{}, CategoryScores: map[string]float64{}}
+	for _, result := range response.Results {
+		merged.Flagged = merged.Flagged || result.Flagged
+		for category, hit := range result.Categories {
+			merged.Categories[category] = merged.Categories[category] || hit
+		}
+		for category, score := range result.CategoryScores {
+			merged.CategoryScores[category] = max(merged.CategoryScores[category], score)
+		}
+	}
+	return merged, nil
+}
+
+// evaluateModeration 按类别阈值判断是否命中，未配置阈值时采用审核服务自身的判定
+func evaluateModeration(result *ModerationResult, thresholds map[string]float64) (bool, []string) {
+	categories := make([]string, 0)
+	if len(thresholds) == 0 {
+		for category, hit := range result.Categories {
+			if hit {
+				categories = append(categories, category)
+			}
+		}
+		sort.Strings(categories)
+		return result.Flagged || len(categories) > 0, categories
+	}
+	for category, threshold := range thresholds {
+		if score, ok := result.CategoryScores[category]; ok && score >= threshold {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return len(categories) > 0, categories
+}
+
+// NeedPromptModeration 当前请求是否需要外部审核
+func NeedPromptModeration(relayInfo *relaycommon.RelayInfo) bool {
+	if relayInfo == nil || relayInfo.RelayMode == relayconstant.RelayModeModerations {
+		return false
+	}
+	return operation_setting.IsModerationEnabledForGroup(relayInfo.UsingGroup)
+}
+
+// ModeratePrompt 在请求转发到上游前调用外部审核服务，按分组规则拒绝或标记请求，结论保存在上下文中
+func ModeratePrompt(c *gin.Context, relayInfo *relaycommon.RelayInfo, text string) *types.NewAPIError {
+	if !NeedPromptModeration(relayInfo) || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	setting := operation_setting.GetModerationSetting()
+	if setting.MaxInputLength > 0 {
+		// 最新的消息在末尾，超长时保留尾部，避免用前置填充把违规内容挤出送审范围
+		if runes := []rune(text); len(runes) > setting.MaxInputLength {
+			text = string(runes[len(runes)-setting.MaxInputLength:])
+		}
+	}
+	timeout := time.Duration(setting.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	decision := &ModerationDecision{}
+	result, err := func() (*ModerationResult, error) {
+		provider, err := newModerationProvider(setting)
+		if err != nil {
+			return nil, err
+		}
+		decision.Provider = provider.Name()
+		return provider.Moderate(ctx, text)
+	}()
+	if err != nil {
+		logger.LogWarn(c, "prompt moderation failed: "+err.Error())
+		decision.Error = err.Error()
+		common.SetContextKey(c, constant.ContextKeyModerationDecision, decision)
+		if setting.FailOpen {
+			return nil
+		}
+		return types.NewErrorWithStatusCode(errors.New("content moderation service unavailable"), types.ErrorCodeModerationFailed,
+			http.StatusServiceUnavailable, types.ErrOptionWithSkipRetry())
+	}
+
+	action, thresholds := operation_setting.GetModerationRule(relayInfo.UsingGroup)
+	decision.Action = action
+	decision.Flagged, decision.Categories = evaluateModeration(result, thresholds)
+	common.SetContextKey(c, constant.ContextKeyModerationDecision, decision)
+	if !decision.Flagged || action != operation_setting.ModerationActionBlock {
+		return nil
+	}
+
+	reason := fmt.Sprintf("prompt rejected by content moderation: %s", strings.Join(decision.Categories, ", "))
+	logger.LogWarn(c, reason)
+	if constant.ErrorLogEnabled {
+		other := map[string]interface{}{
+			"request_path": c.Request.URL.Path,
+			"error_code":   types.ErrorCodeModerationBlocked,
+		}
+		appendModerationLogInfo(c, other)
+		model.RecordErrorLog(c, relayInfo.UserId, 0, relayInfo.OriginModelName, c.GetString("token_name"), reason, relayInfo.TokenId,
+			0, relayInfo.IsStream, relayInfo.UsingGroup, other)
+	}
+	return types.NewErrorWithStatusCode(errors.New(reason), types.ErrorCodeModerationBlocked, http.StatusBadRequest,
+		types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+}
+
+// appendModerationLogInfo 在日志中记录外部审核结论
+func appendModerationLogInfo(ctx *gin.Context, other map[string]interface{}) {
+	decision, ok := common.GetContextKeyType[*ModerationDecision](ctx, constant.ContextKeyModerationDecision)
+	if !ok || decision == nil {
+		return
+	}
+	other["moderation"] = decision
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeModerationProvider struct {
+	result *ModerationResult
+	err    error
+	input  string
+}
+
+func (p *fakeModerationProvider) Name() string { return "fake" }
+
+func (p *fakeModerationProvider) Moderate(ctx context.Context, input string) (*ModerationResult, error) {
+	p.input = input
+	return p.result, p.err
+}
+
+func useTestModeration(t *testing.T, provider ModerationProvider) *operation_setting.ModerationSetting {
+	t.Helper()
+	setting := operation_setting.GetModerationSetting()
+	oldSetting, oldProvider, oldErrorLog := *setting, newModerationProvider, constant.ErrorLogEnabled
+	t.Cleanup(func() {
+		*setting, newModerationProvider, constant.ErrorLogEnabled = oldSetting, oldProvider, oldErrorLog
+	})
+	newModerationProvider = func(*operation_setting.ModerationSetting) (ModerationProvider, error) { return provider, nil }
+	constant.ErrorLogEnabled = false
+	setting.Enabled = true
+	setting.Action = operation_setting.ModerationActionBlock
+	setting.DefaultThresholds = map[string]float64{}
+	setting.GroupRules = map[string]operation_setting.ModerationGroupRule{}
+	return setting
+}
+
+func newModerationTestContext() (*gin.Context, *relaycommon.RelayInfo) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	return c, &relaycommon.RelayInfo{UsingGroup: "default"}
+}
+
+func TestParseModerationResponseMergesResults(t *testing.T) {
+	result, err := parseModerationResponse([]byte(`{"results":[
+		{"flagged":false,"categories":{"violence":false},"category_scores":{"violence":0.2,"hate":0.1}},
+		{"flagged":true,"categories":{"violence":true},"category_scores":{"violence":0.9}}]}`))
+	require.NoError(t, err)
+	assert.True(t, result.Flagged)
+	assert.Equal(t, 0.9, result.CategoryScores["violence"])
+	assert.Equal(t, 0.1, result.CategoryScores["hate"])
+
+	// 自定义分类接口直接返回结果
+	result, err = parseModerationResponse([]byte(`{"flagged":true,"categories":{"spam":true}}`))
+	require.NoError(t, err)
+	assert.True(t, result.Flagged)
+	assert.True(t, result.Categories["spam"])
+}
+
+func TestModeratePromptAppliesGroupThresholds(t *testing.T) {
+	provider := &fakeModerationProvider{result: &ModerationResult{
+		Flagged:        true,
+		CategoryScores: map[string]float64{"violence": 0.6, "hate": 0.2},
+	}}
+	setting := useTestModeration(t, provider)
+	setting.DefaultThresholds = map[string]float64{"violence": 0.5}
+	setting.GroupRules = map[string]operation_setting.ModerationGroupRule{
+		"vip":      {Thresholds: map[string]float64{"violence": 0.8}},
+		"internal": {Disabled: true},
+		"audit":    {Action: operation_setting.ModerationActionFlag},
+	}
+
+	c, info := newModerationTestContext()
+	apiErr := ModeratePrompt(c, info, "some prompt")
+	require.NotNil(t, apiErr)
+	assert.Equal(t, types.ErrorCodeModerationBlocked, apiErr.GetErrorCode())
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Error(), "violence")
+
+	c, info = newModerationTestContext()
+	info.UsingGroup = "vip"
+	assert.Nil(t, ModeratePrompt(c, info, "some prompt"))
+
+	c, info = newModerationTestContext()
+	info.UsingGroup = "internal"
+	assert.Nil(t, ModeratePrompt(c, info, "some prompt"))
+	_, recorded := common.GetContextKeyType[*ModerationDecision](c, constant.ContextKeyModerationDecision)
+	assert.False(t, recorded)
+
+	// flag 模式放行并在日志中记录
+	c, info = newModerationTestContext()
+	info.UsingGroup = "audit"
+	assert.Nil(t, ModeratePrompt(c, info, "some prompt"))
+	other := map[string]interface{}{}
+	appendModerationLogInfo(c, other)
+	decision, ok := other["moderation"].(*ModerationDecision)
+	require.True(t, ok)
+	assert.True(t, decision.Flagged)
+	assert.Equal(t, operation_setting.ModerationActionFlag, decision.Action)
+	assert.Equal(t, []string{"violence"}, decision.Categories)
+}
+
+func TestModeratePromptFailOpen(t *testing.T) {
+	setting := useTestModeration(t, &fakeModerationProvider{err: errors.New("upstream down")})
+
+	setting.FailOpen = true
+	c, info := newModerationTestContext()
+	assert.Nil(t, ModeratePrompt(c, info, "some prompt"))
+
+	setting.FailOpen = false
+	c, info = newModerationTestContext()
+	apiErr := ModeratePrompt(c, info, "some prompt")
+	require.NotNil(t, apiErr)
+	assert.Equal(t, types.ErrorCodeModerationFailed, apiErr.GetErrorCode())
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+}
+
+func TestModeratePromptKeepsTailOfLongInput(t *testing.T) {
+	provider := &fakeModerationProvider{result: &ModerationResult{}}
+	setting := useTestModeration(t, provider)
+	setting.MaxInputLength = 5
+
+	c, info := newModerationTestContext()
+	assert.Nil(t, ModeratePrompt(c, info, "padding...最新的问题"))
+	assert.Equal(t, "最新的问题", provider.input)
+}
@@ -0,0 +1,591 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+const (
+	batchInputPurpose  = "batch"
+	batchOutputPurpose = "batch_output"
+
+	batchErrorCodeCancelled   = "batch_cancelled"
+	batchErrorCodeExpired     = "batch_expired"
+	batchErrorCodeInterrupted = "request_interrupted"
+
+	// batchProcessBatchLimit 每轮处理时拉取的批处理数量，各批处理按块轮转执行
+	batchProcessBatchLimit = 20
+	batchFinalizePageSize  = 200
+)
+
+// supportedBatchEndpoints 允许批处理的接口，与 relay 路由保持一致
+var supportedBatchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+	"/v1/responses":        true,
+	"/v1/moderations":      true,
+}
+
+// IsSupportedBatchEndpoint 判断接口是否支持批处理
+func IsSupportedBatchEndpoint(endpoint string) bool {
+	return supportedBatchEndpoints[endpoint]
+}
+
+func newBatchError(err error, code types.ErrorCode, statusCode int) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(err, code, statusCode, types.ErrOptionWithSkipRetry())
+}
+
+// BatchToOpenAI 转换为 OpenAI batch 对象
+func BatchToOpenAI(batch *model.Batch) dto.OpenAIBatch {
+	result := dto.OpenAIBatch{
+		Id:               batch.BatchId,
+		Object:           "batch",
+		Endpoint:         batch.Endpoint,
+		InputFileId:      batch.InputFileId,
+		CompletionWindow: batch.CompletionWindow,
+		Status:           batch.Status,
+		CreatedAt:        batch.CreatedAt,
+		InProgressAt:     optionalTimestamp(batch.InProgressAt),
+		ExpiresAt:        optionalTimestamp(batch.ExpiresAt),
+		FinalizingAt:     optionalTimestamp(batch.FinalizingAt),
+		CompletedAt:      optionalTimestamp(batch.CompletedAt),
+		FailedAt:         optionalTimestamp(batch.FailedAt),
+		ExpiredAt:        optionalTimestamp(batch.ExpiredAt),
+		CancellingAt:     optionalTimestamp(batch.CancellingAt),
+		CancelledAt:      optionalTimestamp(batch.CancelledAt),
+		RequestCounts: dto.OpenAIBatchRequestCounts{
+			Total:     batch.RequestTotal,
+			Completed: batch.RequestCompleted,
+			Failed:    batch.RequestFailed,
+		},
+	}
+	if batch.OutputFileId != "" {
+		result.OutputFileId = common.GetPointer(batch.OutputFileId)
+	}
+	if batch.ErrorFileId != "" {
+		result.ErrorFileId = common.GetPointer(batch.ErrorFileId)
+	}
+	if batch.Metadata != "" {
+		_ = common.UnmarshalJsonStr(batch.Metadata, &result.Metadata)
+	}
+	return result
+}
+
+func optionalTimestamp(ts int64) *int64 {
+	if ts == 0 {
+		return nil
+	}
+	return &ts
+}
+
+// GetOwnedBatch 获取属于该用户的批处理，不存在时返回 404 错误
+func GetOwnedBatch(userId int, batchId string) (*model.Batch, *types.NewAPIError) {
+	batch, err := model.GetUserBatchByBatchId(userId, batchId)
+	if err != nil {
+		return nil, newBatchError(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError)
+	}
+	if batch == nil {
+		return nil, newBatchError(fmt.Errorf("no such batch: %s", batchId), types.ErrorCodeBatchNotFound, http.StatusNotFound)
+	}
+	return batch, nil
+}
+
+// CreateBatch 校验输入文件并创建批处理，请求行落库后由后台任务异步执行
+func CreateBatch(ctx context.Context, userId int, tokenId int, clientIp string, request dto.OpenAIBatchCreateRequest) (*model.Batch, *types.NewAPIError) {
+	batchSetting := operation_setting.GetBatchSetting()
+	if !batchSetting.Enabled {
+		return nil, newBatchError(errors.New("batch API is disabled"), types.ErrorCodeAccessDenied, http.StatusForbidden)
+	}
+	if !IsSupportedBatchEndpoint(request.Endpoint) {
+		return nil, newBatchError(fmt.Errorf("unsupported endpoint: %s", request.Endpoint), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	if request.CompletionWindow == "" {
+		request.CompletionWindow = "24h"
+	}
+	if request.CompletionWindow != "24h" {
+		return nil, newBatchError(fmt.Errorf("unsupported completion_window: %s", request.CompletionWindow), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	if request.InputFileId == "" {
+		return nil, newBatchError(errors.New("input_file_id is required"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+
+	inputFile, apiErr := GetOwnedUserFile(userId, request.InputFileId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if inputFile.Purpose != batchInputPurpose {
+		return nil, newBatchError(fmt.Errorf("file %s must be uploaded with purpose %q", inputFile.FileId, batchInputPurpose), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	maxBytes := int64(operation_setting.GetFileSetting().MaxFileSizeMB) << 20
+	if maxBytes <= 0 {
+		maxBytes = unlimitedUserFileBytes
+	}
+	data, apiErr := ReadUserFileBytes(ctx, inputFile, maxBytes)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	requests, err := ParseBatchInput(data, request.Endpoint, batchSetting.MaxRequestsPerBatch)
+	if err != nil {
+		return nil, newBatchError(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+
+	batchId, err := model.GenerateBatchId()
+	if err != nil {
+		return nil, newBatchError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+	}
+	metadata := ""
+	if len(request.Metadata) > 0 {
+		metadataBytes, err := common.Marshal(request.Metadata)
+		if err != nil {
+			return nil, newBatchError(err, types.ErrorCodeJsonMarshalFailed, http.StatusBadRequest)
+		}
+		metadata = string(metadataBytes)
+	}
+	now := common.GetTimestamp()
+	windowHours := batchSetting.CompletionWindowHours
+	if windowHours <= 0 {
+		windowHours = 24
+	}
+	batch := &model.Batch{
+		BatchId:          batchId,
+		UserId:           userId,
+		TokenId:          tokenId,
+		ClientIp:         clientIp,
+		Endpoint:         request.Endpoint,
+		InputFileId:      inputFile.FileId,
+		CompletionWindow: request.CompletionWindow,
+		Status:           model.BatchStatusInProgress,
+		Metadata:         metadata,
+		RequestTotal:     len(requests),
+		CreatedAt:        now,
+		InProgressAt:     now,
+		ExpiresAt:        now + int64(windowHours)*3600,
+	}
+	for _, r := range requests {
+		r.BatchId = batchId
+	}
+	if err := model.CreateBatchWithRequests(batch, requests); err != nil {
+		return nil, newBatchError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+	}
+	if _, _, err := EnqueueSystemTask(model.SystemTaskTypeBatchProcess, nil); err != nil {
+		// 定时调度仍会拾取该批处理，这里只记录日志
+		logger.LogWarn(ctx, fmt.Sprintf("enqueue batch process task failed: %v", err))
+	}
+	return batch, nil
+}
+
+// ParseBatchInput 解析 JSONL 输入文件，校验每行的 custom_id、method、url 与 body
+func ParseBatchInput(data []byte, endpoint string, maxRequests int) ([]*model.BatchRequest, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+
+	requests := make([]*model.BatchRequest, 0)
+	customIds := make(map[string]struct{})
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var input dto.OpenAIBatchInputLine
+		if err := common.Unmarshal(line, &input); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", lineNo, err)
+		}
+		if input.CustomId == "" {
+			return nil, fmt.Errorf("line %d: custom_id is required", lineNo)
+		}
+		if _, ok := customIds[input.CustomId]; ok {
+			return nil, fmt.Errorf("line %d: duplicate custom_id %q", lineNo, input.CustomId)
+		}
+		customIds[input.CustomId] = struct{}{}
+		if input.Method != "" && !strings.EqualFold(input.Method, http.MethodPost) {
+			return nil, fmt.Errorf("line %d: only POST method is supported", lineNo)
+		}
+		if input.Url != endpoint {
+			return nil, fmt.Errorf("line %d: url %q does not match batch endpoint %q", lineNo, input.Url, endpoint)
+		}
+		if common.GetJsonType(input.Body) != "object" {
+			return nil, fmt.Errorf("line %d: body must be a JSON object", lineNo)
+		}
+		var body struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		if err := common.Unmarshal(input.Body, &body); err != nil {
+			return nil, fmt.Errorf("line %d: invalid body: %w", lineNo, err)
+		}
+		if body.Model == "" {
+			return nil, fmt.Errorf("line %d: body.model is required", lineNo)
+		}
+		if body.Stream {
+			return nil, fmt.Errorf("line %d: streaming is not supported in batch requests", lineNo)
+		}
+		requests = append(requests, &model.BatchRequest{
+			LineIndex: len(requests),
+			CustomId:  input.CustomId,
+			Method:    http.MethodPost,
+			Url:       input.Url,
+			Body:      string(input.Body),
+			Status:    model.BatchRequestStatusPending,
+		})
+		if maxRequests > 0 && len(requests) > maxRequests {
+			return nil, fmt.Errorf("batch exceeds the maximum of %d requests", maxRequests)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	if len(requests) == 0 {
+		return nil, errors.New("input file contains no requests")
+	}
+	return requests, nil
+}
+
+// CancelBatch 请求取消批处理，未执行的请求会在后台处理时标记为失败
+func CancelBatch(userId int, batchId string) (*model.Batch, *types.NewAPIError) {
+	batch, apiErr := GetOwnedBatch(userId, batchId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	switch batch.Status {
+	case model.BatchStatusCancelling, model.BatchStatusCancelled:
+		return batch, nil
+	case model.BatchStatusInProgress:
+	default:
+		return nil, newBatchError(fmt.Errorf("cannot cancel batch with status %s", batch.Status), types.ErrorCodeInvalidRequest, http.StatusConflict)
+	}
+	now := common.GetTimestamp()
+	updated, err := model.UpdateBatchStatus(batchId, []string{model.BatchStatusInProgress}, map[string]any{
+		"status":        model.BatchStatusCancelling,
+		"cancelling_at": now,
+	})
+	if err != nil {
+		return nil, newBatchError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+	}
+	if updated {
+		if _, _, err := EnqueueSystemTask(model.SystemTaskTypeBatchProcess, nil); err != nil {
+			common.SysError(fmt.Sprintf("enqueue batch process task failed: %v", err))
+		}
+	}
+	return GetOwnedBatch(userId, batchId)
+}
+
+// BatchRequestExecutor 通过 relay 管线执行单个批处理请求，并把结果写回 request
+// （Status、StatusCode、RequestId、Response、Error）。ctx 取消时可以不写结果。
+type BatchRequestExecutor func(ctx context.Context, batch *model.Batch, request *model.BatchRequest)
+
+type BatchProcessSummary struct {
+	Batches   int `json:"batches"`
+	Executed  int `json:"executed"`
+	Finalized int `json:"finalized"`
+}
+
+// RunBatchProcessing 轮转处理所有活跃批处理，直到全部进入终态或 ctx 被取消。
+// 每个批处理每次只执行一块（MaxConcurrency 个请求），避免大批处理饿死其他批处理。
+func RunBatchProcessing(ctx context.Context, execute BatchRequestExecutor) BatchProcessSummary {
+	summary := BatchProcessSummary{}
+	seen := make(map[string]struct{})
+	for ctx.Err() == nil {
+		batches, err := model.FindActiveBatches(batchProcessBatchLimit)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("batch process query failed: %v", err))
+			return summary
+		}
+		if len(batches) == 0 {
+			return summary
+		}
+		for _, batch := range batches {
+			if ctx.Err() != nil {
+				return summary
+			}
+			if _, ok := seen[batch.BatchId]; !ok {
+				seen[batch.BatchId] = struct{}{}
+				summary.Batches++
+			}
+			executed, finalized, err := processBatchChunk(ctx, batch, execute)
+			summary.Executed += executed
+			if finalized {
+				summary.Finalized++
+			}
+			if err != nil {
+				// 多为数据库等暂时性错误，结束本轮，由下一次调度重试
+				logger.LogWarn(ctx, fmt.Sprintf("batch %s process failed: %v", batch.BatchId, err))
+				return summary
+			}
+		}
+	}
+	return summary
+}
+
+func processBatchChunk(ctx context.Context, batch *model.Batch, execute BatchRequestExecutor) (int, bool, error) {
+	switch batch.Status {
+	case model.BatchStatusCancelling:
+		if _, err := model.FailPendingBatchRequests(batch.BatchId, NewBatchRequestError(batchErrorCodeCancelled, "the batch was cancelled")); err != nil {
+			return 0, false, err
+		}
+		return 0, true, finalizeBatch(batch, model.BatchStatusCancelled)
+	case model.BatchStatusFinalizing:
+		return 0, true, finalizeBatch(batch, model.BatchStatusCompleted)
+	}
+	if batch.ExpiresAt > 0 && common.GetTimestamp() >= batch.ExpiresAt {
+		if _, err := model.FailPendingBatchRequests(batch.BatchId, NewBatchRequestError(batchErrorCodeExpired, "the batch expired before this request could be executed")); err != nil {
+			return 0, false, err
+		}
+		return 0, true, finalizeBatch(batch, model.BatchStatusExpired)
+	}
+
+	// 处理器独占执行，块开始时仍处于 running 的请求只可能来自已退出的执行者；
+	// 这些请求可能已经计费，标记失败而不是重新执行
+	if _, err := model.FailInterruptedBatchRequests(batch.BatchId, NewBatchRequestError(batchErrorCodeInterrupted, "the request was interrupted before completion")); err != nil {
+		return 0, false, err
+	}
+
+	concurrency := operation_setting.GetBatchSetting().MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	requests, err := model.FindPendingBatchRequests(batch.BatchId, concurrency)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(requests) == 0 {
+		return 0, true, finalizeBatch(batch, model.BatchStatusCompleted)
+	}
+
+	ids := make([]int, 0, len(requests))
+	for _, request := range requests {
+		ids = append(ids, request.Id)
+	}
+	if err := model.MarkBatchRequestsRunning(ids); err != nil {
+		return 0, false, err
+	}
+
+	var wg sync.WaitGroup
+	for _, request := range requests {
+		wg.Add(1)
+		go func(request *model.BatchRequest) {
+			defer wg.Done()
+			execute(ctx, batch, request)
+			if ctx.Err() != nil && request.Status != model.BatchRequestStatusCompleted && request.Status != model.BatchRequestStatusFailed {
+				return
+			}
+			if request.Status != model.BatchRequestStatusCompleted {
+				request.Status = model.BatchRequestStatusFailed
+			}
+			if err := model.SaveBatchRequestResult(request); err != nil {
+				logger.LogWarn(ctx, fmt.Sprintf("batch %s save request %s result failed: %v", batch.BatchId, request.CustomId, err))
+			}
+		}(request)
+	}
+	wg.Wait()
+
+	if err := refreshBatchRequestCounts(batch); err != nil {
+		return len(requests), false, err
+	}
+	return len(requests), false, nil
+}
+
+func refreshBatchRequestCounts(batch *model.Batch) error {
+	completed, failed, err := model.CountBatchRequestResults(batch.BatchId)
+	if err != nil {
+		return err
+	}
+	batch.RequestCompleted = completed
+	batch.RequestFailed = failed
+	_, err = model.UpdateBatchStatus(batch.BatchId, []string{batch.Status}, map[string]any{
+		"request_completed": completed,
+		"request_failed":    failed,
+	})
+	return err
+}
+
+// finalizeBatch 汇总请求结果生成 output/error 文件，并把批处理置为终态
+func finalizeBatch(batch *model.Batch, finalStatus string) error {
+	now := common.GetTimestamp()
+	if finalStatus == model.BatchStatusCompleted && batch.Status != model.BatchStatusFinalizing {
+		updated, err := model.UpdateBatchStatus(batch.BatchId, []string{batch.Status}, map[string]any{
+			"status":        model.BatchStatusFinalizing,
+			"finalizing_at": now,
+		})
+		if err != nil {
+			return err
+		}
+		if !updated {
+			// 状态已被并发修改（例如用户取消），下一轮按新状态处理
+			return nil
+		}
+		batch.Status = model.BatchStatusFinalizing
+	}
+
+	completed, failed, err := model.CountBatchRequestResults(batch.BatchId)
+	if err != nil {
+		return err
+	}
+	updates := map[string]any{
+		"status":            finalStatus,
+		"request_completed": completed,
+		"request_failed":    failed,
+	}
+	// 状态未能更新时删除本轮生成的文件，下一轮会重新生成，避免留下无主文件
+	var generated []*model.UserFile
+	discardGenerated := func() {
+		for _, file := range generated {
+			if apiErr := DeleteUserFile(context.Background(), file); apiErr != nil {
+				logger.LogWarn(context.Background(), fmt.Sprintf("batch %s discard file %s failed: %v", batch.BatchId, file.FileId, apiErr.Err))
+			}
+		}
+	}
+	outputFile, err := saveBatchResultFile(batch, "_output.jsonl", false)
+	if err != nil {
+		return fmt.Errorf("save output file: %w", err)
+	}
+	if outputFile != nil {
+		generated = append(generated, outputFile)
+		updates["output_file_id"] = outputFile.FileId
+	}
+	errorFile, err := saveBatchResultFile(batch, "_error.jsonl", true)
+	if err != nil {
+		discardGenerated()
+		return fmt.Errorf("save error file: %w", err)
+	}
+	if errorFile != nil {
+		generated = append(generated, errorFile)
+		updates["error_file_id"] = errorFile.FileId
+	}
+	switch finalStatus {
+	case model.BatchStatusCompleted:
+		updates["completed_at"] = now
+	case model.BatchStatusCancelled:
+		updates["cancelled_at"] = now
+	case model.BatchStatusExpired:
+		updates["expired_at"] = now
+	}
+	updated, err := model.UpdateBatchStatus(batch.BatchId, []string{batch.Status}, updates)
+	if err != nil {
+		discardGenerated()
+		return err
+	}
+	if !updated {
+		// 已由并发的一轮完成收尾
+		discardGenerated()
+		return nil
+	}
+	if err := model.DeleteBatchRequests(batch.BatchId); err != nil {
+		logger.LogWarn(context.Background(), fmt.Sprintf("batch %s cleanup requests failed: %v", batch.BatchId, err))
+	}
+	return nil
+}
+
+// saveBatchResultFile 分页读取请求结果并逐行写入文件存储，不在内存中汇总整个文件。
+// errorLines 为 true 时生成 error 文件，没有对应结果行时不生成文件
+func saveBatchResultFile(batch *model.Batch, suffix string, errorLines bool) (*model.UserFile, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeBatchResultLines(batch.BatchId, errorLines, writer))
+	}()
+	file, err := SaveGeneratedUserFile(batch.UserId, batch.BatchId+suffix, batchOutputPurpose, "application/jsonl", reader)
+	// 保存失败时关闭读端，结束仍在写入的协程
+	_ = reader.Close()
+	if err != nil {
+		return nil, err
+	}
+	if file.Bytes == 0 {
+		if apiErr := DeleteUserFile(context.Background(), file); apiErr != nil {
+			return nil, apiErr.Err
+		}
+		return nil, nil
+	}
+	return file, nil
+}
+
+func writeBatchResultLines(batchId string, errorLines bool, w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	afterId := 0
+	for {
+		requests, err := model.ListBatchRequests(batchId, afterId, batchFinalizePageSize)
+		if err != nil {
+			return err
+		}
+		for _, request := range requests {
+			afterId = request.Id
+			line, isError := buildBatchOutputLine(request)
+			if isError != errorLines {
+				continue
+			}
+			data, err := common.Marshal(line)
+			if err != nil {
+				return err
+			}
+			if _, err := buffered.Write(data); err != nil {
+				return err
+			}
+			if err := buffered.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+		if len(requests) < batchFinalizePageSize {
+			break
+		}
+	}
+	return buffered.Flush()
+}
+
+// buildBatchOutputLine 生成结果文件中的一行，第二个返回值表示是否写入 error 文件
+func buildBatchOutputLine(request *model.BatchRequest) (dto.OpenAIBatchOutputLine, bool) {
+	line := dto.OpenAIBatchOutputLine{
+		Id:       fmt.Sprintf("batch_req_%d", request.Id),
+		CustomId: request.CustomId,
+	}
+	if request.StatusCode > 0 {
+		body := json.RawMessage(request.Response)
+		if !json.Valid(body) {
+			body, _ = common.Marshal(request.Response)
+		}
+		line.Response = &dto.OpenAIBatchOutputResponse{
+			StatusCode: request.StatusCode,
+			RequestId:  request.RequestId,
+			Body:       body,
+		}
+	}
+	if request.Status == model.BatchRequestStatusCompleted {
+		return line, false
+	}
+	if line.Response == nil {
+		line.Error = parseBatchErrorText(request.Error)
+	}
+	return line, true
+}
+
+// NewBatchRequestError 生成写入 BatchRequest.Error 的错误文本
+func NewBatchRequestError(code string, message string) string {
+	text, _ := common.Marshal(dto.OpenAIBatchOutputError{Code: code, Message: message})
+	return string(text)
+}
+
+func parseBatchErrorText(text string) *dto.OpenAIBatchOutputError {
+	var batchErr dto.OpenAIBatchOutputError
+	if text != "" && common.UnmarshalJsonStr(text, &batchErr) == nil && batchErr.Code != "" {
+		return &batchErr
+	}
+	if text == "" {
+		text = "the request failed"
+	}
+	return &dto.OpenAIBatchOutputError{Code: "request_failed", Message: text}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	input := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[]}}
+
+{"custom_id":"b","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[]}}
+`
+	requests, err := ParseBatchInput([]byte(input), "/v1/chat/completions", 10)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "a", requests[0].CustomId)
+	assert.Equal(t, 0, requests[0].LineIndex)
+	assert.Equal(t, "b", requests[1].CustomId)
+	assert.Equal(t, 1, requests[1].LineIndex)
+	assert.Equal(t, model.BatchRequestStatusPending, requests[1].Status)
+}
+
+func TestParseBatchInputRejectsInvalidLines(t *testing.T) {
+	cases := map[string]string{
+		"duplicate custom_id": `{"custom_id":"a","url":"/v1/embeddings","body":{"model":"m"}}
+{"custom_id":"a","url":"/v1/embeddings","body":{"model":"m"}}`,
+		"url mismatch":  `{"custom_id":"a","url":"/v1/chat/completions","body":{"model":"m"}}`,
+		"missing model": `{"custom_id":"a","url":"/v1/embeddings","body":{}}`,
+		"stream":        `{"custom_id":"a","url":"/v1/embeddings","body":{"model":"m","stream":true}}`,
+		"get method":    `{"custom_id":"a","method":"GET","url":"/v1/embeddings","body":{"model":"m"}}`,
+		"empty file":    "\n\n",
+		"too many lines": `{"custom_id":"a","url":"/v1/embeddings","body":{"model":"m"}}
+{"custom_id":"b","url":"/v1/embeddings","body":{"model":"m"}}`,
+	}
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseBatchInput([]byte(input), "/v1/embeddings", 1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBuildBatchOutputLine(t *testing.T) {
+	line, isError := buildBatchOutputLine(&model.BatchRequest{
+		Id:         7,
+		CustomId:   "ok",
+		Status:     model.BatchRequestStatusCompleted,
+		StatusCode: 200,
+		RequestId:  "req",
+		Response:   `{"id":"chatcmpl"}`,
+	})
+	assert.False(t, isError)
+	require.NotNil(t, line.Response)
+	assert.Equal(t, "batch_req_7", line.Id)
+	assert.JSONEq(t, `{"id":"chatcmpl"}`, string(line.Response.Body))
+	assert.Nil(t, line.Error)
+
+	line, isError = buildBatchOutputLine(&model.BatchRequest{
+		Id:         8,
+		CustomId:   "upstream",
+		Status:     model.BatchRequestStatusFailed,
+		StatusCode: 500,
+		Response:   "not json",
+	})
+	assert.True(t, isError)
+	require.NotNil(t, line.Response)
+	assert.JSONEq(t, `"not json"`, string(line.Response.Body))
+
+	line, isError = buildBatchOutputLine(&model.BatchRequest{
+		Id:       9,
+		CustomId: "cancelled",
+		Status:   model.BatchRequestStatusFailed,
+		Error:    NewBatchRequestError(batchErrorCodeCancelled, "the batch was cancelled"),
+	})
+	assert.True(t, isError)
+	assert.Nil(t, line.Response)
+	require.NotNil(t, line.Error)
+	assert.Equal(t, batchErrorCodeCancelled, line.Error.Code)
+}
+
+func TestFinalizeBatchDiscardsFilesWhenStatusChanged(t *testing.T) {
+	require.NoError(t, model.DB.AutoMigrate(&model.Batch{}, &model.BatchRequest{}, &model.UserFile{}))
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM batches")
+		model.DB.Exec("DELETE FROM batch_requests")
+		model.DB.Exec("DELETE FROM user_files")
+	})
+	fileSetting := operation_setting.GetFileSetting()
+	originalPath := fileSetting.StoragePath
+	fileSetting.StoragePath = t.TempDir()
+	t.Cleanup(func() { fileSetting.StoragePath = originalPath })
+
+	batch := &model.Batch{BatchId: "batch_race", UserId: 1, Status: model.BatchStatusFinalizing}
+	require.NoError(t, model.DB.Create(batch).Error)
+	require.NoError(t, model.DB.Create(&model.BatchRequest{
+		BatchId:    batch.BatchId,
+		CustomId:   "a",
+		Status:     model.BatchRequestStatusCompleted,
+		StatusCode: 200,
+		Response:   `{"id":"x"}`,
+	}).Error)
+	// 另一轮已完成收尾，本轮的状态更新不会生效
+	require.NoError(t, model.DB.Model(&model.Batch{}).Where("batch_id = ?", batch.BatchId).Update("status", model.BatchStatusCompleted).Error)
+
+	require.NoError(t, finalizeBatch(batch, model.BatchStatusCompleted))
+	var count int64
+	require.NoError(t, model.DB.Model(&model.UserFile{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestFinalizeBatchWritesResultFiles(t *testing.T) {
+	require.NoError(t, model.DB.AutoMigrate(&model.Batch{}, &model.BatchRequest{}, &model.UserFile{}))
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM batches")
+		model.DB.Exec("DELETE FROM batch_requests")
+		model.DB.Exec("DELETE FROM user_files")
+	})
+	fileSetting := operation_setting.GetFileSetting()
+	originalPath := fileSetting.StoragePath
+	fileSetting.StoragePath = t.TempDir()
+	t.Cleanup(func() { fileSetting.StoragePath = originalPath })
+
+	batch := &model.Batch{BatchId: "batch_files", UserId: 1, Status: model.BatchStatusFinalizing}
+	require.NoError(t, model.DB.Create(batch).Error)
+	// 超过一页的结果分页写入
+	for i := 0; i < batchFinalizePageSize+1; i++ {
+		require.NoError(t, model.DB.Create(&model.BatchRequest{
+			BatchId:    batch.BatchId,
+			LineIndex:  i,
+			CustomId:   fmt.Sprintf("ok-%d", i),
+			Status:     model.BatchRequestStatusCompleted,
+			StatusCode: 200,
+			Response:   `{"id":"x"}`,
+		}).Error)
+	}
+
+	require.NoError(t, finalizeBatch(batch, model.BatchStatusCompleted))
+	var saved model.Batch
+	require.NoError(t, model.DB.Where("batch_id = ?", batch.BatchId).First(&saved).Error)
+	assert.Equal(t, model.BatchStatusCompleted, saved.Status)
+	assert.Empty(t, saved.ErrorFileId)
+	require.NotEmpty(t, saved.OutputFileId)
+
+	file, apiErr := GetOwnedUserFile(1, saved.OutputFileId)
+	require.Nil(t, apiErr)
+	data, apiErr := ReadUserFileBytes(context.Background(), file, 1<<20)
+	require.Nil(t, apiErr)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, batchFinalizePageSize+1)
+	assert.Equal(t, "ok-0", gjson.Get(lines[0], "custom_id").String())
+	assert.Equal(t, int64(len(data)), file.Bytes)
+
+	// 没有失败请求时不生成 error 文件
+	var count int64
+	require.NoError(t, model.DB.Model(&model.UserFile{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	fileStorageBackendUpstream = "upstream"
+	// unlimitedUserFileBytes 单文件大小不限制时的兜底上限（1TB）
+	unlimitedUserFileBytes int64 = 1 << 40
+)
+
+// UserFileToOpenAI 转换为 OpenAI files 对象
+func UserFileToOpenAI(file *model.UserFile) dto.OpenAIFile {
+	return dto.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		ExpiresAt: file.ExpiresAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+}
+
+func newFileError(err error, code types.ErrorCode, statusCode int) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(err, code, statusCode, types.ErrOptionWithSkipRetry())
+}
+
+// GetOwnedUserFile 获取属于该用户的文件，不存在时返回 404 错误
+func GetOwnedUserFile(userId int, fileId string) (*model.UserFile, *types.NewAPIError) {
+	file, err := model.GetUserFileByFileId(userId, fileId)
+	if err != nil {
+		return nil, newFileError(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError)
+	}
+	if file == nil {
+		return nil, newFileError(fmt.Errorf("no such file: %s", fileId), types.ErrorCodeFileNotFound, http.StatusNotFound)
+	}
+	return file, nil
+}
+
+// CheckFilesAPIEnabled Files API 关闭时拒绝上传和读取，删除仍然允许以便用户清理
+func CheckFilesAPIEnabled() *types.NewAPIError {
+	if !operation_setting.GetFileSetting().Enabled {
+		return newFileError(errors.New("files API is disabled"), types.ErrorCodeAccessDenied, http.StatusForbidden)
+	}
+	return nil
+}
+
+// CreateUserFile 保存上传的文件并记录归属，用途命中透传配置时存放到固定上游渠道
+func CreateUserFile(ctx context.Context, userId int, header *multipart.FileHeader, purpose string) (*model.UserFile, *types.NewAPIError) {
+	fileSetting := operation_setting.GetFileSetting()
+	if apiErr := CheckFilesAPIEnabled(); apiErr != nil {
+		return nil, apiErr
+	}
+	if header == nil {
+		return nil, newFileError(errors.New("file is required"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	if purpose == "" {
+		return nil, newFileError(errors.New("purpose is required"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	maxBytes := int64(fileSetting.MaxFileSizeMB) << 20
+	if maxBytes <= 0 {
+		maxBytes = unlimitedUserFileBytes
+	}
+	if header.Size > maxBytes {
+		return nil, newFileError(fmt.Errorf("file size exceeds maximum allowed size: %dMB", fileSetting.MaxFileSizeMB), types.ErrorCodeFileQuotaExceeded, http.StatusRequestEntityTooLarge)
+	}
+	if apiErr := checkUserFileQuota(userId, header.Size); apiErr != nil {
+		return nil, apiErr
+	}
+
+	fileId, err := model.GenerateUserFileId()
+	if err != nil {
+		return nil, newFileError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+	}
+	userFile := &model.UserFile{
+		FileId:   fileId,
+		UserId:   userId,
+		Purpose:  purpose,
+		Filename: filepath.Base(header.Filename),
+		MimeType: detectUploadMimeType(header),
+		Bytes:    header.Size,
+		Status:   model.UserFileStatusProcessed,
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return nil, newFileError(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest)
+	}
+	defer src.Close()
+
+	if operation_setting.ShouldStoreFileUpstream(purpose) {
+		channel, err := model.CacheGetChannel(fileSetting.UpstreamChannelId)
+		if err != nil {
+			return nil, newFileError(fmt.Errorf("files upstream channel is unavailable: %w", err), types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable)
+		}
+		upstreamFile, apiErr := uploadFileToUpstream(ctx, channel, userFile.Filename, purpose, src)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		userFile.StorageBackend = fileStorageBackendUpstream
+		userFile.ChannelId = channel.Id
+		userFile.UpstreamFileId = upstreamFile.Id
+		if upstreamFile.Bytes > 0 {
+			userFile.Bytes = upstreamFile.Bytes
+		}
+		if upstreamFile.Status != "" {
+			userFile.Status = upstreamFile.Status
+		}
+	} else {
+		storage, err := GetFileStorage("")
+		if err != nil {
+			return nil, newFileError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+		}
+		written, err := storage.Save(fileId, src, maxBytes)
+		if err != nil {
+			return nil, newFileError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+		}
+		userFile.StorageBackend = storage.Name()
+		userFile.StorageKey = fileId
+		userFile.Bytes = written
+	}
+
+	if err := userFile.Insert(); err != nil {
+		removeUserFileContent(ctx, userFile)
+		return nil, newFileError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+	}
+	return userFile, nil
+}
+
+// SaveGeneratedUserFile 保存系统生成的文件（例如批处理结果），内容从 reader 流式写入存储，不计入用户上传配额
+func SaveGeneratedUserFile(userId int, filename string, purpose string, mimeType string, reader io.Reader) (*model.UserFile, error) {
+	fileId, err := model.GenerateUserFileId()
+	if err != nil {
+		return nil, err
+	}
+	storage, err := GetFileStorage("")
+	if err != nil {
+		return nil, err
+	}
+	written, err := storage.Save(fileId, reader, unlimitedUserFileBytes)
+	if err != nil {
+		return nil, err
+	}
+	userFile := &model.UserFile{
+		FileId:         fileId,
+		UserId:         userId,
+		Purpose:        purpose,
+		Filename:       filename,
+		MimeType:       mimeType,
+		Bytes:          written,
+		Status:         model.UserFileStatusProcessed,
+		StorageBackend: storage.Name(),
+		StorageKey:     fileId,
+	}
+	if err := userFile.Insert(); err != nil {
+		_ = storage.Delete(fileId)
+		return nil, err
+	}
+	return userFile, nil
+}
+
+func checkUserFileQuota(userId int, incomingBytes int64) *types.NewAPIError {
+	fileSetting := operation_setting.GetFileSetting()
+	if fileSetting.MaxUserFiles <= 0 && fileSetting.MaxUserStorageMB <= 0 {
+		return nil
+	}
+	count, totalBytes, err := model.GetUserFileUsage(userId)
+	if err != nil {
+		return newFileError(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError)
+	}
+	if fileSetting.MaxUserFiles > 0 && count >= int64(fileSetting.MaxUserFiles) {
+		return newFileError(fmt.Errorf("file count limit reached: %d", fileSetting.MaxUserFiles), types.ErrorCodeFileQuotaExceeded, http.StatusTooManyRequests)
+	}
+	if fileSetting.MaxUserStorageMB > 0 && totalBytes+incomingBytes > int64(fileSetting.MaxUserStorageMB)<<20 {
+		return newFileError(fmt.Errorf("file storage limit reached: %dMB", fileSetting.MaxUserStorageMB), types.ErrorCodeFileQuotaExceeded, http.StatusTooManyRequests)
+	}
+	return nil
+}
+
+func detectUploadMimeType(header *multipart.FileHeader) string {
+	mimeType := header.Header.Get("Content-Type")
+	if idx := strings.Index(mimeType, ";"); idx != -1 {
+		mimeType = strings.TrimSpace(mimeType[:idx])
+	}
+	if mimeType != "" && mimeType != "application/octet-stream" {
+		return mimeType
+	}
+	if ext := strings.TrimPrefix(filepath.Ext(header.Filename), "."); ext != "" {
+		return GetMimeTypeByExtension(strings.ToLower(ext))
+	}
+	return "application/octet-stream"
+}
+
+// OpenUserFileContent 打开文件内容，上游文件会从固定渠道拉取
+func OpenUserFileContent(ctx context.Context, file *model.UserFile) (io.ReadCloser, *types.NewAPIError) {
+	if file.IsUpstream() {
+		channel, err := model.CacheGetChannel(file.ChannelId)
+		if err != nil {
+			return nil, newFileError(fmt.Errorf("file channel is unavailable: %w", err), types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable)
+		}
+		resp, err := DoOpenAIUpstreamRequest(ctx, channel, OpenAIUpstreamRequest{
+			Method: http.MethodGet,
+			Path:   "/v1/files/" + file.UpstreamFileId + "/content",
+		})
+		if err != nil {
+			return nil, newFileError(err, types.ErrorCodeDoRequestFailed, http.StatusBadGateway)
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer CloseResponseBodyGracefully(resp)
+			return nil, RelayErrorHandler(ctx, resp, false)
+		}
+		return resp.Body, nil
+	}
+	storage, err := GetFileStorage(file.StorageBackend)
+	if err != nil {
+		return nil, newFileError(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError)
+	}
+	reader, err := storage.Open(file.StorageKey)
+	if err != nil {
+		return nil, newFileError(err, types.ErrorCodeFileNotFound, http.StatusNotFound)
+	}
+	return reader, nil
+}
+
+// ReadUserFileBytes 读取用户文件全部内容，超过 maxBytes 时返回错误
+func ReadUserFileBytes(ctx context.Context, file *model.UserFile, maxBytes int64) ([]byte, *types.NewAPIError) {
+	reader, apiErr := OpenUserFileContent(ctx, file)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return nil, newFileError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, newFileError(fmt.Errorf("file size exceeds maximum allowed size: %d bytes", maxBytes), types.ErrorCodeFileQuotaExceeded, http.StatusRequestEntityTooLarge)
+	}
+	return data, nil
+}
+
+// DeleteUserFile 删除文件内容与记录
+func DeleteUserFile(ctx context.Context, file *model.UserFile) *types.NewAPIError {
+	removeUserFileContent(ctx, file)
+	if err := model.DeleteUserFileById(file.Id); err != nil {
+		return newFileError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+	}
+	return nil
+}
+
+// removeUserFileContent 尽力删除存储内容，失败只记录日志，不阻塞记录删除
+func removeUserFileContent(ctx context.Context, file *model.UserFile) {
+	if file.IsUpstream() {
+		channel, err := model.CacheGetChannel(file.ChannelId)
+		if err != nil {
+			common.SysError(fmt.Sprintf("delete upstream file %s: channel #%d unavailable: %v", file.FileId, file.ChannelId, err))
+			return
+		}
+		resp, err := DoOpenAIUpstreamRequest(ctx, channel, OpenAIUpstreamRequest{
+			Method: http.MethodDelete,
+			Path:   "/v1/files/" + file.UpstreamFileId,
+		})
+		if err != nil {
+			common.SysError(fmt.Sprintf("delete upstream file %s failed: %v", file.FileId, err))
+			return
+		}
+		CloseResponseBodyGracefully(resp)
+		return
+	}
+	if file.StorageKey == "" {
+		return
+	}
+	storage, err := GetFileStorage(file.StorageBackend)
+	if err != nil {
+		common.SysError(fmt.Sprintf("delete file %s: %v", file.FileId, err))
+		return
+	}
+	if err := storage.Delete(file.StorageKey); err != nil {
+		common.SysError(fmt.Sprintf("delete file %s failed: %v", file.FileId, err))
+	}
+}
+
+func uploadFileToUpstream(ctx context.Context, channel *model.Channel, filename string, purpose string, src io.Reader) (*dto.OpenAIFile, *types.NewAPIError) {
+	pipeReader, pipeWriter := io.Pipe()
+	writer := multipart.NewWriter(pipeWriter)
+	go func() {
+		err := writer.WriteField("purpose", purpose)
+		if err == nil {
+			var part io.Writer
+			part, err = writer.CreateFormFile("file", filename)
+			if err == nil {
+				_, err = io.Copy(part, src)
+			}
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	resp, err := DoOpenAIUpstreamRequest(ctx, channel, OpenAIUpstreamRequest{
+		Method:      http.MethodPost,
+		Path:        "/v1/files",
+		Body:        pipeReader,
+		ContentType: writer.FormDataContentType(),
+	})
+	if err != nil {
+		pipeReader.CloseWithError(err)
+		return nil, newFileError(err, types.ErrorCodeDoRequestFailed, http.StatusBadGateway)
+	}
+	defer CloseResponseBodyGracefully(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, RelayErrorHandler(ctx, resp, false)
+	}
+	var upstreamFile dto.OpenAIFile
+	if err := common.DecodeJson(resp.Body, &upstreamFile); err != nil {
+		return nil, newFileError(err, types.ErrorCodeBadResponseBody, http.StatusBadGateway)
+	}
+	if upstreamFile.Id == "" {
+		return nil, newFileError(errors.New("upstream returned empty file id"), types.ErrorCodeBadResponseBody, http.StatusBadGateway)
+	}
+	return &upstreamFile, nil
+}
+
+// loadFromUserFile 读取 file_id 对应的本地文件，供 LoadFileSource 使用
+func loadFromUserFile(c *gin.Context, fileId string) (*types.CachedFileData, error) {
+	file, apiErr := GetOwnedUserFile(c.GetInt("id"), fileId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if file.IsUpstream() {
+		return nil, fmt.Errorf("file %s is stored upstream and cannot be inlined", fileId)
+	}
+	data, apiErr := ReadUserFileBytes(c.Request.Context(), file, int64(constant.MaxFileDownloadMB)<<20)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return loadFromBase64(base64.StdEncoding.EncodeToString(data), file.MimeType)
+}
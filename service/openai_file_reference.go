@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveUserFileReferences 把请求中引用的 /v1/files 文件 ID 改写为上游可识别的形式：
+// 本地存储的文件内联为 data URL，存放在上游的文件替换为上游文件 ID。
+// 不属于当前用户的 file_id 保持原样，交由上游自行处理。
+func ResolveUserFileReferences(c *gin.Context, request dto.Request) *types.NewAPIError {
+	userId := c.GetInt("id")
+	if userId == 0 {
+		return nil
+	}
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		return resolveChatFileReferences(c, userId, r)
+	case *dto.OpenAIResponsesRequest:
+		return resolveResponsesFileReferences(c, userId, r)
+	}
+	return nil
+}
+
+func resolveChatFileReferences(c *gin.Context, userId int, request *dto.GeneralOpenAIRequest) *types.NewAPIError {
+	for i := range request.Messages {
+		message := &request.Messages[i]
+		if message.IsStringContent() {
+			continue
+		}
+		contents := message.ParseContent()
+		changed := false
+		for j := range contents {
+			if contents[j].Type != dto.ContentTypeFile {
+				continue
+			}
+			file := contents[j].GetFile()
+			if file == nil || file.FileId == "" || file.FileData != "" {
+				continue
+			}
+			ref, apiErr := resolveUserFileReference(c, userId, file.FileId)
+			if apiErr != nil {
+				return apiErr
+			}
+			if ref == nil {
+				continue
+			}
+			if ref.upstreamId != "" {
+				file.FileId = ref.upstreamId
+			} else {
+				file.FileId = ""
+				file.FileData = ref.dataURL
+				if file.FileName == "" {
+					file.FileName = ref.filename
+				}
+			}
+			contents[j].File = file
+			changed = true
+		}
+		if changed {
+			message.SetMediaContent(contents)
+		}
+	}
+	return nil
+}
+
+func resolveResponsesFileReferences(c *gin.Context, userId int, request *dto.OpenAIResponsesRequest) *types.NewAPIError {
+	if common.GetJsonType(request.Input) != "array" {
+		return nil
+	}
+	var inputs []map[string]any
+	if err := common.Unmarshal(request.Input, &inputs); err != nil {
+		return nil
+	}
+	changed := false
+	for _, input := range inputs {
+		parts, ok := input["content"].([]any)
+		if !ok {
+			continue
+		}
+		for _, partAny := range parts {
+			part, ok := partAny.(map[string]any)
+			if !ok {
+				continue
+			}
+			partType, _ := part["type"].(string)
+			fileId, _ := part["file_id"].(string)
+			if fileId == "" || (partType != "input_file" && partType != "input_image") {
+				continue
+			}
+			ref, apiErr := resolveUserFileReference(c, userId, fileId)
+			if apiErr != nil {
+				return apiErr
+			}
+			if ref == nil {
+				continue
+			}
+			changed = true
+			if ref.upstreamId != "" {
+				part["file_id"] = ref.upstreamId
+				continue
+			}
+			delete(part, "file_id")
+			if partType == "input_image" {
+				part["image_url"] = ref.dataURL
+			} else {
+				part["file_data"] = ref.dataURL
+				if _, ok := part["filename"]; !ok {
+					part["filename"] = ref.filename
+				}
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	data, err := common.Marshal(inputs)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry())
+	}
+	request.Input = data
+	return nil
+}
+
+const ginKeyUpstreamFileChannelPinned = "upstream_file_channel_pinned"
+
+// IsUpstreamFileChannelPinned 请求引用了存放在上游的文件时返回 true，此时不能换渠道
+func IsUpstreamFileChannelPinned(c *gin.Context) bool {
+	return c.GetBool(ginKeyUpstreamFileChannelPinned)
+}
+
+// MarkUpstreamFileChannelPinned 请求已固定到上游文件所在渠道，失败后不再重试其他渠道
+func MarkUpstreamFileChannelPinned(c *gin.Context) {
+	c.Set(ginKeyUpstreamFileChannelPinned, true)
+	c.Set(ginKeyChannelAffinitySkipRetry, true)
+}
+
+// GetUpstreamFileChannel 返回请求引用的上游文件所在渠道，没有引用上游文件时返回 0。
+// 上游文件只存在于上传时的渠道，同一请求引用了不同渠道上的文件时无法满足。
+func GetUpstreamFileChannel(userId int, fileIds []string) (int, error) {
+	channelId := 0
+	for _, fileId := range fileIds {
+		if !strings.HasPrefix(fileId, "file-") {
+			continue
+		}
+		file, err := model.GetUserFileByFileId(userId, fileId)
+		if err != nil {
+			return 0, err
+		}
+		if file == nil || !file.IsUpstream() {
+			continue
+		}
+		if channelId != 0 && channelId != file.ChannelId {
+			return 0, errors.New("the referenced files are stored on different channels and cannot be used in one request")
+		}
+		channelId = file.ChannelId
+	}
+	return channelId, nil
+}
+
+type userFileReference struct {
+	filename   string
+	dataURL    string
+	upstreamId string
+}
+
+// resolveUserFileReference 返回 nil 表示该 ID 不是当前用户上传的文件
+func resolveUserFileReference(c *gin.Context, userId int, fileId string) (*userFileReference, *types.NewAPIError) {
+	if !strings.HasPrefix(fileId, "file-") {
+		return nil, nil
+	}
+	file, err := model.GetUserFileByFileId(userId, fileId)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if file == nil {
+		return nil, nil
+	}
+	if file.IsUpstream() {
+		// 分发时已固定到文件所在渠道，重试也必须留在该渠道
+		MarkUpstreamFileChannelPinned(c)
+		return &userFileReference{filename: file.Filename, upstreamId: file.UpstreamFileId}, nil
+	}
+	source := types.NewFileIDSource(fileId)
+	cachedData, err := LoadFileSource(c, source, "resolve_file_id")
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("failed to load file %s: %w", fileId, err), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	base64Data, err := cachedData.GetBase64Data()
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
+	}
+	mimeType := cachedData.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return &userFileReference{
+		filename: file.Filename,
+		dataURL:  fmt.Sprintf("data:%s;base64,%s", mimeType, base64Data),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveUserFileReferencesPinsUpstreamFileChannel(t *testing.T) {
+	require.NoError(t, model.DB.AutoMigrate(&model.UserFile{}))
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM user_files")
+	})
+	require.NoError(t, model.DB.Create(&model.UserFile{
+		FileId:         "file-upstream",
+		UserId:         1,
+		Filename:       "a.pdf",
+		Status:         "processed",
+		ChannelId:      7,
+		UpstreamFileId: "file-abc",
+	}).Error)
+
+	newRequest := func(channelId int) (*gin.Context, *dto.GeneralOpenAIRequest) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		c.Set("id", 1)
+		common.SetContextKey(c, constant.ContextKeyChannelId, channelId)
+		message := dto.Message{Role: "user"}
+		message.SetMediaContent([]dto.MediaContent{{Type: dto.ContentTypeFile, File: &dto.MessageFile{FileId: "file-upstream"}}})
+		return c, &dto.GeneralOpenAIRequest{Messages: []dto.Message{message}}
+	}
+
+	// 同一渠道：替换为上游文件 ID，并禁止换渠道重试
+	c, request := newRequest(7)
+	require.Nil(t, ResolveUserFileReferences(c, request))
+	assert.Equal(t, "file-abc", request.Messages[0].ParseContent()[0].GetFile().FileId)
+	assert.True(t, IsUpstreamFileChannelPinned(c))
+	assert.True(t, ShouldSkipRetryAfterChannelAffinityFailure(c))
+
+	// 引用同一渠道上的上游文件时固定到该渠道，本地文件与非本用户的 ID 不影响选择
+	require.NoError(t, model.DB.Create(&model.UserFile{
+		FileId:   "file-local",
+		UserId:   1,
+		Filename: "b.txt",
+		Status:   "processed",
+	}).Error)
+	channelId, err := GetUpstreamFileChannel(1, []string{"file-local", "file-upstream", "file-unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, 7, channelId)
+	channelId, err = GetUpstreamFileChannel(1, []string{"file-local"})
+	require.NoError(t, err)
+	assert.Zero(t, channelId)
+
+	// 文件分布在不同渠道时无法满足
+	require.NoError(t, model.DB.Create(&model.UserFile{
+		FileId:         "file-upstream-2",
+		UserId:         1,
+		Filename:       "c.pdf",
+		Status:         "processed",
+		ChannelId:      8,
+		UpstreamFileId: "file-def",
+	}).Error)
+	_, err = GetUpstreamFileChannel(1, []string{"file-upstream", "file-upstream-2"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+const (
+	fineTuningJobsPath      = "/v1/fine_tuning/jobs"
+	fineTuningPollBatchSize = 50
+)
+
+func newFineTuningError(err error, code types.ErrorCode, statusCode int) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(err, code, statusCode, types.ErrOptionWithSkipRetry())
+}
+
+// FineTuningJobToOpenAI 返回上游任务对象，并把文件 ID 改写回用户可见的 /v1/files ID
+func FineTuningJobToOpenAI(job *model.FineTuningJob) map[string]any {
+	data := make(map[string]any)
+	if job.Data != "" {
+		_ = common.UnmarshalJsonStr(job.Data, &data)
+	}
+	data["id"] = job.JobId
+	if _, ok := data["object"]; !ok {
+		data["object"] = "fine_tuning.job"
+	}
+	if _, ok := data["status"]; !ok {
+		data["status"] = job.Status
+	}
+	if _, ok := data["model"]; !ok {
+		data["model"] = job.Model
+	}
+	if job.TrainingFileId != "" {
+		data["training_file"] = job.TrainingFileId
+	}
+	if job.ValidationFileId != "" {
+		data["validation_file"] = job.ValidationFileId
+	}
+	return data
+}
+
+// GetOwnedFineTuningJob 获取属于该用户的微调任务，不存在时返回 404 错误
+func GetOwnedFineTuningJob(userId int, jobId string) (*model.FineTuningJob, *types.NewAPIError) {
+	job, err := model.GetUserFineTuningJob(userId, jobId)
+	if err != nil {
+		return nil, newFineTuningError(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError)
+	}
+	if job == nil {
+		return nil, newFineTuningError(fmt.Errorf("no such fine-tuning job: %s", jobId), types.ErrorCodeFineTuningJobNotFound, http.StatusNotFound)
+	}
+	return job, nil
+}
+
+// resolveFineTuningFile 把用户的 file_id 映射为渠道上的上游文件 ID。
+// 微调文件必须以 fine-tune 用途上传并透传到同一渠道。
+func resolveFineTuningFile(userId int, channel *model.Channel, fileId string) (string, *types.NewAPIError) {
+	file, apiErr := GetOwnedUserFile(userId, fileId)
+	if apiErr != nil {
+		return "", apiErr
+	}
+	if !file.IsUpstream() {
+		return "", newFineTuningError(fmt.Errorf("file %s is stored locally; upload it with purpose \"fine-tune\" so it is forwarded to the fine-tuning channel", fileId), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	if file.ChannelId != channel.Id {
+		return "", newFineTuningError(fmt.Errorf("file %s belongs to a different upstream channel", fileId), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	return file.UpstreamFileId, nil
+}
+
+func getFineTuningChannel(channelId int) (*model.Channel, *types.NewAPIError) {
+	if channelId <= 0 {
+		return nil, newFineTuningError(errors.New("fine-tuning channel is not configured"), types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable)
+	}
+	channel, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		return nil, newFineTuningError(fmt.Errorf("fine-tuning channel is unavailable: %w", err), types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable)
+	}
+	if !IsOpenAIUpstreamChannelType(channel.Type) {
+		return nil, newFineTuningError(fmt.Errorf("channel #%d does not support fine-tuning", channel.Id), types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable)
+	}
+	return channel, nil
+}
+
+// doFineTuningRequest 向任务所在渠道发送请求并解析 JSON 对象响应
+func doFineTuningRequest(ctx context.Context, channel *model.Channel, method string, path string, body []byte) (map[string]any, *types.NewAPIError) {
+	request := OpenAIUpstreamRequest{Method: method, Path: path}
+	if body != nil {
+		request.Body = bytes.NewReader(body)
+		request.ContentType = "application/json"
+	}
+	resp, err := DoOpenAIUpstreamRequest(ctx, channel, request)
+	if err != nil {
+		return nil, newFineTuningError(err, types.ErrorCodeDoRequestFailed, http.StatusBadGateway)
+	}
+	defer CloseResponseBodyGracefully(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, RelayErrorHandler(ctx, resp, false)
+	}
+	data := make(map[string]any)
+	if err := common.DecodeJson(resp.Body, &data); err != nil {
+		return nil, newFineTuningError(err, types.ErrorCodeBadResponseBody, http.StatusBadGateway)
+	}
+	return data, nil
+}
+
+// CreateFineTuningJob 校验文件归属后把创建请求转发到微调渠道，并记录任务归属。
+// tokenModelLimit 为令牌的可用模型限制，nil 表示不限制
+func CreateFineTuningJob(ctx context.Context, userId int, tokenId int, organizationId int, group string, tokenModelLimit map[string]bool, body []byte) (*model.FineTuningJob, *types.NewAPIError) {
+	if !operation_setting.GetFineTuningSetting().Enabled {
+		return nil, newFineTuningError(errors.New("fine-tuning API is disabled"), types.ErrorCodeAccessDenied, http.StatusForbidden)
+	}
+	request := make(map[string]any)
+	if err := common.Unmarshal(body, &request); err != nil {
+		return nil, newFineTuningError(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	baseModel, _ := request["model"].(string)
+	trainingFileId, _ := request["training_file"].(string)
+	validationFileId, _ := request["validation_file"].(string)
+	if baseModel == "" || trainingFileId == "" {
+		return nil, newFineTuningError(errors.New("model and training_file are required"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	if tokenModelLimit != nil && !tokenModelLimit[ratio_setting.FormatMatchingModelName(baseModel)] {
+		return nil, newFineTuningError(fmt.Errorf("this token has no access to model %s", baseModel), types.ErrorCodeAccessDenied, http.StatusForbidden)
+	}
+	// 训练结束后才计费，未定价的模型在此拒绝，避免产生无法计费的训练
+	if !isFineTuningModelPriced(baseModel) {
+		return nil, newFineTuningError(fmt.Errorf("model %s has no fine-tuning price configured", baseModel), types.ErrorCodeModelPriceError, http.StatusBadRequest)
+	}
+
+	// 训练费用在任务完成后才能确定，创建时只要求账户仍有余额；
+	// 组织令牌检查组织状态、余额和成员月度上限
+	if organizationId > 0 {
+		if err := model.ReserveOrganizationQuota(organizationId, userId, 0); err != nil {
+			return nil, newOrganizationFundingError(err)
+		}
+	} else {
+		userQuota, err := model.GetUserQuota(userId, false)
+		if err != nil {
+			return nil, newFineTuningError(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError)
+		}
+		if userQuota <= 0 {
+			return nil, newFineTuningError(errors.New("insufficient user quota"), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden)
+		}
+	}
+
+	channel, apiErr := getFineTuningChannel(operation_setting.GetFineTuningChannelId())
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	group, apiErr = resolveFineTuningGroup(userId, group, baseModel, channel.Id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	upstreamTrainingFileId, apiErr := resolveFineTuningFile(userId, channel, trainingFileId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	request["training_file"] = upstreamTrainingFileId
+	upstreamValidationFileId := ""
+	if validationFileId != "" {
+		upstreamValidationFileId, apiErr = resolveFineTuningFile(userId, channel, validationFileId)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		request["validation_file"] = upstreamValidationFileId
+	}
+
+	upstreamBody, err := common.Marshal(request)
+	if err != nil {
+		return nil, newFineTuningError(err, types.ErrorCodeJsonMarshalFailed, http.StatusInternalServerError)
+	}
+	data, apiErr := doFineTuningRequest(ctx, channel, http.MethodPost, fineTuningJobsPath, upstreamBody)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	jobId, _ := data["id"].(string)
+	if jobId == "" {
+		return nil, newFineTuningError(errors.New("upstream returned empty job id"), types.ErrorCodeBadResponseBody, http.StatusBadGateway)
+	}
+
+	job := &model.FineTuningJob{
+		JobId:                    jobId,
+		UserId:                   userId,
+		TokenId:                  tokenId,
+		OrganizationId:           organizationId,
+		Group:                    group,
+		ChannelId:                channel.Id,
+		Model:                    baseModel,
+		TrainingFileId:           trainingFileId,
+		UpstreamTrainingFileId:   upstreamTrainingFileId,
+		ValidationFileId:         validationFileId,
+		UpstreamValidationFileId: upstreamValidationFileId,
+		Status:                   model.FineTuningJobStatusValidatingFiles,
+	}
+	applyUpstreamFineTuningJob(job, data)
+	if err := job.Insert(); err != nil {
+		// 上游任务已创建但无法记录归属，尽力取消避免产生无人计费的训练
+		if _, cancelErr := doFineTuningRequest(context.Background(), channel, http.MethodPost, fineTuningJobsPath+"/"+jobId+"/cancel", nil); cancelErr != nil {
+			common.SysError(fmt.Sprintf("cancel orphan fine-tuning job %s failed: %v", jobId, cancelErr))
+		}
+		return nil, newFineTuningError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+	}
+	return job, nil
+}
+
+func isFineTuningModelPriced(modelName string) bool {
+	if _, ok := operation_setting.GetFineTuningTrainingPrice(modelName); ok {
+		return true
+	}
+	_, ok, _ := ratio_setting.GetModelRatio(modelName)
+	return ok
+}
+
+// resolveFineTuningGroup 与 Distribute 选渠道时的校验一致：微调渠道需在令牌分组下启用该模型，
+// auto 分组依次尝试用户可用的自动分组，返回实际计费的分组
+func resolveFineTuningGroup(userId int, group string, modelName string, channelId int) (string, *types.NewAPIError) {
+	if group != "auto" {
+		if model.IsChannelEnabledForGroupModel(group, modelName, channelId) {
+			return group, nil
+		}
+	} else {
+		userGroup, err := model.GetUserGroup(userId, false)
+		if err != nil {
+			return "", newFineTuningError(err, types.ErrorCodeQueryDataError, http.StatusInternalServerError)
+		}
+		for _, autoGroup := range GetUserAutoGroup(userGroup) {
+			if model.IsChannelEnabledForGroupModel(autoGroup, modelName, channelId) {
+				return autoGroup, nil
+			}
+		}
+	}
+	return "", newFineTuningError(fmt.Errorf("model %s is not available for fine-tuning in group %s", modelName, group), types.ErrorCodeModelNotFound, http.StatusForbidden)
+}
+
+// applyUpstreamFineTuningJob 用上游任务对象刷新本地记录（不落库）
+func applyUpstreamFineTuningJob(job *model.FineTuningJob, data map[string]any) {
+	if status, ok := data["status"].(string); ok && status != "" {
+		job.Status = status
+	}
+	if fineTunedModel, ok := data["fine_tuned_model"].(string); ok {
+		job.FineTunedModel = fineTunedModel
+	}
+	if trainedTokens, ok := data["trained_tokens"].(float64); ok {
+		job.TrainedTokens = int(trainedTokens)
+	}
+	if finishedAt, ok := data["finished_at"].(float64); ok && finishedAt > 0 {
+		job.FinishTime = int64(finishedAt)
+	}
+	if model.IsFineTuningJobFinished(job.Status) && job.FinishTime == 0 {
+		job.FinishTime = common.GetTimestamp()
+	}
+	if text, err := common.Marshal(data); err == nil {
+		job.Data = string(text)
+	}
+}
+
+// RefreshFineTuningJob 从上游拉取任务最新状态并落库，到达终态时触发计费
+func RefreshFineTuningJob(ctx context.Context, job *model.FineTuningJob) *types.NewAPIError {
+	return updateFineTuningJobFromUpstream(ctx, job, http.MethodGet, fineTuningJobsPath+"/"+job.JobId)
+}
+
+// CancelFineTuningJob 取消上游任务并刷新本地记录
+func CancelFineTuningJob(ctx context.Context, job *model.FineTuningJob) *types.NewAPIError {
+	if model.IsFineTuningJobFinished(job.Status) {
+		return newFineTuningError(fmt.Errorf("cannot cancel fine-tuning job with status %s", job.Status), types.ErrorCodeInvalidRequest, http.StatusConflict)
+	}
+	return updateFineTuningJobFromUpstream(ctx, job, http.MethodPost, fineTuningJobsPath+"/"+job.JobId+"/cancel")
+}
+
+func updateFineTuningJobFromUpstream(ctx context.Context, job *model.FineTuningJob, method string, path string) *types.NewAPIError {
+	channel, apiErr := getFineTuningChannel(job.ChannelId)
+	if apiErr != nil {
+		return apiErr
+	}
+	data, apiErr := doFineTuningRequest(ctx, channel, method, path, nil)
+	if apiErr != nil {
+		return apiErr
+	}
+	oldStatus := job.Status
+	applyUpstreamFineTuningJob(job, data)
+	won, err := job.UpdateWithStatus(oldStatus)
+	if err != nil {
+		return newFineTuningError(err, types.ErrorCodeUpdateDataError, http.StatusInternalServerError)
+	}
+	if won && model.IsFineTuningJobFinished(job.Status) {
+		settleFineTuningJobBilling(ctx, job)
+	}
+	return nil
+}
+
+// ProxyFineTuningJobSubresource 透传 events/checkpoints 等只读子资源，调用方负责关闭响应体
+func ProxyFineTuningJobSubresource(ctx context.Context, job *model.FineTuningJob, subresource string, query url.Values) (*http.Response, *types.NewAPIError) {
+	channel, apiErr := getFineTuningChannel(job.ChannelId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	path := fineTuningJobsPath + "/" + job.JobId + "/" + subresource
+	if encoded := query.Encode(); encoded != "" {
+		path += "?" + encoded
+	}
+	resp, err := DoOpenAIUpstreamRequest(ctx, channel, OpenAIUpstreamRequest{Method: http.MethodGet, Path: path})
+	if err != nil {
+		return nil, newFineTuningError(err, types.ErrorCodeDoRequestFailed, http.StatusBadGateway)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer CloseResponseBodyGracefully(resp)
+		return nil, RelayErrorHandler(ctx, resp, false)
+	}
+	return resp, nil
+}
+
+// PollFineTuningJobs 刷新未完成的微调任务，并为已完成但未计费的任务补计费。
+// 由异步任务轮询循环调用，返回本轮处理的任务数。
+func PollFineTuningJobs(ctx context.Context) int {
+	interval := int64(operation_setting.GetFineTuningSetting().PollIntervalSeconds)
+	jobs, err := model.GetFineTuningJobsToPoll(common.GetTimestamp()-interval, fineTuningPollBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("query fine-tuning jobs to poll failed: %v", err))
+		return 0
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		if model.IsFineTuningJobFinished(job.Status) {
+			settleFineTuningJobBilling(ctx, job)
+			continue
+		}
+		if apiErr := RefreshFineTuningJob(ctx, job); apiErr != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("poll fine-tuning job %s failed: %s", job.JobId, apiErr.Error()))
+		}
+	}
+	return len(jobs)
+}
+
+// calculateFineTuningQuota 按训练价格（美元/1M token）计费，未配置训练价格时回退到模型倍率
+func calculateFineTuningQuota(job *model.FineTuningJob) (int, map[string]any, *common.QuotaClamp) {
+	groupRatio := ratio_setting.GetGroupRatio(job.Group)
+	other := map[string]any{
+		"fine_tuning_job_id": job.JobId,
+		"trained_tokens":     job.TrainedTokens,
+		"group_ratio":        groupRatio,
+	}
+	if job.TrainedTokens <= 0 {
+		return 0, other, nil
+	}
+	if price, ok := operation_setting.GetFineTuningTrainingPrice(job.Model); ok {
+		other["training_price"] = price
+		quota, clamp := common.QuotaFromFloatChecked(float64(job.TrainedTokens) / 1000000 * price * common.QuotaPerUnit * groupRatio)
+		return quota, other, clamp
+	}
+	modelRatio, ok, _ := ratio_setting.GetModelRatio(job.Model)
+	if !ok {
+		return 0, other, nil
+	}
+	other["model_ratio"] = modelRatio
+	quota, clamp := common.QuotaFromFloatChecked(float64(job.TrainedTokens) * modelRatio * groupRatio)
+	return quota, other, clamp
+}
+
+// settleFineTuningJobBilling 任务到达终态后按训练 token 扣费，ChargeAndMarkBilled 保证只扣一次
+func settleFineTuningJobBilling(ctx context.Context, job *model.FineTuningJob) {
+	quota, other, clamp := calculateFineTuningQuota(job)
+	won, err := job.ChargeAndMarkBilled(quota)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("fine-tuning job %s charge failed, will retry: %v", job.JobId, err))
+		return
+	}
+	if !won || quota <= 0 {
+		if won && job.TrainedTokens > 0 {
+			logger.LogWarn(ctx, fmt.Sprintf("fine-tuning job %s trained %d tokens but model %s has no training price", job.JobId, job.TrainedTokens, job.Model))
+		}
+		return
+	}
+
+	if job.TokenId > 0 {
+		if tokenKey := resolveTokenKey(ctx, job.TokenId, job.JobId); tokenKey != "" {
+			if err := model.DecreaseTokenQuota(job.TokenId, tokenKey, quota); err != nil {
+				logger.LogWarn(ctx, fmt.Sprintf("fine-tuning job %s charge token quota failed: %v", job.JobId, err))
+			} else {
+				adjustTokenBudgetUsage(job.TokenId, quota)
+			}
+		}
+	}
+	model.UpdateUserUsedQuotaAndRequestCount(job.UserId, quota)
+	model.UpdateChannelUsedQuota(job.ChannelId, quota)
+
+	attachQuotaSaturationToOther(other, clamp)
+	model.RecordTaskBillingLog(model.RecordTaskBillingLogParams{
+		UserId:    job.UserId,
+		LogType:   model.LogTypeConsume,
+		Content:   fmt.Sprintf("微调任务 %s 完成，训练 %d tokens", job.JobId, job.TrainedTokens),
+		ChannelId: job.ChannelId,
+		ModelName: job.Model,
+		Quota:     quota,
+		TokenId:   job.TokenId,
+		Group:     job.Group,
+		Other:     other,
+	})
+}
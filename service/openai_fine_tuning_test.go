@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFineTuningJobToOpenAIRewritesFileIds(t *testing.T) {
+	job := &model.FineTuningJob{
+		JobId:            "ftjob-abc",
+		Model:            "gpt-4o-mini-2024-07-18",
+		Status:           model.FineTuningJobStatusRunning,
+		TrainingFileId:   "file-local-train",
+		ValidationFileId: "file-local-valid",
+	}
+	applyUpstreamFineTuningJob(job, map[string]any{
+		"id":              "ftjob-abc",
+		"object":          "fine_tuning.job",
+		"status":          "succeeded",
+		"training_file":   "file-upstream-train",
+		"validation_file": "file-upstream-valid",
+		"trained_tokens":  float64(1200),
+		"finished_at":     float64(1700000000),
+	})
+	assert.Equal(t, model.FineTuningJobStatusSucceeded, job.Status)
+	assert.Equal(t, 1200, job.TrainedTokens)
+	assert.Equal(t, int64(1700000000), job.FinishTime)
+
+	data := FineTuningJobToOpenAI(job)
+	assert.Equal(t, "file-local-train", data["training_file"])
+	assert.Equal(t, "file-local-valid", data["validation_file"])
+	assert.Equal(t, "succeeded", data["status"])
+}
+
+func TestCalculateFineTuningQuotaUsesTrainingPrice(t *testing.T) {
+	job := &model.FineTuningJob{
+		JobId:         "ftjob-abc",
+		Model:         "gpt-4o-mini-2024-07-18",
+		Group:         "default",
+		TrainedTokens: 2000000,
+	}
+	quota, other, clamp := calculateFineTuningQuota(job)
+	require.Nil(t, clamp)
+	// 默认训练价格 3 美元 / 1M token
+	assert.Equal(t, int(2*3*common.QuotaPerUnit), quota)
+	assert.Equal(t, float64(3), other["training_price"])
+
+	job.TrainedTokens = 0
+	quota, _, _ = calculateFineTuningQuota(job)
+	assert.Equal(t, 0, quota)
+}
+
+func TestCreateFineTuningJobChecksModelAccessAndPricing(t *testing.T) {
+	setting := operation_setting.GetFineTuningSetting()
+	originalEnabled := setting.Enabled
+	setting.Enabled = true
+	t.Cleanup(func() { setting.Enabled = originalEnabled })
+
+	body := []byte(`{"model":"gpt-4o-mini-2024-07-18","training_file":"file-abc"}`)
+	_, apiErr := CreateFineTuningJob(context.Background(), 1, 1, 0, "default", map[string]bool{"gpt-4o": true}, body)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, types.ErrorCodeAccessDenied, apiErr.GetErrorCode())
+
+	body = []byte(`{"model":"unpriced-base-model","training_file":"file-abc"}`)
+	_, apiErr = CreateFineTuningJob(context.Background(), 1, 1, 0, "default", nil, body)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, types.ErrorCodeModelPriceError, apiErr.GetErrorCode())
+}
+
+func TestFineTuningJobWithOrganizationTokenUsesOrganizationFunding(t *testing.T) {
+	require.NoError(t, model.DB.AutoMigrate(&model.FineTuningJob{}))
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM fine_tuning_jobs")
+		model.DB.Exec("DELETE FROM organizations")
+		model.DB.Exec("DELETE FROM organization_members")
+		model.DB.Exec("DELETE FROM users")
+	})
+	setting := operation_setting.GetFineTuningSetting()
+	originalEnabled := setting.Enabled
+	setting.Enabled = true
+	t.Cleanup(func() { setting.Enabled = originalEnabled })
+
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "ft-user", Quota: 1000, Status: common.UserStatusEnabled}).Error)
+	org := &model.Organization{Name: "ft-org", OwnerId: 1}
+	require.NoError(t, model.CreateOrganization(org))
+	require.NoError(t, model.DB.Model(&model.Organization{}).Where("id = ?", org.Id).Update("quota", 100000000).Error)
+	model.DB.Model(&model.OrganizationMember{}).Where("organization_id = ?", org.Id).Update("monthly_quota_limit", 100)
+	model.DB.Model(&model.OrganizationMember{}).Where("organization_id = ?", org.Id).Update("monthly_used_quota", 100)
+
+	// 成员月度上限已用尽时拒绝创建
+	body := []byte(`{"model":"gpt-4o-mini-2024-07-18","training_file":"file-abc"}`)
+	_, apiErr := CreateFineTuningJob(context.Background(), 1, 0, org.Id, "default", nil, body)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+
+	// 结算从组织钱包扣费并计入成员用量，个人钱包不变
+	job := &model.FineTuningJob{
+		JobId:          "ftjob-org",
+		UserId:         1,
+		OrganizationId: org.Id,
+		Group:          "default",
+		Model:          "gpt-4o-mini-2024-07-18",
+		Status:         model.FineTuningJobStatusSucceeded,
+		TrainedTokens:  1000000,
+	}
+	require.NoError(t, model.DB.Create(job).Error)
+	settleFineTuningJobBilling(context.Background(), job)
+
+	quota := int(3 * common.QuotaPerUnit)
+	var savedOrg model.Organization
+	require.NoError(t, model.DB.First(&savedOrg, org.Id).Error)
+	assert.Equal(t, 100000000-quota, savedOrg.Quota)
+	var member model.OrganizationMember
+	require.NoError(t, model.DB.Where("organization_id = ? AND user_id = ?", org.Id, 1).First(&member).Error)
+	assert.Equal(t, 100+quota, member.MonthlyUsedQuota)
+	userQuota, err := model.GetUserQuota(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, userQuota)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func newResponsesError(err error, code types.ErrorCode, statusCode int) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(err, code, statusCode, types.ErrOptionWithSkipRetry())
+}
+
+// IsResponsesUpstreamChannelType 判断渠道是否支持 response 检索、删除、取消等接口
+func IsResponsesUpstreamChannelType(channelType int) bool {
+	return IsOpenAIUpstreamChannelType(channelType) || channelType == constant.ChannelTypeCodex
+}
+
+// ResponseUpstream 生成某个 response 的渠道与 key
+type ResponseUpstream struct {
+	ResponseId string
+	Channel    *model.Channel
+	Key        string
+}
+
+// GetOwnedResponseUpstream 获取属于该用户的 response 所在渠道，渠道已不可用时同样返回 404
+func GetOwnedResponseUpstream(userId int, responseId string) (*ResponseUpstream, *types.NewAPIError) {
+	notFound := newResponsesError(fmt.Errorf("no response found with id '%s'", responseId), types.ErrorCodeResponseNotFound, http.StatusNotFound)
+	affinity, found := GetResponseAffinity(userId, responseId)
+	if !found {
+		return nil, notFound
+	}
+	channel, err := model.CacheGetChannel(affinity.ChannelId)
+	if err != nil || channel == nil || channel.Status != common.ChannelStatusEnabled {
+		return nil, notFound
+	}
+	if !IsResponsesUpstreamChannelType(channel.Type) {
+		return nil, newResponsesError(fmt.Errorf("channel #%d (type %d) does not support this API", channel.Id, channel.Type), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	upstream := &ResponseUpstream{ResponseId: responseId, Channel: channel}
+	if affinity.KeyIndex >= 0 {
+		key, enabled := channel.GetEnabledKeyByIndex(affinity.KeyIndex)
+		if !enabled {
+			return nil, notFound
+		}
+		upstream.Key = key
+	} else {
+		upstream.Key = channel.Key
+	}
+	return upstream, nil
+}
+
+// buildResponsesUpstreamRequest 构造 /v1/responses/{id}[/subresource] 在渠道上的请求，
+// OpenAI/Azure 渠道与其他管理类接口共用地址与鉴权规则
+func buildResponsesUpstreamRequest(ctx context.Context, upstream *ResponseUpstream, method string, subresource string, query url.Values) (*http.Request, error) {
+	path := "/v1/responses/" + url.PathEscape(upstream.ResponseId)
+	if subresource != "" {
+		path += "/" + subresource
+	}
+	if encoded := query.Encode(); encoded != "" {
+		path += "?" + encoded
+	}
+	channel := upstream.Channel
+	if channel.Type != constant.ChannelTypeCodex {
+		return NewOpenAIUpstreamRequest(ctx, channel, OpenAIUpstreamRequest{Method: method, Path: path, Key: upstream.Key})
+	}
+
+	// Codex 渠道使用 ChatGPT 后端地址与 OAuth 凭据
+	req, err := http.NewRequestWithContext(ctx, method, BuildOpenAIUpstreamURL(channel, "/backend-api/codex"+strings.TrimPrefix(path, "/v1")), nil)
+	if err != nil {
+		return nil, err
+	}
+	oauthKey, err := parseCodexOAuthKey(upstream.Key)
+	if err != nil {
+		return nil, err
+	}
+	setCodexWhamRequestHeaders(req, strings.TrimSpace(oauthKey.AccessToken), strings.TrimSpace(oauthKey.AccountID))
+	return req, nil
+}
+
+func cloneURLValues(values url.Values) url.Values {
+	cloned := make(url.Values, len(values))
+	for key, value := range values {
+		cloned[key] = append([]string(nil), value...)
+	}
+	return cloned
+}
+
+// ProxyResponseRequest 将 response 检索、删除、取消等请求转发到生成该 response 的渠道，
+// 上游返回非 2xx 时转换为错误，调用方负责关闭响应体
+func ProxyResponseRequest(ctx context.Context, upstream *ResponseUpstream, method string, subresource string, query url.Values) (*http.Response, *types.NewAPIError) {
+	req, err := buildResponsesUpstreamRequest(ctx, upstream, method, subresource, query)
+	if err != nil {
+		return nil, newResponsesError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	client, err := GetHttpClientWithProxy(upstream.Channel.GetSetting().Proxy)
+	if err != nil {
+		return nil, newResponsesError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newResponsesError(err, types.ErrorCodeDoRequestFailed, http.StatusBadGateway)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer CloseResponseBodyGracefully(resp)
+		return nil, RelayErrorHandler(ctx, resp, false)
+	}
+	return resp, nil
+}
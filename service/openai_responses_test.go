@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildResponsesUpstreamRequest(t *testing.T) {
+	t.Parallel()
+
+	openaiBase := "https://api.openai.com"
+	req, err := buildResponsesUpstreamRequest(context.Background(), &ResponseUpstream{
+		ResponseId: "resp_1",
+		Channel:    &model.Channel{Type: constant.ChannelTypeOpenAI, BaseURL: &openaiBase},
+		Key:        "sk-test",
+	}, http.MethodGet, "input_items", url.Values{"limit": {"5"}})
+	require.NoError(t, err)
+	require.Equal(t, "https://api.openai.com/v1/responses/resp_1/input_items?limit=5", req.URL.String())
+	require.Equal(t, "Bearer sk-test", req.Header.Get("Authorization"))
+
+	azureBase := "https://demo.openai.azure.com"
+	req, err = buildResponsesUpstreamRequest(context.Background(), &ResponseUpstream{
+		ResponseId: "resp_2",
+		Channel:    &model.Channel{Type: constant.ChannelTypeAzure, BaseURL: &azureBase},
+		Key:        "azure-key",
+	}, http.MethodPost, "cancel", nil)
+	require.NoError(t, err)
+	require.Equal(t, "https://demo.openai.azure.com/openai/v1/responses/resp_2/cancel?api-version=preview", req.URL.String())
+	require.Equal(t, "azure-key", req.Header.Get("api-key"))
+
+	codexBase := "https://chatgpt.com"
+	req, err = buildResponsesUpstreamRequest(context.Background(), &ResponseUpstream{
+		ResponseId: "resp_3",
+		Channel:    &model.Channel{Type: constant.ChannelTypeCodex, BaseURL: &codexBase},
+		Key:        `{"access_token":"at","account_id":"acc"}`,
+	}, http.MethodDelete, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, "https://chatgpt.com/backend-api/codex/responses/resp_3", req.URL.String())
+	require.Equal(t, "Bearer at", req.Header.Get("Authorization"))
+	require.Equal(t, "acc", req.Header.Get("chatgpt-account-id"))
+}
+
+func TestGetOwnedResponseUpstreamNotFound(t *testing.T) {
+	t.Parallel()
+
+	_, apiErr := GetOwnedResponseUpstream(1, "resp_missing")
+	require.NotNil(t, apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+)
+
+// OpenAIUpstreamRequest 描述一次直接发往 OpenAI/Azure 渠道的管理类请求
+// （files、fine_tuning 等不经过 relay adaptor 的接口）。
+// Path 使用 OpenAI 形式，例如 /v1/files/file-abc；Azure 渠道会自动改写。
+type OpenAIUpstreamRequest struct {
+	Method      string
+	Path        string
+	Body        io.Reader
+	ContentType string
+	// Key 指定使用的渠道 key，为空时按渠道多 key 策略选择
+	Key string
+}
+
+// IsOpenAIUpstreamChannelType 判断渠道是否支持直接代理 OpenAI 管理接口
+func IsOpenAIUpstreamChannelType(channelType int) bool {
+	switch channelType {
+	case constant.ChannelTypeOpenAI, constant.ChannelTypeAzure:
+		return true
+	}
+	return false
+}
+
+// BuildOpenAIUpstreamURL 计算渠道上的完整请求地址
+func BuildOpenAIUpstreamURL(channel *model.Channel, path string) string {
+	baseURL := strings.TrimSuffix(channel.GetBaseURL(), "/")
+	if baseURL == "" && channel.Type < len(constant.ChannelBaseURLs) {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	if channel.Type != constant.ChannelTypeAzure {
+		return baseURL + path
+	}
+	apiVersion := channel.Other
+	if apiVersion == "" {
+		apiVersion = constant.AzureDefaultAPIVersion
+	}
+	subPath := "/openai/" + strings.TrimPrefix(path, "/v1/")
+	// responses 接口与转发 POST /v1/responses 时的地址规则保持一致
+	if strings.HasPrefix(path, "/v1/responses") {
+		if !strings.Contains(baseURL, "cognitiveservices.azure.com") {
+			subPath = "/openai" + path
+			apiVersion = "preview"
+		}
+		if version := channel.GetOtherSettings().AzureResponsesVersion; version != "" {
+			apiVersion = version
+		}
+	}
+	separator := "?"
+	if strings.Contains(subPath, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s%sapi-version=%s", baseURL, subPath, separator, apiVersion)
+}
+
+// DoOpenAIUpstreamRequest 向 OpenAI/Azure 渠道发送管理类请求，调用方负责关闭响应体
+func DoOpenAIUpstreamRequest(ctx context.Context, channel *model.Channel, request OpenAIUpstreamRequest) (*http.Response, error) {
+	req, err := NewOpenAIUpstreamRequest(ctx, channel, request)
+	if err != nil {
+		return nil, err
+	}
+	client, err := GetHttpClientWithProxy(channel.GetSetting().Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// NewOpenAIUpstreamRequest 构造发往 OpenAI/Azure 渠道的管理类请求，设置地址与鉴权头
+func NewOpenAIUpstreamRequest(ctx context.Context, channel *model.Channel, request OpenAIUpstreamRequest) (*http.Request, error) {
+	if channel == nil {
+		return nil, errors.New("channel is nil")
+	}
+	if !IsOpenAIUpstreamChannelType(channel.Type) {
+		return nil, fmt.Errorf("channel #%d (type %d) does not support this API", channel.Id, channel.Type)
+	}
+	key := request.Key
+	if key == "" {
+		nextKey, _, apiErr := channel.GetNextEnabledKey()
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		key = nextKey
+	}
+
+	req, err := http.NewRequestWithContext(ctx, request.Method, BuildOpenAIUpstreamURL(channel, request.Path), request.Body)
+	if err != nil {
+		return nil, err
+	}
+	if request.ContentType != "" {
+		req.Header.Set("Content-Type", request.ContentType)
+	}
+	if channel.Type == constant.ChannelTypeAzure {
+		req.Header.Set("api-key", key)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+key)
+		if channel.OpenAIOrganization != nil && *channel.OpenAIOrganization != "" {
+			req.Header.Set("OpenAI-Organization", *channel.OpenAIOrganization)
+		}
+	}
+	return req, nil
+}
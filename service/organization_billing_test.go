@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedOrganization(t *testing.T, id int, ownerId int, quota int) {
+	t.Helper()
+	require.NoError(t, model.DB.Create(&model.Organization{
+		Id:      id,
+		Name:    "test_org",
+		OwnerId: ownerId,
+		Status:  model.OrganizationStatusEnabled,
+		Quota:   quota,
+	}).Error)
+}
+
+func seedOrganizationMember(t *testing.T, orgId int, userId int, monthlyLimit int) {
+	t.Helper()
+	require.NoError(t, model.DB.Create(&model.OrganizationMember{
+		OrganizationId:    orgId,
+		UserId:            userId,
+		Role:              model.OrganizationRoleMember,
+		MonthlyQuotaLimit: monthlyLimit,
+	}).Error)
+}
+
+func getOrganization(t *testing.T, id int) model.Organization {
+	t.Helper()
+	var org model.Organization
+	require.NoError(t, model.DB.Where("id = ?", id).First(&org).Error)
+	return org
+}
+
+func getMemberMonthlyUsed(t *testing.T, orgId int, userId int) int {
+	t.Helper()
+	var member model.OrganizationMember
+	require.NoError(t, model.DB.Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error)
+	return member.MonthlyUsedQuota
+}
+
+// newOrganizationRelayInfo 跳过令牌额度预扣（测试库未初始化 key 列名），只验证资金来源
+func newOrganizationRelayInfo(userId int, orgId int) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		UserId:         userId,
+		OrganizationId: orgId,
+		IsPlayground:   true,
+		RequestId:      "req_org_" + time.Now().Format("150405.000000"),
+	}
+}
+
+func newBillingTestContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	return c
+}
+
+func TestOrganizationTokenBillsOrganizationWallet(t *testing.T) {
+	truncate(t)
+	const userID, orgID = 41, 1
+	seedUser(t, userID, 5000)
+	seedOrganization(t, orgID, userID, 8000)
+	seedOrganizationMember(t, orgID, userID, 0)
+
+	info := newOrganizationRelayInfo(userID, orgID)
+	session, apiErr := NewBillingSession(newBillingTestContext(), info, 1000)
+	require.Nil(t, apiErr)
+	assert.Equal(t, BillingSourceOrganization, info.BillingSource)
+
+	// 预扣来自组织钱包，个人钱包不变
+	assert.Equal(t, 7000, getOrganization(t, orgID).Quota)
+	assert.Equal(t, 5000, getUserQuota(t, userID))
+	assert.Equal(t, 1000, getMemberMonthlyUsed(t, orgID, userID))
+
+	require.NoError(t, session.Settle(1500))
+	org := getOrganization(t, orgID)
+	assert.Equal(t, 6500, org.Quota)
+	assert.Equal(t, 1500, org.UsedQuota)
+	assert.Equal(t, 1500, getMemberMonthlyUsed(t, orgID, userID))
+	assert.Equal(t, 5000, getUserQuota(t, userID))
+}
+
+func TestOrganizationMemberMonthlyLimit(t *testing.T) {
+	truncate(t)
+	const userID, orgID = 42, 2
+	seedUser(t, userID, 5000)
+	seedOrganization(t, orgID, userID, 8000)
+	seedOrganizationMember(t, orgID, userID, 1200)
+
+	_, apiErr := NewBillingSession(newBillingTestContext(), newOrganizationRelayInfo(userID, orgID), 1000)
+	require.Nil(t, apiErr)
+
+	_, apiErr = NewBillingSession(newBillingTestContext(), newOrganizationRelayInfo(userID, orgID), 500)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+
+	// 超限时组织钱包只扣了第一次
+	assert.Equal(t, 7000, getOrganization(t, orgID).Quota)
+	assert.Equal(t, 1000, getMemberMonthlyUsed(t, orgID, userID))
+}
+
+func TestOrganizationBillingRejectsNonMemberAndEmptyWallet(t *testing.T) {
+	truncate(t)
+	const userID, orgID = 43, 3
+	seedUser(t, userID, 5000)
+	seedOrganization(t, orgID, userID, 0)
+
+	_, apiErr := NewBillingSession(newBillingTestContext(), newOrganizationRelayInfo(userID, orgID), 100)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	seedOrganizationMember(t, orgID, userID, 0)
+	_, apiErr = NewBillingSession(newBillingTestContext(), newOrganizationRelayInfo(userID, orgID), 100)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	// 不回退到个人钱包
+	assert.Equal(t, 5000, getUserQuota(t, userID))
+}
+
+func TestRefundTaskQuota_Organization(t *testing.T) {
+	truncate(t)
+	const userID, tokenID, channelID, orgID = 44, 44, 44, 4
+	const preConsumed = 2000
+	seedUser(t, userID, 5000)
+	seedToken(t, tokenID, userID, "sk-org-task", 8000)
+	seedChannel(t, channelID)
+	seedOrganization(t, orgID, userID, 6000)
+	seedOrganizationMember(t, orgID, userID, 0)
+	require.NoError(t, model.ReserveOrganizationQuota(orgID, userID, preConsumed))
+
+	task := makeTask(userID, channelID, preConsumed, tokenID, BillingSourceOrganization, 0)
+	task.PrivateData.OrganizationId = orgID
+	RefundTaskQuota(context.Background(), task, "organization task failed")
+
+	assert.Equal(t, 6000, getOrganization(t, orgID).Quota)
+	assert.Equal(t, 0, getMemberMonthlyUsed(t, orgID, userID))
+	assert.Equal(t, 5000, getUserQuota(t, userID))
+	assert.Equal(t, 8000+preConsumed, getTokenRemainQuota(t, tokenID))
+}
+
+func TestMidjourneyOrganizationBilling(t *testing.T) {
+	truncate(t)
+	const userID, orgID = 45, 5
+	seedUser(t, userID, 5000)
+	seedOrganization(t, orgID, userID, 8000)
+	seedOrganizationMember(t, orgID, userID, 1200)
+
+	// 提交前从组织钱包预扣，成员月度上限生效
+	info := newOrganizationRelayInfo(userID, orgID)
+	require.Nil(t, PreConsumeMidjourneyQuota(newBillingTestContext(), info, 1000))
+	require.NotNil(t, info.Billing)
+	assert.Equal(t, 7000, getOrganization(t, orgID).Quota)
+	assert.NotNil(t, PreConsumeMidjourneyQuota(newBillingTestContext(), newOrganizationRelayInfo(userID, orgID), 500))
+
+	// 任务失败退回组织钱包，个人钱包不变
+	require.NoError(t, RefundMidjourneyTaskQuota(&model.Midjourney{UserId: userID, OrganizationId: orgID, Quota: 1000}))
+	assert.Equal(t, 8000, getOrganization(t, orgID).Quota)
+	assert.Equal(t, 0, getMemberMonthlyUsed(t, orgID, userID))
+	assert.Equal(t, 5000, getUserQuota(t, userID))
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// PIITypeSecret 凭据类信息（API Key、Token 等），检测规则与泄露防护相同
+const PIITypeSecret = "secret"
+
+var (
+	piiRedactionTypes = []string{
+		operation_setting.PIITypeEmail,
+		operation_setting.PIITypePhone,
+		operation_setting.PIITypeIdNumber,
+		operation_setting.PIITypeCreditCard,
+		PIITypeSecret,
+	}
+	piiPlaceholderPattern = regexp.MustCompile(`\[(?:EMAIL|PHONE|ID_NUMBER|CREDIT_CARD|SECRET)_\d+\]`)
+	// 流式输出末尾可能是被拆开的占位符，暂存到下一个分片
+	piiPlaceholderPrefix = regexp.MustCompile(`^\[[A-Z_]*\d*$`)
+)
+
+const piiPlaceholderMaxLen = len("[CREDIT_CARD_999999]")
+
+// PIIRedactor 单次上游请求的脱敏映射。同一原文在请求内使用同一个占位符，
+// 上游输出中的占位符按 key（如 choice index）分别还原，跨分片的占位符同样可以还原
+type PIIRedactor struct {
+	types        []string
+	restore      bool
+	placeholders map[string]string
+	originals    map[string]string
+	counts       map[string]int
+	pending      map[string]string
+}
+
+func newPIIRedactor(types []string, restore bool) *PIIRedactor {
+	if len(types) == 0 {
+		types = piiRedactionTypes
+	}
+	return &PIIRedactor{
+		types:        types,
+		restore:      restore,
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counts:       make(map[string]int),
+		pending:      make(map[string]string),
+	}
+}
+
+func (r *PIIRedactor) placeholder(piiType string, original string) string {
+	if placeholder, ok := r.placeholders[original]; ok {
+		return placeholder
+	}
+	r.counts[piiType]++
+	placeholder := fmt.Sprintf("[%s_%d]", strings.ToUpper(piiType), r.counts[piiType])
+	r.placeholders[original] = placeholder
+	r.originals[placeholder] = original
+	return placeholder
+}
+
+// Redact 将文本中的个人信息替换为占位符
+func (r *PIIRedactor) Redact(text string) string {
+	type piiMatch struct {
+		start, end int
+		piiType    string
+	}
+	matches := make([]piiMatch, 0)
+	for _, piiType := range r.types {
+		if piiType == PIITypeSecret {
+			for _, secret := range findLeakSecrets(text) {
+				for offset := 0; ; {
+					index := strings.Index(text[offset:], secret)
+					if index < 0 {
+						break
+					}
+					matches = append(matches, piiMatch{start: offset + index, end: offset + index + len(secret), piiType: piiType})
+					offset += index + len(secret)
+				}
+			}
+			continue
+		}
+		detector, ok := piiDetectors[piiType]
+		if !ok {
+			continue
+		}
+		for _, loc := range detector.pattern.FindAllStringIndex(text, -1) {
+			if detector.validate != nil && !detector.validate(text[loc[0]:loc[1]]) {
+				continue
+			}
+			matches = append(matches, piiMatch{start: loc[0], end: loc[1], piiType: piiType})
+		}
+	}
+	if len(matches) == 0 {
+		return text
+	}
+	// 重叠时保留先出现且更长的匹配
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+	var builder strings.Builder
+	builder.Grow(len(text))
+	lastPos := 0
+	for _, match := range matches {
+		if match.start < lastPos {
+			continue
+		}
+		builder.WriteString(text[lastPos:match.start])
+		builder.WriteString(r.placeholder(match.piiType, text[match.start:match.end]))
+		lastPos = match.end
+	}
+	builder.WriteString(text[lastPos:])
+	return builder.String()
+}
+
+// Restore 将文本中的占位符还原为原文
+func (r *PIIRedactor) Restore(text string) string {
+	if len(r.originals) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	return piiPlaceholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if original, ok := r.originals[placeholder]; ok {
+			return original
+		}
+		return placeholder
+	})
+}
+
+// restoreJSON 还原 JSON 原文中的占位符，原文按 JSON 字符串转义
+func (r *PIIRedactor) restoreJSON(data string) string {
+	if len(r.originals) == 0 || !strings.Contains(data, "[") {
+		return data
+	}
+	return piiPlaceholderPattern.ReplaceAllStringFunc(data, func(placeholder string) string {
+		original, ok := r.originals[placeholder]
+		if !ok {
+			return placeholder
+		}
+		escaped, err := common.Marshal(original)
+		if err != nil {
+			return placeholder
+		}
+		return string(escaped[1 : len(escaped)-1])
+	})
+}
+
+// restoreDelta 还原一段增量输出，末尾疑似不完整的占位符暂存到下一次，final 时全部输出
+func (r *PIIRedactor) restoreDelta(key string, delta string, final bool) string {
+	text := r.pending[key] + delta
+	hold := ""
+	if !final {
+		if index := strings.LastIndexByte(text, '['); index >= 0 && len(text)-index < piiPlaceholderMaxLen &&
+			piiPlaceholderPrefix.MatchString(text[index:]) {
+			text, hold = text[:index], text[index:]
+		}
+	}
+	if hold == "" {
+		delete(r.pending, key)
+	} else {
+		r.pending[key] = hold
+	}
+	return r.Restore(text)
+}
+
+func (r *PIIRedactor) restoreJSONDelta(data string, key string, path string, final bool) string {
+	result := gjson.Get(data, path)
+	if result.Type != gjson.String && !(final && r.pending[key] != "") {
+		return data
+	}
+	text := r.restoreDelta(key, result.Str, final)
+	if text == result.Str {
+		return data
+	}
+	return setJSONValue(data, path, text)
+}
+
+// getPIIRedactor 返回当前上游请求的脱敏映射，未开启或无需还原时返回 nil
+func getPIIRedactor(c *gin.Context) *PIIRedactor {
+	if c == nil {
+		return nil
+	}
+	redactor, ok := common.GetContextKeyType[*PIIRedactor](c, constant.ContextKeyPIIRedactor)
+	if !ok || redactor == nil || !redactor.restore || len(redactor.originals) == 0 {
+		return nil
+	}
+	return redactor
+}
+
+// RedactRequestPII 按渠道配置将请求体中用户文本里的个人信息替换为占位符，应在参数覆盖之后、
+// 发送到上游之前调用。每次上游请求重新生成映射，重试切换到未开启脱敏的渠道时不会误还原
+func RedactRequestPII(c *gin.Context, info *relaycommon.RelayInfo, jsonData []byte) []byte {
+	settings := info.ChannelOtherSettings.GetPIIRedaction()
+	if settings == nil {
+		common.SetContextKey(c, constant.ContextKeyPIIRedactor, (*PIIRedactor)(nil))
+		return jsonData
+	}
+	redactor := newPIIRedactor(settings.Types, !settings.DisableRestore)
+	common.SetContextKey(c, constant.ContextKeyPIIRedactor, redactor)
+	redacted, changed, err := TransformRequestBodyText(jsonData, redactor.Redact)
+	if err != nil {
+		logger.LogWarn(c, "pii redaction skipped: "+err.Error())
+		return jsonData
+	}
+	if !changed {
+		return jsonData
+	}
+	return redacted
+}
+
+// RestorePIIStreamChunk 还原一条流式输出中的占位符。第二个返回值为需要在该条数据之前补发的
+// 增量事件（Claude、Responses 格式结束内容块时输出暂存的文本），为空表示无需补发
+func RestorePIIStreamChunk(c *gin.Context, data string) (string, string) {
+	r := getPIIRedactor(c)
+	if r == nil {
+		return data, ""
+	}
+	if !strings.HasPrefix(data, "{") {
+		return r.restoreJSON(data), ""
+	}
+	switch eventType := gjson.Get(data, "type").String(); {
+	case gjson.Get(data, "choices").Exists():
+		count := int(gjson.Get(data, "choices.#").Int())
+		for i := 0; i < count; i++ {
+			key := "choice:" + gjson.Get(data, fmt.Sprintf("choices.%d.index", i)).String()
+			final := gjson.Get(data, fmt.Sprintf("choices.%d.finish_reason", i)).String() != ""
+			path := fmt.Sprintf("choices.%d.delta.content", i)
+			if textPath := fmt.Sprintf("choices.%d.text", i); gjson.Get(data, textPath).Exists() {
+				path = textPath
+			}
+			data = r.restoreJSONDelta(data, key, path, final)
+		}
+		return data, ""
+	case gjson.Get(data, "candidates").Exists():
+		count := int(gjson.Get(data, "candidates.#").Int())
+		for i := 0; i < count; i++ {
+			key := fmt.Sprintf("candidate:%d", gjson.Get(data, fmt.Sprintf("candidates.%d.index", i)).Int())
+			final := gjson.Get(data, fmt.Sprintf("candidates.%d.finishReason", i)).String() != ""
+			parts := int(gjson.Get(data, fmt.Sprintf("candidates.%d.content.parts.#", i)).Int())
+			for j := 0; j < parts; j++ {
+				data = r.restoreJSONDelta(data, key, fmt.Sprintf("candidates.%d.content.parts.%d.text", i, j), final && j == parts-1)
+			}
+		}
+		return data, ""
+	case eventType == "content_block_delta" && gjson.Get(data, "delta.type").String() == "text_delta":
+		return r.restoreJSONDelta(data, "content:"+gjson.Get(data, "index").String(), "delta.text", false), ""
+	case eventType == "content_block_stop":
+		key := "content:" + gjson.Get(data, "index").String()
+		if r.pending[key] == "" {
+			return data, ""
+		}
+		flush := fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, gjson.Get(data, "index").Int())
+		return data, setJSONValue(flush, "delta.text", r.restoreDelta(key, "", true))
+	case eventType == "response.output_text.delta":
+		key := gjson.Get(data, "item_id").String() + ":" + gjson.Get(data, "content_index").String()
+		return r.restoreJSONDelta(data, key, "delta", false), ""
+	case eventType == "response.output_text.done":
+		key := gjson.Get(data, "item_id").String() + ":" + gjson.Get(data, "content_index").String()
+		data = r.restoreJSON(data)
+		if r.pending[key] == "" {
+			return data, ""
+		}
+		flush := setJSONValue(`{"type":"response.output_text.delta"}`, "item_id", gjson.Get(data, "item_id").String())
+		flush = setJSONValue(flush, "output_index", gjson.Get(data, "output_index").Int())
+		flush = setJSONValue(flush, "content_index", gjson.Get(data, "content_index").Int())
+		return data, setJSONValue(flush, "delta", r.restoreDelta(key, "", true))
+	}
+	return r.restoreJSON(data), ""
+}
+
+// RestorePIIBody 还原非流式响应体中的占位符
+func RestorePIIBody(c *gin.Context, body []byte) []byte {
+	r := getPIIRedactor(c)
+	if r == nil || len(body) == 0 {
+		return body
+	}
+	return []byte(r.restoreJSON(string(body)))
+}
+
+// appendPIIRedactionLogInfo 在消费日志中记录各类型脱敏数量，不记录原文
+func appendPIIRedactionLogInfo(ctx *gin.Context, other map[string]interface{}) {
+	redactor, ok := common.GetContextKeyType[*PIIRedactor](ctx, constant.ContextKeyPIIRedactor)
+	if !ok || redactor == nil || len(redactor.counts) == 0 {
+		return
+	}
+	other["pii_redaction"] = redactor.counts
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func newPIIRedactionTestContext(types ...string) (*gin.Context, *relaycommon.RelayInfo) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{
+		ChannelOtherSettings: dto.ChannelOtherSettings{PIIRedaction: &dto.PIIRedactionSettings{Enabled: true, Types: types}},
+	}}
+	return c, info
+}
+
+func TestRedactRequestPIIUsesStablePlaceholders(t *testing.T) {
+	c, info := newPIIRedactionTestContext(operation_setting.PIITypeEmail, operation_setting.PIITypePhone)
+	body := `{"model":"gpt-4o","messages":[{"role":"system","content":"contact alice@example.com"},` +
+		`{"role":"user","content":[{"type":"text","text":"mail alice@example.com or bob@example.com, call 13812345678"}]}]}`
+
+	redacted := string(RedactRequestPII(c, info, []byte(body)))
+	assert.Equal(t, "contact [EMAIL_1]", gjson.Get(redacted, "messages.0.content").String())
+	assert.Equal(t, "mail [EMAIL_1] or [EMAIL_2], call [PHONE_1]", gjson.Get(redacted, "messages.1.content.0.text").String())
+	assert.Equal(t, "gpt-4o", gjson.Get(redacted, "model").String())
+
+	restored := string(RestorePIIBody(c, []byte(`{"choices":[{"message":{"content":"sent to [EMAIL_2] and [EMAIL_9]"}}]}`)))
+	assert.Equal(t, "sent to bob@example.com and [EMAIL_9]", gjson.Get(restored, "choices.0.message.content").String())
+
+	other := map[string]interface{}{}
+	appendPIIRedactionLogInfo(c, other)
+	assert.Equal(t, map[string]int{"email": 2, "phone": 1}, other["pii_redaction"])
+}
+
+func TestRedactRequestPIIDisabledChannel(t *testing.T) {
+	c, info := newPIIRedactionTestContext()
+	RedactRequestPII(c, info, []byte(`{"messages":[{"role":"user","content":"alice@example.com"}]}`))
+
+	// 重试切换到未开启脱敏的渠道后不再还原
+	info.ChannelOtherSettings.PIIRedaction = nil
+	body := `{"messages":[{"role":"user","content":"alice@example.com"}]}`
+	assert.Equal(t, body, string(RedactRequestPII(c, info, []byte(body))))
+	assert.Equal(t, `{"text":"[EMAIL_1]"}`, string(RestorePIIBody(c, []byte(`{"text":"[EMAIL_1]"}`))))
+}
+
+func TestRestorePIIStreamChunkAcrossChunks(t *testing.T) {
+	c, info := newPIIRedactionTestContext(operation_setting.PIITypeEmail)
+	RedactRequestPII(c, info, []byte(`{"messages":[{"role":"user","content":"reply to alice@example.com"}]}`))
+
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"content":"ok [EM"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"AIL_1] done ["}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	}
+	text := ""
+	for _, chunk := range chunks {
+		data, flush := RestorePIIStreamChunk(c, chunk)
+		assert.Empty(t, flush)
+		require.True(t, gjson.Valid(data))
+		text += gjson.Get(data, "choices.0.delta.content").String()
+	}
+	assert.Equal(t, "ok alice@example.com done [", text)
+
+	// Claude 内容块结束时补发暂存文本
+	data, flush := RestorePIIStreamChunk(c, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"see [EMAIL"}}`)
+	assert.Equal(t, "see ", gjson.Get(data, "delta.text").String())
+	assert.Empty(t, flush)
+	_, flush = RestorePIIStreamChunk(c, `{"type":"content_block_stop","index":0}`)
+	assert.Equal(t, "[EMAIL", gjson.Get(flush, "delta.text").String())
+	assert.Equal(t, "content_block_delta", gjson.Get(flush, "type").String())
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -14,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/pkg/billingexpr"
 	perfmetrics "github.com/QuantumNous/new-api/pkg/perf_metrics"
+	prommetrics "github.com/QuantumNous/new-api/pkg/prom_metrics"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -381,6 +383,8 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	})
 	gopool.Go(func() {
 		perfmetrics.RecordRelaySample(relayInfo, true, int64(usage.CompletionTokens))
+		prommetrics.RecordRelay(relayInfo, true)
+		prommetrics.RecordBilling(relayInfo, quota, usage.PromptTokens, usage.CompletionTokens)
 		if err := RecordChannelRequestSuccessForContext(ctx, relayInfo.ChannelId); err != nil {
 			logger.LogError(ctx, "record channel request success: "+err.Error())
 		}
@@ -404,17 +408,51 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if !relayInfo.TokenUnlimited && token.RemainQuota < quota {
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
+	// 令牌 RPM 已在 TokenAuth 入口计入，这里只补充未经 ReserveTPM 预占的 TPM
+	if token.TpmLimit > 0 && !relayInfo.TokenTPMReserved && !relayInfo.TokenRateLimitChecked {
+		if err := CheckTokenRateLimit(context.Background(), token.Id, 0, token.TpmLimit, relayInfo.GetEstimatePromptTokens()); err != nil {
+			return err
+		}
+		relayInfo.TokenRateLimitChecked = true
+	}
+	if token.HasQuotaBudget() {
+		if err := model.ReserveTokenBudget(token.Id, quota); err != nil {
+			return err
+		}
+		relayInfo.TokenQuotaBudgeted = true
+	}
 	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
 	if err != nil {
+		if relayInfo.TokenQuotaBudgeted {
+			adjustTokenBudgetUsage(relayInfo.TokenId, -quota)
+		}
 		return err
 	}
 	return nil
 }
 
+// adjustTokenBudgetUsage 令牌额度变动后同步周期预算用量
+func adjustTokenBudgetUsage(tokenId int, delta int) {
+	if err := model.AdjustTokenBudgetUsage(tokenId, delta); err != nil {
+		common.SysLog(fmt.Sprintf("error adjusting token budget usage (tokenId=%d, delta=%d): %s", tokenId, delta, err.Error()))
+	}
+}
+
+// IsTokenQuotaLimitError 是否为令牌周期预算或 RPM/TPM 超限错误，这类错误应返回 429
+func IsTokenQuotaLimitError(err error) bool {
+	var budgetErr *model.TokenBudgetExceededError
+	var rateErr *RateLimitExceededError
+	return errors.As(err, &budgetErr) || errors.As(err, &rateErr)
+}
+
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
 
-	// 1) Consume from wallet quota OR subscription item
-	if relayInfo != nil && relayInfo.BillingSource == BillingSourceSubscription {
+	// 1) Consume from wallet quota, subscription item OR organization wallet
+	if relayInfo != nil && relayInfo.OrganizationId > 0 {
+		if err := model.AdjustOrganizationUsage(relayInfo.OrganizationId, relayInfo.UserId, quota); err != nil {
+			return err
+		}
+	} else if relayInfo != nil && relayInfo.BillingSource == BillingSourceSubscription {
 		if relayInfo.SubscriptionId == 0 {
 			return errors.New("subscription id is missing")
 		}
@@ -446,9 +484,12 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		if err != nil {
 			return err
 		}
+		if relayInfo.TokenQuotaBudgeted {
+			adjustTokenBudgetUsage(relayInfo.TokenId, quota)
+		}
 	}
 
-	if sendEmail {
+	if sendEmail && relayInfo.OrganizationId == 0 {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const rateWindowDuration = time.Minute
+
+// RateLimitExceededError 滑动窗口限流被拒绝，Scope 为限流对象（如 token、user），Kind 为 RPM 或 TPM
+type RateLimitExceededError struct {
+	Scope      string
+	Kind       string
+	Limit      int64
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitExceededError) Error() string {
+	retryAfter := e.RetryAfter.Round(time.Second)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return fmt.Sprintf("%s %s limit exceeded: limit %d per minute, retry after %s", e.Scope, e.Kind, e.Limit, retryAfter)
+}
+
+// rateWindowRequest 一次限流检查中的一个维度：key 的一分钟窗口内累计权重不超过 limit
+type rateWindowRequest struct {
+	key    string
+	scope  string
+	kind   string
+	limit  int64
+	weight int64
+}
+
+func (r rateWindowRequest) exceeded(retryAfter time.Duration) *RateLimitExceededError {
+	return &RateLimitExceededError{Scope: r.scope, Kind: r.kind, Limit: r.limit, RetryAfter: retryAfter}
+}
+
+type rateWindowSample struct {
+	at     time.Time
+	member string
+	weight int64
+}
+
+// weightedRateWindow 一分钟滑动窗口限流，每个样本带权重（RPM 权重为 1，TPM 为 token 数）。
+// 多个维度一次性检查，全部通过才写入样本；写入后可按 member 修正权重。Redis 开启时跨节点共享。
+type weightedRateWindow struct {
+	mu        sync.Mutex
+	local     map[string][]rateWindowSample
+	serial    atomic.Uint64
+	sweepOnce sync.Once
+}
+
+var globalRateWindow = &weightedRateWindow{local: make(map[string][]rateWindowSample)}
+
+func (w *weightedRateWindow) newMember(now time.Time) string {
+	return fmt.Sprintf("%d-%d", now.UnixNano(), w.serial.Add(1))
+}
+
+// acquire 返回写入样本的 member、被拒绝的维度下标（-1 表示通过）以及需要等待的时间
+func (w *weightedRateWindow) acquire(ctx context.Context, requests []rateWindowRequest) (string, int, time.Duration, error) {
+	if len(requests) == 0 {
+		return "", -1, 0, nil
+	}
+	now := time.Now()
+	member := w.newMember(now)
+	if common.RedisEnabled {
+		index, retryAfter, err := w.redisAcquire(ctx, requests, member, now)
+		return member, index, retryAfter, err
+	}
+	index, retryAfter := w.localAcquire(requests, member, now)
+	return member, index, retryAfter, nil
+}
+
+// adjust 将已写入样本的权重由 oldWeight 改为 newWeight，样本已过期时忽略
+func (w *weightedRateWindow) adjust(ctx context.Context, keys []string, member string, oldWeight, newWeight int64) error {
+	if len(keys) == 0 || member == "" || oldWeight == newWeight {
+		return nil
+	}
+	if common.RedisEnabled {
+		return w.redisAdjust(ctx, keys, member, oldWeight, newWeight)
+	}
+	w.localAdjust(keys, member, newWeight)
+	return nil
+}
+
+// rateWindowRetryAfter 计算最早的若干样本过期、腾出足够额度所需的时间
+func rateWindowRetryAfter(samples []rateWindowSample, used, limit, weight int64, now time.Time) time.Duration {
+	freed := int64(0)
+	at := now
+	for _, sample := range samples {
+		freed += sample.weight
+		at = sample.at
+		if used-freed+weight <= limit {
+			break
+		}
+	}
+	return at.Add(rateWindowDuration).Sub(now)
+}
+
+// localAcquire 单个请求的权重超过上限时，窗口为空即可放行，避免永远无法请求
+func (w *weightedRateWindow) localAcquire(requests []rateWindowRequest, member string, now time.Time) (int, time.Duration) {
+	w.sweepOnce.Do(func() {
+		go w.sweepLocal()
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cutoff := now.Add(-rateWindowDuration)
+	for i, request := range requests {
+		samples := w.local[request.key]
+		firstValid := 0
+		for firstValid < len(samples) && !samples[firstValid].at.After(cutoff) {
+			firstValid++
+		}
+		samples = samples[firstValid:]
+		if len(samples) == 0 {
+			delete(w.local, request.key)
+		} else {
+			w.local[request.key] = samples
+		}
+		used := int64(0)
+		for _, sample := range samples {
+			used += sample.weight
+		}
+		if used > 0 && used+request.weight > request.limit {
+			return i, rateWindowRetryAfter(samples, used, request.limit, request.weight, now)
+		}
+	}
+	for _, request := range requests {
+		w.local[request.key] = append(w.local[request.key], rateWindowSample{at: now, member: member, weight: request.weight})
+	}
+	return -1, 0
+}
+
+func (w *weightedRateWindow) localAdjust(keys []string, member string, weight int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, key := range keys {
+		samples := w.local[key]
+		for i := range samples {
+			if samples[i].member == member {
+				samples[i].weight = weight
+				break
+			}
+		}
+	}
+}
+
+// sweepLocal 定期清理已无有效样本的 key，避免不再请求的令牌、用户长期占用内存
+func (w *weightedRateWindow) sweepLocal() {
+	for {
+		time.Sleep(rateWindowDuration)
+		w.pruneLocal(time.Now())
+	}
+}
+
+func (w *weightedRateWindow) pruneLocal(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cutoff := now.Add(-rateWindowDuration)
+	for key, samples := range w.local {
+		if len(samples) == 0 || !samples[len(samples)-1].at.After(cutoff) {
+			delete(w.local, key)
+		}
+	}
+}
+
+func rateWindowRedisKey(key string) string {
+	return "new-api:rate-window:v1:" + key
+}
+
+func rateWindowRedisKeys(keys []string) []string {
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = rateWindowRedisKey(key)
+	}
+	return redisKeys
+}
+
+// 样本成员格式为 "权重:member"，分数为毫秒时间戳
+const rateWindowAcquireLua = `
+local cutoff = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local member = ARGV[3]
+for i = 1, #KEYS do
+  redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', cutoff)
+  local limit = tonumber(ARGV[2 + i * 2])
+  local weight = tonumber(ARGV[3 + i * 2])
+  local entries = redis.call('ZRANGE', KEYS[i], 0, -1, 'WITHSCORES')
+  local used = 0
+  for j = 1, #entries, 2 do
+    used = used + tonumber(string.match(entries[j], '^(%d+):'))
+  end
+  if used > 0 and used + weight > limit then
+    local freed = 0
+    local at = now
+    for j = 1, #entries, 2 do
+      freed = freed + tonumber(string.match(entries[j], '^(%d+):'))
+      at = tonumber(entries[j + 1])
+      if used - freed + weight <= limit then break end
+    end
+    return {i, at}
+  end
+end
+for i = 1, #KEYS do
+  redis.call('ZADD', KEYS[i], now, ARGV[3 + i * 2] .. ':' .. member)
+  redis.call('EXPIRE', KEYS[i], 120)
+end
+return {0, 0}`
+
+const rateWindowAdjustLua = `
+for i = 1, #KEYS do
+  local score = redis.call('ZSCORE', KEYS[i], ARGV[1])
+  if score then
+    redis.call('ZREM', KEYS[i], ARGV[1])
+    redis.call('ZADD', KEYS[i], score, ARGV[2])
+  end
+end
+return 1`
+
+func (w *weightedRateWindow) redisAcquire(ctx context.Context, requests []rateWindowRequest, member string, now time.Time) (int, time.Duration, error) {
+	keys := make([]string, len(requests))
+	args := []interface{}{now.Add(-rateWindowDuration).UnixMilli(), now.UnixMilli(), member}
+	for i, request := range requests {
+		keys[i] = rateWindowRedisKey(request.key)
+		args = append(args, request.limit, request.weight)
+	}
+	result, err := common.RDB.Eval(ctx, rateWindowAcquireLua, keys, args...).Int64Slice()
+	if err != nil {
+		return -1, 0, err
+	}
+	if len(result) != 2 || result[0] == 0 {
+		return -1, 0, nil
+	}
+	retryAfter := time.UnixMilli(result[1]).Add(rateWindowDuration).Sub(now)
+	return int(result[0]) - 1, retryAfter, nil
+}
+
+func (w *weightedRateWindow) redisAdjust(ctx context.Context, keys []string, member string, oldWeight, newWeight int64) error {
+	_, err := common.RDB.Eval(ctx, rateWindowAdjustLua, rateWindowRedisKeys(keys),
+		fmt.Sprintf("%d:%s", oldWeight, member), fmt.Sprintf("%d:%s", newWeight, member)).Result()
+	return err
+}
+
+func rateWindowKey(kind string, scope string, id string) string {
+	return strings.ToLower(kind) + ":" + scope + ":" + id
+}
@@ -0,0 +1,325 @@
+package relayconvert
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ConverterOpenAIAudioToGeminiContent = "openai_audio_to_gemini_generate_content"
+	ConverterOpenAIAudioToOpenAIChat    = "openai_audio_to_openai_chat_completions"
+
+	ResponseConverterGeminiChatToOAIAudio = "gemini_chat_to_oai_audio_resp"
+	ResponseConverterOAIChatToOAIAudio    = "oai_chat_to_oai_audio_resp"
+)
+
+// Gemini TTS 预置音色，OpenAI 音色名按近似风格映射，其余原样透传
+var openAIToGeminiVoiceMap = map[string]string{
+	"alloy":   "Kore",
+	"echo":    "Charon",
+	"fable":   "Fenrir",
+	"onyx":    "Orus",
+	"nova":    "Aoede",
+	"shimmer": "Leda",
+}
+
+const geminiDefaultVoice = "Kore"
+
+var audioExtMimeTypes = map[string]string{
+	"mp3":  "audio/mp3",
+	"mpga": "audio/mp3",
+	"mpeg": "audio/mp3",
+	"wav":  "audio/wav",
+	"aiff": "audio/aiff",
+	"aif":  "audio/aiff",
+	"aac":  "audio/aac",
+	"ogg":  "audio/ogg",
+	"oga":  "audio/ogg",
+	"opus": "audio/ogg",
+	"flac": "audio/flac",
+	"m4a":  "audio/mp4",
+	"mp4":  "audio/mp4",
+	"webm": "audio/webm",
+}
+
+// AudioFile 转写请求上传的音频文件
+type AudioFile struct {
+	Filename string
+	Format   string
+	MimeType string
+	Data     []byte
+}
+
+// AudioTranscriptionForm 转写请求的音频与可选参数
+type AudioTranscriptionForm struct {
+	File     *AudioFile
+	Prompt   string
+	Language string
+}
+
+func init() {
+	for _, spec := range []RequestConverterSpec{
+		{
+			ID:      ConverterOpenAIAudioToGeminiContent,
+			From:    types.RelayFormatOpenAIAudio,
+			To:      types.RelayFormatGemini,
+			Quality: RequestConverterQualityFair,
+			Convert: convertOpenAIAudioRequestToGemini,
+		},
+		{
+			ID:      ConverterOpenAIAudioToOpenAIChat,
+			From:    types.RelayFormatOpenAIAudio,
+			To:      types.RelayFormatOpenAI,
+			Quality: RequestConverterQualityFair,
+			Convert: convertOpenAIAudioRequestToOpenAIChat,
+		},
+	} {
+		registerBuiltinRequestConverter(spec)
+	}
+	for _, spec := range []ResponseConverterSpec{
+		{
+			ID:      ResponseConverterGeminiChatToOAIAudio,
+			From:    types.RelayFormatGemini,
+			To:      types.RelayFormatOpenAIAudio,
+			Quality: ResponseConverterQualityFair,
+			Convert: convertGeminiChatResponseToOAIAudio,
+		},
+		{
+			ID:      ResponseConverterOAIChatToOAIAudio,
+			From:    types.RelayFormatOpenAI,
+			To:      types.RelayFormatOpenAIAudio,
+			Quality: ResponseConverterQualityFair,
+			Convert: convertOAIChatResponseToOAIAudio,
+		},
+	} {
+		registerBuiltinResponseConverter(spec)
+	}
+}
+
+// ReadAudioTranscriptionForm 读取 /v1/audio/transcriptions 上传的第一个音频文件及 prompt、language 字段
+func ReadAudioTranscriptionForm(c *gin.Context) (*AudioTranscriptionForm, error) {
+	form, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing multipart form: %w", err)
+	}
+	fileHeaders := form.File["file"]
+	if len(fileHeaders) == 0 {
+		return nil, errors.New("file is required")
+	}
+	fileHeader := fileHeaders[0]
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening audio file: %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audio file: %w", err)
+	}
+
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileHeader.Filename), "."))
+	mimeType, ok := audioExtMimeTypes[format]
+	if !ok {
+		mimeType = fileHeader.Header.Get("Content-Type")
+		if mimeType == "" || mimeType == "application/octet-stream" {
+			mimeType = "audio/mp3"
+		}
+	}
+	result := &AudioTranscriptionForm{
+		File: &AudioFile{
+			Filename: fileHeader.Filename,
+			Format:   format,
+			MimeType: mimeType,
+			Data:     data,
+		},
+	}
+	if values := form.Value["prompt"]; len(values) > 0 {
+		result.Prompt = strings.TrimSpace(values[0])
+	}
+	if values := form.Value["language"]; len(values) > 0 {
+		result.Language = strings.TrimSpace(values[0])
+	}
+	return result, nil
+}
+
+func audioRequestFromAny(request any) (*dto.AudioRequest, error) {
+	audioRequest, ok := request.(*dto.AudioRequest)
+	if !ok {
+		if value, ok := request.(dto.AudioRequest); ok {
+			audioRequest = &value
+		}
+	}
+	if audioRequest == nil {
+		return nil, fmt.Errorf("expected OpenAI audio request, got %T", request)
+	}
+	return audioRequest, nil
+}
+
+func isAudioTranscriptionMode(info *relaycommon.RelayInfo) bool {
+	return info != nil && (info.RelayMode == relayconstant.RelayModeAudioTranscription || info.RelayMode == relayconstant.RelayModeAudioTranslation)
+}
+
+// transcriptionInstruction 生成要求模型只输出转写文本的指令
+func transcriptionInstruction(info *relaycommon.RelayInfo, form *AudioTranscriptionForm) string {
+	var sb strings.Builder
+	if info.RelayMode == relayconstant.RelayModeAudioTranslation {
+		sb.WriteString("Translate the speech in this audio into English. Output only the translated text.")
+	} else {
+		sb.WriteString("Generate a verbatim transcript of the speech in this audio. Output only the transcript text.")
+		if form.Language != "" {
+			sb.WriteString(fmt.Sprintf(" The spoken language is %s.", form.Language))
+		}
+	}
+	if form.Prompt != "" {
+		sb.WriteString("\nContext: ")
+		sb.WriteString(form.Prompt)
+	}
+	return sb.String()
+}
+
+func convertOpenAIAudioRequestToGemini(c *gin.Context, info *relaycommon.RelayInfo, request any) (any, error) {
+	audioRequest, err := audioRequestFromAny(request)
+	if err != nil {
+		return nil, err
+	}
+	if isAudioTranscriptionMode(info) {
+		form, err := ReadAudioTranscriptionForm(c)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.GeminiChatRequest{
+			Contents: []dto.GeminiChatContent{
+				{
+					Role: "user",
+					Parts: []dto.GeminiPart{
+						{Text: transcriptionInstruction(info, form)},
+						{InlineData: &dto.GeminiInlineData{
+							MimeType: form.File.MimeType,
+							Data:     base64.StdEncoding.EncodeToString(form.File.Data),
+						}},
+					},
+				},
+			},
+		}, nil
+	}
+	if info != nil && info.RelayMode != relayconstant.RelayModeAudioSpeech {
+		return nil, fmt.Errorf("unsupported audio relay mode: %d", info.RelayMode)
+	}
+
+	if strings.TrimSpace(audioRequest.Input) == "" {
+		return nil, errors.New("input is required")
+	}
+	voice := audioRequest.Voice
+	if mapped, ok := openAIToGeminiVoiceMap[voice]; ok {
+		voice = mapped
+	} else if voice == "" {
+		voice = geminiDefaultVoice
+	}
+	speechConfig, err := common.Marshal(map[string]any{
+		"voiceConfig": map[string]any{
+			"prebuiltVoiceConfig": map[string]any{
+				"voiceName": voice,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Gemini TTS 通过自然语言控制风格，instructions 作为前缀
+	text := audioRequest.Input
+	if instructions := strings.TrimSpace(audioRequest.Instructions); instructions != "" {
+		text = instructions + ":\n" + text
+	}
+	return &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{
+			{
+				Role:  "user",
+				Parts: []dto.GeminiPart{{Text: text}},
+			},
+		},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"AUDIO"},
+			SpeechConfig:       speechConfig,
+		},
+	}, nil
+}
+
+// convertOpenAIAudioRequestToOpenAIChat 将转写请求转为带 input_audio 的 chat 请求，用于 qwen-asr 等通过对话接口识别的模型
+func convertOpenAIAudioRequestToOpenAIChat(c *gin.Context, info *relaycommon.RelayInfo, request any) (any, error) {
+	audioRequest, err := audioRequestFromAny(request)
+	if err != nil {
+		return nil, err
+	}
+	if !isAudioTranscriptionMode(info) {
+		return nil, errors.New("only audio transcription can be converted to chat completions")
+	}
+	form, err := ReadAudioTranscriptionForm(c)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]dto.Message, 0, 2)
+	if form.Prompt != "" {
+		systemMessage := dto.Message{Role: "system"}
+		systemMessage.SetStringContent(form.Prompt)
+		messages = append(messages, systemMessage)
+	}
+	userMessage := dto.Message{Role: "user"}
+	userMessage.SetMediaContent([]dto.MediaContent{
+		{
+			Type: dto.ContentTypeInputAudio,
+			InputAudio: &dto.MessageInputAudio{
+				Data:   fmt.Sprintf("data:%s;base64,%s", form.File.MimeType, base64.StdEncoding.EncodeToString(form.File.Data)),
+				Format: form.File.Format,
+			},
+		},
+	})
+	messages = append(messages, userMessage)
+	return &dto.GeneralOpenAIRequest{
+		Model:    audioRequest.Model,
+		Messages: messages,
+	}, nil
+}
+
+func convertGeminiChatResponseToOAIAudio(_ *gin.Context, info *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	geminiResponse, err := asGeminiChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	var sb strings.Builder
+	for _, candidate := range geminiResponse.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.Thought {
+				continue
+			}
+			sb.WriteString(part.Text)
+		}
+		break
+	}
+	usage := UsageFromGeminiMetadata(geminiResponse.GetUsageMetadata(), fallbackPromptTokens(info))
+	return &dto.AudioResponse{Text: strings.TrimSpace(sb.String())}, usage, nil
+}
+
+func convertOAIChatResponseToOAIAudio(_ *gin.Context, _ *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	text := ""
+	if len(chatResponse.Choices) > 0 {
+		text = chatResponse.Choices[0].Message.StringContent()
+	}
+	usage := chatResponse.Usage
+	return &dto.AudioResponse{Text: strings.TrimSpace(text)}, &usage, nil
+}
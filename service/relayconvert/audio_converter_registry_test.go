@@ -0,0 +1,118 @@
+package relayconvert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTranscriptionContext(t *testing.T) *gin.Context {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("model", "gemini-2.5-flash"))
+	require.NoError(t, writer.WriteField("language", "zh"))
+	require.NoError(t, writer.WriteField("prompt", "new-api"))
+	part, err := writer.CreateFormFile("file", "speech.mp3")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("fake audio"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c
+}
+
+func TestConvertAudioTranscriptionRequestToGemini(t *testing.T) {
+	c := newTranscriptionContext(t)
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeAudioTranscription}
+
+	result, err := ConvertRequest(c, info, types.RelayFormatGemini, &dto.AudioRequest{Model: "gemini-2.5-flash"})
+	require.NoError(t, err)
+	assert.Equal(t, ConverterOpenAIAudioToGeminiContent, result.Converter)
+
+	geminiRequest, ok := result.Value.(*dto.GeminiChatRequest)
+	require.True(t, ok)
+	require.Len(t, geminiRequest.Contents, 1)
+	parts := geminiRequest.Contents[0].Parts
+	require.Len(t, parts, 2)
+	assert.Contains(t, parts[0].Text, "The spoken language is zh.")
+	assert.Contains(t, parts[0].Text, "Context: new-api")
+	require.NotNil(t, parts[1].InlineData)
+	assert.Equal(t, "audio/mp3", parts[1].InlineData.MimeType)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("fake audio")), parts[1].InlineData.Data)
+}
+
+func TestConvertAudioSpeechRequestToGemini(t *testing.T) {
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeAudioSpeech}
+	request := &dto.AudioRequest{
+		Model:        "gemini-2.5-flash-preview-tts",
+		Input:        "hello",
+		Voice:        "alloy",
+		Instructions: "Say cheerfully",
+	}
+
+	result, err := ConvertRequest(nil, info, types.RelayFormatGemini, request)
+	require.NoError(t, err)
+
+	geminiRequest, ok := result.Value.(*dto.GeminiChatRequest)
+	require.True(t, ok)
+	assert.Equal(t, "Say cheerfully:\nhello", geminiRequest.Contents[0].Parts[0].Text)
+	assert.Equal(t, []string{"AUDIO"}, geminiRequest.GenerationConfig.ResponseModalities)
+	assert.JSONEq(t, `{"voiceConfig":{"prebuiltVoiceConfig":{"voiceName":"Kore"}}}`, string(geminiRequest.GenerationConfig.SpeechConfig))
+}
+
+func TestConvertAudioTranscriptionRequestToOpenAIChat(t *testing.T) {
+	c := newTranscriptionContext(t)
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeAudioTranscription}
+
+	result, err := ConvertRequest(c, info, types.RelayFormatOpenAI, &dto.AudioRequest{Model: "qwen3-asr-flash"})
+	require.NoError(t, err)
+
+	chatRequest, ok := result.Value.(*dto.GeneralOpenAIRequest)
+	require.True(t, ok)
+	require.Len(t, chatRequest.Messages, 2)
+	assert.Equal(t, "new-api", chatRequest.Messages[0].StringContent())
+	content := chatRequest.Messages[1].ParseContent()
+	require.Len(t, content, 1)
+	audio := content[0].GetInputAudio()
+	require.NotNil(t, audio)
+	assert.Equal(t, "mp3", audio.Format)
+	assert.Equal(t, "data:audio/mp3;base64,"+base64.StdEncoding.EncodeToString([]byte("fake audio")), audio.Data)
+
+	_, err = ConvertRequest(nil, &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeAudioSpeech}, types.RelayFormatOpenAI, &dto.AudioRequest{})
+	require.Error(t, err)
+}
+
+func TestConvertResponsesToOpenAIAudio(t *testing.T) {
+	geminiResponse := &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{
+			{Content: dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: " 你好，世界 "}}}},
+		},
+	}
+	result, err := ConvertResponse(nil, nil, types.RelayFormatOpenAIAudio, geminiResponse)
+	require.NoError(t, err)
+	assert.Equal(t, &dto.AudioResponse{Text: "你好，世界"}, result.Value)
+
+	chatResponse := &dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{
+			{Message: dto.Message{Role: "assistant", Content: "hello world"}},
+		},
+	}
+	result, err = ConvertResponse(nil, nil, types.RelayFormatOpenAIAudio, chatResponse)
+	require.NoError(t, err)
+	assert.Equal(t, &dto.AudioResponse{Text: "hello world"}, result.Value)
+}
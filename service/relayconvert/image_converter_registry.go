@@ -0,0 +1,289 @@
+package relayconvert
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	relaymedia "github.com/QuantumNous/new-api/service/relayconvert/internal/media"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ConverterOpenAIImageToGeminiContent = "openai_image_to_gemini_generate_content"
+
+	ResponseConverterGeminiChatToOAIImage = "gemini_chat_to_oai_image_resp"
+)
+
+// ImageVariationPrompt 变体请求没有 prompt，上游需要文本指令时使用
+const ImageVariationPrompt = "Generate a variation of this image that keeps its subject, composition and style."
+
+// ImageInput 编辑、变体请求携带的输入图片，Data 为不带 data: 前缀的 base64
+type ImageInput struct {
+	MimeType string
+	Data     string
+}
+
+func init() {
+	registerBuiltinRequestConverter(RequestConverterSpec{
+		ID:      ConverterOpenAIImageToGeminiContent,
+		From:    types.RelayFormatOpenAIImage,
+		To:      types.RelayFormatGemini,
+		Quality: RequestConverterQualityFair,
+		Convert: convertOpenAIImageRequestToGemini,
+	})
+	registerBuiltinResponseConverter(ResponseConverterSpec{
+		ID:      ResponseConverterGeminiChatToOAIImage,
+		From:    types.RelayFormatGemini,
+		To:      types.RelayFormatOpenAIImage,
+		Quality: ResponseConverterQualityFair,
+		Convert: convertGeminiChatResponseToOAIImage,
+	})
+}
+
+// ImageSizeToAspectRatio 将 OpenAI 的 size 转为宽高比，size 本身为宽高比时原样返回，无法识别时返回空
+func ImageSizeToAspectRatio(size string) string {
+	size = strings.TrimSpace(size)
+	if strings.Contains(size, ":") {
+		return size
+	}
+	switch size {
+	case "256x256", "512x512", "1024x1024":
+		return "1:1"
+	case "1536x1024":
+		return "3:2"
+	case "1024x1536":
+		return "2:3"
+	case "1024x1792":
+		return "9:16"
+	case "1792x1024":
+		return "16:9"
+	}
+	return ""
+}
+
+// IsImageInputMode 编辑与变体请求需要携带输入图片
+func IsImageInputMode(info *relaycommon.RelayInfo) bool {
+	return info != nil && (info.RelayMode == relayconstant.RelayModeImagesEdits || info.RelayMode == relayconstant.RelayModeImagesVariations)
+}
+
+// ReadImageInputs 读取编辑、变体请求的输入图片，multipart 读取 image、image[] 文件字段，
+// JSON 读取 image、images 字段中的 URL 或 base64
+func ReadImageInputs(c *gin.Context, request *dto.ImageRequest) ([]ImageInput, error) {
+	if c != nil && c.Request != nil && strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		return readMultipartImageInputs(c)
+	}
+
+	var sources []string
+	if len(request.Image) > 0 {
+		var image string
+		if err := common.Unmarshal(request.Image, &image); err == nil {
+			sources = append(sources, image)
+		} else {
+			var images []string
+			if err := common.Unmarshal(request.Image, &images); err != nil {
+				return nil, errors.New("image must be a string or an array of strings")
+			}
+			sources = append(sources, images...)
+		}
+	}
+	if len(request.Images) > 0 {
+		var images []string
+		if err := common.Unmarshal(request.Images, &images); err != nil {
+			return nil, errors.New("images must be an array of strings")
+		}
+		sources = append(sources, images...)
+	}
+
+	inputs := make([]ImageInput, 0, len(sources))
+	for _, source := range sources {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		fileSource := types.NewFileSourceFromData(source, "")
+		data, mimeType, err := relaymedia.ResolveBase64Data(c, fileSource, "reading input image")
+		if err != nil {
+			return nil, fmt.Errorf("get image data from '%s' failed: %w", fileSource.GetIdentifier(), err)
+		}
+		inputs = append(inputs, ImageInput{MimeType: mimeType, Data: data})
+	}
+	return inputs, nil
+}
+
+func readMultipartImageInputs(c *gin.Context) ([]ImageInput, error) {
+	form, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse multipart form: %w", err)
+	}
+	var fileHeaders []*multipart.FileHeader
+	for fieldName, files := range form.File {
+		if fieldName == "image" || strings.HasPrefix(fieldName, "image[") {
+			fileHeaders = append(fileHeaders, files...)
+		}
+	}
+
+	inputs := make([]ImageInput, 0, len(fileHeaders))
+	for i, fileHeader := range fileHeaders {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open image file %d: %w", i, err)
+		}
+		data, err := io.ReadAll(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image file %d: %w", i, err)
+		}
+		mimeType := fileHeader.Header.Get("Content-Type")
+		if !strings.HasPrefix(mimeType, "image/") {
+			mimeType = http.DetectContentType(data)
+		}
+		inputs = append(inputs, ImageInput{
+			MimeType: mimeType,
+			Data:     base64.StdEncoding.EncodeToString(data),
+		})
+	}
+	return inputs, nil
+}
+
+func imageRequestFromAny(request any) (*dto.ImageRequest, error) {
+	imageRequest, ok := request.(*dto.ImageRequest)
+	if !ok {
+		if value, ok := request.(dto.ImageRequest); ok {
+			imageRequest = &value
+		}
+	}
+	if imageRequest == nil {
+		return nil, fmt.Errorf("expected OpenAI image request, got %T", request)
+	}
+	return imageRequest, nil
+}
+
+// convertOpenAIImageRequestToGemini 将图片生成、编辑、变体请求转为带图片输出的 generateContent 请求
+func convertOpenAIImageRequestToGemini(c *gin.Context, info *relaycommon.RelayInfo, request any) (any, error) {
+	imageRequest, err := imageRequestFromAny(request)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]dto.GeminiPart, 0, 2)
+	if IsImageInputMode(info) {
+		inputs, err := ReadImageInputs(c, imageRequest)
+		if err != nil {
+			return nil, err
+		}
+		if len(inputs) == 0 {
+			return nil, errors.New("image is required")
+		}
+		for _, input := range inputs {
+			parts = append(parts, dto.GeminiPart{
+				InlineData: &dto.GeminiInlineData{
+					MimeType: input.MimeType,
+					Data:     input.Data,
+				},
+			})
+		}
+	}
+	prompt := strings.TrimSpace(imageRequest.Prompt)
+	if prompt == "" {
+		if info == nil || info.RelayMode != relayconstant.RelayModeImagesVariations {
+			return nil, errors.New("prompt is required")
+		}
+		prompt = ImageVariationPrompt
+	}
+	parts = append(parts, dto.GeminiPart{Text: prompt})
+
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{
+			{
+				Role:  "user",
+				Parts: parts,
+			},
+		},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+		},
+	}
+
+	imageConfig := map[string]any{}
+	if aspectRatio := ImageSizeToAspectRatio(imageRequest.Size); aspectRatio != "" {
+		imageConfig["aspectRatio"] = aspectRatio
+	}
+	switch imageRequest.Quality {
+	case "hd", "high", "2K":
+		imageConfig["imageSize"] = "2K"
+	case "4K":
+		imageConfig["imageSize"] = "4K"
+	}
+	if len(imageConfig) > 0 {
+		geminiRequest.GenerationConfig.ImageConfig, err = common.Marshal(imageConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return geminiRequest, nil
+}
+
+// convertGeminiChatResponseToOAIImage 取出 generateContent 返回的图片，Gemini 只返回 base64，
+// response_format 为 url 时以 data URL 返回
+func convertGeminiChatResponseToOAIImage(_ *gin.Context, info *relaycommon.RelayInfo, response any) (any, *dto.Usage, error) {
+	geminiResponse, err := asGeminiChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	responseFormat := ""
+	if info != nil {
+		if imageRequest, ok := info.Request.(*dto.ImageRequest); ok {
+			responseFormat = imageRequest.ResponseFormat
+		}
+	}
+
+	imageResponse := &dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0, len(geminiResponse.Candidates)),
+	}
+	finishReason := ""
+	for _, candidate := range geminiResponse.Candidates {
+		if candidate.FinishReason != nil {
+			finishReason = *candidate.FinishReason
+		}
+		var revisedPrompt strings.Builder
+		for _, part := range candidate.Content.Parts {
+			if part.Thought {
+				continue
+			}
+			if part.Text != "" {
+				revisedPrompt.WriteString(part.Text)
+			}
+		}
+		for _, part := range candidate.Content.Parts {
+			if part.Thought || part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				continue
+			}
+			imageData := dto.ImageData{RevisedPrompt: strings.TrimSpace(revisedPrompt.String())}
+			if responseFormat == "url" {
+				imageData.Url = fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data)
+			} else {
+				imageData.B64Json = part.InlineData.Data
+			}
+			imageResponse.Data = append(imageResponse.Data, imageData)
+		}
+	}
+	if len(imageResponse.Data) == 0 {
+		if finishReason != "" {
+			return nil, nil, fmt.Errorf("no images generated, finish reason: %s", finishReason)
+		}
+		return nil, nil, errors.New("no images generated")
+	}
+	usage := UsageFromGeminiMetadata(geminiResponse.GetUsageMetadata(), fallbackPromptTokens(info))
+	return imageResponse, usage, nil
+}
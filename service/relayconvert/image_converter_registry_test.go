@@ -0,0 +1,103 @@
+package relayconvert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertImageGenerationRequestToGemini(t *testing.T) {
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesGenerations}
+	request := &dto.ImageRequest{
+		Model:   "gemini-2.5-flash-image",
+		Prompt:  "a cat",
+		Size:    "1792x1024",
+		Quality: "hd",
+	}
+
+	result, err := ConvertRequest(nil, info, types.RelayFormatGemini, request)
+	require.NoError(t, err)
+	assert.Equal(t, ConverterOpenAIImageToGeminiContent, result.Converter)
+
+	geminiRequest, ok := result.Value.(*dto.GeminiChatRequest)
+	require.True(t, ok)
+	require.Len(t, geminiRequest.Contents, 1)
+	assert.Equal(t, []dto.GeminiPart{{Text: "a cat"}}, geminiRequest.Contents[0].Parts)
+	assert.Equal(t, []string{"TEXT", "IMAGE"}, geminiRequest.GenerationConfig.ResponseModalities)
+	assert.JSONEq(t, `{"aspectRatio":"16:9","imageSize":"2K"}`, string(geminiRequest.GenerationConfig.ImageConfig))
+
+	_, err = ConvertRequest(nil, info, types.RelayFormatGemini, &dto.ImageRequest{Model: "gemini-2.5-flash-image"})
+	require.Error(t, err)
+}
+
+func TestConvertImageVariationRequestToGemini(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("model", "gemini-2.5-flash-image"))
+	part, err := writer.CreateFormFile("image", "cat.png")
+	require.NoError(t, err)
+	pngHeader := []byte("\x89PNG\r\n\x1a\n fake image")
+	_, err = part.Write(pngHeader)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/variations", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesVariations}
+
+	result, err := ConvertRequest(c, info, types.RelayFormatGemini, &dto.ImageRequest{Model: "gemini-2.5-flash-image"})
+	require.NoError(t, err)
+
+	geminiRequest, ok := result.Value.(*dto.GeminiChatRequest)
+	require.True(t, ok)
+	parts := geminiRequest.Contents[0].Parts
+	require.Len(t, parts, 2)
+	require.NotNil(t, parts[0].InlineData)
+	assert.Equal(t, "image/png", parts[0].InlineData.MimeType)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(pngHeader), parts[0].InlineData.Data)
+	assert.Equal(t, ImageVariationPrompt, parts[1].Text)
+}
+
+func TestConvertGeminiChatResponseToOpenAIImage(t *testing.T) {
+	geminiResponse := &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{
+			{Content: dto.GeminiChatContent{Parts: []dto.GeminiPart{
+				{Text: "Here is your cat"},
+				{InlineData: &dto.GeminiInlineData{MimeType: "image/png", Data: "aW1hZ2U="}},
+			}}},
+		},
+	}
+
+	info := &relaycommon.RelayInfo{Request: &dto.ImageRequest{}}
+	result, err := ConvertResponse(nil, info, types.RelayFormatOpenAIImage, geminiResponse)
+	require.NoError(t, err)
+	imageResponse, ok := result.Value.(*dto.ImageResponse)
+	require.True(t, ok)
+	require.Len(t, imageResponse.Data, 1)
+	assert.Equal(t, "aW1hZ2U=", imageResponse.Data[0].B64Json)
+	assert.Equal(t, "Here is your cat", imageResponse.Data[0].RevisedPrompt)
+
+	info.Request = &dto.ImageRequest{ResponseFormat: "url"}
+	result, err = ConvertResponse(nil, info, types.RelayFormatOpenAIImage, geminiResponse)
+	require.NoError(t, err)
+	assert.Equal(t, "data:image/png;base64,aW1hZ2U=", result.Value.(*dto.ImageResponse).Data[0].Url)
+
+	finishReason := "IMAGE_SAFETY"
+	_, err = ConvertResponse(nil, info, types.RelayFormatOpenAIImage, &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{FinishReason: common.GetPointer(finishReason)}},
+	})
+	require.ErrorContains(t, err, "IMAGE_SAFETY")
+}
@@ -32,6 +32,10 @@ func ApplyGeminiThinkingConfig(geminiRequest *dto.GeminiChatRequest, info *relay
 	sharedgemini.ApplyThinkingConfig(geminiRequest, info, oaiRequest...)
 }
 
+func CleanGeminiFunctionParameters(params interface{}) interface{} {
+	return sharedgemini.CleanFunctionParameters(params)
+}
+
 func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*dto.OpenAIResponsesRequest, error) {
 	return oaichat.ChatCompletionsRequestToResponsesRequest(req)
 }
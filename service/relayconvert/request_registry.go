@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	claudemessages "github.com/QuantumNous/new-api/service/relayconvert/internal/claude_messages"
 	geminichat "github.com/QuantumNous/new-api/service/relayconvert/internal/gemini_chat"
@@ -15,6 +16,7 @@ import (
 	oairesponses "github.com/QuantumNous/new-api/service/relayconvert/internal/oai_responses"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type RequestConverterFunc func(c *gin.Context, info *relaycommon.RelayInfo, request any) (any, error)
@@ -235,11 +237,21 @@ func executeRequestSpec(c *gin.Context, info *relaycommon.RelayInfo, from types.
 	return executeRequestSteps(c, info, from, target, request, spec.ID, spec.Quality, steps)
 }
 
-func executeRequestSteps(c *gin.Context, info *relaycommon.RelayInfo, from types.RelayFormat, target types.RelayFormat, request any, converter string, quality RequestConverterQuality, specs []RequestConverterSpec) (*RequestResult, error) {
+func executeRequestSteps(c *gin.Context, info *relaycommon.RelayInfo, from types.RelayFormat, target types.RelayFormat, request any, converter string, quality RequestConverterQuality, specs []RequestConverterSpec) (result *RequestResult, err error) {
+	_, span := tracing.Start(info.TraceContext(), "relayconvert.request",
+		attribute.String("newapi.convert_from", string(from)),
+		attribute.String("newapi.convert_to", string(target)),
+	)
+	defer func() {
+		if result != nil {
+			span.SetAttributes(attribute.String("newapi.converter", result.Converter))
+		}
+		tracing.End(span, err)
+	}()
+
 	current := request
 	steps := make([]RequestStep, 0, len(specs))
 	for _, spec := range specs {
-		var err error
 		current, err = prepareRequestForStep(current, spec, target)
 		if err != nil {
 			return nil, err
@@ -82,6 +82,9 @@ func TestRequestConverterRegistryListsSupportedTextConverters(t *testing.T) {
 			quality:        RequestConverterQualityFair,
 			advancedCustom: true,
 		},
+		{converter: ConverterOpenAIAudioToGeminiContent, from: types.RelayFormatOpenAIAudio, to: types.RelayFormatGemini, quality: RequestConverterQualityFair},
+		{converter: ConverterOpenAIAudioToOpenAIChat, from: types.RelayFormatOpenAIAudio, to: types.RelayFormatOpenAI, quality: RequestConverterQualityFair},
+		{converter: ConverterOpenAIImageToGeminiContent, from: types.RelayFormatOpenAIImage, to: types.RelayFormatGemini, quality: RequestConverterQualityFair},
 	}
 
 	require.Len(t, requestConverters, len(tests))
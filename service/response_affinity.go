@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+)
+
+const (
+	ginKeyResponseAffinity = "response_affinity"
+
+	responseAffinityCacheNamespace = "new-api:response_affinity:v1"
+)
+
+var (
+	responseAffinityCacheOnce sync.Once
+	responseAffinityCache     *cachex.HybridCache[ResponseAffinity]
+)
+
+// ResponseAffinity 生成某个 response 的渠道与 key。previous_response_id、检索、取消等
+// 有状态请求必须发往同一上游账号，KeyIndex 为 -1 表示单 key 渠道
+type ResponseAffinity struct {
+	ResponseId string `json:"-"`
+	ChannelId  int    `json:"channel_id"`
+	KeyIndex   int    `json:"key_index"`
+	Model      string `json:"model"`
+}
+
+func getResponseAffinityCache() *cachex.HybridCache[ResponseAffinity] {
+	responseAffinityCacheOnce.Do(func() {
+		setting := operation_setting.GetChannelAffinitySetting()
+		capacity := setting.MaxEntries
+		if capacity <= 0 {
+			capacity = 100_000
+		}
+		responseAffinityCache = cachex.NewHybridCache[ResponseAffinity](cachex.HybridCacheConfig[ResponseAffinity]{
+			Namespace: cachex.Namespace(responseAffinityCacheNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.JSONCodec[ResponseAffinity]{},
+			Memory: func() *hot.HotCache[string, ResponseAffinity] {
+				return hot.NewHotCache[string, ResponseAffinity](hot.LRU, capacity).
+					WithTTL(responseAffinityTTL()).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return responseAffinityCache
+}
+
+func responseAffinityTTL() time.Duration {
+	ttlSeconds := operation_setting.GetChannelAffinitySetting().ResponseAffinityTTLSeconds
+	if ttlSeconds <= 0 {
+		ttlSeconds = 30 * 24 * 3600
+	}
+	return time.Duration(ttlSeconds) * time.Second
+}
+
+// response id 只对创建它的用户有效，避免其他用户借助同一上游账号读取
+func responseAffinityCacheKey(userId int, responseId string) string {
+	return fmt.Sprintf("%d:%s", userId, responseId)
+}
+
+func IsResponseAffinityEnabled() bool {
+	return operation_setting.GetChannelAffinitySetting().ResponseAffinityEnabled
+}
+
+// RecordResponseAffinity 记录当前请求所用渠道生成的 response id
+func RecordResponseAffinity(c *gin.Context, responseId string) {
+	if c == nil || responseId == "" || !IsResponseAffinityEnabled() {
+		return
+	}
+	userId := common.GetContextKeyInt(c, constant.ContextKeyUserId)
+	channelId := common.GetContextKeyInt(c, constant.ContextKeyChannelId)
+	if userId <= 0 || channelId <= 0 {
+		return
+	}
+	affinity := ResponseAffinity{ChannelId: channelId, KeyIndex: -1, Model: c.GetString("original_model")}
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		affinity.KeyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	cacheKey := responseAffinityCacheKey(userId, responseId)
+	if err := getResponseAffinityCache().SetWithTTL(cacheKey, affinity, responseAffinityTTL()); err != nil {
+		common.SysError(fmt.Sprintf("response affinity cache set failed: key=%s, err=%v", cacheKey, err))
+	}
+}
+
+// GetResponseAffinity 查询 response id 所在的渠道
+func GetResponseAffinity(userId int, responseId string) (ResponseAffinity, bool) {
+	if userId <= 0 || responseId == "" || !IsResponseAffinityEnabled() {
+		return ResponseAffinity{}, false
+	}
+	cacheKey := responseAffinityCacheKey(userId, responseId)
+	affinity, found, err := getResponseAffinityCache().Get(cacheKey)
+	if err != nil {
+		common.SysError(fmt.Sprintf("response affinity cache get failed: key=%s, err=%v", cacheKey, err))
+		return ResponseAffinity{}, false
+	}
+	affinity.ResponseId = responseId
+	return affinity, found
+}
+
+func DeleteResponseAffinity(userId int, responseId string) {
+	if userId <= 0 || responseId == "" {
+		return
+	}
+	if _, err := getResponseAffinityCache().DeleteMany([]string{responseAffinityCacheKey(userId, responseId)}); err != nil {
+		common.SysError(fmt.Sprintf("response affinity cache delete failed: err=%v", err))
+	}
+}
+
+// MarkResponseAffinityUsed 请求已固定到 response 所在渠道，失败后不再重试其他渠道
+func MarkResponseAffinityUsed(c *gin.Context, affinity ResponseAffinity) {
+	if c == nil {
+		return
+	}
+	c.Set(ginKeyResponseAffinity, affinity)
+	c.Set(ginKeyChannelAffinitySkipRetry, true)
+}
+
+// IsResponseAffinityUsed 请求是否已固定到 previous_response_id 所在渠道
+func IsResponseAffinityUsed(c *gin.Context) bool {
+	_, ok := getUsedResponseAffinity(c)
+	return ok
+}
+
+func getUsedResponseAffinity(c *gin.Context) (ResponseAffinity, bool) {
+	if c == nil {
+		return ResponseAffinity{}, false
+	}
+	anyAffinity, ok := c.Get(ginKeyResponseAffinity)
+	if !ok {
+		return ResponseAffinity{}, false
+	}
+	affinity, ok := anyAffinity.(ResponseAffinity)
+	return affinity, ok
+}
+
+// GetPinnedResponseKeyIndex 返回固定到该渠道的 key 序号
+func GetPinnedResponseKeyIndex(c *gin.Context, channelId int) (int, bool) {
+	affinity, ok := getUsedResponseAffinity(c)
+	if !ok || affinity.ChannelId != channelId || affinity.KeyIndex < 0 {
+		return 0, false
+	}
+	return affinity.KeyIndex, true
+}
+
+func appendResponseAffinityAdminInfo(c *gin.Context, adminInfo map[string]interface{}) {
+	affinity, ok := getUsedResponseAffinity(c)
+	if !ok {
+		return
+	}
+	adminInfo["response_affinity"] = map[string]interface{}{
+		"response_id": affinity.ResponseId,
+		"channel_id":  affinity.ChannelId,
+		"key_index":   affinity.KeyIndex,
+	}
+}
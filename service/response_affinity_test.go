@@ -0,0 +1,51 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseAffinityRecordAndPin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	common.SetContextKey(c, constant.ContextKeyUserId, 7)
+	common.SetContextKey(c, constant.ContextKeyChannelId, 12)
+	common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
+	common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, 3)
+	c.Set("original_model", "gpt-5")
+
+	RecordResponseAffinity(c, "resp_affinity_test")
+
+	affinity, found := GetResponseAffinity(7, "resp_affinity_test")
+	require.True(t, found)
+	require.Equal(t, ResponseAffinity{ResponseId: "resp_affinity_test", ChannelId: 12, KeyIndex: 3, Model: "gpt-5"}, affinity)
+
+	// 其他用户无法使用该 response id
+	_, found = GetResponseAffinity(8, "resp_affinity_test")
+	require.False(t, found)
+
+	next, _ := gin.CreateTestContext(httptest.NewRecorder())
+	MarkResponseAffinityUsed(next, affinity)
+	require.True(t, ShouldSkipRetryAfterChannelAffinityFailure(next))
+	index, ok := GetPinnedResponseKeyIndex(next, 12)
+	require.True(t, ok)
+	require.Equal(t, 3, index)
+	_, ok = GetPinnedResponseKeyIndex(next, 13)
+	require.False(t, ok)
+
+	adminInfo := map[string]interface{}{}
+	AppendChannelAffinityAdminInfo(next, adminInfo)
+	require.Contains(t, adminInfo, "response_affinity")
+
+	DeleteResponseAffinity(7, "resp_affinity_test")
+	_, found = GetResponseAffinity(7, "resp_affinity_test")
+	require.False(t, found)
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+)
+
+const (
+	responseCacheNamespace = "new-api:response_cache:v1"
+	// ResponseCacheHeader 响应头，标记本次响应是否来自缓存（hit / miss）
+	ResponseCacheHeader = "X-NewAPI-Cache"
+)
+
+// ResponseCacheEntry 缓存的响应。Body 为原样写给客户端的内容（JSON 或 SSE），命中时原样回放；
+// Usage 为首次请求的用量，命中时据此计费。
+type ResponseCacheEntry struct {
+	Stream      bool       `json:"stream"`
+	ContentType string     `json:"content_type"`
+	Body        string     `json:"body"`
+	Usage       *dto.Usage `json:"usage,omitempty"`
+	CreatedAt   int64      `json:"created_at"`
+}
+
+var (
+	responseCacheOnce sync.Once
+	responseCache     *cachex.HybridCache[ResponseCacheEntry]
+)
+
+func getResponseCache() *cachex.HybridCache[ResponseCacheEntry] {
+	responseCacheOnce.Do(func() {
+		capacity := operation_setting.GetResponseCacheSetting().MemoryCapacity
+		if capacity <= 0 {
+			capacity = 10000
+		}
+		responseCache = cachex.NewHybridCache[ResponseCacheEntry](cachex.HybridCacheConfig[ResponseCacheEntry]{
+			Namespace: cachex.Namespace(responseCacheNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.JSONCodec[ResponseCacheEntry]{},
+			Memory: func() *hot.HotCache[string, ResponseCacheEntry] {
+				return hot.NewHotCache[string, ResponseCacheEntry](hot.LRU, capacity).
+					WithTTL(responseCacheTTL()).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return responseCache
+}
+
+func responseCacheTTL() time.Duration {
+	ttl := operation_setting.GetResponseCacheSetting().TTLSeconds
+	if ttl <= 0 {
+		ttl = 3600
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func isResponseCacheEnabledForRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) bool {
+	setting := operation_setting.GetResponseCacheSetting()
+	if !setting.Enabled {
+		return false
+	}
+	return common.GetContextKeyBool(c, constant.ContextKeyTokenResponseCache) ||
+		operation_setting.IsResponseCacheGroupEnabled(relayInfo.UsingGroup)
+}
+
+// isResponseCacheableRequest 只缓存 embeddings 与 temperature 为 0、n<=1 的 chat completions
+func isResponseCacheableRequest(relayInfo *relaycommon.RelayInfo, request dto.Request) bool {
+	setting := operation_setting.GetResponseCacheSetting()
+	switch req := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if !setting.CacheChat || relayInfo.RelayMode != relayconstant.RelayModeChatCompletions {
+			return false
+		}
+		if req.Temperature == nil || *req.Temperature != 0 {
+			return false
+		}
+		return req.N == nil || *req.N <= 1
+	case *dto.EmbeddingRequest:
+		return setting.CacheEmbeddings && relayInfo.RelayMode == relayconstant.RelayModeEmbeddings
+	}
+	return false
+}
+
+// buildResponseCacheKey 由请求路径、模型、分组、是否流式与规范化后的请求体计算缓存 key。
+// 规范化：重新序列化 JSON（字段排序），并去掉不影响结果的 user 字段。
+func buildResponseCacheKey(path string, modelName string, group string, stream bool, body []byte) (string, error) {
+	var payload map[string]any
+	if err := common.Unmarshal(body, &payload); err != nil {
+		return "", err
+	}
+	delete(payload, "user")
+	normalized, err := common.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(fmt.Sprintf("%s\n%s\n%s\n%t\n", path, modelName, group, stream)))
+	hash.Write(normalized)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GetResponseCacheKey 判断请求是否可以使用响应缓存，可以时返回缓存 key
+func GetResponseCacheKey(c *gin.Context, relayInfo *relaycommon.RelayInfo, request dto.Request) (string, bool) {
+	if relayInfo == nil || !isResponseCacheEnabledForRequest(c, relayInfo) || !isResponseCacheableRequest(relayInfo, request) {
+		return "", false
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return "", false
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return "", false
+	}
+	key, err := buildResponseCacheKey(c.Request.URL.Path, relayInfo.OriginModelName, relayInfo.UsingGroup, relayInfo.IsStream, body)
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// GetResponseCacheEntry 查询缓存，缓存不可用时视为未命中
+func GetResponseCacheEntry(c *gin.Context, key string) (*ResponseCacheEntry, bool) {
+	entry, found, err := getResponseCache().Get(key)
+	if err != nil {
+		logger.LogWarn(c, "get response cache failed: "+err.Error())
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// ServeResponseCacheHit 回放缓存的响应并按配置计费
+func ServeResponseCacheHit(c *gin.Context, relayInfo *relaycommon.RelayInfo, entry *ResponseCacheEntry) {
+	common.SetContextKey(c, constant.ContextKeyResponseCacheHit, true)
+	c.Header(ResponseCacheHeader, "hit")
+	if entry.Stream {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+	} else if entry.ContentType != "" {
+		c.Header("Content-Type", entry.ContentType)
+	} else {
+		c.Header("Content-Type", "application/json")
+	}
+	c.Status(http.StatusOK)
+	relayInfo.SetFirstResponseTime()
+	if _, err := c.Writer.WriteString(entry.Body); err != nil {
+		logger.LogError(c, "write response cache hit failed: "+err.Error())
+	}
+	c.Writer.Flush()
+
+	// 缓存命中不经过任何渠道
+	if relayInfo.ChannelMeta == nil {
+		relayInfo.ChannelMeta = &relaycommon.ChannelMeta{}
+	}
+	usage := entry.Usage
+	if usage == nil {
+		usage = &dto.Usage{}
+	}
+	mode, ratio := operation_setting.GetResponseCacheBilling()
+	switch mode {
+	case operation_setting.ResponseCacheBillingFree:
+		settleFreeResponseCacheHit(c, relayInfo, usage)
+	case operation_setting.ResponseCacheBillingDiscount:
+		relayInfo.PriceData.AddOtherRatio("response_cache", ratio)
+		if relayInfo.TieredBillingSnapshot != nil {
+			// 阶梯表达式计费不读取 OtherRatios，折扣并入分组倍率
+			relayInfo.TieredBillingSnapshot.GroupRatio *= ratio
+		}
+		PostTextConsumeQuota(c, relayInfo, usage, []string{"响应缓存命中"})
+	default:
+		PostTextConsumeQuota(c, relayInfo, usage, []string{"响应缓存命中"})
+	}
+}
+
+func settleFreeResponseCacheHit(c *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
+	if err := SettleBilling(c, relayInfo, 0); err != nil {
+		logger.LogError(c, "error settling billing: "+err.Error())
+	}
+	priceData := relayInfo.PriceData
+	other := GenerateTextOtherInfo(c, relayInfo, priceData.ModelRatio, priceData.GroupRatioInfo.GroupRatio,
+		priceData.CompletionRatio, 0, priceData.CacheRatio, priceData.ModelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
+		ChannelId:        0,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ModelName:        relayInfo.OriginModelName,
+		TokenName:        c.GetString("token_name"),
+		Quota:            0,
+		Content:          "响应缓存命中，免费",
+		TokenId:          relayInfo.TokenId,
+		UseTimeSeconds:   int(time.Since(relayInfo.StartTime).Seconds()),
+		IsStream:         relayInfo.IsStream,
+		Group:            relayInfo.UsingGroup,
+		Other:            other,
+	})
+}
+
+// appendResponseCacheLogInfo 在消费日志中记录响应缓存命中信息
+func appendResponseCacheLogInfo(ctx *gin.Context, other map[string]interface{}) {
+	if !common.GetContextKeyBool(ctx, constant.ContextKeyResponseCacheHit) {
+		return
+	}
+	mode, ratio := operation_setting.GetResponseCacheBilling()
+	other["response_cache_hit"] = true
+	other["response_cache_billing_mode"] = mode
+	other["response_cache_ratio"] = ratio
+}
+
+// responseCacheWriter 将写给客户端的响应复制到有限大小的缓冲区，超过上限后放弃缓存
+type responseCacheWriter struct {
+	gin.ResponseWriter
+	mu       sync.Mutex
+	body     bytes.Buffer
+	maxSize  int
+	overflow bool
+}
+
+func (w *responseCacheWriter) capture(b []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(b) > w.maxSize {
+		w.overflow = true
+		w.body = bytes.Buffer{}
+		return
+	}
+	w.body.Write(b)
+}
+
+func (w *responseCacheWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCacheWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ResponseCacheState 未命中时记录待写入缓存的响应
+type ResponseCacheState struct {
+	key    string
+	writer *responseCacheWriter
+	usage  *dto.Usage
+}
+
+// StartResponseCacheCapture 缓存未命中时开始捕获响应，请求成功后由 FinishResponseCacheCapture 写入缓存
+func StartResponseCacheCapture(c *gin.Context, key string) {
+	maxSize := operation_setting.GetResponseCacheSetting().MaxEntryBytes
+	if maxSize <= 0 {
+		return
+	}
+	writer := &responseCacheWriter{ResponseWriter: c.Writer, maxSize: maxSize}
+	c.Writer = writer
+	c.Header(ResponseCacheHeader, "miss")
+	common.SetContextKey(c, constant.ContextKeyResponseCache, &ResponseCacheState{key: key, writer: writer})
+}
+
+// rememberResponseCacheUsage 记录本次请求的计费用量，供写入缓存后命中时计费
+func rememberResponseCacheUsage(ctx *gin.Context, usage *dto.Usage) {
+	if ctx == nil || usage == nil {
+		return
+	}
+	state, ok := common.GetContextKeyType[*ResponseCacheState](ctx, constant.ContextKeyResponseCache)
+	if !ok || state == nil {
+		return
+	}
+	usageCopy := *usage
+	state.usage = &usageCopy
+}
+
+// FinishResponseCacheCapture 请求成功后写入缓存。仅缓存 200 响应且已拿到用量的请求。
+func FinishResponseCacheCapture(c *gin.Context, relayInfo *relaycommon.RelayInfo) {
+	state, ok := common.GetContextKeyType[*ResponseCacheState](c, constant.ContextKeyResponseCache)
+	if !ok || state == nil {
+		return
+	}
+	writer := state.writer
+	writer.mu.Lock()
+	overflow := writer.overflow
+	body := writer.body.String()
+	writer.mu.Unlock()
+	if overflow || body == "" || writer.Status() != http.StatusOK || state.usage == nil {
+		return
+	}
+	entry := ResponseCacheEntry{
+		Stream:      relayInfo.IsStream,
+		ContentType: writer.Header().Get("Content-Type"),
+		Body:        body,
+		Usage:       state.usage,
+		CreatedAt:   common.GetTimestamp(),
+	}
+	if err := getResponseCache().SetWithTTL(state.key, entry, responseCacheTTL()); err != nil {
+		logger.LogWarn(c, "set response cache failed: "+err.Error())
+	}
+}
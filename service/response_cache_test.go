@@ -0,0 +1,115 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildResponseCacheKeyNormalizesBody(t *testing.T) {
+	a, err := buildResponseCacheKey("/v1/chat/completions", "gpt-4o", "default", false,
+		[]byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}],"user":"a"}`))
+	require.NoError(t, err)
+	b, err := buildResponseCacheKey("/v1/chat/completions", "gpt-4o", "default", false,
+		[]byte(`{"messages":[{"content":"hi","role":"user"}],"user":"b","temperature":0,  "model":"gpt-4o"}`))
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	// 分组与流式不同的请求不能共用缓存
+	c, err := buildResponseCacheKey("/v1/chat/completions", "gpt-4o", "vip", false,
+		[]byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c)
+	d, err := buildResponseCacheKey("/v1/chat/completions", "gpt-4o", "default", true,
+		[]byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	require.NoError(t, err)
+	assert.NotEqual(t, a, d)
+
+	_, err = buildResponseCacheKey("/v1/chat/completions", "gpt-4o", "default", false, []byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestIsResponseCacheableRequest(t *testing.T) {
+	chatInfo := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeChatCompletions}
+	zero := 0.0
+	warm := 0.7
+	two := 2
+
+	assert.True(t, isResponseCacheableRequest(chatInfo, &dto.GeneralOpenAIRequest{Temperature: &zero}))
+	assert.False(t, isResponseCacheableRequest(chatInfo, &dto.GeneralOpenAIRequest{}))
+	assert.False(t, isResponseCacheableRequest(chatInfo, &dto.GeneralOpenAIRequest{Temperature: &warm}))
+	assert.False(t, isResponseCacheableRequest(chatInfo, &dto.GeneralOpenAIRequest{Temperature: &zero, N: &two}))
+
+	embeddingInfo := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeEmbeddings}
+	assert.True(t, isResponseCacheableRequest(embeddingInfo, &dto.EmbeddingRequest{}))
+
+	setting := operation_setting.GetResponseCacheSetting()
+	old := *setting
+	t.Cleanup(func() { *setting = old })
+	setting.CacheEmbeddings = false
+	assert.False(t, isResponseCacheableRequest(embeddingInfo, &dto.EmbeddingRequest{}))
+}
+
+func TestResponseCacheCaptureStoresSuccessfulResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetResponseCacheSetting()
+	old := *setting
+	t.Cleanup(func() { *setting = old })
+	setting.MaxEntryBytes = 64
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+
+	StartResponseCacheCapture(c, "test-capture-key")
+	assert.Equal(t, "miss", recorder.Header().Get(ResponseCacheHeader))
+	c.Header("Content-Type", "application/json")
+	c.Status(http.StatusOK)
+	_, err := c.Writer.WriteString(`{"object":"list"}`)
+	require.NoError(t, err)
+	rememberResponseCacheUsage(c, &dto.Usage{PromptTokens: 3, TotalTokens: 3})
+	FinishResponseCacheCapture(c, &relaycommon.RelayInfo{})
+
+	entry, found := GetResponseCacheEntry(c, "test-capture-key")
+	require.True(t, found)
+	assert.Equal(t, `{"object":"list"}`, entry.Body)
+	assert.Equal(t, "application/json", entry.ContentType)
+	require.NotNil(t, entry.Usage)
+	assert.Equal(t, 3, entry.Usage.PromptTokens)
+}
+
+func TestResponseCacheCaptureSkipsOversizedResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetResponseCacheSetting()
+	old := *setting
+	t.Cleanup(func() { *setting = old })
+	setting.MaxEntryBytes = 8
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+
+	StartResponseCacheCapture(c, "test-oversized-key")
+	c.Status(http.StatusOK)
+	_, err := c.Writer.WriteString(`{"object":"list"}`)
+	require.NoError(t, err)
+	// 客户端仍然拿到完整响应
+	assert.Equal(t, `{"object":"list"}`, recorder.Body.String())
+	rememberResponseCacheUsage(c, &dto.Usage{PromptTokens: 3, TotalTokens: 3})
+	FinishResponseCacheCapture(c, &relaycommon.RelayInfo{})
+
+	_, found := GetResponseCacheEntry(c, "test-oversized-key")
+	assert.False(t, found)
+	assert.False(t, common.GetContextKeyBool(c, constant.ContextKeyResponseCacheHit))
+}
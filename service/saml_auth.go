@@ -0,0 +1,295 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	"github.com/QuantumNous/new-api/pkg/saml"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/samber/hot"
+)
+
+const (
+	samlReplayCacheNamespace  = "new-api:saml_assertion:v1"
+	samlTicketCacheNamespace  = "new-api:saml_ticket:v1"
+	samlRequestCacheNamespace = "new-api:saml_request:v1"
+	// 发起登录到 IdP 回调 ACS 之间允许的最长时间
+	samlRequestTTL = 10 * time.Minute
+	// ACS 到前端回调之间的一次性票据有效期
+	samlTicketTTL = 5 * time.Minute
+	// 与 user_oauth_bindings.provider_user_id 的列宽一致
+	samlMaxNameIDLength = 256
+
+	nameIDFormatTransient = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+)
+
+var (
+	ErrSAMLDisabled = errors.New("saml login is disabled")
+	ErrSAMLReplayed = errors.New("saml assertion has already been used")
+	// transient NameID 每次登录都会变化，无法作为绑定标识
+	ErrSAMLTransientNameID = errors.New("saml transient NameID cannot identify a user")
+)
+
+var (
+	samlCacheOnce   sync.Once
+	samlReplayCache *cachex.HybridCache[int64]
+	samlTicketCache *cachex.HybridCache[SAMLLoginResult]
+	// RelayState 按规范不超过 80 字节，因此只传随机键，请求信息保存在服务端
+	samlRequestCache *cachex.HybridCache[SAMLRequestState]
+)
+
+// SAMLIdentity 是断言校验通过后映射出的用户信息
+type SAMLIdentity struct {
+	NameID      string   `json:"name_id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	DisplayName string   `json:"display_name"`
+	Groups      []string `json:"groups,omitempty"`
+	// 按 GroupMapping 映射后的本系统分组，未命中时为空
+	Group string `json:"group,omitempty"`
+}
+
+// ProviderUserId 用于 user_oauth_bindings 的外部账户标识
+func (i *SAMLIdentity) ProviderUserId() string {
+	return i.NameID
+}
+
+// SAMLLoginResult 由 ACS 写入票据，前端回调时取出；登录失败时只携带错误信息的 i18n key
+type SAMLLoginResult struct {
+	Identity *SAMLIdentity `json:"identity,omitempty"`
+	ErrorKey string        `json:"error_key,omitempty"`
+}
+
+// SAMLRequestState 记录一次 AuthnRequest，ACS 据此校验 InResponseTo 并回传前端的 OAuth state
+type SAMLRequestState struct {
+	RequestID string `json:"request_id"`
+	State     string `json:"state"`
+}
+
+func SAMLEntityID() string {
+	if id := strings.TrimSpace(system_setting.GetSAMLSettings().SPEntityId); id != "" {
+		return id
+	}
+	return strings.TrimRight(system_setting.ServerAddress, "/") + "/api/saml/metadata"
+}
+
+func SAMLACSURL() string {
+	return strings.TrimRight(system_setting.ServerAddress, "/") + "/api/saml/acs"
+}
+
+// GetSAMLServiceProvider 按当前设置构造 SP，证书与私钥每次解析以便设置修改后立即生效
+func GetSAMLServiceProvider() (*saml.ServiceProvider, error) {
+	settings := system_setting.GetSAMLSettings()
+	sp := &saml.ServiceProvider{
+		EntityID:     SAMLEntityID(),
+		ACSURL:       SAMLACSURL(),
+		IdPEntityID:  strings.TrimSpace(settings.IdPEntityId),
+		IdPSSOURL:    strings.TrimSpace(settings.IdPSSOUrl),
+		NameIDFormat: strings.TrimSpace(settings.NameIDFormat),
+		ClockSkew:    time.Duration(settings.AllowedClockSkew) * time.Second,
+	}
+	if settings.SPCertificate != "" {
+		certs, err := saml.ParseCertificates(settings.SPCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid saml sp certificate: %w", err)
+		}
+		sp.Certificate = certs[0]
+	}
+	if settings.SPPrivateKey != "" {
+		key, err := saml.ParsePrivateKey(settings.SPPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid saml sp private key: %w", err)
+		}
+		sp.PrivateKey = key
+	}
+	certs, err := saml.ParseCertificates(settings.IdPCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid saml idp certificate: %w", err)
+	}
+	sp.IdPCertificates = certs
+	return sp, nil
+}
+
+// ConsumeSAMLResponse 校验 ACS 收到的 SAMLResponse，同一断言只能使用一次
+func ConsumeSAMLResponse(encoded string, requestID string) (*SAMLIdentity, error) {
+	settings := system_setting.GetSAMLSettings()
+	if !settings.Enabled {
+		return nil, ErrSAMLDisabled
+	}
+	sp, err := GetSAMLServiceProvider()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	assertion, err := sp.ParseResponse(encoded, requestID, now)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := buildSAMLIdentity(settings, assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	initSAMLCaches()
+	replayKey := assertion.Issuer + "|" + assertion.ID
+	if _, found, err := samlReplayCache.Get(replayKey); err != nil {
+		return nil, err
+	} else if found {
+		return nil, ErrSAMLReplayed
+	}
+	// 记录到断言过期为止，之后 ParseResponse 本身就会拒绝
+	ttl := assertion.NotOnOrAfter.Sub(now) + sp.ClockSkew
+	if assertion.NotOnOrAfter.IsZero() || ttl < samlTicketTTL {
+		ttl = samlTicketTTL
+	}
+	if err := samlReplayCache.SetWithTTL(replayKey, now.Unix(), ttl); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func buildSAMLIdentity(settings *system_setting.SAMLSettings, assertion *saml.Assertion) (*SAMLIdentity, error) {
+	if assertion.NameIDFormat == nameIDFormatTransient {
+		return nil, ErrSAMLTransientNameID
+	}
+	if len(assertion.NameID) > samlMaxNameIDLength {
+		return nil, errors.New("saml NameID is too long")
+	}
+	identity := &SAMLIdentity{
+		NameID:      assertion.NameID,
+		Username:    assertion.AttributeValue(settings.UsernameAttribute),
+		Email:       assertion.AttributeValue(settings.EmailAttribute),
+		DisplayName: assertion.AttributeValue(settings.DisplayNameAttribute),
+	}
+	if identity.Email == "" && assertion.NameIDFormat == saml.NameIDFormatEmailAddress {
+		identity.Email = assertion.NameID
+	}
+	if settings.GroupAttribute != "" {
+		identity.Groups = assertion.Attributes[settings.GroupAttribute]
+	}
+	identity.Group = MapSAMLGroup(identity.Groups, settings.GroupMapping)
+	return identity, nil
+}
+
+// MapSAMLGroup 按断言中组的顺序返回第一个命中映射的本系统分组，组名不区分大小写
+func MapSAMLGroup(groups []string, mapping map[string]string) string {
+	if len(groups) == 0 || len(mapping) == 0 {
+		return ""
+	}
+	normalized := make(map[string]string, len(mapping))
+	for name, group := range mapping {
+		normalized[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(group)
+	}
+	for _, name := range groups {
+		if group := normalized[strings.ToLower(strings.TrimSpace(name))]; group != "" {
+			return group
+		}
+	}
+	return ""
+}
+
+// IssueSAMLTicket 保存登录结果并返回一次性票据，作为前端回调的 code
+func IssueSAMLTicket(result SAMLLoginResult) (string, error) {
+	initSAMLCaches()
+	ticket := common.GetRandomString(48)
+	if err := samlTicketCache.SetWithTTL(ticket, result, samlTicketTTL); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// ConsumeSAMLTicket 取出并删除票据
+func ConsumeSAMLTicket(ticket string) (*SAMLLoginResult, bool) {
+	if ticket == "" {
+		return nil, false
+	}
+	initSAMLCaches()
+	result, ok := consumeOnce(samlTicketCache, ticket)
+	if !ok {
+		return nil, false
+	}
+	return &result, true
+}
+
+// SaveSAMLRequestState 保存请求信息，返回用作 RelayState 的随机键
+func SaveSAMLRequestState(relayState string, state SAMLRequestState) error {
+	initSAMLCaches()
+	return samlRequestCache.SetWithTTL(relayState, state, samlRequestTTL)
+}
+
+// ConsumeSAMLRequestState 取出并删除 RelayState 对应的请求信息
+func ConsumeSAMLRequestState(relayState string) (*SAMLRequestState, bool) {
+	if relayState == "" {
+		return nil, false
+	}
+	initSAMLCaches()
+	state, ok := consumeOnce(samlRequestCache, relayState)
+	if !ok {
+		return nil, false
+	}
+	return &state, true
+}
+
+// consumeOnce 读取后删除，只有真正删除成功的请求才算取到，避免并发重复使用
+func consumeOnce[V any](cache *cachex.HybridCache[V], key string) (V, bool) {
+	var zero V
+	value, found, err := cache.Get(key)
+	if err != nil || !found {
+		return zero, false
+	}
+	deleted, err := cache.DeleteMany([]string{key})
+	if err != nil || !deleted[cache.FullKey(key)] {
+		return zero, false
+	}
+	return value, true
+}
+
+func initSAMLCaches() {
+	samlCacheOnce.Do(func() {
+		redisEnabled := func() bool {
+			return common.RedisEnabled && common.RDB != nil
+		}
+		samlReplayCache = cachex.NewHybridCache[int64](cachex.HybridCacheConfig[int64]{
+			Namespace:    cachex.Namespace(samlReplayCacheNamespace),
+			Redis:        common.RDB,
+			RedisEnabled: redisEnabled,
+			RedisCodec:   cachex.JSONCodec[int64]{},
+			Memory: func() *hot.HotCache[string, int64] {
+				return hot.NewHotCache[string, int64](hot.LRU, 100_000).
+					WithTTL(time.Hour).
+					WithJanitor().
+					Build()
+			},
+		})
+		samlTicketCache = cachex.NewHybridCache[SAMLLoginResult](cachex.HybridCacheConfig[SAMLLoginResult]{
+			Namespace:    cachex.Namespace(samlTicketCacheNamespace),
+			Redis:        common.RDB,
+			RedisEnabled: redisEnabled,
+			RedisCodec:   cachex.JSONCodec[SAMLLoginResult]{},
+			Memory: func() *hot.HotCache[string, SAMLLoginResult] {
+				return hot.NewHotCache[string, SAMLLoginResult](hot.LRU, 10_000).
+					WithTTL(samlTicketTTL).
+					WithJanitor().
+					Build()
+			},
+		})
+		samlRequestCache = cachex.NewHybridCache[SAMLRequestState](cachex.HybridCacheConfig[SAMLRequestState]{
+			Namespace:    cachex.Namespace(samlRequestCacheNamespace),
+			Redis:        common.RDB,
+			RedisEnabled: redisEnabled,
+			RedisCodec:   cachex.JSONCodec[SAMLRequestState]{},
+			Memory: func() *hot.HotCache[string, SAMLRequestState] {
+				return hot.NewHotCache[string, SAMLRequestState](hot.LRU, 10_000).
+					WithTTL(samlRequestTTL).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+}
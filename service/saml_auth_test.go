@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/pkg/saml"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapSAMLGroup(t *testing.T) {
+	mapping := map[string]string{"Engineering": "dev", " VIP ": "vip"}
+	assert.Equal(t, "vip", MapSAMLGroup([]string{"everyone", "vip", "engineering"}, mapping))
+	assert.Equal(t, "dev", MapSAMLGroup([]string{"ENGINEERING"}, mapping))
+	assert.Empty(t, MapSAMLGroup([]string{"everyone"}, mapping))
+	assert.Empty(t, MapSAMLGroup(nil, mapping))
+}
+
+func TestBuildSAMLIdentity(t *testing.T) {
+	settings := &system_setting.SAMLSettings{
+		UsernameAttribute:    "uid",
+		EmailAttribute:       "mail",
+		DisplayNameAttribute: "displayName",
+		GroupAttribute:       "groups",
+		GroupMapping:         map[string]string{"staff": "vip"},
+	}
+	assertion := &saml.Assertion{
+		NameID:       "erin@corp.example",
+		NameIDFormat: saml.NameIDFormatEmailAddress,
+		Attributes: map[string][]string{
+			"uid":    {"erin"},
+			"groups": {"Staff"},
+		},
+	}
+	identity, err := buildSAMLIdentity(settings, assertion)
+	require.NoError(t, err)
+	assert.Equal(t, "erin", identity.Username)
+	assert.Equal(t, "erin@corp.example", identity.Email)
+	assert.Equal(t, "vip", identity.Group)
+	assert.Equal(t, "erin@corp.example", identity.ProviderUserId())
+
+	assertion.NameIDFormat = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+	_, err = buildSAMLIdentity(settings, assertion)
+	assert.ErrorIs(t, err, ErrSAMLTransientNameID)
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"errors"
+	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/setting"
@@ -48,30 +50,64 @@ func SensitiveWordContains(text string) (bool, []string) {
 	return AcSearch(checkText, setting.SensitiveWords, true)
 }
 
-// SensitiveWordReplace 敏感词替换，返回是否包含敏感词和替换后的文本
-func SensitiveWordReplace(text string, returnImmediately bool) (bool, []string, string) {
-	if len(setting.SensitiveWords) == 0 {
-		return false, nil, text
+// sensitiveHit 命中的敏感词，start/end 为文本中的字符（rune）下标，区间左闭右开
+type sensitiveHit struct {
+	start int
+	end   int
+	word  string
+}
+
+// searchSensitiveHits 在 text 中查找敏感词，按出现位置排序
+func searchSensitiveHits(text []rune, returnImmediately bool) []sensitiveHit {
+	if len(setting.SensitiveWords) == 0 || len(text) == 0 {
+		return nil
 	}
-	checkText := strings.ToLower(text)
 	m := getOrBuildAC(setting.SensitiveWords)
-	hits := m.MultiPatternSearch([]rune(checkText), returnImmediately)
-	if len(hits) > 0 {
-		words := make([]string, 0, len(hits))
-		var builder strings.Builder
-		builder.Grow(len(text))
-		lastPos := 0
+	if m == nil {
+		return nil
+	}
+	// 逐字符转小写，保证命中位置与原文一一对应
+	checkText := make([]rune, len(text))
+	for i, r := range text {
+		checkText[i] = unicode.ToLower(r)
+	}
+	terms := m.MultiPatternSearch(checkText, returnImmediately)
+	hits := make([]sensitiveHit, 0, len(terms))
+	for _, term := range terms {
+		hits = append(hits, sensitiveHit{start: term.Pos, end: term.Pos + len(term.Word), word: string(term.Word)})
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].start < hits[j].start })
+	return hits
+}
 
-		for _, hit := range hits {
-			pos := hit.Pos
-			word := string(hit.Word)
-			builder.WriteString(text[lastPos:pos])
-			builder.WriteString("**###**")
-			lastPos = pos + len(word)
-			words = append(words, word)
+// writeSensitiveRedacted 将 text[from:] 写入 builder，命中区间替换为 **###**，重叠的命中合并替换
+func writeSensitiveRedacted(builder *strings.Builder, text []rune, hits []sensitiveHit, from int) {
+	lastPos := from
+	for _, hit := range hits {
+		if hit.end <= lastPos {
+			continue
 		}
-		builder.WriteString(text[lastPos:])
-		return true, words, builder.String()
+		start := max(hit.start, lastPos)
+		builder.WriteString(string(text[lastPos:start]))
+		builder.WriteString("**###**")
+		lastPos = hit.end
+	}
+	builder.WriteString(string(text[lastPos:]))
+}
+
+// SensitiveWordReplace 敏感词替换，返回是否包含敏感词和替换后的文本
+func SensitiveWordReplace(text string, returnImmediately bool) (bool, []string, string) {
+	runes := []rune(text)
+	hits := searchSensitiveHits(runes, returnImmediately)
+	if len(hits) == 0 {
+		return false, nil, text
+	}
+	words := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		words = append(words, hit.word)
 	}
-	return false, nil, text
+	var builder strings.Builder
+	builder.Grow(len(text))
+	writeSensitiveRedacted(&builder, runes, hits, 0)
+	return true, words, builder.String()
 }
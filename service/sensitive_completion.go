@@ -0,0 +1,393 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	completionSensitiveActionRedact = "redact"
+	completionSensitiveActionStop   = "stop"
+
+	// 各格式终止输出时使用的结束原因
+	openAIContentFilterFinishReason = "content_filter"
+	responsesIncompleteEventType    = "response.incomplete"
+	ClaudeRefusalStopReason         = "refusal"
+	geminiSafetyFinishReason        = "SAFETY"
+)
+
+// CompletionSensitiveScanner 模型输出敏感词扫描器。流式输出按 key（如 choice index）分别暂存末尾
+// maxLen-1 个尚未输出的字符，与新的增量拼接后扫描，跨分片的敏感词在输出前即可检测到
+type CompletionSensitiveScanner struct {
+	stopOnHit bool
+	maxLen    int
+	pending   map[string][]rune
+	words     []string
+	stopped   bool
+	// Responses 流中最近一次出现的 response 对象，终止时据此补发 response.incomplete
+	responseSnapshot string
+}
+
+func NewCompletionSensitiveScanner(stopOnHit bool) *CompletionSensitiveScanner {
+	maxLen := 0
+	for _, word := range setting.SensitiveWords {
+		maxLen = max(maxLen, len([]rune(strings.TrimSpace(word))))
+	}
+	return &CompletionSensitiveScanner{stopOnHit: stopOnHit, maxLen: maxLen, pending: make(map[string][]rune)}
+}
+
+// Scan 检查一段增量输出，返回可以输出的文本以及是否需要终止输出。末尾 maxLen-1 个字符可能是
+// 敏感词的开头，暂存到下一次与新的增量一起检查，final 时全部输出。
+// 替换模式下替换命中的敏感词；终止模式下返回命中位置之前的文本
+func (s *CompletionSensitiveScanner) Scan(key string, delta string, final bool) (string, bool) {
+	if s.stopped {
+		return "", true
+	}
+	pending := s.pending[key]
+	if delta == "" && (!final || len(pending) == 0) {
+		return "", false
+	}
+	text := make([]rune, 0, len(pending)+len(delta))
+	text = append(text, pending...)
+	text = append(text, []rune(delta)...)
+
+	// cut 之前的文本本次输出，跨过 cut 的命中整体输出
+	cut := len(text)
+	if !final {
+		cut = max(len(text)-(s.maxLen-1), 0)
+	}
+	hits := make([]sensitiveHit, 0)
+	for _, hit := range searchSensitiveHits(text, false) {
+		if hit.start >= cut {
+			break
+		}
+		cut = max(cut, hit.end)
+		hits = append(hits, hit)
+	}
+	if cut < len(text) {
+		s.pending[key] = append([]rune(nil), text[cut:]...)
+	} else {
+		delete(s.pending, key)
+	}
+	if len(hits) == 0 {
+		return string(text[:cut]), false
+	}
+	for _, hit := range hits {
+		s.words = append(s.words, hit.word)
+	}
+	if s.stopOnHit {
+		s.stopped = true
+		return string(text[:hits[0].start]), true
+	}
+	var builder strings.Builder
+	builder.Grow(len(delta))
+	writeSensitiveRedacted(&builder, text[:cut], hits, 0)
+	return builder.String(), false
+}
+
+// Stopped 是否已因命中敏感词终止输出
+func (s *CompletionSensitiveScanner) Stopped() bool {
+	return s.stopped
+}
+
+// Words 命中的敏感词（去重）
+func (s *CompletionSensitiveScanner) Words() []string {
+	return RemoveDuplicate(s.words)
+}
+
+// getCompletionSensitiveScanner 返回当前请求的输出扫描器，未开启输出检查时返回 nil
+func getCompletionSensitiveScanner(c *gin.Context) *CompletionSensitiveScanner {
+	if c == nil || !setting.ShouldCheckCompletionSensitive() || len(setting.SensitiveWords) == 0 {
+		return nil
+	}
+	if scanner, ok := common.GetContextKeyType[*CompletionSensitiveScanner](c, constant.ContextKeyCompletionSensitive); ok && scanner != nil {
+		return scanner
+	}
+	scanner := NewCompletionSensitiveScanner(setting.StopOnSensitiveEnabled)
+	common.SetContextKey(c, constant.ContextKeyCompletionSensitive, scanner)
+	return scanner
+}
+
+// IsCompletionSensitiveStopped 当前请求的输出是否已因敏感词终止，流式读取据此停止消费上游
+func IsCompletionSensitiveStopped(c *gin.Context) bool {
+	if c == nil {
+		return false
+	}
+	scanner, ok := common.GetContextKeyType[*CompletionSensitiveScanner](c, constant.ContextKeyCompletionSensitive)
+	return ok && scanner != nil && scanner.Stopped()
+}
+
+// scanJSONText 扫描 JSON 中 path 处的字符串并写回处理结果，final 时连同暂存的文本一起写回
+func scanJSONText(scanner *CompletionSensitiveScanner, data string, key string, path string, final bool) (string, bool) {
+	result := gjson.Get(data, path)
+	if result.Type != gjson.String && !(final && len(scanner.pending[key]) > 0) {
+		return data, scanner.Stopped()
+	}
+	text, stop := scanner.Scan(key, result.Str, final)
+	if text != result.Str {
+		data = setJSONValue(data, path, text)
+	}
+	return data, stop
+}
+
+func setJSONValue(data string, path string, value interface{}) string {
+	if updated, err := sjson.Set(data, path, value); err == nil {
+		return updated
+	}
+	return data
+}
+
+// moderateOpenAICompletion 检查 chat/completions 与 completions 的 choices，pathPrefix 为 delta 或 message。
+// 流式输出在带 finish_reason 的分片中输出暂存的文本
+func moderateOpenAICompletion(scanner *CompletionSensitiveScanner, data string, pathPrefix string) (string, bool) {
+	stopped := false
+	count := int(gjson.Get(data, "choices.#").Int())
+	for i := 0; i < count; i++ {
+		key := "choice:" + gjson.Get(data, fmt.Sprintf("choices.%d.index", i)).String()
+		final := pathPrefix != "delta" || gjson.Get(data, fmt.Sprintf("choices.%d.finish_reason", i)).String() != ""
+		path := fmt.Sprintf("choices.%d.%s.content", i, pathPrefix)
+		if textPath := fmt.Sprintf("choices.%d.text", i); gjson.Get(data, textPath).Exists() {
+			path = textPath
+		}
+		var stop bool
+		data, stop = scanJSONText(scanner, data, key, path, final)
+		if stop {
+			data = setJSONValue(data, fmt.Sprintf("choices.%d.finish_reason", i), openAIContentFilterFinishReason)
+			stopped = true
+		}
+	}
+	return data, stopped
+}
+
+// moderateGeminiCompletion 检查 candidates，流式输出在带 finishReason 的分片中输出暂存的文本
+func moderateGeminiCompletion(scanner *CompletionSensitiveScanner, data string, stream bool) (string, bool) {
+	stopped := false
+	count := int(gjson.Get(data, "candidates.#").Int())
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("candidate:%d", gjson.Get(data, fmt.Sprintf("candidates.%d.index", i)).Int())
+		final := !stream || gjson.Get(data, fmt.Sprintf("candidates.%d.finishReason", i)).String() != ""
+		stop := false
+		parts := int(gjson.Get(data, fmt.Sprintf("candidates.%d.content.parts.#", i)).Int())
+		for j := 0; j < parts; j++ {
+			var hit bool
+			data, hit = scanJSONText(scanner, data, key, fmt.Sprintf("candidates.%d.content.parts.%d.text", i, j), final && j == parts-1)
+			stop = stop || hit
+		}
+		if stop {
+			data = setJSONValue(data, fmt.Sprintf("candidates.%d.finishReason", i), geminiSafetyFinishReason)
+			stopped = true
+		}
+	}
+	return data, stopped
+}
+
+// moderateResponsesOutput 检查 Responses 对象 output[].content[] 中的 output_text，prefix 为对象所在路径
+func moderateResponsesOutput(scanner *CompletionSensitiveScanner, data string, prefix string) (string, bool) {
+	stopped := false
+	outputs := int(gjson.Get(data, prefix+"output.#").Int())
+	for i := 0; i < outputs; i++ {
+		parts := int(gjson.Get(data, fmt.Sprintf("%soutput.%d.content.#", prefix, i)).Int())
+		for j := 0; j < parts; j++ {
+			if gjson.Get(data, fmt.Sprintf("%soutput.%d.content.%d.type", prefix, i, j)).String() != "output_text" {
+				continue
+			}
+			var stop bool
+			data, stop = scanJSONText(scanner, data, fmt.Sprintf("output:%d:%d", i, j), fmt.Sprintf("%soutput.%d.content.%d.text", prefix, i, j), true)
+			stopped = stopped || stop
+		}
+	}
+	if stopped {
+		data = markResponsesIncomplete(data, prefix)
+	}
+	return data, stopped
+}
+
+func markResponsesIncomplete(data string, prefix string) string {
+	data = setJSONValue(data, prefix+"status", "incomplete")
+	return setJSONValue(data, prefix+"incomplete_details", map[string]string{"reason": openAIContentFilterFinishReason})
+}
+
+// redactJSONFullText 替换完整文本中的敏感词，用于 Responses 流中重复携带全文的 done 事件。
+// 命中的敏感词已在增量事件中记录，这里不再计入
+func redactJSONFullText(data string, path string) string {
+	result := gjson.Get(data, path)
+	if result.Type != gjson.String || result.Str == "" {
+		return data
+	}
+	if hit, _, text := SensitiveWordReplace(result.Str, false); hit {
+		data = setJSONValue(data, path, text)
+	}
+	return data
+}
+
+func redactResponsesOutputFullText(data string, prefix string) string {
+	outputs := int(gjson.Get(data, prefix+"output.#").Int())
+	for i := 0; i < outputs; i++ {
+		data = redactResponsesContentFullText(data, fmt.Sprintf("%soutput.%d.", prefix, i))
+	}
+	return data
+}
+
+func redactResponsesContentFullText(data string, prefix string) string {
+	parts := int(gjson.Get(data, prefix+"content.#").Int())
+	for j := 0; j < parts; j++ {
+		data = redactJSONFullText(data, fmt.Sprintf("%scontent.%d.text", prefix, j))
+	}
+	return data
+}
+
+// moderateResponsesStreamEvent 检查 Responses 流事件：output_text.delta 增量扫描，output_text.done 时
+// 补发暂存文本的增量事件，其余携带全文的事件按全文替换
+func moderateResponsesStreamEvent(scanner *CompletionSensitiveScanner, data string, eventType string) (string, string, bool) {
+	if response := gjson.Get(data, "response"); response.IsObject() {
+		scanner.responseSnapshot = response.Raw
+	}
+	key := fmt.Sprintf("output:%d:%d", gjson.Get(data, "output_index").Int(), gjson.Get(data, "content_index").Int())
+	switch eventType {
+	case "response.output_text.delta":
+		data, stopped := scanJSONText(scanner, data, key, "delta", false)
+		return data, "", stopped
+	case "response.output_text.done":
+		data = redactJSONFullText(data, "text")
+		if len(scanner.pending[key]) == 0 {
+			return data, "", false
+		}
+		text, stopped := scanner.Scan(key, "", true)
+		flush := setJSONValue(`{"type":"response.output_text.delta"}`, "item_id", gjson.Get(data, "item_id").String())
+		flush = setJSONValue(flush, "output_index", gjson.Get(data, "output_index").Int())
+		flush = setJSONValue(flush, "content_index", gjson.Get(data, "content_index").Int())
+		return data, setJSONValue(flush, "delta", text), stopped
+	case "response.content_part.added", "response.content_part.done":
+		return redactJSONFullText(data, "part.text"), "", false
+	case "response.output_item.added", "response.output_item.done":
+		return redactResponsesContentFullText(data, "item."), "", false
+	}
+	if gjson.Get(data, "response").IsObject() {
+		data = redactResponsesOutputFullText(data, "response.")
+	}
+	return data, "", false
+}
+
+// ResponsesSensitiveIncompleteEvent 返回因敏感词终止 Responses 流时补发的 response.incomplete 事件
+func ResponsesSensitiveIncompleteEvent(c *gin.Context) string {
+	response := `{"object":"response"}`
+	if scanner, ok := common.GetContextKeyType[*CompletionSensitiveScanner](c, constant.ContextKeyCompletionSensitive); ok && scanner != nil && scanner.responseSnapshot != "" {
+		response = scanner.responseSnapshot
+	}
+	response = setJSONValue(response, "output", []any{})
+	response = setJSONValue(response, "usage", nil)
+	data := setJSONValue(`{"type":"`+responsesIncompleteEventType+`"}`, "response", gjson.Parse(response).Value())
+	return markResponsesIncomplete(data, "response.")
+}
+
+func isResponsesStreamEvent(eventType string) bool {
+	return strings.HasPrefix(eventType, "response.")
+}
+
+func isClaudeStreamEvent(eventType string) bool {
+	return strings.HasPrefix(eventType, "message_") || strings.HasPrefix(eventType, "content_block_")
+}
+
+// ModerateCompletionStreamChunk 检查一条流式输出（OpenAI、Responses、Claude、Gemini 格式），返回处理后的数据、
+// 需要在该条数据之前补发的增量事件（Claude、Responses 格式结束内容块时输出暂存的文本）、是否继续输出该条数据，
+// 以及是否触发终止。终止后除不含输出内容的用量数据外不再输出。
+// Claude 与 Responses 格式触发终止时由调用方补发结束事件
+func ModerateCompletionStreamChunk(c *gin.Context, data string) (string, string, bool, bool) {
+	scanner := getCompletionSensitiveScanner(c)
+	if scanner == nil || !strings.HasPrefix(data, "{") {
+		return data, "", true, false
+	}
+	wasStopped := scanner.Stopped()
+	switch eventType := gjson.Get(data, "type").String(); {
+	case gjson.Get(data, "choices").Exists():
+		if wasStopped {
+			return data, "", gjson.Get(data, "choices.#").Int() == 0, false
+		}
+		data, stopped := moderateOpenAICompletion(scanner, data, "delta")
+		return data, "", true, stopped
+	case gjson.Get(data, "candidates").Exists():
+		if wasStopped {
+			return data, "", false, false
+		}
+		data, stopped := moderateGeminiCompletion(scanner, data, true)
+		return data, "", true, stopped
+	case isResponsesStreamEvent(eventType):
+		if wasStopped {
+			return data, "", false, false
+		}
+		data, flush, stopped := moderateResponsesStreamEvent(scanner, data, eventType)
+		// 补发的文本触发终止时，该条事件由调用方补发的结束事件代替
+		return data, flush, !(stopped && flush != ""), stopped
+	case isClaudeStreamEvent(eventType):
+		if wasStopped {
+			return data, "", false, false
+		}
+		key := "content:" + gjson.Get(data, "index").String()
+		switch {
+		case eventType == "content_block_delta" && gjson.Get(data, "delta.type").String() == "text_delta":
+			data, stopped := scanJSONText(scanner, data, key, "delta.text", false)
+			return data, "", true, stopped
+		case eventType == "content_block_stop" && len(scanner.pending[key]) > 0:
+			text, stopped := scanner.Scan(key, "", true)
+			flush := fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, gjson.Get(data, "index").Int())
+			// 终止时调用方补发的结束事件中已包含 content_block_stop
+			return data, setJSONValue(flush, "delta.text", text), !stopped, stopped
+		}
+	}
+	return data, "", true, false
+}
+
+// ModerateCompletionBody 检查非流式响应体（OpenAI、Responses、Claude、Gemini 格式），替换敏感词或截断到首个
+// 敏感词之前并设置对应的结束原因，无法识别的响应原样返回
+func ModerateCompletionBody(c *gin.Context, body []byte) []byte {
+	scanner := getCompletionSensitiveScanner(c)
+	if scanner == nil || len(body) == 0 || body[0] != '{' || !gjson.ValidBytes(body) {
+		return body
+	}
+	data := string(body)
+	switch {
+	case gjson.Get(data, "choices").Exists():
+		data, _ = moderateOpenAICompletion(scanner, data, "message")
+	case gjson.Get(data, "candidates").Exists():
+		data, _ = moderateGeminiCompletion(scanner, data, false)
+	case gjson.Get(data, "object").String() == "response":
+		data, _ = moderateResponsesOutput(scanner, data, "")
+	case gjson.Get(data, "type").String() == "message":
+		count := int(gjson.Get(data, "content.#").Int())
+		for i := 0; i < count; i++ {
+			if gjson.Get(data, fmt.Sprintf("content.%d.type", i)).String() != "text" {
+				continue
+			}
+			var stop bool
+			data, stop = scanJSONText(scanner, data, fmt.Sprintf("content:%d", i), fmt.Sprintf("content.%d.text", i), true)
+			if stop {
+				data = setJSONValue(data, "stop_reason", ClaudeRefusalStopReason)
+			}
+		}
+	default:
+		return body
+	}
+	return []byte(data)
+}
+
+// appendCompletionSensitiveLogInfo 在消费日志中记录输出命中的敏感词及处理方式
+func appendCompletionSensitiveLogInfo(ctx *gin.Context, other map[string]interface{}) {
+	scanner, ok := common.GetContextKeyType[*CompletionSensitiveScanner](ctx, constant.ContextKeyCompletionSensitive)
+	if !ok || scanner == nil || len(scanner.words) == 0 {
+		return
+	}
+	other["completion_sensitive_words"] = scanner.Words()
+	if scanner.stopOnHit {
+		other["completion_sensitive_action"] = completionSensitiveActionStop
+	} else {
+		other["completion_sensitive_action"] = completionSensitiveActionRedact
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func useTestSensitiveWords(t *testing.T, stopOnHit bool, words ...string) *gin.Context {
+	t.Helper()
+	oldWords, oldEnabled, oldCompletion, oldStop := setting.SensitiveWords, setting.CheckSensitiveEnabled,
+		setting.CheckSensitiveOnCompletionEnabled, setting.StopOnSensitiveEnabled
+	t.Cleanup(func() {
+		setting.SensitiveWords, setting.CheckSensitiveEnabled = oldWords, oldEnabled
+		setting.CheckSensitiveOnCompletionEnabled, setting.StopOnSensitiveEnabled = oldCompletion, oldStop
+	})
+	setting.SensitiveWords = words
+	setting.CheckSensitiveEnabled = true
+	setting.CheckSensitiveOnCompletionEnabled = true
+	setting.StopOnSensitiveEnabled = stopOnHit
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	return c
+}
+
+func TestSensitiveWordReplaceUsesRunePositions(t *testing.T) {
+	useTestSensitiveWords(t, false, "敏感词", "BAD")
+
+	ok, words, text := SensitiveWordReplace("前缀敏感词中间bad结尾", false)
+	require.True(t, ok)
+	assert.ElementsMatch(t, []string{"敏感词", "bad"}, words)
+	assert.Equal(t, "前缀**###**中间**###**结尾", text)
+}
+
+func TestCompletionSensitiveScannerRedactsAcrossChunks(t *testing.T) {
+	useTestSensitiveWords(t, false, "forbidden")
+	scanner := NewCompletionSensitiveScanner(false)
+
+	// 末尾 8 个字符可能是敏感词的开头，暂存到下一次
+	out, stop := scanner.Scan("choice:0", "this is forb", false)
+	assert.False(t, stop)
+	assert.Equal(t, "this", out)
+	out, stop = scanner.Scan("choice:0", "idden text", false)
+	assert.False(t, stop)
+	assert.Equal(t, " is **###**", out)
+	out, _ = scanner.Scan("choice:0", "", true)
+	assert.Equal(t, " text", out)
+	out, _ = scanner.Scan("choice:1", "idden", false)
+	assert.Equal(t, "", out)
+	out, _ = scanner.Scan("choice:1", "", true)
+	assert.Equal(t, "idden", out)
+	assert.Equal(t, []string{"forbidden"}, scanner.Words())
+}
+
+func TestModerateCompletionStreamChunkStopsOpenAIStream(t *testing.T) {
+	c := useTestSensitiveWords(t, true, "forbidden")
+
+	// 敏感词的前半部分暂存，不会先于命中输出
+	data, _, keep, stopped := ModerateCompletionStreamChunk(c, `{"id":"1","choices":[{"index":0,"delta":{"content":"ok forbi"}}]}`)
+	assert.True(t, keep)
+	assert.False(t, stopped)
+	assert.Equal(t, "", gjson.Get(data, "choices.0.delta.content").String())
+
+	data, _, keep, stopped = ModerateCompletionStreamChunk(c, `{"id":"1","choices":[{"index":0,"delta":{"content":"dden!"}}]}`)
+	assert.True(t, keep)
+	assert.True(t, stopped)
+	assert.Equal(t, "ok ", gjson.Get(data, "choices.0.delta.content").String())
+	assert.Equal(t, "content_filter", gjson.Get(data, "choices.0.finish_reason").String())
+	assert.True(t, IsCompletionSensitiveStopped(c))
+
+	// 终止后丢弃内容分片，只保留用量分片
+	_, _, keep, _ = ModerateCompletionStreamChunk(c, `{"id":"1","choices":[{"index":0,"delta":{"content":"more"}}]}`)
+	assert.False(t, keep)
+	_, _, keep, _ = ModerateCompletionStreamChunk(c, `{"id":"1","choices":[],"usage":{"total_tokens":3}}`)
+	assert.True(t, keep)
+
+	other := map[string]interface{}{}
+	appendCompletionSensitiveLogInfo(c, other)
+	assert.Equal(t, []string{"forbidden"}, other["completion_sensitive_words"])
+	assert.Equal(t, "stop", other["completion_sensitive_action"])
+}
+
+func TestModerateCompletionStreamChunkClaudeAndGemini(t *testing.T) {
+	c := useTestSensitiveWords(t, false, "forbidden")
+
+	data, _, keep, stopped := ModerateCompletionStreamChunk(c, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"a forbidden b"}}`)
+	assert.True(t, keep)
+	assert.False(t, stopped)
+	assert.Equal(t, "a **###**", gjson.Get(data, "delta.text").String())
+	// 内容块结束前补发暂存的文本
+	data, flush, keep, _ := ModerateCompletionStreamChunk(c, `{"type":"content_block_stop","index":0}`)
+	assert.True(t, keep)
+	assert.Equal(t, "content_block_stop", gjson.Get(data, "type").String())
+	assert.Equal(t, "content_block_delta", gjson.Get(flush, "type").String())
+	assert.Equal(t, " b", gjson.Get(flush, "delta.text").String())
+
+	data, _, _, _ = ModerateCompletionStreamChunk(c, `{"candidates":[{"content":{"parts":[{"text":"FORBIDDEN"}]},"finishReason":"STOP","index":0}]}`)
+	assert.Equal(t, "**###**", gjson.Get(data, "candidates.0.content.parts.0.text").String())
+}
+
+func TestModerateCompletionBodyTruncatesOnStop(t *testing.T) {
+	c := useTestSensitiveWords(t, true, "forbidden")
+
+	body := ModerateCompletionBody(c, []byte(`{"type":"message","content":[{"type":"text","text":"safe forbidden tail"}],"stop_reason":"end_turn"}`))
+	assert.Equal(t, "safe ", gjson.GetBytes(body, "content.0.text").String())
+	assert.Equal(t, "refusal", gjson.GetBytes(body, "stop_reason").String())
+
+	// 非 JSON 响应原样返回
+	assert.Equal(t, []byte("forbidden"), ModerateCompletionBody(c, []byte("forbidden")))
+}
+
+func TestModerateCompletionStreamChunkStopsResponsesStream(t *testing.T) {
+	c := useTestSensitiveWords(t, true, "forbidden")
+
+	_, _, keep, _ := ModerateCompletionStreamChunk(c, `{"type":"response.created","response":{"id":"resp_1","object":"response","model":"gpt-4o","status":"in_progress","output":[]}}`)
+	assert.True(t, keep)
+	data, _, keep, stopped := ModerateCompletionStreamChunk(c, `{"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"ok forbi"}`)
+	assert.True(t, keep)
+	assert.False(t, stopped)
+	assert.Equal(t, "", gjson.Get(data, "delta").String())
+	data, _, keep, stopped = ModerateCompletionStreamChunk(c, `{"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"dden!"}`)
+	assert.True(t, keep)
+	assert.True(t, stopped)
+	assert.Equal(t, "ok ", gjson.Get(data, "delta").String())
+
+	// 终止后上游事件（包括携带全文的 done 事件）不再输出
+	_, _, keep, _ = ModerateCompletionStreamChunk(c, `{"type":"response.output_text.done","output_index":0,"content_index":0,"text":"ok forbidden!"}`)
+	assert.False(t, keep)
+
+	event := ResponsesSensitiveIncompleteEvent(c)
+	assert.Equal(t, "response.incomplete", gjson.Get(event, "type").String())
+	assert.Equal(t, "resp_1", gjson.Get(event, "response.id").String())
+	assert.Equal(t, "incomplete", gjson.Get(event, "response.status").String())
+	assert.Equal(t, "content_filter", gjson.Get(event, "response.incomplete_details.reason").String())
+}
+
+func TestModerateCompletionStreamChunkRedactsResponsesFullText(t *testing.T) {
+	c := useTestSensitiveWords(t, false, "forbidden")
+
+	data, _, _, _ := ModerateCompletionStreamChunk(c, `{"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"a forbidden b"}`)
+	assert.Equal(t, "a **###**", gjson.Get(data, "delta").String())
+	// done 事件前补发暂存的文本
+	data, flush, keep, _ := ModerateCompletionStreamChunk(c, `{"type":"response.output_text.done","item_id":"msg_1","output_index":0,"content_index":0,"text":"a forbidden b"}`)
+	assert.True(t, keep)
+	assert.Equal(t, "a **###** b", gjson.Get(data, "text").String())
+	assert.Equal(t, "response.output_text.delta", gjson.Get(flush, "type").String())
+	assert.Equal(t, "msg_1", gjson.Get(flush, "item_id").String())
+	assert.Equal(t, " b", gjson.Get(flush, "delta").String())
+	data, _, _, _ = ModerateCompletionStreamChunk(c, `{"type":"response.completed","response":{"object":"response","status":"completed","output":[{"type":"message","content":[{"type":"output_text","text":"a forbidden b"}]}]}}`)
+	assert.Equal(t, "a **###** b", gjson.Get(data, "response.output.0.content.0.text").String())
+	assert.Equal(t, "completed", gjson.Get(data, "response.status").String())
+}
+
+func TestModerateCompletionBodyResponses(t *testing.T) {
+	c := useTestSensitiveWords(t, true, "forbidden")
+
+	body := ModerateCompletionBody(c, []byte(`{"id":"resp_1","object":"response","status":"completed","output":[{"type":"reasoning","summary":[]},{"type":"message","content":[{"type":"output_text","text":"safe forbidden tail"},{"type":"output_text","text":"more"}]}]}`))
+	assert.Equal(t, "safe ", gjson.GetBytes(body, "output.1.content.0.text").String())
+	assert.Equal(t, "", gjson.GetBytes(body, "output.1.content.1.text").String())
+	assert.Equal(t, "incomplete", gjson.GetBytes(body, "status").String())
+	assert.Equal(t, "content_filter", gjson.GetBytes(body, "incomplete_details.reason").String())
+}
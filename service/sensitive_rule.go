@@ -0,0 +1,437 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// piiDetector PII 检测器，validate 用于排除格式相符但校验位不正确的误报
+type piiDetector struct {
+	pattern  *regexp.Regexp
+	validate func(match string) bool
+}
+
+var piiDetectors = map[string]piiDetector{
+	operation_setting.PIITypeEmail: {
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	},
+	operation_setting.PIITypePhone: {
+		pattern: regexp.MustCompile(`(?:\+?86[ \-]?)?\b1[3-9]\d{9}\b|\+\d{1,3}[ \-]?\(?\d{1,4}\)?(?:[ \-]?\d{2,4}){2,3}\b|\b\(?\d{3}\)?[ \-.]\d{3}[ \-.]\d{4}\b`),
+	},
+	operation_setting.PIITypeIdNumber: {
+		pattern:  regexp.MustCompile(`\b\d{17}[\dXx]\b|\b\d{3}-\d{2}-\d{4}\b`),
+		validate: validateIdNumber,
+	},
+	operation_setting.PIITypeCreditCard: {
+		pattern:  regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		validate: validateLuhn,
+	},
+}
+
+// validateIdNumber 校验 18 位居民身份证号的校验位，其他格式（如 SSN）直接通过
+func validateIdNumber(match string) bool {
+	if len(match) != 18 {
+		return true
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, weight := range weights {
+		sum += int(match[i]-'0') * weight
+	}
+	return strings.EqualFold(string("10X98765432"[sum%11]), match[17:])
+}
+
+func validateLuhn(match string) bool {
+	sum, digits := 0, 0
+	for i := len(match) - 1; i >= 0; i-- {
+		c := match[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+type compiledSensitiveRule struct {
+	name     string
+	category string
+	pattern  *regexp.Regexp
+	validate func(match string) bool
+}
+
+type compiledSensitiveRuleSet struct {
+	name   string
+	action string
+	rules  []compiledSensitiveRule
+}
+
+var sensitiveRuleCache sync.Map
+
+// compileSensitiveRule 编译单条规则，字面量规则转换为不区分大小写的正则，结果按类型与表达式缓存
+func compileSensitiveRule(rule operation_setting.SensitiveRule) (compiledSensitiveRule, bool) {
+	compiled := compiledSensitiveRule{name: rule.Name, category: rule.Category}
+	if rule.Type == operation_setting.SensitiveRuleTypePII {
+		detector, ok := piiDetectors[rule.Pattern]
+		if !ok {
+			return compiled, false
+		}
+		compiled.pattern, compiled.validate = detector.pattern, detector.validate
+		if compiled.category == "" {
+			compiled.category = rule.Pattern
+		}
+		return compiled, true
+	}
+	cacheKey := rule.Type + "\x00" + rule.Pattern
+	if cached, ok := sensitiveRuleCache.Load(cacheKey); ok {
+		compiled.pattern = cached.(*regexp.Regexp)
+		return compiled, true
+	}
+	expr := rule.Pattern
+	if rule.Type == operation_setting.SensitiveRuleTypeLiteral {
+		words := make([]string, 0)
+		for _, word := range strings.Split(rule.Pattern, "\n") {
+			if word = strings.TrimSpace(word); word != "" {
+				words = append(words, regexp.QuoteMeta(word))
+			}
+		}
+		if len(words) == 0 {
+			return compiled, false
+		}
+		expr = "(?i)" + strings.Join(words, "|")
+	} else if rule.Type != operation_setting.SensitiveRuleTypeRegex {
+		return compiled, false
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		common.SysError(fmt.Sprintf("invalid sensitive rule %s: %s", rule.Name, err.Error()))
+		return compiled, false
+	}
+	sensitiveRuleCache.Store(cacheKey, pattern)
+	compiled.pattern = pattern
+	return compiled, true
+}
+
+// resolveSensitiveRuleSets 返回请求生效的规则集：分组绑定的规则集加上令牌附加的规则集
+func resolveSensitiveRuleSets(c *gin.Context, group string) []compiledSensitiveRuleSet {
+	setting := operation_setting.GetSensitiveRuleSetting()
+	if !setting.Enabled || len(setting.RuleSets) == 0 {
+		return nil
+	}
+	names := append([]string{}, operation_setting.GetGroupSensitiveRuleSets(group)...)
+	for _, name := range strings.Split(common.GetContextKeyString(c, constant.ContextKeyTokenSensitiveRuleSets), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+	ruleSets := make([]compiledSensitiveRuleSet, 0, len(wanted))
+	for _, ruleSet := range setting.RuleSets {
+		if _, ok := wanted[ruleSet.Name]; !ok || !ruleSet.Enabled {
+			continue
+		}
+		compiled := compiledSensitiveRuleSet{name: ruleSet.Name, action: ruleSet.Action}
+		for _, rule := range ruleSet.Rules {
+			if compiledRule, ok := compileSensitiveRule(rule); ok {
+				compiled.rules = append(compiled.rules, compiledRule)
+			}
+		}
+		if len(compiled.rules) > 0 {
+			ruleSets = append(ruleSets, compiled)
+		}
+	}
+	return ruleSets
+}
+
+// SensitiveRuleHit 规则集命中，记录到消费日志与命中记录表
+type SensitiveRuleHit struct {
+	RuleSet  string `json:"rule_set"`
+	Rule     string `json:"rule"`
+	Category string `json:"category"`
+	Action   string `json:"action"`
+	sample   string
+}
+
+type sensitiveMatch struct {
+	start, end int
+}
+
+func (r compiledSensitiveRule) find(text string) []sensitiveMatch {
+	matches := make([]sensitiveMatch, 0)
+	for _, loc := range r.pattern.FindAllStringIndex(text, -1) {
+		if r.validate != nil && !r.validate(text[loc[0]:loc[1]]) {
+			continue
+		}
+		matches = append(matches, sensitiveMatch{start: loc[0], end: loc[1]})
+	}
+	return matches
+}
+
+// maskSensitiveSample 保留命中片段首尾各一个字符，用于命中记录
+func maskSensitiveSample(match string) string {
+	runes := []rune(match)
+	if len(runes) <= 2 {
+		return strings.Repeat("*", len(runes))
+	}
+	if len(runes) > 64 {
+		runes = runes[:64]
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
+
+// matchSensitiveRuleSets 返回文本命中的规则（同一规则只记录一次）
+func matchSensitiveRuleSets(ruleSets []compiledSensitiveRuleSet, text string) []SensitiveRuleHit {
+	hits := make([]SensitiveRuleHit, 0)
+	for _, ruleSet := range ruleSets {
+		for _, rule := range ruleSet.rules {
+			matches := rule.find(text)
+			if len(matches) == 0 {
+				continue
+			}
+			hits = append(hits, SensitiveRuleHit{
+				RuleSet:  ruleSet.name,
+				Rule:     rule.name,
+				Category: rule.category,
+				Action:   ruleSet.action,
+				sample:   maskSensitiveSample(text[matches[0].start:matches[0].end]),
+			})
+		}
+	}
+	return hits
+}
+
+// maskSensitiveText 将 action 为 mask 的规则集命中内容替换为 **###**
+func maskSensitiveText(ruleSets []compiledSensitiveRuleSet, text string) string {
+	matches := make([]sensitiveMatch, 0)
+	for _, ruleSet := range ruleSets {
+		if ruleSet.action != operation_setting.SensitiveActionMask {
+			continue
+		}
+		for _, rule := range ruleSet.rules {
+			matches = append(matches, rule.find(text)...)
+		}
+	}
+	if len(matches) == 0 {
+		return text
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	var builder strings.Builder
+	builder.Grow(len(text))
+	lastPos := 0
+	for _, match := range matches {
+		if match.end <= lastPos {
+			continue
+		}
+		builder.WriteString(text[lastPos:max(match.start, lastPos)])
+		builder.WriteString("**###**")
+		lastPos = match.end
+	}
+	builder.WriteString(text[lastPos:])
+	return builder.String()
+}
+
+// requestTextKeys 请求体中承载用户文本的字段，其下的字符串（含嵌套数组）会被处理
+var requestTextKeys = map[string]bool{
+	"content":      true,
+	"text":         true,
+	"prompt":       true,
+	"input":        true,
+	"system":       true,
+	"instructions": true,
+	"query":        true,
+	"documents":    true,
+}
+
+func transformJSONText(value interface{}, inText bool, transform func(string) string) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		if !inText {
+			return v, false
+		}
+		transformed := transform(v)
+		return transformed, transformed != v
+	case []interface{}:
+		changed := false
+		for i, item := range v {
+			var itemChanged bool
+			v[i], itemChanged = transformJSONText(item, inText, transform)
+			changed = changed || itemChanged
+		}
+		return v, changed
+	case map[string]interface{}:
+		changed := false
+		for key, item := range v {
+			var itemChanged bool
+			v[key], itemChanged = transformJSONText(item, requestTextKeys[key], transform)
+			changed = changed || itemChanged
+		}
+		return v, changed
+	}
+	return value, false
+}
+
+// TransformRequestBodyText 对 JSON 请求体中的用户文本字段逐个调用 transform，数字保持原始精度
+func TransformRequestBodyText(body []byte, transform func(string) string) ([]byte, bool, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return body, false, err
+	}
+	root, changed := transformJSONText(root, false, transform)
+	if !changed {
+		return body, false, nil
+	}
+	transformed, err := common.Marshal(root)
+	if err != nil {
+		return body, false, err
+	}
+	return transformed, true, nil
+}
+
+// maskSensitiveRequest 对原始请求体应用脱敏，并同步到已解析的请求与请求体缓存
+func maskSensitiveRequest(c *gin.Context, request dto.Request, ruleSets []compiledSensitiveRuleSet) error {
+	if !strings.HasPrefix(c.GetHeader("Content-Type"), "application/json") {
+		return nil
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return err
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return err
+	}
+	masked, changed, err := TransformRequestBodyText(body, func(text string) string {
+		return maskSensitiveText(ruleSets, text)
+	})
+	if err != nil || !changed {
+		return err
+	}
+	// 解析到新对象再整体替换，避免残留旧内容的解析缓存
+	fresh := reflect.New(reflect.TypeOf(request).Elem())
+	if err := common.Unmarshal(masked, fresh.Interface()); err != nil {
+		return err
+	}
+	reflect.ValueOf(request).Elem().Set(fresh.Elem())
+	newStorage, err := common.CreateBodyStorage(masked)
+	if err != nil {
+		return err
+	}
+	storage.Close()
+	c.Set(common.KeyBodyStorage, newStorage)
+	return nil
+}
+
+// NeedSensitiveRules 当前请求是否绑定了规则集
+func NeedSensitiveRules(c *gin.Context, relayInfo *relaycommon.RelayInfo) bool {
+	setting := operation_setting.GetSensitiveRuleSetting()
+	if !setting.Enabled || len(setting.RuleSets) == 0 {
+		return false
+	}
+	return len(operation_setting.GetGroupSensitiveRuleSets(relayInfo.UsingGroup)) > 0 ||
+		common.GetContextKeyString(c, constant.ContextKeyTokenSensitiveRuleSets) != ""
+}
+
+// ApplySensitiveRules 按分组与令牌的规则集检查 prompt：block 拒绝请求，mask 脱敏后转发，log 仅记录。
+// 命中记录异步写入命中记录表，并保存在上下文中写入消费日志
+func ApplySensitiveRules(c *gin.Context, relayInfo *relaycommon.RelayInfo, request dto.Request, text string) *types.NewAPIError {
+	ruleSets := resolveSensitiveRuleSets(c, relayInfo.UsingGroup)
+	if len(ruleSets) == 0 || text == "" {
+		return nil
+	}
+	hits := matchSensitiveRuleSets(ruleSets, text)
+	if len(hits) == 0 {
+		return nil
+	}
+	common.SetContextKey(c, constant.ContextKeySensitiveRuleHits, hits)
+	recordSensitiveRuleHits(c, relayInfo, hits)
+
+	action := operation_setting.SensitiveActionLog
+	for _, hit := range hits {
+		if hit.Action == operation_setting.SensitiveActionBlock {
+			logger.LogWarn(c, fmt.Sprintf("request blocked by sensitive rule set %s (rule %s)", hit.RuleSet, hit.Rule))
+			return types.NewErrorWithStatusCode(fmt.Errorf("request blocked by sensitive rule: %s", hit.Category),
+				types.ErrorCodeSensitiveWordsDetected, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		if hit.Action == operation_setting.SensitiveActionMask {
+			action = operation_setting.SensitiveActionMask
+		}
+	}
+	if action == operation_setting.SensitiveActionMask {
+		if err := maskSensitiveRequest(c, request, ruleSets); err != nil {
+			// 无法脱敏时不能把原文转发到上游
+			return types.NewErrorWithStatusCode(fmt.Errorf("mask sensitive content failed: %w", err),
+				types.ErrorCodeSensitiveWordsDetected, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+	}
+	return nil
+}
+
+func recordSensitiveRuleHits(c *gin.Context, relayInfo *relaycommon.RelayInfo, hits []SensitiveRuleHit) {
+	now := common.GetTimestamp()
+	records := make([]*model.SensitiveHit, 0, len(hits))
+	for _, hit := range hits {
+		records = append(records, &model.SensitiveHit{
+			CreatedAt: now,
+			UserId:    relayInfo.UserId,
+			Username:  c.GetString("username"),
+			TokenId:   relayInfo.TokenId,
+			Group:     relayInfo.UsingGroup,
+			ModelName: relayInfo.OriginModelName,
+			RuleSet:   hit.RuleSet,
+			Rule:      hit.Rule,
+			Category:  hit.Category,
+			Action:    hit.Action,
+			Sample:    hit.sample,
+			RequestId: c.GetString(common.RequestIdKey),
+		})
+	}
+	gopool.Go(func() {
+		if err := model.RecordSensitiveHits(records); err != nil {
+			common.SysError("failed to record sensitive hits: " + err.Error())
+		}
+	})
+}
+
+// appendSensitiveRuleLogInfo 在消费日志中记录规则集命中情况
+func appendSensitiveRuleLogInfo(ctx *gin.Context, other map[string]interface{}) {
+	hits, ok := common.GetContextKeyType[[]SensitiveRuleHit](ctx, constant.ContextKeySensitiveRuleHits)
+	if !ok || len(hits) == 0 {
+		return
+	}
+	other["sensitive_rule_hits"] = hits
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useTestSensitiveRules(t *testing.T, ruleSets ...operation_setting.SensitiveRuleSet) *operation_setting.SensitiveRuleSetting {
+	t.Helper()
+	setting := operation_setting.GetSensitiveRuleSetting()
+	oldSetting := *setting
+	t.Cleanup(func() { *setting = oldSetting })
+	setting.Enabled = true
+	setting.RuleSets = ruleSets
+	setting.DefaultRuleSets = []string{}
+	setting.GroupRuleSets = map[string][]string{}
+	return setting
+}
+
+func newSensitiveRuleTestContext(body string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c
+}
+
+func TestPIIDetectorsValidateChecksums(t *testing.T) {
+	find := func(piiType, text string) bool {
+		rule, ok := compileSensitiveRule(operation_setting.SensitiveRule{Type: operation_setting.SensitiveRuleTypePII, Pattern: piiType})
+		require.True(t, ok)
+		return len(rule.find(text)) > 0
+	}
+	assert.True(t, find(operation_setting.PIITypeEmail, "mail me at alice.w@example.co.uk"))
+	assert.True(t, find(operation_setting.PIITypePhone, "call 13812345678 now"))
+	assert.True(t, find(operation_setting.PIITypeIdNumber, "id 11010519491231002X"))
+	assert.False(t, find(operation_setting.PIITypeIdNumber, "id 110105194912310021"))
+	assert.True(t, find(operation_setting.PIITypeIdNumber, "ssn 123-45-6789"))
+	assert.True(t, find(operation_setting.PIITypeCreditCard, "card 4111 1111 1111 1111"))
+	assert.False(t, find(operation_setting.PIITypeCreditCard, "card 4111 1111 1111 1112"))
+}
+
+func TestApplySensitiveRulesUsesStrongestAction(t *testing.T) {
+	setting := useTestSensitiveRules(t,
+		operation_setting.SensitiveRuleSet{Name: "audit", Enabled: true, Action: operation_setting.SensitiveActionLog,
+			Rules: []operation_setting.SensitiveRule{{Name: "secret", Type: operation_setting.SensitiveRuleTypeLiteral, Pattern: "project x", Category: "internal"}}},
+		operation_setting.SensitiveRuleSet{Name: "strict", Enabled: true, Action: operation_setting.SensitiveActionBlock,
+			Rules: []operation_setting.SensitiveRule{{Name: "ticket", Type: operation_setting.SensitiveRuleTypeRegex, Pattern: `TICKET-\d+`, Category: "ticket"}}},
+	)
+	setting.DefaultRuleSets = []string{"audit"}
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"Project X TICKET-42"}]}`
+	c := newSensitiveRuleTestContext(body)
+	info := &relaycommon.RelayInfo{UsingGroup: "default"}
+	assert.Nil(t, ApplySensitiveRules(c, info, &dto.GeneralOpenAIRequest{}, "Project X TICKET-42"))
+	other := map[string]interface{}{}
+	appendSensitiveRuleLogInfo(c, other)
+	hits, ok := other["sensitive_rule_hits"].([]SensitiveRuleHit)
+	require.True(t, ok)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "audit", hits[0].RuleSet)
+
+	// 令牌附加的规则集与分组规则集叠加
+	c = newSensitiveRuleTestContext(body)
+	common.SetContextKey(c, constant.ContextKeyTokenSensitiveRuleSets, "strict")
+	apiErr := ApplySensitiveRules(c, info, &dto.GeneralOpenAIRequest{}, "Project X TICKET-42")
+	require.NotNil(t, apiErr)
+	assert.Equal(t, types.ErrorCodeSensitiveWordsDetected, apiErr.GetErrorCode())
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
+
+func TestApplySensitiveRulesMasksRequestBody(t *testing.T) {
+	setting := useTestSensitiveRules(t, operation_setting.SensitiveRuleSet{Name: "pii", Enabled: true, Action: operation_setting.SensitiveActionMask,
+		Rules: []operation_setting.SensitiveRule{{Name: "email", Type: operation_setting.SensitiveRuleTypePII, Pattern: operation_setting.PIITypeEmail}}})
+	setting.GroupRuleSets = map[string][]string{"default": {"pii"}}
+
+	body := `{"model":"bob@example.com","temperature":0.30,"messages":[{"role":"user","content":[{"type":"text","text":"write to bob@example.com"}]}]}`
+	c := newSensitiveRuleTestContext(body)
+	request := &dto.GeneralOpenAIRequest{}
+	require.NoError(t, common.UnmarshalBodyReusable(c, request))
+	info := &relaycommon.RelayInfo{UsingGroup: "default"}
+	require.Nil(t, ApplySensitiveRules(c, info, request, "write to bob@example.com"))
+
+	storage, err := common.GetBodyStorage(c)
+	require.NoError(t, err)
+	masked, err := storage.Bytes()
+	require.NoError(t, err)
+	assert.Contains(t, string(masked), `"text":"write to **###**"`)
+	// 非文本字段与数字保持不变
+	assert.Contains(t, string(masked), `"model":"bob@example.com"`)
+	assert.Contains(t, string(masked), `"temperature":0.30`)
+	assert.Equal(t, "write to **###**", request.Messages[0].ParseContent()[0].Text)
+
+	other := map[string]interface{}{}
+	appendSensitiveRuleLogInfo(c, other)
+	hits := other["sensitive_rule_hits"].([]SensitiveRuleHit)
+	assert.Equal(t, "email", hits[0].Category)
+	assert.Equal(t, "b*************m", hits[0].sample)
+}
@@ -81,13 +81,26 @@ func runSubscriptionQuotaResetOnce() {
 			break
 		}
 	}
+	// 令牌周期预算复用同一任务清零，请求时也会按重置时间惰性清零
+	totalTokenBudgetReset := 0
+	for {
+		n, err := model.ResetDueTokenBudgets(subscriptionResetBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("token budget reset task failed: %v", err))
+			break
+		}
+		totalTokenBudgetReset += n
+		if n < subscriptionResetBatchSize {
+			break
+		}
+	}
 	lastCleanup := time.Unix(subscriptionCleanupLast.Load(), 0)
 	if time.Since(lastCleanup) >= subscriptionCleanupInterval {
 		if _, err := model.CleanupSubscriptionPreConsumeRecords(7 * 24 * 3600); err == nil {
 			subscriptionCleanupLast.Store(time.Now().Unix())
 		}
 	}
-	if common.DebugEnabled && (totalReset > 0 || totalExpired > 0) {
-		logger.LogDebug(ctx, "subscription maintenance: reset_count=%d, expired_count=%d", totalReset, totalExpired)
+	if common.DebugEnabled && (totalReset > 0 || totalExpired > 0 || totalTokenBudgetReset > 0) {
+		logger.LogDebug(ctx, "subscription maintenance: reset_count=%d, expired_count=%d, token_budget_reset_count=%d", totalReset, totalExpired, totalTokenBudgetReset)
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/pkg/billingexpr"
 	perfmetrics "github.com/QuantumNous/new-api/pkg/perf_metrics"
+	prommetrics "github.com/QuantumNous/new-api/pkg/prom_metrics"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -345,6 +346,7 @@ func usageSemanticFromUsage(relayInfo *relaycommon.RelayInfo, usage *dto.Usage)
 }
 
 func PostTextConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent []string) {
+	rememberResponseCacheUsage(ctx, usage)
 	originUsage := usage
 	billingUsage := effectiveBillingUsage(usage)
 	if usage == nil {
@@ -356,6 +358,7 @@ func PostTextConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, us
 
 	adminRejectReason := common.GetContextKeyString(ctx, constant.ContextKeyAdminRejectReason)
 	summary := calculateTextQuotaSummary(ctx, relayInfo, billingUsage)
+	reconcileTPMReservation(ctx, summary.TotalTokens)
 
 	var tieredResult *billingexpr.TieredResult
 	tieredBillingApplied := false
@@ -502,8 +505,17 @@ func PostTextConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, us
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
 	})
+	// 响应缓存命中没有经过上游渠道，不计入渠道性能与成功统计
+	cacheHit := common.GetContextKeyBool(ctx, constant.ContextKeyResponseCacheHit)
 	gopool.Go(func() {
-		perfmetrics.RecordRelaySample(relayInfo, true, int64(summary.CompletionTokens))
+		if !cacheHit {
+			perfmetrics.RecordRelaySample(relayInfo, true, int64(summary.CompletionTokens))
+		}
+		prommetrics.RecordRelay(relayInfo, true)
+		prommetrics.RecordBilling(relayInfo, summary.Quota, summary.PromptTokens, summary.CompletionTokens)
+		if cacheHit {
+			return
+		}
 		if err := RecordChannelRequestSuccessForContext(ctx, relayInfo.ChannelId); err != nil {
 			logger.LogError(ctx, "record channel request success: "+err.Error())
 		}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func tokenRateWindowKey(kind string, tokenId int) string {
+	return rateWindowKey(kind, "token", strconv.Itoa(tokenId))
+}
+
+// CheckTokenRateLimit 检查并计入令牌的 RPM/TPM，promptTokens 为预估输入 token 数。
+// 文本请求的令牌 TPM 已由 ReserveTPM 预占，调用方传入 tpmLimit=0 跳过
+func CheckTokenRateLimit(ctx context.Context, tokenId int, rpmLimit int, tpmLimit int, promptTokens int) error {
+	requests := make([]rateWindowRequest, 0, 2)
+	if rpmLimit > 0 {
+		requests = append(requests, rateWindowRequest{key: tokenRateWindowKey("RPM", tokenId), scope: "token", kind: "RPM", limit: int64(rpmLimit), weight: 1})
+	}
+	if tpmLimit > 0 && promptTokens > 0 {
+		requests = append(requests, rateWindowRequest{key: tokenRateWindowKey("TPM", tokenId), scope: "token", kind: "TPM", limit: int64(tpmLimit), weight: int64(promptTokens)})
+	}
+	_, index, retryAfter, err := globalRateWindow.acquire(ctx, requests)
+	if err != nil {
+		// 限流存储不可用时放行，避免影响正常请求
+		common.SysLog("token rate limit check failed: " + err.Error())
+		return nil
+	}
+	if index < 0 {
+		return nil
+	}
+	return requests[index].exceeded(retryAfter)
+}
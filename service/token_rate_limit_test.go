@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedRateWindowChecksAllDimensionsBeforeRecording(t *testing.T) {
+	window := &weightedRateWindow{local: make(map[string][]rateWindowSample)}
+	now := time.Unix(1_000_000, 0)
+	requests := func(tokens int64) []rateWindowRequest {
+		return []rateWindowRequest{
+			{key: "rpm", kind: "RPM", limit: 3, weight: 1},
+			{key: "tpm", kind: "TPM", limit: 100, weight: tokens},
+		}
+	}
+
+	index, _ := window.localAcquire(requests(60), "a", now)
+	assert.Equal(t, -1, index)
+	index, retryAfter := window.localAcquire(requests(60), "b", now.Add(10*time.Second))
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 50*time.Second, retryAfter)
+	// 被拒绝的请求不计入 RPM
+	assert.Len(t, window.local["rpm"], 1)
+
+	index, _ = window.localAcquire(requests(40), "c", now.Add(20*time.Second))
+	assert.Equal(t, -1, index)
+	index, _ = window.localAcquire(requests(0), "d", now.Add(30*time.Second))
+	assert.Equal(t, -1, index)
+	index, retryAfter = window.localAcquire(requests(0), "e", now.Add(40*time.Second))
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 20*time.Second, retryAfter)
+
+	// 窗口滑过后恢复
+	index, _ = window.localAcquire(requests(60), "f", now.Add(61*time.Second))
+	assert.Equal(t, -1, index)
+}
+
+func TestWeightedRateWindowAllowsOversizedRequestOnEmptyWindow(t *testing.T) {
+	window := &weightedRateWindow{local: make(map[string][]rateWindowSample)}
+	now := time.Unix(1_000_000, 0)
+	request := []rateWindowRequest{{key: "tpm", kind: "TPM", limit: 100, weight: 500}}
+
+	index, _ := window.localAcquire(request, "g", now)
+	assert.Equal(t, -1, index)
+	index, retryAfter := window.localAcquire(request, "h", now.Add(time.Second))
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 59*time.Second, retryAfter)
+}
+
+func TestWeightedRateWindowPrunesIdleKeys(t *testing.T) {
+	window := &weightedRateWindow{local: make(map[string][]rateWindowSample)}
+	now := time.Unix(1_000_000, 0)
+
+	window.localAcquire([]rateWindowRequest{{key: "idle", kind: "RPM", limit: 10, weight: 1}}, "a", now)
+	window.localAcquire([]rateWindowRequest{{key: "active", kind: "RPM", limit: 10, weight: 1}}, "b", now.Add(30*time.Second))
+
+	window.pruneLocal(now.Add(61 * time.Second))
+	assert.NotContains(t, window.local, "idle")
+	assert.Contains(t, window.local, "active")
+
+	window.pruneLocal(now.Add(91 * time.Second))
+	assert.Empty(t, window.local)
+}
+
+func TestCheckTokenRateLimitReturnsRateLimitError(t *testing.T) {
+	useTestRateWindow(t)
+
+	require.NoError(t, CheckTokenRateLimit(t.Context(), 42, 1, 0, 10))
+	err := CheckTokenRateLimit(t.Context(), 42, 1, 0, 10)
+	var rateErr *RateLimitExceededError
+	require.True(t, errors.As(err, &rateErr))
+	assert.Equal(t, "RPM", rateErr.Kind)
+	assert.True(t, IsTokenQuotaLimitError(err))
+	assert.Contains(t, err.Error(), "token RPM limit exceeded")
+}
+
+func TestCheckTokenRateLimitSkipsReservedTPM(t *testing.T) {
+	useTestRateWindow(t)
+
+	require.NoError(t, CheckTokenRateLimit(t.Context(), 42, 0, 100, 80))
+	err := CheckTokenRateLimit(t.Context(), 42, 0, 100, 80)
+	var rateErr *RateLimitExceededError
+	require.True(t, errors.As(err, &rateErr))
+	assert.Equal(t, "TPM", rateErr.Kind)
+	// ReserveTPM 已预占时调用方传入 tpmLimit=0
+	assert.NoError(t, CheckTokenRateLimit(t.Context(), 42, 0, 0, 80))
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateJSONSchema 按 JSON Schema 校验 value（由 common.Unmarshal 解析得到），
+// 只支持工具参数中常用的关键字，$ref、format、pattern 等不识别的关键字直接忽略
+func validateJSONSchema(schema any, value any, path string) error {
+	schemaMap, ok := schema.(map[string]any)
+	if !ok {
+		// true / 空 schema 不做限制，false 不允许任何值
+		if allowed, isBool := schema.(bool); isBool && !allowed {
+			return fmt.Errorf("%s: value is not allowed", schemaPath(path))
+		}
+		return nil
+	}
+
+	if value == nil && schemaMap["nullable"] == true {
+		return nil
+	}
+	if types := schemaTypes(schemaMap["type"]); len(types) > 0 {
+		if !slices.ContainsFunc(types, func(t string) bool { return matchJSONType(t, value) }) {
+			return fmt.Errorf("%s: expected %s, got %s", schemaPath(path), strings.Join(types, " or "), jsonTypeName(value))
+		}
+	}
+	if enum, ok := schemaMap["enum"].([]any); ok && len(enum) > 0 {
+		if !slices.ContainsFunc(enum, func(item any) bool { return reflect.DeepEqual(item, value) }) {
+			return fmt.Errorf("%s: value is not one of the allowed values", schemaPath(path))
+		}
+	}
+	if constValue, ok := schemaMap["const"]; ok && !reflect.DeepEqual(constValue, value) {
+		return fmt.Errorf("%s: value does not match const", schemaPath(path))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if err := validateJSONObject(schemaMap, v, path); err != nil {
+			return err
+		}
+	case []any:
+		if err := validateJSONArray(schemaMap, v, path); err != nil {
+			return err
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if minLength, ok := schemaNumber(schemaMap, "minLength"); ok && float64(length) < minLength {
+			return fmt.Errorf("%s: string is shorter than %v", schemaPath(path), minLength)
+		}
+		if maxLength, ok := schemaNumber(schemaMap, "maxLength"); ok && float64(length) > maxLength {
+			return fmt.Errorf("%s: string is longer than %v", schemaPath(path), maxLength)
+		}
+	case float64:
+		if minimum, ok := schemaNumber(schemaMap, "minimum"); ok && v < minimum {
+			return fmt.Errorf("%s: value is less than %v", schemaPath(path), minimum)
+		}
+		if maximum, ok := schemaNumber(schemaMap, "maximum"); ok && v > maximum {
+			return fmt.Errorf("%s: value is greater than %v", schemaPath(path), maximum)
+		}
+		if minimum, ok := schemaNumber(schemaMap, "exclusiveMinimum"); ok && v <= minimum {
+			return fmt.Errorf("%s: value must be greater than %v", schemaPath(path), minimum)
+		}
+		if maximum, ok := schemaNumber(schemaMap, "exclusiveMaximum"); ok && v >= maximum {
+			return fmt.Errorf("%s: value must be less than %v", schemaPath(path), maximum)
+		}
+	}
+
+	if allOf, ok := schemaMap["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if err := validateJSONSchema(sub, value, path); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := schemaMap["anyOf"].([]any); ok && len(anyOf) > 0 {
+		if countMatchedSchemas(anyOf, value, path) == 0 {
+			return fmt.Errorf("%s: value does not match any of the allowed schemas", schemaPath(path))
+		}
+	}
+	if oneOf, ok := schemaMap["oneOf"].([]any); ok && len(oneOf) > 0 {
+		if countMatchedSchemas(oneOf, value, path) != 1 {
+			return fmt.Errorf("%s: value must match exactly one of the allowed schemas", schemaPath(path))
+		}
+	}
+	return nil
+}
+
+func validateJSONObject(schema map[string]any, value map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, exists := value[name]; name != "" && !exists {
+				return fmt.Errorf("%s: missing required property %q", schemaPath(path), name)
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for _, key := range sortedObjectKeys(value) {
+		if propertySchema, ok := properties[key]; ok {
+			if err := validateJSONSchema(propertySchema, value[key], path+"."+key); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property %q", schemaPath(path), key)
+			}
+		case map[string]any:
+			if err := validateJSONSchema(additional, value[key], path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateJSONArray(schema map[string]any, value []any, path string) error {
+	if minItems, ok := schemaNumber(schema, "minItems"); ok && float64(len(value)) < minItems {
+		return fmt.Errorf("%s: array has fewer than %v items", schemaPath(path), minItems)
+	}
+	if maxItems, ok := schemaNumber(schema, "maxItems"); ok && float64(len(value)) > maxItems {
+		return fmt.Errorf("%s: array has more than %v items", schemaPath(path), maxItems)
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			if err := validateJSONSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func countMatchedSchemas(schemas []any, value any, path string) int {
+	matched := 0
+	for _, sub := range schemas {
+		if validateJSONSchema(sub, value, path) == nil {
+			matched++
+		}
+	}
+	return matched
+}
+
+func schemaTypes(raw any) []string {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchJSONType(typeName string, value any) bool {
+	switch typeName {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	// 未知类型不做限制
+	return true
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func schemaNumber(schema map[string]any, key string) (float64, bool) {
+	number, ok := schema[key].(float64)
+	return number, ok
+}
+
+func schemaPath(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return "$" + path
+}
+
+func sortedObjectKeys(value map[string]any) []string {
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
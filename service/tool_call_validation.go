@@ -0,0 +1,483 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	responsesOutputTypeFunctionCall         = "function_call"
+	responsesEventFunctionCallArgumentsDone = "response.function_call_arguments.done"
+	responsesEventCompleted                 = "response.completed"
+)
+
+// ToolCallValidationError 工具调用参数不合法
+type ToolCallValidationError struct {
+	Tool string
+	Err  error
+}
+
+func (e *ToolCallValidationError) Error() string {
+	return fmt.Sprintf("invalid arguments for tool call %q: %v", e.Tool, e.Err)
+}
+
+func (e *ToolCallValidationError) Unwrap() error {
+	return e.Err
+}
+
+// NewInvalidToolCallError 按配置的处理方式生成错误：retry 时可换渠道重试，error 时直接返回给客户端
+func NewInvalidToolCallError(err error) *types.NewAPIError {
+	if operation_setting.ShouldRetryInvalidToolCall() {
+		return types.NewOpenAIError(err, types.ErrorCodeInvalidToolCall, http.StatusBadGateway)
+	}
+	return types.NewOpenAIError(err, types.ErrorCodeInvalidToolCall, http.StatusBadGateway, types.ErrOptionWithSkipRetry())
+}
+
+// ToolCallValidator 按请求中 tools 的 parameters 校验模型输出的工具调用参数，开启修复时先做确定性修复。
+// 流式 chat 输出按 (choice, index) 缓存工具调用增量，choice 结束时一次性输出修复后的完整参数
+type ToolCallValidator struct {
+	schemas map[string]any
+	repair  bool
+	// pending choice index -> tool call index -> 缓存的工具调用
+	pending map[int]map[int]*pendingToolCall
+	// itemNames responses 流式输出中 item id 对应的函数名
+	itemNames map[string]string
+}
+
+type pendingToolCall struct {
+	id        string
+	callType  string
+	name      string
+	arguments strings.Builder
+}
+
+// NewToolCallValidator 当前分组启用了校验且请求声明了函数工具时返回校验器，否则返回 nil。
+// 校验器带有流式状态，每次请求上游都需要重新创建
+func NewToolCallValidator(info *relaycommon.RelayInfo) *ToolCallValidator {
+	if info == nil || !operation_setting.IsToolCallValidationEnabled(info.UsingGroup) {
+		return nil
+	}
+	schemas := toolSchemasFromRequest(info.Request)
+	if len(schemas) == 0 {
+		return nil
+	}
+	return &ToolCallValidator{
+		schemas:   schemas,
+		repair:    operation_setting.GetToolCallValidationSetting().Repair,
+		pending:   make(map[int]map[int]*pendingToolCall),
+		itemNames: make(map[string]string),
+	}
+}
+
+// toolSchemasFromRequest 取出请求中声明的函数工具，函数名 -> parameters（未声明时为 nil）
+func toolSchemasFromRequest(request dto.Request) map[string]any {
+	schemas := make(map[string]any)
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		for _, tool := range r.Tools {
+			if tool.Type != "function" || tool.Function.Name == "" {
+				continue
+			}
+			schemas[tool.Function.Name] = normalizeToolSchema(tool.Function.Parameters)
+		}
+	case *dto.OpenAIResponsesRequest:
+		gjson.ParseBytes(r.Tools).ForEach(func(_, tool gjson.Result) bool {
+			name := tool.Get("name").String()
+			if tool.Get("type").String() != "function" || name == "" {
+				return true
+			}
+			var schema any
+			if parameters := tool.Get("parameters"); parameters.Exists() {
+				_ = common.UnmarshalJsonStr(parameters.Raw, &schema)
+			}
+			schemas[name] = schema
+			return true
+		})
+	}
+	return schemas
+}
+
+// normalizeToolSchema 将 parameters 统一为 common.Unmarshal 解析得到的结构，便于校验
+func normalizeToolSchema(parameters any) any {
+	if parameters == nil {
+		return nil
+	}
+	data, err := common.Marshal(parameters)
+	if err != nil {
+		return nil
+	}
+	var schema any
+	if err := common.Unmarshal(data, &schema); err != nil {
+		return nil
+	}
+	return schema
+}
+
+// CheckArguments 校验一次工具调用的参数，返回修复后的参数
+func (v *ToolCallValidator) CheckArguments(name string, arguments string) (string, error) {
+	schema, ok := v.schemas[name]
+	if !ok {
+		return arguments, &ToolCallValidationError{Tool: name, Err: errors.New("tool is not declared in the request")}
+	}
+	fixed := arguments
+	if v.repair && !gjson.Valid(fixed) {
+		fixed = RepairToolCallArguments(fixed)
+	}
+	var value any
+	if err := common.UnmarshalJsonStr(fixed, &value); err != nil {
+		return arguments, &ToolCallValidationError{Tool: name, Err: fmt.Errorf("arguments are not valid JSON: %w", err)}
+	}
+	if _, ok := value.(map[string]any); !ok {
+		return arguments, &ToolCallValidationError{Tool: name, Err: errors.New("arguments must be a JSON object")}
+	}
+	if err := validateJSONSchema(schema, value, ""); err != nil {
+		return arguments, &ToolCallValidationError{Tool: name, Err: err}
+	}
+	return fixed, nil
+}
+
+// RepairToolCallArguments 确定性地修复常见的参数格式错误：代码块包裹、空参数、
+// 尾随逗号、未闭合的字符串与括号，无法判断的错误保持原样交给校验处理
+func RepairToolCallArguments(arguments string) string {
+	text := strings.TrimSpace(arguments)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.IndexByte(text, '\n'); newline >= 0 && !strings.ContainsAny(text[:newline], "{[") {
+			text = text[newline+1:]
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+	}
+	if text == "" {
+		return "{}"
+	}
+	if gjson.Valid(text) {
+		return text
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(text) + 4)
+	stack := make([]byte, 0, 8)
+	inString := false
+	escaped := false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			builder.WriteByte(ch)
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			trimTrailingComma(&builder)
+			if len(stack) > 0 && stack[len(stack)-1] == ch {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		builder.WriteByte(ch)
+	}
+
+	if inString {
+		if escaped {
+			repaired := builder.String()
+			builder.Reset()
+			builder.WriteString(repaired[:len(repaired)-1])
+		}
+		builder.WriteByte('"')
+	}
+	if len(stack) > 0 {
+		trimTrailingComma(&builder)
+		if repaired := strings.TrimRightFunc(builder.String(), isJSONSpace); strings.HasSuffix(repaired, ":") {
+			builder.WriteString("null")
+		}
+		for i := len(stack) - 1; i >= 0; i-- {
+			builder.WriteByte(stack[i])
+		}
+	}
+	return builder.String()
+}
+
+// trimTrailingComma 去掉已输出内容末尾（忽略空白）的逗号
+func trimTrailingComma(builder *strings.Builder) {
+	text := strings.TrimRightFunc(builder.String(), isJSONSpace)
+	if !strings.HasSuffix(text, ",") {
+		return
+	}
+	text = strings.TrimSuffix(text, ",")
+	builder.Reset()
+	builder.WriteString(text)
+}
+
+func isJSONSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// ValidateChatResponseBody 校验非流式 chat completions 响应中的工具调用，返回写入修复结果后的响应体
+func (v *ToolCallValidator) ValidateChatResponseBody(body []byte) ([]byte, error) {
+	var fixes []toolCallArgumentsFix
+	var checkErr error
+	gjson.GetBytes(body, "choices").ForEach(func(choiceIndex, choice gjson.Result) bool {
+		choice.Get("message.tool_calls").ForEach(func(callIndex, call gjson.Result) bool {
+			arguments := call.Get("function.arguments").String()
+			fixed, err := v.CheckArguments(call.Get("function.name").String(), arguments)
+			if err != nil {
+				checkErr = err
+				return false
+			}
+			if fixed != arguments {
+				fixes = append(fixes, toolCallArgumentsFix{
+					path:      fmt.Sprintf("choices.%d.message.tool_calls.%d.function.arguments", choiceIndex.Int(), callIndex.Int()),
+					arguments: fixed,
+				})
+			}
+			return true
+		})
+		return checkErr == nil
+	})
+	if checkErr != nil {
+		return body, checkErr
+	}
+	return applyToolCallArgumentsFixes(body, fixes)
+}
+
+// ValidateResponsesBody 校验非流式 responses 响应中的 function_call，返回写入修复结果后的响应体
+func (v *ToolCallValidator) ValidateResponsesBody(body []byte) ([]byte, error) {
+	return v.validateResponsesOutput(body, "output")
+}
+
+func (v *ToolCallValidator) validateResponsesOutput(body []byte, outputPath string) ([]byte, error) {
+	var fixes []toolCallArgumentsFix
+	var checkErr error
+	gjson.GetBytes(body, outputPath).ForEach(func(index, item gjson.Result) bool {
+		if item.Get("type").String() != responsesOutputTypeFunctionCall {
+			return true
+		}
+		arguments := item.Get("arguments").String()
+		fixed, err := v.CheckArguments(item.Get("name").String(), arguments)
+		if err != nil {
+			checkErr = err
+			return false
+		}
+		if fixed != arguments {
+			fixes = append(fixes, toolCallArgumentsFix{
+				path:      fmt.Sprintf("%s.%d.arguments", outputPath, index.Int()),
+				arguments: fixed,
+			})
+		}
+		return true
+	})
+	if checkErr != nil {
+		return body, checkErr
+	}
+	return applyToolCallArgumentsFixes(body, fixes)
+}
+
+type toolCallArgumentsFix struct {
+	path      string
+	arguments string
+}
+
+func applyToolCallArgumentsFixes(body []byte, fixes []toolCallArgumentsFix) ([]byte, error) {
+	var err error
+	for _, fix := range fixes {
+		body, err = sjson.SetBytes(body, fix.path, fix.arguments)
+		if err != nil {
+			return body, err
+		}
+	}
+	return body, nil
+}
+
+// FilterChatStreamChunk 处理一条流式 chat completions 输出：缓存其中的工具调用增量，
+// choice 结束时将校验、修复后的完整工具调用写入该分片。返回处理后的分片以及是否需要输出
+func (v *ToolCallValidator) FilterChatStreamChunk(data string) (string, bool, error) {
+	choices := gjson.Get(data, "choices")
+	if !choices.IsArray() {
+		return data, true, nil
+	}
+	modified := false
+	var err error
+	for position, choice := range choices.Array() {
+		choiceIndex := int(choice.Get("index").Int())
+		deltaPath := fmt.Sprintf("choices.%d.delta.tool_calls", position)
+		if toolCalls := choice.Get("delta.tool_calls"); toolCalls.IsArray() {
+			for _, call := range toolCalls.Array() {
+				v.bufferToolCallDelta(choiceIndex, call)
+			}
+			if data, err = sjson.Delete(data, deltaPath); err != nil {
+				return data, true, err
+			}
+			modified = true
+		}
+		if finishReason := choice.Get("finish_reason").String(); finishReason == "" || len(v.pending[choiceIndex]) == 0 {
+			continue
+		}
+		toolCalls, err := v.completeToolCalls(choiceIndex)
+		if err != nil {
+			return data, false, err
+		}
+		if data, err = sjson.SetRaw(data, deltaPath, string(toolCalls)); err != nil {
+			return data, true, err
+		}
+	}
+	if modified && isEmptyChatStreamChunk(data) {
+		return data, false, nil
+	}
+	return data, true, nil
+}
+
+// FinishChatStream 流式输出结束时检查是否还有未结束的工具调用
+func (v *ToolCallValidator) FinishChatStream() error {
+	for _, calls := range v.pending {
+		for _, call := range calls {
+			return &ToolCallValidationError{Tool: call.name, Err: errors.New("stream ended before the tool call finished")}
+		}
+	}
+	return nil
+}
+
+func (v *ToolCallValidator) bufferToolCallDelta(choiceIndex int, call gjson.Result) {
+	calls := v.pending[choiceIndex]
+	if calls == nil {
+		calls = make(map[int]*pendingToolCall)
+		v.pending[choiceIndex] = calls
+	}
+	index := int(call.Get("index").Int())
+	pending := calls[index]
+	if pending == nil {
+		pending = &pendingToolCall{}
+		calls[index] = pending
+	}
+	if id := call.Get("id").String(); id != "" {
+		pending.id = id
+	}
+	if callType := call.Get("type").String(); callType != "" {
+		pending.callType = callType
+	}
+	if name := call.Get("function.name").String(); name != "" && pending.name == "" {
+		pending.name = name
+	}
+	pending.arguments.WriteString(call.Get("function.arguments").String())
+}
+
+func (v *ToolCallValidator) completeToolCalls(choiceIndex int) ([]byte, error) {
+	calls := v.pending[choiceIndex]
+	delete(v.pending, choiceIndex)
+
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+
+	toolCalls := make([]dto.ToolCallResponse, 0, len(indexes))
+	for _, index := range indexes {
+		call := calls[index]
+		arguments, err := v.CheckArguments(call.name, call.arguments.String())
+		if err != nil {
+			return nil, err
+		}
+		callType := call.callType
+		if callType == "" {
+			callType = "function"
+		}
+		toolCall := dto.ToolCallResponse{
+			ID:   call.id,
+			Type: callType,
+			Function: dto.FunctionResponse{
+				Name:      call.name,
+				Arguments: arguments,
+			},
+		}
+		toolCall.SetIndex(index)
+		toolCalls = append(toolCalls, toolCall)
+	}
+	return common.Marshal(toolCalls)
+}
+
+// isEmptyChatStreamChunk 去掉工具调用增量后分片是否已没有需要输出的内容
+func isEmptyChatStreamChunk(data string) bool {
+	if usage := gjson.Get(data, "usage"); usage.Exists() && usage.Type != gjson.Null {
+		return false
+	}
+	empty := true
+	gjson.Get(data, "choices").ForEach(func(_, choice gjson.Result) bool {
+		if choice.Get("finish_reason").String() != "" {
+			empty = false
+			return false
+		}
+		choice.Get("delta").ForEach(func(_, value gjson.Result) bool {
+			if value.Type != gjson.Null && value.String() != "" {
+				empty = false
+			}
+			return empty
+		})
+		return empty
+	})
+	return empty
+}
+
+// ValidateResponsesStreamEvent 校验 responses 流式输出中已完成的 function_call，修复结果写回
+// arguments.done、output_item.done 与 response.completed 事件。参数增量已原样输出，无法修改
+func (v *ToolCallValidator) ValidateResponsesStreamEvent(data string) (string, error) {
+	switch gjson.Get(data, "type").String() {
+	case dto.ResponsesOutputTypeItemAdded:
+		if item := gjson.Get(data, "item"); item.Get("type").String() == responsesOutputTypeFunctionCall {
+			v.itemNames[item.Get("id").String()] = item.Get("name").String()
+		}
+	case responsesEventFunctionCallArgumentsDone:
+		name := gjson.Get(data, "name").String()
+		if name == "" {
+			name = v.itemNames[gjson.Get(data, "item_id").String()]
+		}
+		arguments := gjson.Get(data, "arguments").String()
+		fixed, err := v.CheckArguments(name, arguments)
+		if err != nil {
+			return data, err
+		}
+		if fixed != arguments {
+			return sjson.Set(data, "arguments", fixed)
+		}
+	case dto.ResponsesOutputTypeItemDone:
+		item := gjson.Get(data, "item")
+		if item.Get("type").String() != responsesOutputTypeFunctionCall {
+			break
+		}
+		arguments := item.Get("arguments").String()
+		fixed, err := v.CheckArguments(item.Get("name").String(), arguments)
+		if err != nil {
+			return data, err
+		}
+		if fixed != arguments {
+			return sjson.Set(data, "item.arguments", fixed)
+		}
+	case responsesEventCompleted:
+		body, err := v.validateResponsesOutput([]byte(data), "response.output")
+		return string(body), err
+	}
+	return data, nil
+}
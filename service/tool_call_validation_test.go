@@ -0,0 +1,164 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func useTestToolCallValidator(t *testing.T, repair bool) *ToolCallValidator {
+	t.Helper()
+	setting := operation_setting.GetToolCallValidationSetting()
+	old := *setting
+	t.Cleanup(func() { *setting = old })
+	setting.Enabled = true
+	setting.EnabledGroups = []string{"default"}
+	setting.Repair = repair
+
+	info := &relaycommon.RelayInfo{
+		UsingGroup: "default",
+		Request: &dto.GeneralOpenAIRequest{
+			Tools: []dto.ToolCallRequest{{
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name: "get_weather",
+					Parameters: map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"city": map[string]any{"type": "string", "minLength": 1},
+							"unit": map[string]any{"type": "string", "enum": []any{"c", "f"}},
+							"days": map[string]any{"type": "integer", "minimum": 1},
+						},
+						"required":             []any{"city"},
+						"additionalProperties": false,
+					},
+				},
+			}},
+		},
+	}
+	validator := NewToolCallValidator(info)
+	require.NotNil(t, validator)
+	return validator
+}
+
+func TestRepairToolCallArguments(t *testing.T) {
+	cases := map[string]string{
+		`{"a":1,}`:                   `{"a":1}`,
+		`{"a":[1,2,],}`:              `{"a":[1,2]}`,
+		`{"a":[1,2,`:                 `{"a":[1,2]}`,
+		`{"a":"b`:                    `{"a":"b"}`,
+		`{"a":{"b":"c\`:              `{"a":{"b":"c"}}`,
+		`{"a":`:                      `{"a":null}`,
+		"":                           `{}`,
+		"```json\n{\"a\":1}\n```":    `{"a":1}`,
+		`{"a":"x,}"}`:                `{"a":"x,}"}`,
+		`  {"a":"brace { in string"`: `{"a":"brace { in string"}`,
+	}
+	for input, expected := range cases {
+		assert.Equal(t, expected, RepairToolCallArguments(input), input)
+	}
+}
+
+func TestToolCallValidatorCheckArguments(t *testing.T) {
+	validator := useTestToolCallValidator(t, true)
+
+	fixed, err := validator.CheckArguments("get_weather", `{"city":"Paris","days":3,`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"city":"Paris","days":3}`, fixed)
+
+	for _, arguments := range []string{
+		`{"unit":"c"}`,
+		`{"city":""}`,
+		`{"city":"Paris","unit":"k"}`,
+		`{"city":"Paris","days":1.5}`,
+		`{"city":"Paris","extra":true}`,
+		`["Paris"]`,
+	} {
+		_, err := validator.CheckArguments("get_weather", arguments)
+		assert.Error(t, err, arguments)
+	}
+
+	_, err = validator.CheckArguments("unknown_tool", `{}`)
+	assert.Error(t, err)
+}
+
+func TestToolCallValidatorWithoutRepair(t *testing.T) {
+	validator := useTestToolCallValidator(t, false)
+
+	_, err := validator.CheckArguments("get_weather", `{"city":"Paris",}`)
+	var validationErr *ToolCallValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "get_weather", validationErr.Tool)
+}
+
+func TestToolCallValidatorChatResponseBody(t *testing.T) {
+	validator := useTestToolCallValidator(t, true)
+
+	body := []byte(`{"choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\""}}]}}]}`)
+	fixed, err := validator.ValidateChatResponseBody(body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"city":"Paris"}`, gjson.GetBytes(fixed, "choices.0.message.tool_calls.0.function.arguments").String())
+
+	body = []byte(`{"choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"unit\":\"c\"}"}}]}}]}`)
+	_, err = validator.ValidateChatResponseBody(body)
+	assert.Error(t, err)
+}
+
+func TestToolCallValidatorChatStream(t *testing.T) {
+	validator := useTestToolCallValidator(t, true)
+
+	chunks := []string{
+		`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]},"finish_reason":null}]}`,
+		`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}`,
+		`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\","}}]},"finish_reason":null}]}`,
+		`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	}
+	var output []string
+	for _, chunk := range chunks {
+		data, keep, err := validator.FilterChatStreamChunk(chunk)
+		require.NoError(t, err)
+		if keep {
+			output = append(output, data)
+		}
+	}
+	require.Len(t, output, 2)
+	assert.False(t, gjson.Get(output[0], "choices.0.delta.tool_calls").Exists())
+	assert.Equal(t, "assistant", gjson.Get(output[0], "choices.0.delta.role").String())
+
+	toolCall := gjson.Get(output[1], "choices.0.delta.tool_calls.0")
+	assert.Equal(t, "call_1", toolCall.Get("id").String())
+	assert.Equal(t, "get_weather", toolCall.Get("function.name").String())
+	assert.Equal(t, `{"city":"Paris"}`, toolCall.Get("function.arguments").String())
+	assert.Equal(t, "tool_calls", gjson.Get(output[1], "choices.0.finish_reason").String())
+	assert.NoError(t, validator.FinishChatStream())
+}
+
+func TestToolCallValidatorChatStreamInvalid(t *testing.T) {
+	validator := useTestToolCallValidator(t, true)
+
+	_, _, err := validator.FilterChatStreamChunk(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"days\":0}"}}]},"finish_reason":null}]}`)
+	require.NoError(t, err)
+	assert.Error(t, validator.FinishChatStream())
+
+	_, _, err = validator.FilterChatStreamChunk(`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`)
+	assert.Error(t, err)
+}
+
+func TestToolCallValidatorResponsesStreamEvent(t *testing.T) {
+	validator := useTestToolCallValidator(t, true)
+
+	_, err := validator.ValidateResponsesStreamEvent(`{"type":"response.output_item.added","item":{"id":"fc_1","type":"function_call","name":"get_weather","arguments":""}}`)
+	require.NoError(t, err)
+	data, err := validator.ValidateResponsesStreamEvent(`{"type":"response.function_call_arguments.done","item_id":"fc_1","arguments":"{\"city\":\"Paris\",}"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"city":"Paris"}`, gjson.Get(data, "arguments").String())
+
+	_, err = validator.ValidateResponsesStreamEvent(`{"type":"response.output_item.done","item":{"id":"fc_1","type":"function_call","name":"get_weather","arguments":"{}"}}`)
+	assert.Error(t, err)
+}
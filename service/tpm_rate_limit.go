@@ -0,0 +1,116 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TPMReservation 本次请求预占的 TPM，结算后按实际用量修正，请求失败时释放
+type TPMReservation struct {
+	keys   []string
+	member string
+	weight int64
+}
+
+// NeedTPMRateLimit 当前请求是否受 TPM 限制（系统 TPM 限流开启或令牌设置了 TPM）
+func NeedTPMRateLimit(c *gin.Context) bool {
+	return operation_setting.GetTPMRateLimitSetting().Enabled || common.GetContextKeyInt(c, constant.ContextKeyTokenTPMLimit) > 0
+}
+
+func tpmRateLimitRequests(c *gin.Context, relayInfo *relaycommon.RelayInfo, weight int64) []rateWindowRequest {
+	requests := make([]rateWindowRequest, 0, 3)
+	userId := strconv.Itoa(relayInfo.UserId)
+	if operation_setting.GetTPMRateLimitSetting().Enabled {
+		group := relayInfo.TokenGroup
+		if group == "" {
+			group = relayInfo.UserGroup
+		}
+		if limit := operation_setting.GetUserTPMLimit(group); limit > 0 {
+			requests = append(requests, rateWindowRequest{
+				key: rateWindowKey("TPM", "user", userId), scope: "user", kind: "TPM", limit: int64(limit), weight: weight,
+			})
+		}
+		if limit := operation_setting.GetModelTPMLimit(relayInfo.OriginModelName); limit > 0 {
+			requests = append(requests, rateWindowRequest{
+				key:   rateWindowKey("TPM", "user-model", userId+":"+relayInfo.OriginModelName),
+				scope: "model " + relayInfo.OriginModelName, kind: "TPM", limit: int64(limit), weight: weight,
+			})
+		}
+	}
+	if limit := common.GetContextKeyInt(c, constant.ContextKeyTokenTPMLimit); limit > 0 {
+		requests = append(requests, rateWindowRequest{
+			key: tokenRateWindowKey("TPM", relayInfo.TokenId), scope: "token", kind: "TPM", limit: int64(limit), weight: weight,
+		})
+	}
+	return requests
+}
+
+// ReserveTPM 按预估输入 token 数预占用户、模型与令牌的 TPM，任一维度超限时返回 429。
+// relayInfo 未预估 token（CountToken 关闭）时使用 EstimateTokenByModel 按请求文本估算。
+func ReserveTPM(c *gin.Context, relayInfo *relaycommon.RelayInfo, meta *types.TokenCountMeta) *types.NewAPIError {
+	if !NeedTPMRateLimit(c) {
+		return nil
+	}
+	tokens := relayInfo.GetEstimatePromptTokens()
+	if tokens <= 0 && meta != nil {
+		tokens = EstimateTokenByModel(relayInfo.OriginModelName, meta.CombineText)
+	}
+	requests := tpmRateLimitRequests(c, relayInfo, int64(tokens))
+	if len(requests) == 0 {
+		return nil
+	}
+	member, index, retryAfter, err := globalRateWindow.acquire(c.Request.Context(), requests)
+	if err != nil {
+		// 限流存储不可用时放行，避免影响正常请求
+		logger.LogWarn(c, "tpm rate limit check failed: "+err.Error())
+		return nil
+	}
+	if index >= 0 {
+		return types.NewErrorWithStatusCode(requests[index].exceeded(retryAfter), types.ErrorCodeRateLimitExceeded,
+			http.StatusTooManyRequests, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	keys := make([]string, len(requests))
+	for i, request := range requests {
+		keys[i] = request.key
+	}
+	common.SetContextKey(c, constant.ContextKeyTPMReservation, &TPMReservation{keys: keys, member: member, weight: int64(tokens)})
+	relayInfo.TokenTPMReserved = common.GetContextKeyInt(c, constant.ContextKeyTokenTPMLimit) > 0
+	return nil
+}
+
+func adjustTPMReservation(c *gin.Context, weight int64) {
+	if c == nil {
+		return
+	}
+	reservation, ok := common.GetContextKeyType[*TPMReservation](c, constant.ContextKeyTPMReservation)
+	if !ok || reservation == nil || reservation.weight == weight {
+		return
+	}
+	if err := globalRateWindow.adjust(c.Request.Context(), reservation.keys, reservation.member, reservation.weight, weight); err != nil {
+		logger.LogWarn(c, "adjust tpm reservation failed: "+err.Error())
+		return
+	}
+	reservation.weight = weight
+}
+
+// reconcileTPMReservation 按实际消耗的 token 数修正预占，未拿到用量时保留预估值
+func reconcileTPMReservation(c *gin.Context, totalTokens int) {
+	if totalTokens <= 0 {
+		return
+	}
+	adjustTPMReservation(c, int64(totalTokens))
+}
+
+// ReleaseTPMReservation 请求失败时释放预占的 TPM
+func ReleaseTPMReservation(c *gin.Context) {
+	adjustTPMReservation(c, 0)
+}
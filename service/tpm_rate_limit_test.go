@@ -0,0 +1,75 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useTestRateWindow(t *testing.T) *weightedRateWindow {
+	t.Helper()
+	old := globalRateWindow
+	globalRateWindow = &weightedRateWindow{local: make(map[string][]rateWindowSample)}
+	t.Cleanup(func() { globalRateWindow = old })
+	return globalRateWindow
+}
+
+func TestReserveTPMReconcilesWithActualUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestRateWindow(t)
+	setting := operation_setting.GetTPMRateLimitSetting()
+	old := *setting
+	t.Cleanup(func() { *setting = old })
+	setting.Enabled = true
+	setting.DefaultUserTPM = 1000
+	setting.ModelTPM = map[string]int{"gpt-4o": 300}
+
+	newContext := func() (*gin.Context, *relaycommon.RelayInfo) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		common.SetContextKey(c, constant.ContextKeyTokenTPMLimit, 0)
+		info := &relaycommon.RelayInfo{UserId: 7, TokenId: 3, OriginModelName: "gpt-4o"}
+		info.SetEstimatePromptTokens(100)
+		return c, info
+	}
+
+	c, info := newContext()
+	require.Nil(t, ReserveTPM(c, info, nil))
+	// 实际用量 250，模型窗口剩余 50，再次预占 100 被拒绝
+	reconcileTPMReservation(c, 250)
+	c2, info2 := newContext()
+	apiErr := ReserveTPM(c2, info2, nil)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Error(), "model gpt-4o TPM limit exceeded")
+
+	// 失败请求释放预占后可以继续请求
+	ReleaseTPMReservation(c)
+	c3, info3 := newContext()
+	assert.Nil(t, ReserveTPM(c3, info3, nil))
+}
+
+func TestReserveTPMMarksTokenTPMReserved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestRateWindow(t)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	common.SetContextKey(c, constant.ContextKeyTokenTPMLimit, 150)
+	info := &relaycommon.RelayInfo{UserId: 7, TokenId: 5, OriginModelName: "gpt-4o"}
+	info.SetEstimatePromptTokens(100)
+
+	require.Nil(t, ReserveTPM(c, info, nil))
+	assert.True(t, info.TokenTPMReserved)
+	// 令牌 TPM 与预扣费路径共用同一窗口
+	err := CheckTokenRateLimit(t.Context(), 5, 0, 150, 100)
+	assert.True(t, IsTokenQuotaLimitError(err))
+}
@@ -0,0 +1,50 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// BatchSetting Batch API（/v1/batches）相关配置
+type BatchSetting struct {
+	Enabled bool `json:"enabled"`
+	// MaxConcurrency 后台同时执行的请求数
+	MaxConcurrency int `json:"max_concurrency"`
+	// MaxRequestsPerBatch 单个批处理最多包含的请求数
+	MaxRequestsPerBatch int `json:"max_requests_per_batch"`
+	// CompletionWindowHours 批处理的完成时限（小时），超时未执行的请求记为失败
+	CompletionWindowHours int `json:"completion_window_hours"`
+	// DiscountRatio 默认批处理折扣倍率，1 表示不打折，必须大于 0
+	DiscountRatio float64 `json:"discount_ratio"`
+	// ModelDiscountRatios 按模型配置的折扣倍率，优先于 DiscountRatio
+	ModelDiscountRatios map[string]float64 `json:"model_discount_ratios"`
+}
+
+// 默认配置
+var batchSetting = BatchSetting{
+	Enabled:               true,
+	MaxConcurrency:        4,
+	MaxRequestsPerBatch:   50000,
+	CompletionWindowHours: 24,
+	DiscountRatio:         0.5,
+	ModelDiscountRatios:   map[string]float64{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
+
+// GetBatchDiscountRatio 获取模型的批处理折扣倍率，未配置或配置非法时使用默认值
+func GetBatchDiscountRatio(modelName string) float64 {
+	if ratio, ok := batchSetting.ModelDiscountRatios[modelName]; ok && ratio > 0 {
+		return ratio
+	}
+	if batchSetting.DiscountRatio <= 0 {
+		return 1
+	}
+	return batchSetting.DiscountRatio
+}
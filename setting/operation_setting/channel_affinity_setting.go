@@ -34,6 +34,10 @@ type ChannelAffinitySetting struct {
 	MaxEntries            int                   `json:"max_entries"`
 	DefaultTTLSeconds     int                   `json:"default_ttl_seconds"`
 	Rules                 []ChannelAffinityRule `json:"rules"`
+
+	// Responses API 按 response id 固定渠道与 key，不受 Enabled 影响
+	ResponseAffinityEnabled    bool `json:"response_affinity_enabled"`
+	ResponseAffinityTTLSeconds int  `json:"response_affinity_ttl_seconds"`
 }
 
 // Keep Codex CLI passthrough aligned with upstream. Codex uses lower-case
@@ -115,6 +119,9 @@ var channelAffinitySetting = ChannelAffinitySetting{
 	KeepOnChannelDisabled: false,
 	MaxEntries:            100_000,
 	DefaultTTLSeconds:     3600,
+	// 与 OpenAI 默认保存 response 的时长一致
+	ResponseAffinityEnabled:    true,
+	ResponseAffinityTTLSeconds: 30 * 24 * 3600,
 	Rules: []ChannelAffinityRule{
 		{
 			Name:       "codex cli trace",
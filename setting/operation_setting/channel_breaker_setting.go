@@ -0,0 +1,48 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ChannelBreakerSetting 渠道熔断配置。
+// 熔断只影响选路（跳过熔断中的渠道 / key），不修改数据库中的渠道状态，也不发送渠道禁用通知。
+type ChannelBreakerSetting struct {
+	Enabled bool `json:"enabled"`
+	// KeyLevelEnabled 多 key 渠道是否对单个 key 独立熔断
+	KeyLevelEnabled bool `json:"key_level_enabled"`
+	// ConsecutiveFailures 连续失败多少次后熔断，<=0 表示不按连续失败熔断
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// ErrorRateThreshold 统计窗口内失败率达到该值后熔断（0-1），<=0 表示不按失败率熔断
+	ErrorRateThreshold float64 `json:"error_rate_threshold"`
+	// MinRequests 统计窗口内至少多少次请求才按失败率判断
+	MinRequests int `json:"min_requests"`
+	// WindowSeconds 失败率统计窗口
+	WindowSeconds int `json:"window_seconds"`
+	// CooldownSeconds 熔断后的冷却时间，连续熔断时翻倍，最长 MaxCooldownSeconds
+	CooldownSeconds    int `json:"cooldown_seconds"`
+	MaxCooldownSeconds int `json:"max_cooldown_seconds"`
+	// HalfOpenProbes 冷却结束后放行的探测请求数，全部成功后恢复
+	HalfOpenProbes int `json:"half_open_probes"`
+}
+
+// 默认配置
+var channelBreakerSetting = ChannelBreakerSetting{
+	Enabled:             false,
+	KeyLevelEnabled:     true,
+	ConsecutiveFailures: 5,
+	ErrorRateThreshold:  0.5,
+	MinRequests:         20,
+	WindowSeconds:       60,
+	CooldownSeconds:     30,
+	MaxCooldownSeconds:  600,
+	HalfOpenProbes:      1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_breaker_setting", &channelBreakerSetting)
+}
+
+func GetChannelBreakerSetting() *ChannelBreakerSetting {
+	return &channelBreakerSetting
+}
@@ -0,0 +1,70 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 渠道选择策略：同一优先级内如何在候选渠道之间分配请求
+const (
+	ChannelSelectStrategyWeightedRandom = "weighted_random" // 按权重随机（默认）
+	ChannelSelectStrategyLeastInflight  = "least_inflight"  // 进行中请求最少
+	ChannelSelectStrategyLeastLatency   = "least_latency"   // EWMA 延迟 × 负载最低
+	ChannelSelectStrategyP2C            = "p2c"             // 按权重随机取两个，选负载代价更低者
+)
+
+// 延迟策略使用的指标
+const (
+	ChannelSelectLatencyMetricTtft    = "ttft"    // 首字延迟，无首字数据时回退到总延迟
+	ChannelSelectLatencyMetricLatency = "latency" // 总延迟
+)
+
+// ChannelSelectSetting 渠道选择策略配置
+type ChannelSelectSetting struct {
+	// DefaultStrategy 未单独配置的分组使用的策略
+	DefaultStrategy string `json:"default_strategy"`
+	// GroupStrategies 按分组覆盖策略
+	GroupStrategies map[string]string `json:"group_strategies"`
+	// LatencyMetric least_latency / p2c 使用的延迟指标
+	LatencyMetric string `json:"latency_metric"`
+	// ErrorPenalty 近期失败率对延迟代价的放大系数，代价 = 延迟 × (1 + ErrorPenalty × 失败率)
+	ErrorPenalty float64 `json:"error_penalty"`
+}
+
+// 默认配置
+var channelSelectSetting = ChannelSelectSetting{
+	DefaultStrategy: ChannelSelectStrategyWeightedRandom,
+	GroupStrategies: map[string]string{},
+	LatencyMetric:   ChannelSelectLatencyMetricTtft,
+	ErrorPenalty:    4,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_select_setting", &channelSelectSetting)
+}
+
+func GetChannelSelectSetting() *ChannelSelectSetting {
+	return &channelSelectSetting
+}
+
+// IsValidChannelSelectStrategy 是否为支持的渠道选择策略
+func IsValidChannelSelectStrategy(strategy string) bool {
+	switch strategy {
+	case ChannelSelectStrategyWeightedRandom, ChannelSelectStrategyLeastInflight,
+		ChannelSelectStrategyLeastLatency, ChannelSelectStrategyP2C:
+		return true
+	}
+	return false
+}
+
+// GetChannelSelectStrategy 获取分组使用的渠道选择策略，未知取值按加权随机处理
+func GetChannelSelectStrategy(group string) string {
+	strategy, ok := channelSelectSetting.GroupStrategies[group]
+	if !ok || strategy == "" {
+		strategy = channelSelectSetting.DefaultStrategy
+	}
+	if !IsValidChannelSelectStrategy(strategy) {
+		return ChannelSelectStrategyWeightedRandom
+	}
+	return strategy
+}
@@ -0,0 +1,58 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	FileStorageBackendLocal = "local"
+)
+
+// FileSetting Files API（/v1/files）相关配置
+type FileSetting struct {
+	Enabled bool `json:"enabled"`
+	// StorageBackend 本地存储后端名称，默认 local
+	StorageBackend string `json:"storage_backend"`
+	// StoragePath 本地存储根目录，空表示工作目录
+	StoragePath string `json:"storage_path"`
+	// MaxFileSizeMB 单个文件最大大小（MB）
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	// MaxUserFiles 每用户最大文件数量，0 表示不限制
+	MaxUserFiles int `json:"max_user_files"`
+	// MaxUserStorageMB 每用户最大存储总量（MB），0 表示不限制
+	MaxUserStorageMB int `json:"max_user_storage_mb"`
+	// UpstreamChannelId 透传上游的固定渠道，0 表示不透传
+	UpstreamChannelId int `json:"upstream_channel_id"`
+	// UpstreamPurposes 需要存放在上游的文件用途，例如 fine-tune
+	UpstreamPurposes []string `json:"upstream_purposes"`
+}
+
+// 默认配置
+var fileSetting = FileSetting{
+	Enabled:          true,
+	StorageBackend:   FileStorageBackendLocal,
+	StoragePath:      "",
+	MaxFileSizeMB:    512,
+	MaxUserFiles:     100,
+	MaxUserStorageMB: 1024,
+	UpstreamPurposes: []string{"fine-tune"},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("file_setting", &fileSetting)
+}
+
+func GetFileSetting() *FileSetting {
+	return &fileSetting
+}
+
+// ShouldStoreFileUpstream 判断指定用途的文件是否需要透传到上游固定渠道
+func ShouldStoreFileUpstream(purpose string) bool {
+	if fileSetting.UpstreamChannelId <= 0 {
+		return false
+	}
+	return slices.Contains(fileSetting.UpstreamPurposes, purpose)
+}
@@ -0,0 +1,57 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// FineTuningSetting 微调任务代理（/v1/fine_tuning/jobs）相关配置
+type FineTuningSetting struct {
+	Enabled bool `json:"enabled"`
+	// ChannelId 代理微调任务的 OpenAI/Azure 渠道，0 表示沿用 Files API 的透传渠道
+	ChannelId int `json:"channel_id"`
+	// TrainingPrices 按基础模型配置的训练价格（美元 / 1M 训练 token）
+	TrainingPrices map[string]float64 `json:"training_prices"`
+	// PollIntervalSeconds 同一任务两次轮询之间的最小间隔
+	PollIntervalSeconds int `json:"poll_interval_seconds"`
+}
+
+// 默认配置
+var fineTuningSetting = FineTuningSetting{
+	Enabled:   true,
+	ChannelId: 0,
+	TrainingPrices: map[string]float64{
+		"gpt-4o-2024-08-06":       25,
+		"gpt-4o-mini-2024-07-18":  3,
+		"gpt-4.1-2025-04-14":      25,
+		"gpt-4.1-mini-2025-04-14": 5,
+		"gpt-4.1-nano-2025-04-14": 1.5,
+		"gpt-3.5-turbo-0125":      8,
+	},
+	PollIntervalSeconds: 60,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("fine_tuning_setting", &fineTuningSetting)
+}
+
+func GetFineTuningSetting() *FineTuningSetting {
+	return &fineTuningSetting
+}
+
+// GetFineTuningChannelId 获取代理微调任务的渠道
+func GetFineTuningChannelId() int {
+	if fineTuningSetting.ChannelId > 0 {
+		return fineTuningSetting.ChannelId
+	}
+	return fileSetting.UpstreamChannelId
+}
+
+// GetFineTuningTrainingPrice 获取基础模型的训练价格，未配置时返回 false
+func GetFineTuningTrainingPrice(modelName string) (float64, bool) {
+	price, ok := fineTuningSetting.TrainingPrices[modelName]
+	if !ok || price < 0 {
+		return 0, false
+	}
+	return price, true
+}
@@ -0,0 +1,30 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// MetricsSetting Prometheus /metrics 导出相关配置
+type MetricsSetting struct {
+	Enabled bool `json:"enabled"`
+	// Token 抓取端需携带 Authorization: Bearer <token>
+	Token string `json:"token"`
+	// AllowedIPs 允许免 token 抓取的 IP 或 CIDR
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+// 默认配置
+var metricsSetting = MetricsSetting{
+	Enabled:    false,
+	Token:      "",
+	AllowedIPs: []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("metrics_setting", &metricsSetting)
+}
+
+func GetMetricsSetting() *MetricsSetting {
+	return &metricsSetting
+}
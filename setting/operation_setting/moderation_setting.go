@@ -0,0 +1,90 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	ModerationProviderChannel = "channel"
+	ModerationProviderHTTP    = "http"
+
+	ModerationActionBlock = "block"
+	ModerationActionFlag  = "flag"
+)
+
+// ModerationGroupRule 分组级审核规则，未设置的字段使用全局默认值
+type ModerationGroupRule struct {
+	// Disabled 该分组跳过外部审核
+	Disabled bool `json:"disabled"`
+	// Action 命中后的处理方式：block 拒绝请求，flag 放行并记录
+	Action string `json:"action"`
+	// Thresholds 各类别分数阈值，分数达到阈值即视为命中
+	Thresholds map[string]float64 `json:"thresholds"`
+}
+
+// ModerationSetting 外部内容审核配置，请求转发到上游前先由审核模型检查 prompt
+type ModerationSetting struct {
+	Enabled bool `json:"enabled"`
+	// Provider 审核服务来源：channel 调用指定渠道的 /v1/moderations，http 调用自定义分类接口
+	Provider  string `json:"provider"`
+	ChannelId int    `json:"channel_id"`
+	Model     string `json:"model"`
+	// Endpoint 自定义分类接口地址，经过 SSRF 防护校验
+	Endpoint       string `json:"endpoint"`
+	ApiKey         string `json:"api_key"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	// FailOpen 审核服务不可用时是否放行
+	FailOpen bool `json:"fail_open"`
+	// MaxInputLength 送审文本的最大字符数，超出时只保留末尾部分，0 表示不截断
+	MaxInputLength    int                            `json:"max_input_length"`
+	Action            string                         `json:"action"`
+	DefaultThresholds map[string]float64             `json:"default_thresholds"`
+	GroupRules        map[string]ModerationGroupRule `json:"group_rules"`
+}
+
+// 默认配置
+var moderationSetting = ModerationSetting{
+	Enabled:           false,
+	Provider:          ModerationProviderChannel,
+	Model:             "omni-moderation-latest",
+	TimeoutSeconds:    10,
+	FailOpen:          true,
+	MaxInputLength:    32000,
+	Action:            ModerationActionBlock,
+	DefaultThresholds: map[string]float64{},
+	GroupRules:        map[string]ModerationGroupRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("moderation_setting", &moderationSetting)
+}
+
+func GetModerationSetting() *ModerationSetting {
+	return &moderationSetting
+}
+
+// IsModerationEnabledForGroup 外部审核是否对该分组生效
+func IsModerationEnabledForGroup(group string) bool {
+	if !moderationSetting.Enabled {
+		return false
+	}
+	rule, ok := moderationSetting.GroupRules[group]
+	return !ok || !rule.Disabled
+}
+
+// GetModerationRule 返回分组生效的处理方式与类别阈值，阈值为空时以审核服务的 flagged 结果为准
+func GetModerationRule(group string) (string, map[string]float64) {
+	action := moderationSetting.Action
+	thresholds := moderationSetting.DefaultThresholds
+	if rule, ok := moderationSetting.GroupRules[group]; ok {
+		if rule.Action != "" {
+			action = rule.Action
+		}
+		if len(rule.Thresholds) > 0 {
+			thresholds = rule.Thresholds
+		}
+	}
+	if action != ModerationActionFlag {
+		action = ModerationActionBlock
+	}
+	return action, thresholds
+}
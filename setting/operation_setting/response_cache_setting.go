@@ -0,0 +1,75 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 响应缓存命中后的计费方式
+const (
+	ResponseCacheBillingFree     = "free"     // 命中不计费
+	ResponseCacheBillingDiscount = "discount" // 按 DiscountRatio 折扣计费
+	ResponseCacheBillingFull     = "full"     // 按原价计费
+)
+
+// ResponseCacheSetting 响应缓存（embeddings 与确定性 chat 请求）相关配置。
+// 启用后仅对 EnabledGroups 中的分组或开启了响应缓存的令牌生效。
+type ResponseCacheSetting struct {
+	Enabled bool `json:"enabled"`
+	// EnabledGroups 对分组内所有令牌启用缓存
+	EnabledGroups []string `json:"enabled_groups"`
+	// CacheChat 缓存 temperature 为 0 的 chat completions 请求
+	CacheChat bool `json:"cache_chat"`
+	// CacheEmbeddings 缓存 embeddings 请求
+	CacheEmbeddings bool `json:"cache_embeddings"`
+	TTLSeconds      int  `json:"ttl_seconds"`
+	// MaxEntryBytes 单条缓存的最大响应体大小，超过时不缓存
+	MaxEntryBytes int `json:"max_entry_bytes"`
+	// MemoryCapacity 未启用 Redis 时内存缓存的最大条目数
+	MemoryCapacity int `json:"memory_capacity"`
+	// BillingMode 命中后的计费方式：free / discount / full
+	BillingMode string `json:"billing_mode"`
+	// DiscountRatio BillingMode 为 discount 时的计费倍率，必须大于 0
+	DiscountRatio float64 `json:"discount_ratio"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:         false,
+	EnabledGroups:   []string{},
+	CacheChat:       true,
+	CacheEmbeddings: true,
+	TTLSeconds:      3600,
+	MaxEntryBytes:   1 << 20,
+	MemoryCapacity:  10000,
+	BillingMode:     ResponseCacheBillingDiscount,
+	DiscountRatio:   0.1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// IsResponseCacheGroupEnabled 分组是否对所有令牌启用了响应缓存
+func IsResponseCacheGroupEnabled(group string) bool {
+	return slices.Contains(responseCacheSetting.EnabledGroups, group)
+}
+
+// GetResponseCacheBilling 返回命中后的计费方式与倍率（free 时倍率为 0），未知取值按原价计费
+func GetResponseCacheBilling() (string, float64) {
+	switch responseCacheSetting.BillingMode {
+	case ResponseCacheBillingFree:
+		return ResponseCacheBillingFree, 0
+	case ResponseCacheBillingDiscount:
+		if responseCacheSetting.DiscountRatio > 0 {
+			return ResponseCacheBillingDiscount, responseCacheSetting.DiscountRatio
+		}
+	}
+	return ResponseCacheBillingFull, 1
+}
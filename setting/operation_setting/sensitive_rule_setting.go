@@ -0,0 +1,123 @@
+package operation_setting
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	SensitiveRuleTypeLiteral = "literal"
+	SensitiveRuleTypeRegex   = "regex"
+	SensitiveRuleTypePII     = "pii"
+
+	// SensitiveActionBlock 拒绝请求；SensitiveActionMask 将命中内容替换为 **###** 后转发；SensitiveActionLog 仅记录
+	SensitiveActionBlock = "block"
+	SensitiveActionMask  = "mask"
+	SensitiveActionLog   = "log"
+
+	PIITypeEmail      = "email"
+	PIITypePhone      = "phone"
+	PIITypeIdNumber   = "id_number"
+	PIITypeCreditCard = "credit_card"
+)
+
+// SensitiveRule 单条检测规则
+type SensitiveRule struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Pattern 字面量规则为词语列表（换行分隔，不区分大小写），正则规则为表达式，
+	// PII 规则为 email、phone、id_number、credit_card 之一
+	Pattern  string `json:"pattern"`
+	Category string `json:"category"`
+}
+
+// SensitiveRuleSet 命名规则集，命中任一规则即按 Action 处理
+type SensitiveRuleSet struct {
+	Name    string          `json:"name"`
+	Enabled bool            `json:"enabled"`
+	Action  string          `json:"action"`
+	Rules   []SensitiveRule `json:"rules"`
+}
+
+// SensitiveRuleSetting 规则集配置。请求使用的规则集为分组绑定的规则集（未绑定时使用 DefaultRuleSets）
+// 与令牌附加的规则集之和，多个规则集命中时按 block > mask > log 取最严格的处理方式
+type SensitiveRuleSetting struct {
+	Enabled         bool                `json:"enabled"`
+	RuleSets        []SensitiveRuleSet  `json:"rule_sets"`
+	DefaultRuleSets []string            `json:"default_rule_sets"`
+	GroupRuleSets   map[string][]string `json:"group_rule_sets"`
+}
+
+// 默认配置
+var sensitiveRuleSetting = SensitiveRuleSetting{
+	Enabled:         false,
+	RuleSets:        []SensitiveRuleSet{},
+	DefaultRuleSets: []string{},
+	GroupRuleSets:   map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("sensitive_rule_setting", &sensitiveRuleSetting)
+}
+
+func GetSensitiveRuleSetting() *SensitiveRuleSetting {
+	return &sensitiveRuleSetting
+}
+
+// GetGroupSensitiveRuleSets 返回分组绑定的规则集名称
+func GetGroupSensitiveRuleSets(group string) []string {
+	if names, ok := sensitiveRuleSetting.GroupRuleSets[group]; ok {
+		return names
+	}
+	return sensitiveRuleSetting.DefaultRuleSets
+}
+
+// ValidateSensitiveRuleSets 校验规则集配置（JSON 数组）
+func ValidateSensitiveRuleSets(value string) error {
+	var ruleSets []SensitiveRuleSet
+	if err := common.UnmarshalJsonStr(value, &ruleSets); err != nil {
+		return fmt.Errorf("invalid rule sets: %w", err)
+	}
+	names := make(map[string]struct{}, len(ruleSets))
+	for _, ruleSet := range ruleSets {
+		name := strings.TrimSpace(ruleSet.Name)
+		if name == "" {
+			return fmt.Errorf("rule set name is required")
+		}
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("duplicate rule set name: %s", name)
+		}
+		names[name] = struct{}{}
+		switch ruleSet.Action {
+		case SensitiveActionBlock, SensitiveActionMask, SensitiveActionLog:
+		default:
+			return fmt.Errorf("rule set %s: unknown action %q", name, ruleSet.Action)
+		}
+		for _, rule := range ruleSet.Rules {
+			switch rule.Type {
+			case SensitiveRuleTypeLiteral:
+				if strings.TrimSpace(rule.Pattern) == "" {
+					return fmt.Errorf("rule set %s: rule %s has empty pattern", name, rule.Name)
+				}
+			case SensitiveRuleTypeRegex:
+				if _, err := regexp.Compile(rule.Pattern); err != nil {
+					return fmt.Errorf("rule set %s: rule %s: %w", name, rule.Name, err)
+				}
+			case SensitiveRuleTypePII:
+				switch rule.Pattern {
+				case PIITypeEmail, PIITypePhone, PIITypeIdNumber, PIITypeCreditCard:
+				default:
+					return fmt.Errorf("rule set %s: rule %s: unknown pii type %q", name, rule.Name, rule.Pattern)
+				}
+			default:
+				return fmt.Errorf("rule set %s: rule %s: unknown type %q", name, rule.Name, rule.Type)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 工具调用参数校验失败时的处理方式
+const (
+	ToolCallInvalidActionRetry = "retry" // 换渠道重试，已向客户端输出内容时返回流式错误
+	ToolCallInvalidActionError = "error" // 直接返回结构化错误
+)
+
+// ToolCallValidationSetting 工具调用参数校验相关配置。
+// 启用后仅对 EnabledGroups 中的分组生效，按请求中 tools 的 parameters 校验模型输出的 arguments
+type ToolCallValidationSetting struct {
+	Enabled bool `json:"enabled"`
+	// EnabledGroups 启用校验的分组
+	EnabledGroups []string `json:"enabled_groups"`
+	// Repair 校验前尝试修复常见的格式错误（尾随逗号、未闭合的括号与字符串等）
+	Repair bool `json:"repair"`
+	// OnInvalid 修复后仍不合法时的处理方式：retry / error
+	OnInvalid string `json:"on_invalid"`
+}
+
+// 默认配置
+var toolCallValidationSetting = ToolCallValidationSetting{
+	Enabled:       false,
+	EnabledGroups: []string{},
+	Repair:        true,
+	OnInvalid:     ToolCallInvalidActionRetry,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("tool_call_validation_setting", &toolCallValidationSetting)
+}
+
+func GetToolCallValidationSetting() *ToolCallValidationSetting {
+	return &toolCallValidationSetting
+}
+
+// IsToolCallValidationEnabled 分组是否启用了工具调用参数校验
+func IsToolCallValidationEnabled(group string) bool {
+	return toolCallValidationSetting.Enabled && slices.Contains(toolCallValidationSetting.EnabledGroups, group)
+}
+
+// ShouldRetryInvalidToolCall 校验失败时是否换渠道重试，未知取值按 error 处理
+func ShouldRetryInvalidToolCall() bool {
+	return toolCallValidationSetting.OnInvalid == ToolCallInvalidActionRetry
+}
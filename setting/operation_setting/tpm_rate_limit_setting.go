@@ -0,0 +1,45 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TPMRateLimitSetting 每分钟 token 数（TPM）限流配置，所有限制按用户计算，0 表示不限制。
+// 令牌级 TPM 由令牌自身的 tpm_limit 配置，不受 Enabled 影响。
+type TPMRateLimitSetting struct {
+	Enabled bool `json:"enabled"`
+	// DefaultUserTPM 每个用户的默认 TPM 上限
+	DefaultUserTPM int `json:"default_user_tpm"`
+	// GroupUserTPM 按分组覆盖用户 TPM 上限（令牌分组优先，其次用户分组）
+	GroupUserTPM map[string]int `json:"group_user_tpm"`
+	// ModelTPM 每个用户在单个模型上的 TPM 上限
+	ModelTPM map[string]int `json:"model_tpm"`
+}
+
+// 默认配置
+var tpmRateLimitSetting = TPMRateLimitSetting{
+	Enabled:        false,
+	DefaultUserTPM: 0,
+	GroupUserTPM:   map[string]int{},
+	ModelTPM:       map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("tpm_rate_limit_setting", &tpmRateLimitSetting)
+}
+
+func GetTPMRateLimitSetting() *TPMRateLimitSetting {
+	return &tpmRateLimitSetting
+}
+
+// GetUserTPMLimit 返回分组对应的用户 TPM 上限
+func GetUserTPMLimit(group string) int {
+	if limit, ok := tpmRateLimitSetting.GroupUserTPM[group]; ok {
+		return limit
+	}
+	return tpmRateLimitSetting.DefaultUserTPM
+}
+
+// GetModelTPMLimit 返回单个用户在该模型上的 TPM 上限
+func GetModelTPMLimit(modelName string) int {
+	return tpmRateLimitSetting.ModelTPM[modelName]
+}
@@ -0,0 +1,46 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type LDAPSettings struct {
+	Enabled bool `json:"enabled"`
+	// ldap://host:389 或 ldaps://host:636
+	Url                string `json:"url"`
+	StartTLS           bool   `json:"start_tls"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	// 用于搜索用户的服务账号，留空则匿名搜索
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+	BaseDN       string `json:"base_dn"`
+	// {username} 会被替换为转义后的登录名，AD 通常为 (sAMAccountName={username})
+	UserFilter           string `json:"user_filter"`
+	UsernameAttribute    string `json:"username_attribute"`
+	EmailAttribute       string `json:"email_attribute"`
+	DisplayNameAttribute string `json:"display_name_attribute"`
+	GroupAttribute       string `json:"group_attribute"`
+	// 目录组 DN -> 本系统分组，按用户所属组的顺序取第一个命中的映射
+	GroupMapping  map[string]string `json:"group_mapping"`
+	AutoProvision bool              `json:"auto_provision"`
+	Timeout       int               `json:"timeout"`
+}
+
+// 默认配置
+var defaultLDAPSettings = LDAPSettings{
+	UserFilter:           "(uid={username})",
+	UsernameAttribute:    "uid",
+	EmailAttribute:       "mail",
+	DisplayNameAttribute: "cn",
+	GroupAttribute:       "memberOf",
+	GroupMapping:         map[string]string{},
+	AutoProvision:        true,
+	Timeout:              10,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("ldap", &defaultLDAPSettings)
+}
+
+func GetLDAPSettings() *LDAPSettings {
+	return &defaultLDAPSettings
+}
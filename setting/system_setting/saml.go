@@ -0,0 +1,45 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type SAMLSettings struct {
+	Enabled bool `json:"enabled"`
+	// 留空时使用 {ServerAddress}/api/saml/metadata
+	SPEntityId string `json:"sp_entity_id"`
+	// PEM 格式，配置后对 AuthnRequest 签名
+	SPCertificate string `json:"sp_certificate"`
+	SPPrivateKey  string `json:"sp_private_key"`
+	IdPEntityId   string `json:"idp_entity_id"`
+	IdPSSOUrl     string `json:"idp_sso_url"`
+	// 可填多张 PEM 证书以便 IdP 轮换，也接受元数据中的裸 base64 证书
+	IdPCertificate string `json:"idp_certificate"`
+	NameIDFormat   string `json:"name_id_format"`
+	// 断言属性名，可以是 Name 或 FriendlyName
+	UsernameAttribute    string `json:"username_attribute"`
+	EmailAttribute       string `json:"email_attribute"`
+	DisplayNameAttribute string `json:"display_name_attribute"`
+	GroupAttribute       string `json:"group_attribute"`
+	// IdP 组名 -> 本系统分组（不区分大小写），按断言中组的顺序取第一个命中的映射
+	GroupMapping map[string]string `json:"group_mapping"`
+	// 允许的时钟偏差（秒）
+	AllowedClockSkew int `json:"allowed_clock_skew"`
+}
+
+// 默认配置
+var defaultSAMLSettings = SAMLSettings{
+	UsernameAttribute:    "uid",
+	EmailAttribute:       "email",
+	DisplayNameAttribute: "displayName",
+	GroupAttribute:       "groups",
+	GroupMapping:         map[string]string{},
+	AllowedClockSkew:     120,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("saml", &defaultSAMLSettings)
+}
+
+func GetSAMLSettings() *SAMLSettings {
+	return &defaultSAMLSettings
+}
@@ -40,6 +40,9 @@ type ErrorCode string
 const (
 	ErrorCodeInvalidRequest         ErrorCode = "invalid_request"
 	ErrorCodeSensitiveWordsDetected ErrorCode = "sensitive_words_detected"
+	ErrorCodeRateLimitExceeded      ErrorCode = "rate_limit_exceeded"
+	ErrorCodeModerationBlocked      ErrorCode = "moderation_blocked"
+	ErrorCodeModerationFailed       ErrorCode = "moderation_failed"
 	ErrorCodeViolationFeeGrokCSAM   ErrorCode = "violation_fee.grok.csam"
 
 	// new api error
@@ -64,6 +67,13 @@ const (
 	ErrorCodeReadRequestBodyFailed ErrorCode = "read_request_body_failed"
 	ErrorCodeConvertRequestFailed  ErrorCode = "convert_request_failed"
 	ErrorCodeAccessDenied          ErrorCode = "access_denied"
+	ErrorCodeFileNotFound          ErrorCode = "file_not_found"
+	ErrorCodeFileQuotaExceeded     ErrorCode = "file_quota_exceeded"
+	ErrorCodeBatchNotFound         ErrorCode = "batch_not_found"
+	ErrorCodeFineTuningJobNotFound ErrorCode = "fine_tuning_job_not_found"
+
+	ErrorCodePreviousResponseNotFound ErrorCode = "previous_response_not_found"
+	ErrorCodeResponseNotFound         ErrorCode = "response_not_found"
 
 	// request error
 	ErrorCodeBadRequestBody ErrorCode = "bad_request_body"
@@ -77,6 +87,7 @@ const (
 	ErrorCodeAwsInvokeError         ErrorCode = "aws_invoke_error"
 	ErrorCodeModelNotFound          ErrorCode = "model_not_found"
 	ErrorCodePromptBlocked          ErrorCode = "prompt_blocked"
+	ErrorCodeInvalidToolCall        ErrorCode = "invalid_tool_call"
 
 	// sql error
 	ErrorCodeQueryDataError  ErrorCode = "query_data_error"
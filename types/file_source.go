@@ -116,6 +116,23 @@ func (b *Base64Source) ClearRawData() {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// FileIDSource — Files API 上传文件（file_id）来源的 FileSource 实现
+// ---------------------------------------------------------------------------
+
+type FileIDSource struct {
+	baseFileSource
+	FileID string
+}
+
+func (f *FileIDSource) IsURL() bool { return false }
+
+func (f *FileIDSource) GetIdentifier() string { return "file_id:" + f.FileID }
+
+func (f *FileIDSource) GetRawData() string { return f.FileID }
+
+func (f *FileIDSource) ClearRawData() {}
+
 // ---------------------------------------------------------------------------
 // Constructors
 // ---------------------------------------------------------------------------
@@ -131,6 +148,10 @@ func NewBase64FileSource(base64Data string, mimeType string) *Base64Source {
 	}
 }
 
+func NewFileIDSource(fileID string) *FileIDSource {
+	return &FileIDSource{FileID: fileID}
+}
+
 func NewFileSourceFromData(data string, mimeType string) FileSource {
 	if strings.HasPrefix(data, "http://") || strings.HasPrefix(data, "https://") {
 		return NewURLFileSource(data)
@@ -330,6 +330,16 @@ const AUDIT_TEMPLATES: Record<string, string> = {
   'option.payment_compliance': 'Confirmed payment compliance',
   'option.reset_ratio': 'Reset model ratios',
   'option.clear_affinity_cache': 'Cleared channel affinity cache',
+  // Authorization roles
+  'authz.role_create': 'Created authorization role {{key}}',
+  'authz.role_update': 'Updated authorization role {{key}}',
+  'authz.role_delete': 'Deleted authorization role {{key}}',
+  'authz.user_roles_update':
+    'Updated authorization roles of user {{username}} (ID: {{id}})',
+  // Organizations
+  'organization.quota_adjust':
+    'Adjusted quota of organization {{name}} (ID: {{id}}) by {{quota}}',
+  'organization.delete': 'Deleted organization {{name}} (ID: {{id}})',
   // Custom OAuth
   'custom_oauth.create': 'Created a custom OAuth provider',
   'custom_oauth.update': 'Updated a custom OAuth provider',